@@ -0,0 +1,82 @@
+// Copyright 2019 The go-gclchaineum Authors
+// This file is part of go-gclchaineum.
+//
+// go-gclchaineum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-gclchaineum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-gclchaineum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/gclchaineum/go-gclchaineum/core/asm"
+	cli "gopkg.in/urfave/cli.v1"
+)
+
+var AnalyzeFormatFlag = cli.StringFlag{
+	Name:  "format",
+	Usage: "output format of the analysis (json or dot)",
+	Value: "json",
+}
+
+var analyzeCommand = cli.Command{
+	Action:    analyzeCmd,
+	Name:      "analyze",
+	Usage:     "statically analyzes evm bytecode",
+	ArgsUsage: "<code or file>",
+	Flags:     []cli.Flag{AnalyzeFormatFlag},
+	Description: `
+Builds the control flow graph of the given hex encoded bytecode, reporting the
+unreachable code, valid jump destinations, dispatched function selectors, uses
+of SELFDESTRUCT, DELEGATECALL and CALLCODE and an upper bound of the stack depth.
+The code is read from the file if the argument names one.`,
+}
+
+func analyzeCmd(ctx *cli.Context) error {
+	if len(ctx.Args().First()) == 0 {
+		return errors.New("code or filename required")
+	}
+	input := ctx.Args().First()
+	if _, err := os.Stat(input); err == nil {
+		blob, err := ioutil.ReadFile(input)
+		if err != nil {
+			return err
+		}
+		input = string(blob)
+	}
+	code, err := hex.DecodeString(strings.TrimPrefix(strings.TrimSpace(input), "0x"))
+	if err != nil {
+		return fmt.Errorf("invalid hex code: %v", err)
+	}
+	analysis := asm.Analyze(code)
+
+	switch ctx.String(AnalyzeFormatFlag.Name) {
+	case "json":
+		out, err := json.MarshalIndent(analysis, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(out))
+		return nil
+	case "dot":
+		return analysis.WriteDot(os.Stdout)
+	default:
+		return fmt.Errorf("unknown output format %q", ctx.String(AnalyzeFormatFlag.Name))
+	}
+}
@@ -0,0 +1,117 @@
+// Copyright 2019 The go-gclchaineum Authors
+// This file is part of go-gclchaineum.
+//
+// go-gclchaineum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-gclchaineum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-gclchaineum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+
+	"github.com/gclchaineum/go-gclchaineum/core/state"
+	"github.com/gclchaineum/go-gclchaineum/core/vm"
+	"github.com/gclchaineum/go-gclchaineum/log"
+	"github.com/gclchaineum/go-gclchaineum/params"
+	"github.com/gclchaineum/go-gclchaineum/tests"
+
+	cli "gopkg.in/urfave/cli.v1"
+)
+
+var blockTestCommand = cli.Command{
+	Action:    blockTestCmd,
+	Name:      "blocktest",
+	Usage:     "executes the given blockchain tests",
+	ArgsUsage: "<file>",
+	Description: `
+Runs the blockchain tests of the given fixture file, reporting the outcome of
+each test as JSON. If a genesis file is given with --prestate, its chain config
+is used instead of the fork rules the tests were filled for.`,
+}
+
+// BlocktestResult contains the execution status after running a blockchain test,
+// any error that might have occurred and a dump of the final state if requested.
+type BlocktestResult struct {
+	Name  string      `json:"name"`
+	Pass  bool        `json:"pass"`
+	Fork  string      `json:"fork"`
+	Error string      `json:"error,omitempty"`
+	State *state.Dump `json:"state,omitempty"`
+}
+
+func blockTestCmd(ctx *cli.Context) error {
+	if len(ctx.Args().First()) == 0 {
+		return errors.New("path-to-test argument required")
+	}
+	// Configure the go-gclchaineum logger
+	glogger := log.NewGlogHandler(log.StreamHandler(os.Stderr, log.TerminalFormat(false)))
+	glogger.Verbosity(log.Lvl(ctx.GlobalInt(VerbosityFlag.Name)))
+	log.Root().SetHandler(glogger)
+
+	// Use the custom chain config instead of the fork rules, if requested
+	var config *params.ChainConfig
+	if ctx.GlobalString(GenesisFlag.Name) != "" {
+		config = readGenesis(ctx.GlobalString(GenesisFlag.Name)).Config
+		if config == nil {
+			return errors.New("genesis file has no chain configuration")
+		}
+		if err := vm.ValidatePrecompiles(config); err != nil {
+			return err
+		}
+		if err := vm.ValidateOpcodeRestrictions(config); err != nil {
+			return err
+		}
+	}
+	// Load the test content from the input file
+	src, err := ioutil.ReadFile(ctx.Args().First())
+	if err != nil {
+		return err
+	}
+	var tests map[string]tests.BlockTest
+	if err = json.Unmarshal(src, &tests); err != nil {
+		return err
+	}
+	names := make([]string, 0, len(tests))
+	for name := range tests {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	// Iterate over all the tests, run them and aggregate the results
+	results := make([]BlocktestResult, 0, len(tests))
+	for _, name := range names {
+		test := tests[name]
+
+		result := &BlocktestResult{Name: name, Fork: test.Network(), Pass: true}
+		if config != nil {
+			result.Fork = "custom"
+		}
+		state, err := test.RunWith(config, vm.Config{})
+		if err != nil {
+			result.Pass, result.Error = false, err.Error()
+		}
+		if ctx.GlobalBool(DumpFlag.Name) && state != nil {
+			dump := state.RawDump()
+			result.State = &dump
+		}
+		results = append(results, *result)
+	}
+	out, _ := json.MarshalIndent(results, "", "  ")
+	fmt.Println(string(out))
+	return nil
+}
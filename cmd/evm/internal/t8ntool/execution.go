@@ -0,0 +1,217 @@
+// Copyright 2019 The go-gclchaineum Authors
+// This file is part of go-gclchaineum.
+//
+// go-gclchaineum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-gclchaineum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-gclchaineum. If not, see <http://www.gnu.org/licenses/>.
+
+package t8ntool
+
+import (
+	"math/big"
+
+	"github.com/gclchaineum/go-gclchaineum/common"
+	"github.com/gclchaineum/go-gclchaineum/common/math"
+	"github.com/gclchaineum/go-gclchaineum/consensus"
+	"github.com/gclchaineum/go-gclchaineum/consensus/misc"
+	"github.com/gclchaineum/go-gclchaineum/core"
+	"github.com/gclchaineum/go-gclchaineum/core/state"
+	"github.com/gclchaineum/go-gclchaineum/core/types"
+	"github.com/gclchaineum/go-gclchaineum/core/vm"
+	"github.com/gclchaineum/go-gclchaineum/crypto"
+	"github.com/gclchaineum/go-gclchaineum/gcldb"
+	"github.com/gclchaineum/go-gclchaineum/log"
+	"github.com/gclchaineum/go-gclchaineum/params"
+	"github.com/gclchaineum/go-gclchaineum/rlp"
+	"github.com/gclchaineum/go-gclchaineum/tests"
+)
+
+// Prestate is the state and block environment the transactions are applied to.
+type Prestate struct {
+	Env stEnv             `json:"env"`
+	Pre core.GenesisAlloc `json:"pre"`
+}
+
+// ExecutionResult contains the roots and receipts of the block built from the
+// transactions, along with the indexes of those rejected.
+type ExecutionResult struct {
+	StateRoot   common.Hash    `json:"stateRoot"`
+	TxRoot      common.Hash    `json:"txRoot"`
+	ReceiptRoot common.Hash    `json:"receiptRoot"`
+	LogsHash    common.Hash    `json:"logsHash"`
+	Bloom       types.Bloom    `json:"logsBloom"`
+	Receipts    types.Receipts `json:"receipts"`
+	Rejected    []int          `json:"rejected,omitempty"`
+}
+
+// ommer is an uncle of the block, identified by its distance from the block
+// and the beneficiary of its reward.
+type ommer struct {
+	Delta   uint64         `json:"delta"`
+	Address common.Address `json:"address"`
+}
+
+//go:generate gencodec -type stEnv -field-override stEnvMarshaling -out gen_stenv.go
+
+type stEnv struct {
+	Coinbase    common.Address                      `json:"currentCoinbase"   gencodec:"required"`
+	Difficulty  *big.Int                            `json:"currentDifficulty" gencodec:"required"`
+	GasLimit    uint64                              `json:"currentGasLimit"   gencodec:"required"`
+	Number      uint64                              `json:"currentNumber"     gencodec:"required"`
+	Timestamp   uint64                              `json:"currentTimestamp"  gencodec:"required"`
+	BlockHashes map[math.HexOrDecimal64]common.Hash `json:"blockHashes,omitempty"`
+	Ommers      []ommer                             `json:"ommers,omitempty"`
+}
+
+type stEnvMarshaling struct {
+	Coinbase   common.UnprefixedAddress
+	Difficulty *math.HexOrDecimal256
+	GasLimit   math.HexOrDecimal64
+	Number     math.HexOrDecimal64
+	Timestamp  math.HexOrDecimal64
+}
+
+// chainContext implements core.ChainContext, serving the ancestors of the block
+// being built from the hashes supplied in the environment. Only the fields
+// needed by the BLOCKHASH opcode are filled in.
+type chainContext struct {
+	hashes map[math.HexOrDecimal64]common.Hash
+}
+
+// Engine implements core.ChainContext. No consensus engine is needed, as the
+// beneficiary of the block is always given explicitly.
+func (c *chainContext) Engine() consensus.Engine {
+	return nil
+}
+
+// GetHeader implements core.ChainContext, returning a stub header linking the
+// requested block to its parent if both hashes are known.
+func (c *chainContext) GetHeader(hash common.Hash, number uint64) *types.Header {
+	if number == 0 {
+		return nil
+	}
+	if known, ok := c.hashes[math.HexOrDecimal64(number)]; !ok || known != hash {
+		return nil
+	}
+	parent, ok := c.hashes[math.HexOrDecimal64(number-1)]
+	if !ok {
+		return nil
+	}
+	return &types.Header{Number: new(big.Int).SetUint64(number), ParentHash: parent}
+}
+
+// header assembles the header of the block being built from the environment.
+func (pre *Prestate) header() *types.Header {
+	header := &types.Header{
+		Coinbase:   pre.Env.Coinbase,
+		Difficulty: pre.Env.Difficulty,
+		GasLimit:   pre.Env.GasLimit,
+		Number:     new(big.Int).SetUint64(pre.Env.Number),
+		Time:       new(big.Int).SetUint64(pre.Env.Timestamp),
+	}
+	if pre.Env.Number > 0 {
+		header.ParentHash = pre.Env.BlockHashes[math.HexOrDecimal64(pre.Env.Number-1)]
+	}
+	return header
+}
+
+// Apply applies a set of transactions to the pre-state, rejecting those which
+// are invalid, and credits the mining reward unless it is negative. Besides the
+// post-state and the execution result, the transactions which made it into the
+// block are returned.
+func (pre *Prestate) Apply(vmConfig vm.Config, chainConfig *params.ChainConfig, txs types.Transactions, miningReward int64) (*state.StateDB, types.Transactions, *ExecutionResult, error) {
+	var (
+		statedb  = tests.MakePreState(gcldb.NewMemDatabase(), pre.Pre)
+		header   = pre.header()
+		chain    = &chainContext{hashes: pre.Env.BlockHashes}
+		gaspool  = new(core.GasPool).AddGas(pre.Env.GasLimit)
+		gasUsed  uint64
+		included types.Transactions
+		receipts = make(types.Receipts, 0)
+		logs     = make([]*types.Log, 0)
+		rejected []int
+	)
+	// If DAO is supported/enabled, we need to handle it here. Normally it's done
+	// in StateProcessor.Process(block, ...), right before transactions are applied.
+	if chainConfig.DAOForkSupport && chainConfig.DAOForkBlock != nil && chainConfig.DAOForkBlock.Cmp(header.Number) == 0 {
+		misc.ApplyDAOHardFork(statedb)
+	}
+	for i, tx := range txs {
+		statedb.Prepare(tx.Hash(), common.Hash{}, len(included))
+
+		// Rejected transactions may have already bought gas, undo it all
+		var (
+			snapshot = statedb.Snapshot()
+			gas      = *gaspool
+		)
+		receipt, _, err := core.ApplyTransaction(chainConfig, chain, &pre.Env.Coinbase, gaspool, statedb, header, tx, &gasUsed, vmConfig)
+		if err != nil {
+			statedb.RevertToSnapshot(snapshot)
+			*gaspool = gas
+			log.Info("Rejected transaction", "index", i, "hash", tx.Hash(), "err", err)
+			rejected = append(rejected, i)
+			continue
+		}
+		included = append(included, tx)
+		receipts = append(receipts, receipt)
+		logs = append(logs, receipt.Logs...)
+	}
+	statedb.IntermediateRoot(chainConfig.IsEIP158(header.Number))
+
+	if miningReward >= 0 {
+		// Add the mining reward even if zero, as that still touches the coinbase,
+		// which makes a difference if it suicided or there were no valid txs.
+		var (
+			blockReward = big.NewInt(miningReward)
+			minerReward = new(big.Int).Set(blockReward)
+			perOmmer    = new(big.Int).Div(blockReward, big.NewInt(32))
+		)
+		for _, ommer := range pre.Env.Ommers {
+			// Add 1/32th for each ommer included, and (8-delta)/8 to the ommer
+			minerReward.Add(minerReward, perOmmer)
+
+			reward := big.NewInt(8)
+			reward.Sub(reward, new(big.Int).SetUint64(ommer.Delta))
+			reward.Mul(reward, blockReward)
+			reward.Div(reward, big.NewInt(8))
+			statedb.AddBalance(ommer.Address, reward)
+		}
+		statedb.AddBalance(pre.Env.Coinbase, minerReward)
+	}
+	root, err := statedb.Commit(chainConfig.IsEIP158(header.Number))
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	logsHash, err := rlpHash(logs)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	result := &ExecutionResult{
+		StateRoot:   root,
+		TxRoot:      types.DeriveSha(included),
+		ReceiptRoot: types.DeriveSha(receipts),
+		LogsHash:    logsHash,
+		Bloom:       types.CreateBloom(receipts),
+		Receipts:    receipts,
+		Rejected:    rejected,
+	}
+	return statedb, included, result, nil
+}
+
+// rlpHash returns the Keccak256 hash of the RLP encoding of x.
+func rlpHash(x interface{}) (common.Hash, error) {
+	enc, err := rlp.EncodeToBytes(x)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	return crypto.Keccak256Hash(enc), nil
+}
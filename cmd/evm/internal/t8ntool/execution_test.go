@@ -0,0 +1,74 @@
+// Copyright 2019 The go-gclchaineum Authors
+// This file is part of go-gclchaineum.
+//
+// go-gclchaineum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-gclchaineum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-gclchaineum. If not, see <http://www.gnu.org/licenses/>.
+
+package t8ntool
+
+import (
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/gclchaineum/go-gclchaineum/common"
+	"github.com/gclchaineum/go-gclchaineum/core"
+	"github.com/gclchaineum/go-gclchaineum/core/types"
+	"github.com/gclchaineum/go-gclchaineum/core/vm"
+	"github.com/gclchaineum/go-gclchaineum/crypto"
+	"github.com/gclchaineum/go-gclchaineum/tests"
+)
+
+// Tests that rejected transactions are left out of the block, and that the
+// returned transactions match the reported transaction root.
+func TestApplyRejected(t *testing.T) {
+	var (
+		key, _ = crypto.GenerateKey()
+		sender = crypto.PubkeyToAddress(key.PublicKey)
+		signer = types.HomesteadSigner{}
+	)
+	pre := &Prestate{
+		Env: stEnv{
+			Coinbase:   common.Address{0xc0},
+			Difficulty: big.NewInt(0x20000),
+			GasLimit:   1000000,
+			Number:     1,
+			Timestamp:  1000,
+		},
+		Pre: core.GenesisAlloc{sender: {Balance: big.NewInt(1000000000)}},
+	}
+	var txs types.Transactions
+	for _, nonce := range []uint64{0, 5, 1} {
+		tx, _ := types.SignTx(types.NewTransaction(nonce, common.Address{0x01}, big.NewInt(1), 21000, big.NewInt(1), nil), signer, key)
+		txs = append(txs, tx)
+	}
+	statedb, included, result, err := pre.Apply(vm.Config{}, tests.Forks["Byzantium"], txs, 0)
+	if err != nil {
+		t.Fatalf("failed to apply transactions: %v", err)
+	}
+	if want := (types.Transactions{txs[0], txs[2]}); !reflect.DeepEqual(included, want) {
+		t.Errorf("included transactions mismatch: have %d, want %d", len(included), len(want))
+	}
+	if want := []int{1}; !reflect.DeepEqual(result.Rejected, want) {
+		t.Errorf("rejected transactions mismatch: have %v, want %v", result.Rejected, want)
+	}
+	if root := types.DeriveSha(included); result.TxRoot != root {
+		t.Errorf("tx root mismatch: have %x, want %x", result.TxRoot, root)
+	}
+	if len(result.Receipts) != 2 {
+		t.Errorf("receipt count mismatch: have %d, want %d", len(result.Receipts), 2)
+	}
+	if nonce := statedb.GetNonce(sender); nonce != 2 {
+		t.Errorf("sender nonce mismatch: have %d, want %d", nonce, 2)
+	}
+}
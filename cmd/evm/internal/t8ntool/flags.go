@@ -0,0 +1,94 @@
+// Copyright 2019 The go-gclchaineum Authors
+// This file is part of go-gclchaineum.
+//
+// go-gclchaineum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-gclchaineum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-gclchaineum. If not, see <http://www.gnu.org/licenses/>.
+
+package t8ntool
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/gclchaineum/go-gclchaineum/tests"
+	"gopkg.in/urfave/cli.v1"
+)
+
+var (
+	OutputAllocFlag = cli.StringFlag{
+		Name: "output.alloc",
+		Usage: "Determines where to put the `alloc` of the post-state.\n" +
+			"\t<file> - into the file <file> \n" +
+			"\t`stdout` - into the stdout output\n" +
+			"\t`stderr` - into the stderr output",
+		Value: "alloc.json",
+	}
+	OutputResultFlag = cli.StringFlag{
+		Name: "output.result",
+		Usage: "Determines where to put the `result` (stateroot, txroot etc) of the post-state.\n" +
+			"\t<file> - into the file <file> \n" +
+			"\t`stdout` - into the stdout output\n" +
+			"\t`stderr` - into the stderr output",
+		Value: "result.json",
+	}
+	OutputBodyFlag = cli.StringFlag{
+		Name: "output.body",
+		Usage: "If set, the RLP of the transactions (block body) will be written to this file.\n" +
+			"\t<file> - into the file <file> \n" +
+			"\t`stdout` - into the stdout output\n" +
+			"\t`stderr` - into the stderr output",
+	}
+	InputAllocFlag = cli.StringFlag{
+		Name:  "input.alloc",
+		Usage: "`stdin` or file name of where to find the prestate alloc to use.",
+		Value: "alloc.json",
+	}
+	InputEnvFlag = cli.StringFlag{
+		Name:  "input.env",
+		Usage: "`stdin` or file name of where to find the prestate env to use.",
+		Value: "env.json",
+	}
+	InputTxsFlag = cli.StringFlag{
+		Name:  "input.txs",
+		Usage: "`stdin` or file name of where to find the transactions to apply.",
+		Value: "txs.json",
+	}
+	RewardFlag = cli.Int64Flag{
+		Name:  "state.reward",
+		Usage: "Mining reward. Set to -1 to disable",
+		Value: 0,
+	}
+	ChainIDFlag = cli.Int64Flag{
+		Name:  "state.chainid",
+		Usage: "ChainID to use",
+		Value: 1,
+	}
+	ForkFlag = cli.StringFlag{
+		Name: "state.fork",
+		Usage: fmt.Sprintf("Name of ruleset to use."+
+			"\n\tAvailable forknames:"+
+			"\n\t    %v", strings.Join(forkNames(), "\n\t    ")),
+		Value: "Istanbul",
+	}
+)
+
+// forkNames returns the sorted names of the supported fork rulesets.
+func forkNames() []string {
+	names := make([]string, 0, len(tests.Forks))
+	for name := range tests.Forks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,197 @@
+// Copyright 2019 The go-gclchaineum Authors
+// This file is part of go-gclchaineum.
+//
+// go-gclchaineum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-gclchaineum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-gclchaineum. If not, see <http://www.gnu.org/licenses/>.
+
+// Package t8ntool implements the state transition tool of the evm command,
+// applying transactions to a pre-state and reporting the resulting post-state.
+package t8ntool
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+
+	"github.com/gclchaineum/go-gclchaineum/common"
+	"github.com/gclchaineum/go-gclchaineum/common/hexutil"
+	"github.com/gclchaineum/go-gclchaineum/core"
+	"github.com/gclchaineum/go-gclchaineum/core/state"
+	"github.com/gclchaineum/go-gclchaineum/core/types"
+	"github.com/gclchaineum/go-gclchaineum/core/vm"
+	"github.com/gclchaineum/go-gclchaineum/rlp"
+	"github.com/gclchaineum/go-gclchaineum/tests"
+	"gopkg.in/urfave/cli.v1"
+)
+
+// input is the combined format in which the inputs are read if more than one
+// of them is to be taken from stdin.
+type input struct {
+	Alloc core.GenesisAlloc  `json:"alloc,omitempty"`
+	Env   *stEnv             `json:"env,omitempty"`
+	Txs   types.Transactions `json:"txs,omitempty"`
+}
+
+// Main runs the state transition: it loads the pre-state, environment and
+// transactions, applies the transactions and writes out the requested outputs.
+func Main(ctx *cli.Context) error {
+	var (
+		prestate Prestate
+		txs      types.Transactions
+		stdin    *input
+
+		allocStr = ctx.String(InputAllocFlag.Name)
+		envStr   = ctx.String(InputEnvFlag.Name)
+		txStr    = ctx.String(InputTxsFlag.Name)
+	)
+	// Figure out the prestate alloc, environment and transactions
+	if allocStr == "stdin" || envStr == "stdin" || txStr == "stdin" {
+		stdin = new(input)
+		if err := json.NewDecoder(os.Stdin).Decode(stdin); err != nil {
+			return fmt.Errorf("failed unmarshaling stdin: %v", err)
+		}
+	}
+	if allocStr == "stdin" {
+		prestate.Pre = stdin.Alloc
+	} else if err := readFile(allocStr, "alloc", &prestate.Pre); err != nil {
+		return err
+	}
+	if envStr == "stdin" {
+		if stdin.Env == nil {
+			return fmt.Errorf("missing env in stdin")
+		}
+		prestate.Env = *stdin.Env
+	} else if err := readFile(envStr, "env", &prestate.Env); err != nil {
+		return err
+	}
+	if txStr == "stdin" {
+		txs = stdin.Txs
+	} else if err := readFile(txStr, "txs", &txs); err != nil {
+		return err
+	}
+	// Construct the chain config from the fork rules
+	fork := ctx.String(ForkFlag.Name)
+	config, ok := tests.Forks[fork]
+	if !ok {
+		return tests.UnsupportedForkError{Name: fork}
+	}
+	chainConfig := *config
+	chainConfig.ChainID = big.NewInt(ctx.Int64(ChainIDFlag.Name))
+
+	// Run the transactions and collect the post-state
+	statedb, included, result, err := prestate.Apply(vm.Config{}, &chainConfig, txs, ctx.Int64(RewardFlag.Name))
+	if err != nil {
+		return err
+	}
+	// The body only contains the included transactions, matching the tx root
+	body, err := rlp.EncodeToBytes(included)
+	if err != nil {
+		return err
+	}
+	return dispatchOutput(ctx, dumpAlloc(statedb), result, body)
+}
+
+// readFile unmarshals the JSON content of the named input file into v.
+func readFile(path, desc string, v interface{}) error {
+	blob, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed reading %s file: %v", desc, err)
+	}
+	if err := json.Unmarshal(blob, v); err != nil {
+		return fmt.Errorf("failed unmarshaling %s file: %v", desc, err)
+	}
+	return nil
+}
+
+// dumpAlloc converts the post-state into the same allocation format as the
+// input, so that the output of one transition can be fed into the next.
+func dumpAlloc(statedb *state.StateDB) core.GenesisAlloc {
+	alloc := make(core.GenesisAlloc)
+	for addr, dump := range statedb.RawDump().Accounts {
+		balance, _ := new(big.Int).SetString(dump.Balance, 10)
+		account := core.GenesisAccount{
+			Code:    common.FromHex(dump.Code),
+			Balance: balance,
+			Nonce:   dump.Nonce,
+		}
+		// Storage values are dumped in their RLP encoded form
+		if len(dump.Storage) > 0 {
+			account.Storage = make(map[common.Hash]common.Hash)
+			for key, enc := range dump.Storage {
+				var value []byte
+				if err := rlp.DecodeBytes(common.FromHex(enc), &value); err != nil {
+					continue
+				}
+				account.Storage[common.HexToHash(key)] = common.BytesToHash(value)
+			}
+		}
+		alloc[common.HexToAddress(addr)] = account
+	}
+	return alloc
+}
+
+// dispatchOutput writes the post-state alloc, the execution result and the
+// body of the block to the destinations requested. Outputs sent to stdout or
+// stderr are grouped into a single JSON object.
+func dispatchOutput(ctx *cli.Context, alloc core.GenesisAlloc, result *ExecutionResult, body hexutil.Bytes) error {
+	var (
+		stdout = make(map[string]interface{})
+		stderr = make(map[string]interface{})
+	)
+	dispatch := func(name, dest string, obj interface{}) error {
+		switch dest {
+		case "":
+			return nil
+		case "stdout":
+			stdout[name] = obj
+			return nil
+		case "stderr":
+			stderr[name] = obj
+			return nil
+		}
+		blob, err := json.MarshalIndent(obj, "", " ")
+		if err != nil {
+			return fmt.Errorf("failed marshaling %s output: %v", name, err)
+		}
+		if err := ioutil.WriteFile(dest, blob, 0644); err != nil {
+			return fmt.Errorf("failed writing %s output: %v", name, err)
+		}
+		return nil
+	}
+	if err := dispatch("alloc", ctx.String(OutputAllocFlag.Name), alloc); err != nil {
+		return err
+	}
+	if err := dispatch("result", ctx.String(OutputResultFlag.Name), result); err != nil {
+		return err
+	}
+	if err := dispatch("body", ctx.String(OutputBodyFlag.Name), body); err != nil {
+		return err
+	}
+	if len(stdout) > 0 {
+		blob, err := json.MarshalIndent(stdout, "", " ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(os.Stdout, string(blob))
+	}
+	if len(stderr) > 0 {
+		blob, err := json.MarshalIndent(stderr, "", " ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(os.Stderr, string(blob))
+	}
+	return nil
+}
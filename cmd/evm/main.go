@@ -22,6 +22,7 @@ import (
 	"math/big"
 	"os"
 
+	"github.com/gclchaineum/go-gclchaineum/cmd/evm/internal/t8ntool"
 	"github.com/gclchaineum/go-gclchaineum/cmd/utils"
 	"gopkg.in/urfave/cli.v1"
 )
@@ -110,8 +111,34 @@ var (
 		Name:  "nostack",
 		Usage: "disable stack output",
 	}
+	GasProfileFlag = cli.StringFlag{
+		Name:  "gasprofile",
+		Usage: "write a gas profile of the execution to the given file in folded stack format (flamegraph input)",
+	}
+	SourceMapFlag = cli.StringFlag{
+		Name:  "srcmap",
+		Usage: "solc --combined-json srcmap-runtime,bin-runtime output to annotate traces with Solidity source positions",
+	}
 )
 
+var stateTransitionCommand = cli.Command{
+	Name:    "transition",
+	Aliases: []string{"t8n"},
+	Usage:   "executes a full state transition",
+	Action:  t8ntool.Main,
+	Flags: []cli.Flag{
+		t8ntool.OutputAllocFlag,
+		t8ntool.OutputResultFlag,
+		t8ntool.OutputBodyFlag,
+		t8ntool.InputAllocFlag,
+		t8ntool.InputEnvFlag,
+		t8ntool.InputTxsFlag,
+		t8ntool.ForkFlag,
+		t8ntool.ChainIDFlag,
+		t8ntool.RewardFlag,
+	},
+}
+
 func init() {
 	app.Flags = []cli.Flag{
 		CreateFlag,
@@ -133,12 +160,17 @@ func init() {
 		ReceiverFlag,
 		DisableMemoryFlag,
 		DisableStackFlag,
+		GasProfileFlag,
+		SourceMapFlag,
 	}
 	app.Commands = []cli.Command{
 		compileCommand,
 		disasmCommand,
+		analyzeCommand,
 		runCommand,
 		stateTestCommand,
+		blockTestCommand,
+		stateTransitionCommand,
 	}
 }
 
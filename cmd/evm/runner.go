@@ -23,6 +23,7 @@ import (
 	"io/ioutil"
 	"math/big"
 	"os"
+	"path/filepath"
 	goruntime "runtime"
 	"runtime/pprof"
 	"time"
@@ -30,6 +31,7 @@ import (
 	"github.com/gclchaineum/go-gclchaineum/cmd/evm/internal/compiler"
 	"github.com/gclchaineum/go-gclchaineum/cmd/utils"
 	"github.com/gclchaineum/go-gclchaineum/common"
+	solidity "github.com/gclchaineum/go-gclchaineum/common/compiler"
 	"github.com/gclchaineum/go-gclchaineum/core"
 	"github.com/gclchaineum/go-gclchaineum/core/state"
 	"github.com/gclchaineum/go-gclchaineum/core/vm"
@@ -69,6 +71,39 @@ func readGenesis(genesisPath string) *core.Genesis {
 	return genesis
 }
 
+// readSourceMaps loads the source maps of a solc combined JSON output, along
+// with the sources in its source list. Source names are resolved against the
+// working directory first and the directory of the output second.
+func readSourceMaps(path string) *solidity.SourceMaps {
+	combined, err := ioutil.ReadFile(path)
+	if err != nil {
+		utils.Fatalf("Failed to read source maps: %v", err)
+	}
+	var output struct {
+		SourceList []string `json:"sourceList"`
+	}
+	if err := json.Unmarshal(combined, &output); err != nil {
+		utils.Fatalf("invalid source maps: %v", err)
+	}
+	sources := make(map[string]string)
+	for _, name := range output.SourceList {
+		src, err := ioutil.ReadFile(name)
+		if err != nil && !filepath.IsAbs(name) {
+			src, err = ioutil.ReadFile(filepath.Join(filepath.Dir(path), name))
+		}
+		if err != nil {
+			log.Warn("Failed to read contract source", "name", name, "err", err)
+			continue
+		}
+		sources[name] = string(src)
+	}
+	maps, err := solidity.ParseSourceMaps(combined, sources)
+	if err != nil {
+		utils.Fatalf("invalid source maps: %v", err)
+	}
+	return maps
+}
+
 func runCmd(ctx *cli.Context) error {
 	glogger := log.NewGlogHandler(log.StreamHandler(os.Stderr, log.TerminalFormat(false)))
 	glogger.Verbosity(log.Lvl(ctx.GlobalInt(VerbosityFlag.Name)))
@@ -78,21 +113,35 @@ func runCmd(ctx *cli.Context) error {
 		DisableStack:  ctx.GlobalBool(DisableStackFlag.Name),
 		Debug:         ctx.GlobalBool(DebugFlag.Name),
 	}
+	if ctx.GlobalString(SourceMapFlag.Name) != "" {
+		logconfig.Sources = readSourceMaps(ctx.GlobalString(SourceMapFlag.Name))
+	}
 
 	var (
 		tracer        vm.Tracer
 		debugLogger   *vm.StructLogger
+		profiler      *vm.GasProfiler
 		statedb       *state.StateDB
 		chainConfig   *params.ChainConfig
 		sender        = common.BytesToAddress([]byte("sender"))
 		receiver      = common.BytesToAddress([]byte("receiver"))
 		genesisConfig *core.Genesis
 	)
-	if ctx.GlobalBool(MachineFlag.Name) {
+	if ctx.GlobalString(GasProfileFlag.Name) != "" && (ctx.GlobalBool(MachineFlag.Name) || ctx.GlobalBool(DebugFlag.Name)) {
+		utils.Fatalf("--%s can't be combined with --%s or --%s", GasProfileFlag.Name, MachineFlag.Name, DebugFlag.Name)
+	}
+	if ctx.GlobalString(GasProfileFlag.Name) != "" {
+		profiler = vm.NewGasProfiler()
+		tracer = profiler
+	} else if ctx.GlobalBool(MachineFlag.Name) {
 		tracer = vm.NewJSONLogger(logconfig, os.Stdout)
 	} else if ctx.GlobalBool(DebugFlag.Name) {
 		debugLogger = vm.NewStructLogger(logconfig)
 		tracer = debugLogger
+	} else if logconfig.Sources != nil {
+		// Trace silently to be able to report the location of failures
+		debugLogger = vm.NewStructLogger(logconfig)
+		tracer = debugLogger
 	} else {
 		debugLogger = vm.NewStructLogger(logconfig)
 	}
@@ -103,6 +152,14 @@ func runCmd(ctx *cli.Context) error {
 		genesis := gen.ToBlock(db)
 		statedb, _ = state.New(genesis.Root(), state.NewDatabase(db))
 		chainConfig = gen.Config
+		if chainConfig != nil {
+			if err := vm.ValidatePrecompiles(chainConfig); err != nil {
+				utils.Fatalf("invalid genesis precompiles: %v", err)
+			}
+			if err := vm.ValidateOpcodeRestrictions(chainConfig); err != nil {
+				utils.Fatalf("invalid genesis opcode restrictions: %v", err)
+			}
+		}
 	} else {
 		statedb, _ = state.New(common.Hash{}, state.NewDatabase(gcldb.NewMemDatabase()))
 		genesisConfig = new(core.Genesis)
@@ -172,7 +229,7 @@ func runCmd(ctx *cli.Context) error {
 		BlockNumber: new(big.Int).SetUint64(genesisConfig.Number),
 		EVMConfig: vm.Config{
 			Tracer: tracer,
-			Debug:  ctx.GlobalBool(DebugFlag.Name) || ctx.GlobalBool(MachineFlag.Name),
+			Debug:  tracer != nil,
 		},
 	}
 
@@ -224,6 +281,19 @@ func runCmd(ctx *cli.Context) error {
 		f.Close()
 	}
 
+	if profiler != nil {
+		f, err := os.Create(ctx.GlobalString(GasProfileFlag.Name))
+		if err != nil {
+			fmt.Println("could not create gas profile: ", err)
+			os.Exit(1)
+		}
+		if err := profiler.WriteFolded(f); err != nil {
+			fmt.Println("could not write gas profile: ", err)
+			os.Exit(1)
+		}
+		f.Close()
+	}
+
 	if ctx.GlobalBool(DebugFlag.Name) {
 		if debugLogger != nil {
 			fmt.Fprintln(os.Stderr, "#### TRACE ####")
@@ -245,12 +315,17 @@ Gas used:           %d
 
 `, execTime, mem.HeapObjects, mem.Alloc, mem.TotalAlloc, mem.NumGC, initialGas-leftOverGas)
 	}
-	if tracer == nil {
+	if tracer == nil || profiler != nil || (debugLogger != nil && !ctx.GlobalBool(DebugFlag.Name)) {
 		fmt.Printf("0x%x\n", ret)
 		if err != nil {
 			fmt.Printf(" error: %v\n", err)
 		}
 	}
+	if err != nil && debugLogger != nil && logconfig.Sources != nil {
+		if logs := debugLogger.StructLogs(); len(logs) > 0 && logs[len(logs)-1].Source != nil {
+			fmt.Printf(" location: %v\n", logs[len(logs)-1].Source)
+		}
+	}
 
 	return nil
 }
@@ -29,6 +29,7 @@ import (
 	"github.com/gclchaineum/go-gclchaineum/common"
 	"github.com/gclchaineum/go-gclchaineum/console"
 	"github.com/gclchaineum/go-gclchaineum/core"
+	"github.com/gclchaineum/go-gclchaineum/core/rawdb"
 	"github.com/gclchaineum/go-gclchaineum/core/state"
 	"github.com/gclchaineum/go-gclchaineum/core/types"
 	"github.com/gclchaineum/go-gclchaineum/gcl/downloader"
@@ -153,6 +154,30 @@ The first argument must be the directory containing the blockchain to download f
 		Description: `
 Remove blockchain and state databases`,
 	}
+	dumpIterativeFlag = cli.BoolFlag{
+		Name:  "dump.iterative",
+		Usage: "Stream the state as one JSON object per line instead of a single object",
+	}
+	dumpNoCodeFlag = cli.BoolFlag{
+		Name:  "dump.nocode",
+		Usage: "Exclude contract code from the dump",
+	}
+	dumpNoStorageFlag = cli.BoolFlag{
+		Name:  "dump.nostorage",
+		Usage: "Exclude contract storage from the dump",
+	}
+	dumpStartFlag = cli.StringFlag{
+		Name:  "dump.start",
+		Usage: "Hashed account key to start the dump at (hex)",
+	}
+	dumpLimitFlag = cli.IntFlag{
+		Name:  "dump.limit",
+		Usage: "Maximum number of accounts to dump (0 = unlimited)",
+	}
+	dumpOutputFlag = cli.StringFlag{
+		Name:  "dump.output",
+		Usage: "File to write the dump to (default = stdout)",
+	}
 	dumpCommand = cli.Command{
 		Action:    utils.MigrateFlags(dump),
 		Name:      "dump",
@@ -162,11 +187,21 @@ Remove blockchain and state databases`,
 			utils.DataDirFlag,
 			utils.CacheFlag,
 			utils.SyncModeFlag,
+			dumpIterativeFlag,
+			dumpNoCodeFlag,
+			dumpNoStorageFlag,
+			dumpStartFlag,
+			dumpLimitFlag,
+			dumpOutputFlag,
 		},
 		Category: "BLOCKCHAIN COMMANDS",
 		Description: `
 The arguments are interpreted as block numbers or hashes.
-Use "gclchaineum dump 0" to dump the genesis block.`,
+Use "gclchaineum dump 0" to dump the genesis block.
+
+With --dump.iterative the state is streamed one account per line (JSONL) without
+being held in memory, which is needed for large states. The dump can be limited
+to a range of accounts with --dump.start and --dump.limit.`,
 	}
 )
 
@@ -245,7 +280,7 @@ func importChain(ctx *cli.Context) error {
 	fmt.Printf("Import done in %v.\n\n", time.Since(start))
 
 	// Output pre-compaction stats mostly to see the import trashing
-	db := chainDb.(*gcldb.LDBDatabase)
+	db := rawdb.KeyValueStore(chainDb).(*gcldb.LDBDatabase)
 
 	stats, err := db.LDB().GetProperty("leveldb.stats")
 	if err != nil {
@@ -336,7 +371,7 @@ func importPreimages(ctx *cli.Context) error {
 		utils.Fatalf("This command requires an argument.")
 	}
 	stack := makeFullNode(ctx)
-	diskdb := utils.MakeChainDatabase(ctx, stack).(*gcldb.LDBDatabase)
+	diskdb := rawdb.KeyValueStore(utils.MakeChainDatabase(ctx, stack)).(*gcldb.LDBDatabase)
 
 	start := time.Now()
 	if err := utils.ImportPreimages(diskdb, ctx.Args().First()); err != nil {
@@ -352,7 +387,7 @@ func exportPreimages(ctx *cli.Context) error {
 		utils.Fatalf("This command requires an argument.")
 	}
 	stack := makeFullNode(ctx)
-	diskdb := utils.MakeChainDatabase(ctx, stack).(*gcldb.LDBDatabase)
+	diskdb := rawdb.KeyValueStore(utils.MakeChainDatabase(ctx, stack)).(*gcldb.LDBDatabase)
 
 	start := time.Now()
 	if err := utils.ExportPreimages(diskdb, ctx.Args().First()); err != nil {
@@ -402,7 +437,7 @@ func copyDb(ctx *cli.Context) error {
 	// Compact the entire database to remove any sync overhead
 	start = time.Now()
 	fmt.Println("Compacting entire database...")
-	if err = chainDb.(*gcldb.LDBDatabase).LDB().CompactRange(util.Range{}); err != nil {
+	if err = rawdb.KeyValueStore(chainDb).(*gcldb.LDBDatabase).LDB().CompactRange(util.Range{}); err != nil {
 		utils.Fatalf("Compaction failed: %v", err)
 	}
 	fmt.Printf("Compaction done in %v.\n\n", time.Since(start))
@@ -442,6 +477,23 @@ func removeDB(ctx *cli.Context) error {
 func dump(ctx *cli.Context) error {
 	stack := makeFullNode(ctx)
 	chain, chainDb := utils.MakeChain(ctx, stack)
+	defer chainDb.Close()
+
+	output := os.Stdout
+	if path := ctx.String(dumpOutputFlag.Name); path != "" {
+		file, err := os.Create(path)
+		if err != nil {
+			utils.Fatalf("Failed to create output file: %v", err)
+		}
+		defer file.Close()
+		output = file
+	}
+	conf := &state.DumpConfig{
+		SkipCode:    ctx.Bool(dumpNoCodeFlag.Name),
+		SkipStorage: ctx.Bool(dumpNoStorageFlag.Name),
+		Start:       common.FromHex(ctx.String(dumpStartFlag.Name)),
+		Max:         ctx.Int(dumpLimitFlag.Name),
+	}
 	for _, arg := range ctx.Args() {
 		var block *types.Block
 		if hashish(arg) {
@@ -458,10 +510,23 @@ func dump(ctx *cli.Context) error {
 			if err != nil {
 				utils.Fatalf("could not create new state: %v", err)
 			}
-			fmt.Printf("%s\n", state.Dump())
+			if ctx.Bool(dumpIterativeFlag.Name) {
+				if err := state.IterativeDump(conf, output); err != nil {
+					utils.Fatalf("Failed to dump state: %v", err)
+				}
+				continue
+			}
+			dump, err := state.IteratorDump(conf)
+			if err != nil {
+				utils.Fatalf("Failed to dump state: %v", err)
+			}
+			out, err := json.MarshalIndent(dump, "", "    ")
+			if err != nil {
+				utils.Fatalf("Failed to encode state: %v", err)
+			}
+			fmt.Fprintf(output, "%s\n", out)
 		}
 	}
-	chainDb.Close()
 	return nil
 }
 
@@ -0,0 +1,63 @@
+// Copyright 2019 The go-gclchaineum Authors
+// This file is part of go-gclchaineum.
+//
+// go-gclchaineum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-gclchaineum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-gclchaineum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"github.com/gclchaineum/go-gclchaineum/cmd/utils"
+	"github.com/gclchaineum/go-gclchaineum/core/rawdb"
+	"gopkg.in/urfave/cli.v1"
+)
+
+var (
+	dbCommand = cli.Command{
+		Name:      "db",
+		Usage:     "Low level database operations",
+		ArgsUsage: "",
+		Category:  "DATABASE COMMANDS",
+		Subcommands: []cli.Command{
+			dbInspectCmd,
+		},
+	}
+	dbInspectCmd = cli.Command{
+		Action:    utils.MigrateFlags(inspect),
+		Name:      "inspect",
+		Usage:     "Inspect the storage size for each type of data in the database",
+		ArgsUsage: " ",
+		Flags: []cli.Flag{
+			utils.DataDirFlag,
+			utils.AncientFlag,
+			utils.CacheFlag,
+			utils.SyncModeFlag,
+			utils.TestnetFlag,
+			utils.RinkebyFlag,
+			utils.GoerliFlag,
+		},
+		Description: `This command iterates the entire database and reports the number of
+entries and their total size for every data category (headers, bodies, receipts,
+transaction lookups, bloombits, preimages, trie nodes, etc).`,
+	}
+)
+
+// inspect walks the whole chain database and reports the storage size of each
+// data category.
+func inspect(ctx *cli.Context) error {
+	node, _ := makeConfigNode(ctx)
+	db := utils.MakeChainDatabase(ctx, node)
+	defer db.Close()
+
+	return rawdb.InspectDatabase(db)
+}
@@ -0,0 +1,92 @@
+// Copyright 2019 The go-gclchaineum Authors
+// This file is part of go-gclchaineum.
+//
+// go-gclchaineum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-gclchaineum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-gclchaineum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"time"
+
+	"github.com/gclchaineum/go-gclchaineum/cmd/utils"
+	"github.com/gclchaineum/go-gclchaineum/core/rawdb"
+	"github.com/gclchaineum/go-gclchaineum/core/state/pruner"
+	"github.com/gclchaineum/go-gclchaineum/gcldb"
+	"github.com/gclchaineum/go-gclchaineum/log"
+	"github.com/syndtr/goleveldb/leveldb/util"
+	"gopkg.in/urfave/cli.v1"
+)
+
+var (
+	pruneRetainFlag = cli.Uint64Flag{
+		Name:  "prune.retain",
+		Usage: "Number of recent block states to retain",
+		Value: pruner.DefaultRetain,
+	}
+	pruneBloomSizeFlag = cli.Uint64Flag{
+		Name:  "prune.bloomsize",
+		Usage: "Megabytes of memory allocated to the bloom filter tracking retained state",
+		Value: pruner.DefaultBloomSize,
+	}
+	pruneStateCommand = cli.Command{
+		Action:    utils.MigrateFlags(pruneState),
+		Name:      "prune-state",
+		Usage:     "Delete all state data not reachable from the recent blocks",
+		ArgsUsage: " ",
+		Flags: []cli.Flag{
+			utils.DataDirFlag,
+			utils.AncientFlag,
+			utils.CacheFlag,
+			utils.SyncModeFlag,
+			utils.TestnetFlag,
+			utils.RinkebyFlag,
+			utils.GoerliFlag,
+			pruneRetainFlag,
+			pruneBloomSizeFlag,
+		},
+		Category: "BLOCKCHAIN COMMANDS",
+		Description: `
+The prune-state command keeps the state tries of the most recent blocks (see
+--prune.retain), marks every trie node and contract code reachable from them
+and deletes everything else from the database.
+
+The marked set is persisted before anything is deleted, so an interrupted run
+is resumed the next time either this command or the node itself is started.
+False positives of the bloom filter only keep some stale data around; increase
+--prune.bloomsize to reduce them.`,
+	}
+)
+
+// pruneState runs an offline state pruning on the node's chain database,
+// compacting the database afterwards.
+func pruneState(ctx *cli.Context) error {
+	stack, _ := makeConfigNode(ctx)
+	chaindb := utils.MakeChainDatabase(ctx, stack)
+	defer chaindb.Close()
+
+	start := time.Now()
+	p := pruner.NewPruner(chaindb, stack.ResolvePath(""), ctx.GlobalUint64(pruneBloomSizeFlag.Name), ctx.GlobalUint64(pruneRetainFlag.Name))
+	if err := p.Prune(); err != nil {
+		utils.Fatalf("Failed to prune state: %v", err)
+	}
+	// Compact the database to actually release the freed disk space
+	if db, ok := rawdb.KeyValueStore(chaindb).(*gcldb.LDBDatabase); ok {
+		log.Info("Compacting database")
+		if err := db.LDB().CompactRange(util.Range{}); err != nil {
+			utils.Fatalf("Compaction failed: %v", err)
+		}
+	}
+	log.Info("State pruning completed", "elapsed", time.Since(start))
+	return nil
+}
@@ -36,7 +36,9 @@ import (
 	"github.com/gclchaineum/go-gclchaineum/consensus/clique"
 	"github.com/gclchaineum/go-gclchaineum/consensus/gclash"
 	"github.com/gclchaineum/go-gclchaineum/core"
+	"github.com/gclchaineum/go-gclchaineum/core/rawdb"
 	"github.com/gclchaineum/go-gclchaineum/core/state"
+	"github.com/gclchaineum/go-gclchaineum/core/state/pruner"
 	"github.com/gclchaineum/go-gclchaineum/core/vm"
 	"github.com/gclchaineum/go-gclchaineum/crypto"
 	"github.com/gclchaineum/go-gclchaineum/dashboard"
@@ -119,6 +121,10 @@ var (
 		Usage: "Data directory for the databases and keystore",
 		Value: DirectoryString{node.DefaultDataDir()},
 	}
+	AncientFlag = DirectoryFlag{
+		Name:  "datadir.ancient",
+		Usage: "Data directory for ancient chain segments (default = disabled)",
+	}
 	KeyStoreDirFlag = DirectoryFlag{
 		Name:  "keystore",
 		Usage: "Directory for the keystore (default = inside the datadir)",
@@ -263,6 +269,16 @@ var (
 		Usage: "Time interval to regenerate the local transaction journal",
 		Value: core.DefaultTxPoolConfig.Rejournal,
 	}
+	TxPoolSnapshotFlag = cli.StringFlag{
+		Name:  "txpool.snapshot",
+		Usage: "Disk snapshot of all pending and queued transactions to survive node restarts",
+		Value: core.DefaultTxPoolConfig.Snapshot,
+	}
+	TxPoolResnapshotFlag = cli.DurationFlag{
+		Name:  "txpool.resnapshot",
+		Usage: "Time interval to regenerate the transaction snapshot",
+		Value: core.DefaultTxPoolConfig.Resnapshot,
+	}
 	TxPoolPriceLimitFlag = cli.Uint64Flag{
 		Name:  "txpool.pricelimit",
 		Usage: "Minimum gas price limit to enforce for acceptance into the pool",
@@ -319,6 +335,15 @@ var (
 		Usage: "Percentage of cache memory allowance to use for trie pruning",
 		Value: 25,
 	}
+	CacheSnapshotFlag = cli.IntFlag{
+		Name:  "cache.snapshot",
+		Usage: "Percentage of cache memory allowance to use for snapshot caching (requires --snapshot)",
+		Value: 10,
+	}
+	SnapshotFlag = cli.BoolFlag{
+		Name:  "snapshot",
+		Usage: "Enables the flat state snapshot for fast account and storage reads",
+	}
 	TrieCacheGenFlag = cli.IntFlag{
 		Name:  "trie-cache-gens",
 		Usage: "Number of trie node generations to keep in memory",
@@ -638,6 +663,10 @@ var (
 		Usage: "External EVM configuration (default = built-in interpreter)",
 		Value: "",
 	}
+	ParallelExecutionFlag = cli.BoolFlag{
+		Name:  "vm.parallel",
+		Usage: "Speculatively execute the transactions of imported blocks in parallel",
+	}
 )
 
 // MakeDataDir retrieves the currently requested data directory, terminating
@@ -1049,6 +1078,12 @@ func setTxPool(ctx *cli.Context, cfg *core.TxPoolConfig) {
 	if ctx.GlobalIsSet(TxPoolRejournalFlag.Name) {
 		cfg.Rejournal = ctx.GlobalDuration(TxPoolRejournalFlag.Name)
 	}
+	if ctx.GlobalIsSet(TxPoolSnapshotFlag.Name) {
+		cfg.Snapshot = ctx.GlobalString(TxPoolSnapshotFlag.Name)
+	}
+	if ctx.GlobalIsSet(TxPoolResnapshotFlag.Name) {
+		cfg.Resnapshot = ctx.GlobalDuration(TxPoolResnapshotFlag.Name)
+	}
 	if ctx.GlobalIsSet(TxPoolPriceLimitFlag.Name) {
 		cfg.PriceLimit = ctx.GlobalUint64(TxPoolPriceLimitFlag.Name)
 	}
@@ -1199,6 +1234,9 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *gcl.Config) {
 		cfg.DatabaseCache = ctx.GlobalInt(CacheFlag.Name) * ctx.GlobalInt(CacheDatabaseFlag.Name) / 100
 	}
 	cfg.DatabaseHandles = makeDatabaseHandles()
+	if ctx.GlobalIsSet(AncientFlag.Name) {
+		cfg.DatabaseFreezer = ctx.GlobalString(AncientFlag.Name)
+	}
 
 	if gcmode := ctx.GlobalString(GCModeFlag.Name); gcmode != "full" && gcmode != "archive" {
 		Fatalf("--%s must be either 'full' or 'archive'", GCModeFlag.Name)
@@ -1211,6 +1249,9 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *gcl.Config) {
 	if ctx.GlobalIsSet(CacheFlag.Name) || ctx.GlobalIsSet(CacheGCFlag.Name) {
 		cfg.TrieDirtyCache = ctx.GlobalInt(CacheFlag.Name) * ctx.GlobalInt(CacheGCFlag.Name) / 100
 	}
+	if ctx.GlobalBool(SnapshotFlag.Name) {
+		cfg.SnapshotCache = ctx.GlobalInt(CacheFlag.Name) * ctx.GlobalInt(CacheSnapshotFlag.Name) / 100
+	}
 	if ctx.GlobalIsSet(MinerNotifyFlag.Name) {
 		cfg.MinerNotify = strings.Split(ctx.GlobalString(MinerNotifyFlag.Name), ",")
 	}
@@ -1251,11 +1292,17 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *gcl.Config) {
 
 	if ctx.GlobalIsSet(EWASMInterpreterFlag.Name) {
 		cfg.EWASMInterpreter = ctx.GlobalString(EWASMInterpreterFlag.Name)
+		if cfg.EWASMInterpreter != "" {
+			Fatalf("External ewasm interpreters are not supported, omit --%s to use the built-in one", EWASMInterpreterFlag.Name)
+		}
 	}
 
 	if ctx.GlobalIsSet(EVMInterpreterFlag.Name) {
 		cfg.EVMInterpreter = ctx.GlobalString(EVMInterpreterFlag.Name)
 	}
+	if ctx.GlobalIsSet(ParallelExecutionFlag.Name) {
+		cfg.ParallelExecution = ctx.GlobalBool(ParallelExecutionFlag.Name)
+	}
 
 	// Override any default configs for hard coded networks.
 	switch {
@@ -1421,6 +1468,13 @@ func MakeChainDatabase(ctx *cli.Context, stack *node.Node) gcldb.Database {
 	if err != nil {
 		Fatalf("Could not open database: %v", err)
 	}
+	if ctx.GlobalIsSet(AncientFlag.Name) && name == "chaindata" {
+		if freezer := stack.ResolvePath(ctx.GlobalString(AncientFlag.Name)); freezer != "" {
+			if chainDb, err = rawdb.NewDatabaseWithFreezer(chainDb, freezer, ""); err != nil {
+				Fatalf("Could not open ancient database: %v", err)
+			}
+		}
+	}
 	return chainDb
 }
 
@@ -1443,6 +1497,13 @@ func MakeGenesis(ctx *cli.Context) *core.Genesis {
 func MakeChain(ctx *cli.Context, stack *node.Node) (chain *core.BlockChain, chainDb gcldb.Database) {
 	var err error
 	chainDb = MakeChainDatabase(ctx, stack)
+
+	// Complete any interrupted offline state pruning before touching the state
+	if datadir := stack.ResolvePath(""); datadir != "" {
+		if err := pruner.RecoverPruning(datadir, chainDb); err != nil {
+			Fatalf("Failed to resume state pruning: %v", err)
+		}
+	}
 	config, _, err := core.SetupGenesisBlock(chainDb, MakeGenesis(ctx))
 	if err != nil {
 		Fatalf("%v", err)
@@ -1478,7 +1539,13 @@ func MakeChain(ctx *cli.Context, stack *node.Node) (chain *core.BlockChain, chai
 	if ctx.GlobalIsSet(CacheFlag.Name) || ctx.GlobalIsSet(CacheGCFlag.Name) {
 		cache.TrieDirtyLimit = ctx.GlobalInt(CacheFlag.Name) * ctx.GlobalInt(CacheGCFlag.Name) / 100
 	}
-	vmcfg := vm.Config{EnablePreimageRecording: ctx.GlobalBool(VMEnableDebugFlag.Name)}
+	if ctx.GlobalBool(SnapshotFlag.Name) {
+		cache.SnapshotLimit = ctx.GlobalInt(CacheFlag.Name) * ctx.GlobalInt(CacheSnapshotFlag.Name) / 100
+	}
+	vmcfg := vm.Config{
+		EnablePreimageRecording: ctx.GlobalBool(VMEnableDebugFlag.Name),
+		ParallelExecution:       ctx.GlobalBool(ParallelExecutionFlag.Name),
+	}
 	chain, err = core.NewBlockChain(chainDb, cache, config, engine, vmcfg, nil)
 	if err != nil {
 		Fatalf("Can't create BlockChain: %v", err)
@@ -70,7 +70,8 @@ type solcOutput struct {
 		SrcMapRuntime                               string `json:"srcmap-runtime"`
 		Bin, SrcMap, Abi, Devdoc, Userdoc, Metadata string
 	}
-	Version string
+	SourceList []string `json:"sourceList"`
+	Version    string
 }
 
 func (s *Solidity) makeArgs() []string {
@@ -175,18 +176,25 @@ func ParseCombinedJSON(combinedJSON []byte, source string, languageVersion strin
 	// Compilation succeeded, assemble and return the contracts.
 	contracts := make(map[string]*Contract)
 	for name, info := range output.Contracts {
-		// Parse the individual compilation results.
+		// Parse the individual compilation results, any of which may have been
+		// left out of the requested outputs.
 		var abi interface{}
-		if err := json.Unmarshal([]byte(info.Abi), &abi); err != nil {
-			return nil, fmt.Errorf("solc: error reading abi definition (%v)", err)
+		if info.Abi != "" {
+			if err := json.Unmarshal([]byte(info.Abi), &abi); err != nil {
+				return nil, fmt.Errorf("solc: error reading abi definition (%v)", err)
+			}
 		}
 		var userdoc interface{}
-		if err := json.Unmarshal([]byte(info.Userdoc), &userdoc); err != nil {
-			return nil, fmt.Errorf("solc: error reading user doc: %v", err)
+		if info.Userdoc != "" {
+			if err := json.Unmarshal([]byte(info.Userdoc), &userdoc); err != nil {
+				return nil, fmt.Errorf("solc: error reading user doc: %v", err)
+			}
 		}
 		var devdoc interface{}
-		if err := json.Unmarshal([]byte(info.Devdoc), &devdoc); err != nil {
-			return nil, fmt.Errorf("solc: error reading dev doc: %v", err)
+		if info.Devdoc != "" {
+			if err := json.Unmarshal([]byte(info.Devdoc), &devdoc); err != nil {
+				return nil, fmt.Errorf("solc: error reading dev doc: %v", err)
+			}
 		}
 		contracts[name] = &Contract{
 			Code:        "0x" + info.Bin,
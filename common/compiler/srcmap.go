@@ -0,0 +1,300 @@
+// Copyright 2019 The go-gclchaineum Authors
+// This file is part of the go-gclchaineum library.
+//
+// The go-gclchaineum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-gclchaineum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-gclchaineum library. If not, see <http://www.gnu.org/licenses/>.
+
+package compiler
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gclchaineum/go-gclchaineum/common"
+	"github.com/gclchaineum/go-gclchaineum/crypto"
+)
+
+// SourceLocation is a single entry of a solc source map, describing the byte
+// range of the source an instruction was generated from.
+type SourceLocation struct {
+	Offset int  // Byte offset of the range within the source file
+	Length int  // Byte length of the range
+	File   int  // Index into the compiler's source list, -1 for generated code
+	Jump   byte // Jump kind: 'i' into a function, 'o' out of one, '-' otherwise
+}
+
+// ParseSourceMap decodes a compressed solc source map into one location per
+// instruction. Fields left empty inherit their value from the previous entry.
+func ParseSourceMap(srcmap string) ([]SourceLocation, error) {
+	if srcmap == "" {
+		return nil, nil
+	}
+	var (
+		entries = strings.Split(srcmap, ";")
+		locs    = make([]SourceLocation, len(entries))
+		last    = SourceLocation{File: -1, Jump: '-'}
+	)
+	for i, entry := range entries {
+		// Fields beyond the jump kind (e.g. the modifier depth) are not needed
+		for j, field := range strings.Split(entry, ":") {
+			if field == "" || j > 3 {
+				continue
+			}
+			if j == 3 {
+				if len(field) != 1 || !strings.Contains("io-", field) {
+					return nil, fmt.Errorf("source map entry %d: invalid jump kind %q", i, field)
+				}
+				last.Jump = field[0]
+				continue
+			}
+			n, err := strconv.Atoi(field)
+			if err != nil {
+				return nil, fmt.Errorf("source map entry %d: %v", i, err)
+			}
+			switch j {
+			case 0:
+				last.Offset = n
+			case 1:
+				last.Length = n
+			case 2:
+				last.File = n
+			}
+		}
+		locs[i] = last
+	}
+	return locs, nil
+}
+
+// SourceMaps resolves program counters within the runtime code of compiled
+// contracts to positions in their Solidity sources.
+//
+// Code is matched by its hash against the bin-runtime of the compiled contracts,
+// so deployed code that differs from it is not located. This is the case for
+// contracts with immutable variables, whose values are only inserted into the
+// code by the constructor, and for libraries linked at deployment.
+type SourceMaps struct {
+	files []*sourceFile            // Sources in the order of the compiler's source list
+	codes map[common.Hash]*codeMap // Instruction locations keyed by runtime code hash
+}
+
+// codeMap is the source map of a single contract's runtime code.
+type codeMap struct {
+	instrs []int // Instruction index of each program counter, -1 for push data
+	locs   []SourceLocation
+}
+
+// sourceFile is a Solidity source file, indexed for position lookups.
+type sourceFile struct {
+	name  string
+	lines []int      // Byte offsets of the line starts, nil if the content is unknown
+	funcs []funcSpan // Function definitions, ordered by their start offset
+}
+
+// funcSpan is the byte range of a function definition within a source file.
+type funcSpan struct {
+	name       string
+	start, end int
+}
+
+// ParseSourceMaps assembles the source maps of the contracts in the output of
+// solc --combined-json srcmap-runtime,bin-runtime. The sources are keyed by
+// their name in the compiler's source list; positions in missing sources are
+// reported without line and function. Contracts whose runtime code is not
+// valid hex, e.g. because of unlinked libraries, are skipped.
+func ParseSourceMaps(combinedJSON []byte, sources map[string]string) (*SourceMaps, error) {
+	var output solcOutput
+	if err := json.Unmarshal(combinedJSON, &output); err != nil {
+		return nil, err
+	}
+	maps := &SourceMaps{
+		files: make([]*sourceFile, len(output.SourceList)),
+		codes: make(map[common.Hash]*codeMap),
+	}
+	for i, name := range output.SourceList {
+		maps.files[i] = &sourceFile{name: name}
+		if src, ok := sources[name]; ok {
+			maps.files[i] = parseSource(name, src)
+		}
+	}
+	for name, info := range output.Contracts {
+		code, err := hex.DecodeString(strings.TrimPrefix(info.BinRuntime, "0x"))
+		if err != nil || len(code) == 0 {
+			continue
+		}
+		locs, err := ParseSourceMap(info.SrcMapRuntime)
+		if err != nil {
+			return nil, fmt.Errorf("contract %s: %v", name, err)
+		}
+		instrs := make([]int, len(code))
+		for pc, n := 0, 0; pc < len(code); n++ {
+			size := 1
+			if op := code[pc]; op >= 0x60 && op <= 0x7f {
+				size += int(op - 0x5f)
+			}
+			instrs[pc] = n
+			for i := 1; i < size && pc+i < len(code); i++ {
+				instrs[pc+i] = -1
+			}
+			pc += size
+		}
+		maps.codes[crypto.Keccak256Hash(code)] = &codeMap{instrs: instrs, locs: locs}
+	}
+	return maps, nil
+}
+
+// Locator returns a function resolving program counters within the runtime
+// code with the given hash to source positions, or nil if the code doesn't
+// belong to any of the compiled contracts.
+func (s *SourceMaps) Locator(codeHash common.Hash) func(pc uint64) (file string, line int, function string, ok bool) {
+	m := s.codes[codeHash]
+	if m == nil {
+		return nil
+	}
+	return func(pc uint64) (string, int, string, bool) {
+		return s.locate(m, pc)
+	}
+}
+
+// locate returns the source position of the instruction at the given program
+// counter of the code.
+func (s *SourceMaps) locate(m *codeMap, pc uint64) (file string, line int, function string, ok bool) {
+	if pc >= uint64(len(m.instrs)) {
+		return "", 0, "", false
+	}
+	n := m.instrs[pc]
+	if n < 0 || n >= len(m.locs) {
+		return "", 0, "", false
+	}
+	loc := m.locs[n]
+	if loc.File < 0 || loc.File >= len(s.files) {
+		return "", 0, "", false
+	}
+	src := s.files[loc.File]
+	return src.name, src.line(loc.Offset), src.function(loc.Offset), true
+}
+
+// line returns the 1-based line number of the byte offset, or 0 if unknown.
+func (f *sourceFile) line(offset int) int {
+	return sort.Search(len(f.lines), func(i int) bool { return f.lines[i] > offset })
+}
+
+// function returns the name of the innermost function definition enclosing the
+// byte offset, or an empty string if there is none.
+func (f *sourceFile) function(offset int) string {
+	name := ""
+	for _, span := range f.funcs {
+		if span.start > offset {
+			break
+		}
+		if offset < span.end {
+			name = span.name
+		}
+	}
+	return name
+}
+
+var funcRegexp = regexp.MustCompile(`\b(function|modifier|constructor|fallback|receive)\b\s*([A-Za-z_$][A-Za-z0-9_$]*)?`)
+
+// parseSource indexes the line starts and function definitions of a source.
+func parseSource(name, src string) *sourceFile {
+	file := &sourceFile{name: name, lines: []int{0}}
+	for i := 0; i < len(src); i++ {
+		if src[i] == '\n' {
+			file.lines = append(file.lines, i+1)
+		}
+	}
+	code := stripComments(src)
+	for _, match := range funcRegexp.FindAllStringSubmatchIndex(code, -1) {
+		// Name the definition, falling back to the keyword for special functions
+		name := code[match[2]:match[3]]
+		if match[4] >= 0 && name != "constructor" && name != "fallback" && name != "receive" {
+			name = code[match[4]:match[5]]
+		} else if name == "function" {
+			name = "fallback"
+		}
+		// Find the body following the signature, skipping declarations without one
+		// and function types within parameter lists
+		start, depth := -1, 0
+		for i := match[1]; i < len(code) && start < 0 && depth >= 0; i++ {
+			switch code[i] {
+			case '(':
+				depth++
+			case ')':
+				depth--
+			case ';':
+				if depth == 0 {
+					depth = -1
+				}
+			case '{':
+				if depth == 0 {
+					start = i
+				}
+			}
+		}
+		if start < 0 {
+			continue
+		}
+		end, depth := len(code), 0
+		for i := start; i < len(code); i++ {
+			if code[i] == '{' {
+				depth++
+			} else if code[i] == '}' {
+				if depth--; depth == 0 {
+					end = i + 1
+					break
+				}
+			}
+		}
+		file.funcs = append(file.funcs, funcSpan{name: name, start: match[0], end: end})
+	}
+	return file
+}
+
+// stripComments blanks out the comments and string literal contents of a
+// source, retaining the byte offsets of everything else.
+func stripComments(src string) string {
+	code := []byte(src)
+	for i := 0; i < len(code); i++ {
+		switch {
+		case code[i] == '/' && i+1 < len(code) && code[i+1] == '/':
+			for ; i < len(code) && code[i] != '\n'; i++ {
+				code[i] = ' '
+			}
+		case code[i] == '/' && i+1 < len(code) && code[i+1] == '*':
+			for ; i < len(code) && !(code[i] == '*' && i+1 < len(code) && code[i+1] == '/'); i++ {
+				if code[i] != '\n' {
+					code[i] = ' '
+				}
+			}
+			if i < len(code) {
+				code[i], code[i+1] = ' ', ' '
+				i++
+			}
+		case code[i] == '"' || code[i] == '\'':
+			quote := code[i]
+			for i++; i < len(code) && code[i] != quote && code[i] != '\n'; i++ {
+				if code[i] == '\\' && i+1 < len(code) {
+					code[i] = ' '
+					i++
+				}
+				code[i] = ' '
+			}
+		}
+	}
+	return string(code)
+}
@@ -0,0 +1,111 @@
+// Copyright 2019 The go-gclchaineum Authors
+// This file is part of the go-gclchaineum library.
+//
+// The go-gclchaineum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-gclchaineum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-gclchaineum library. If not, see <http://www.gnu.org/licenses/>.
+
+package compiler
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gclchaineum/go-gclchaineum/crypto"
+)
+
+const srcmapSource = `pragma solidity ^0.5.0;
+
+// function commented() { }
+contract Test {
+    modifier guarded() { _; }
+
+    function fail() public guarded {
+        revert("function failed");
+    }
+}
+`
+
+func TestParseSourceMap(t *testing.T) {
+	locs, err := ParseSourceMap("1:2:0:-;:9;;3:4:1:i;-1::-1:o:1")
+	if err != nil {
+		t.Fatalf("failed to parse source map: %v", err)
+	}
+	want := []SourceLocation{
+		{1, 2, 0, '-'},
+		{1, 9, 0, '-'},
+		{1, 9, 0, '-'},
+		{3, 4, 1, 'i'},
+		{-1, 4, -1, 'o'},
+	}
+	if !reflect.DeepEqual(locs, want) {
+		t.Errorf("source map mismatch:\nhave %v\nwant %v", locs, want)
+	}
+	if _, err := ParseSourceMap("1:2:0:x"); err == nil {
+		t.Errorf("expected error for invalid jump kind")
+	}
+	if _, err := ParseSourceMap("1:a"); err == nil {
+		t.Errorf("expected error for invalid length")
+	}
+}
+
+func TestSourceMapsLocate(t *testing.T) {
+	var (
+		contract = strings.Index(srcmapSource, "contract Test")
+		revert   = strings.Index(srcmapSource, "revert(")
+		guard    = strings.Index(srcmapSource, "_;")
+	)
+	// PUSH1 0x80 PUSH1 0x40 MSTORE JUMPDEST PUSH1 0x00 DUP1 REVERT
+	combined := fmt.Sprintf(`{
+		"contracts": {
+			"test.sol:Test": {
+				"bin-runtime": "60806040525b600080fd",
+				"srcmap-runtime": "%d:100:0:-;;;%d:2;%d:25::i;;:::o"
+			}
+		},
+		"sourceList": ["test.sol", "missing.sol"],
+		"version": "0.5.0"
+	}`, contract, guard, revert)
+
+	maps, err := ParseSourceMaps([]byte(combined), map[string]string{"test.sol": srcmapSource})
+	if err != nil {
+		t.Fatalf("failed to parse source maps: %v", err)
+	}
+	locate := maps.Locator(crypto.Keccak256Hash([]byte{0x60, 0x80, 0x60, 0x40, 0x52, 0x5b, 0x60, 0x00, 0x80, 0xfd}))
+	if locate == nil {
+		t.Fatalf("failed to find code")
+	}
+	tests := []struct {
+		pc       uint64
+		ok       bool
+		line     int
+		function string
+	}{
+		{0, true, 4, ""},
+		{1, false, 0, ""}, // push data
+		{5, true, 5, "guarded"},
+		{6, true, 8, "fail"},
+		{9, true, 8, "fail"},
+		{10, false, 0, ""}, // beyond the code
+	}
+	for _, tt := range tests {
+		file, line, function, ok := locate(tt.pc)
+		if ok != tt.ok || line != tt.line || function != tt.function || (ok && file != "test.sol") {
+			t.Errorf("pc %d: have %s:%d (%q, %v), want test.sol:%d (%q, %v)", tt.pc, file, line, function, ok, tt.line, tt.function, tt.ok)
+		}
+	}
+	if maps.Locator(crypto.Keccak256Hash([]byte{0x00})) != nil {
+		t.Errorf("located unknown code")
+	}
+}
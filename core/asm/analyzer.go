@@ -0,0 +1,354 @@
+// Copyright 2019 The go-gclchaineum Authors
+// This file is part of the go-gclchaineum library.
+//
+// The go-gclchaineum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-gclchaineum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-gclchaineum library. If not, see <http://www.gnu.org/licenses/>.
+
+package asm
+
+import (
+	"fmt"
+	"io"
+	"math/big"
+	"strings"
+
+	"github.com/gclchaineum/go-gclchaineum/common/hexutil"
+	"github.com/gclchaineum/go-gclchaineum/core/vm"
+	"github.com/gclchaineum/go-gclchaineum/params"
+)
+
+// Instruction is a single disassembled EVM instruction.
+type Instruction struct {
+	PC  uint64
+	Op  vm.OpCode
+	Arg []byte
+}
+
+// String implements fmt.Stringer, formatting the instruction the same way as
+// the disassembler does.
+func (in Instruction) String() string {
+	if len(in.Arg) > 0 {
+		return fmt.Sprintf("%05x: %v 0x%x", in.PC, in.Op, in.Arg)
+	}
+	return fmt.Sprintf("%05x: %v", in.PC, in.Op)
+}
+
+// BasicBlock is a straight sequence of instructions of the control flow graph,
+// entered only at its first instruction and left only after its last one.
+type BasicBlock struct {
+	Start      uint64        `json:"start"`      // Position of the first instruction
+	End        uint64        `json:"end"`        // Position after the last instruction
+	Successors []uint64      `json:"successors"` // Start of the blocks execution may continue at
+	Dynamic    bool          `json:"dynamic"`    // Whether the block ends in a jump with a computed target
+	Reachable  bool          `json:"reachable"`  // Whether execution can reach the block from the entry
+	Code       []Instruction `json:"-"`          // Instructions of the block
+}
+
+// CodeRange is a range of the code, the end being exclusive.
+type CodeRange struct {
+	Start uint64 `json:"start"`
+	End   uint64 `json:"end"`
+}
+
+// Selector is a function of the contract found in its dispatcher.
+type Selector struct {
+	Selector hexutil.Bytes `json:"selector"` // 4 byte function selector
+	Entry    uint64        `json:"entry"`    // Position the dispatcher jumps to
+}
+
+// Analysis is the result of the static analysis of EVM bytecode.
+type Analysis struct {
+	Blocks       []*BasicBlock       `json:"blocks"`       // Control flow graph of the code
+	JumpDests    []uint64            `json:"jumpdests"`    // Valid jump destinations
+	DynamicJumps []uint64            `json:"dynamicJumps"` // Jumps whose target is computed at runtime
+	InvalidJumps []uint64            `json:"invalidJumps"` // Jumps to a constant target which isn't a JUMPDEST
+	Unreachable  []CodeRange         `json:"unreachable"`  // Code ranges execution can never reach
+	Selectors    []Selector          `json:"selectors"`    // Functions found in the dispatcher
+	Dangerous    map[string][]uint64 `json:"dangerous"`    // Reachable SELFDESTRUCT, DELEGATECALL and CALLCODE
+	MaxStack     int                 `json:"maxStack"`     // Upper bound of the stack depth
+	Unbounded    bool                `json:"unbounded"`    // Whether the stack may grow up to the limit
+}
+
+// Analyze builds the control flow graph of the code and derives its properties.
+//
+// Jumps are resolved only if their target is pushed right before them, which
+// is what compilers emit for all but function returns. As the target of other
+// jumps is unknown, they are assumed to reach any valid jump destination, which
+// keeps the reachability and stack depth results safe to rely on.
+func Analyze(code []byte) *Analysis {
+	a := &Analysis{
+		JumpDests:    vm.JumpDests(code),
+		DynamicJumps: []uint64{},
+		InvalidJumps: []uint64{},
+		Unreachable:  []CodeRange{},
+		Selectors:    []Selector{},
+		Dangerous:    make(map[string][]uint64),
+	}
+	if a.JumpDests == nil {
+		a.JumpDests = []uint64{}
+	}
+	a.Blocks = splitBlocks(disassemble(code))
+	if len(a.Blocks) == 0 {
+		return a
+	}
+	a.link()
+	a.reach()
+	a.scan()
+	a.bound()
+	return a
+}
+
+// disassemble decodes all the instructions of the code. A PUSH truncated by the
+// end of the code is kept with whatever argument bytes are left.
+func disassemble(code []byte) []Instruction {
+	var instrs []Instruction
+
+	it := NewInstructionIterator(code)
+	for it.Next() {
+		instrs = append(instrs, Instruction{PC: it.PC(), Op: it.Op(), Arg: it.Arg()})
+	}
+	if it.Error() != nil {
+		instrs = append(instrs, Instruction{PC: it.PC(), Op: it.Op(), Arg: code[it.PC()+1:]})
+	}
+	return instrs
+}
+
+// halts returns whether execution never continues after the opcode.
+func halts(op vm.OpCode) bool {
+	switch op {
+	case vm.STOP, vm.RETURN, vm.REVERT, vm.SELFDESTRUCT:
+		return true
+	}
+	_, _, ok := vm.StackEffect(op)
+	return !ok
+}
+
+// splitBlocks splits the instructions into basic blocks, starting a new one at
+// every JUMPDEST and after every jump or halting instruction.
+func splitBlocks(instrs []Instruction) []*BasicBlock {
+	var (
+		blocks []*BasicBlock
+		block  *BasicBlock
+	)
+	for _, in := range instrs {
+		if block == nil || in.Op == vm.JUMPDEST {
+			block = &BasicBlock{Start: in.PC}
+			blocks = append(blocks, block)
+		}
+		block.Code = append(block.Code, in)
+		block.End = in.PC + 1 + uint64(len(in.Arg))
+
+		if in.Op == vm.JUMP || in.Op == vm.JUMPI || halts(in.Op) {
+			block = nil
+		}
+	}
+	return blocks
+}
+
+// link connects the basic blocks along the possible paths of execution.
+func (a *Analysis) link() {
+	dests := make(map[uint64]bool)
+	for _, dest := range a.JumpDests {
+		dests[dest] = true
+	}
+	for i, block := range a.Blocks {
+		block.Successors = []uint64{}
+
+		last := block.Code[len(block.Code)-1]
+		switch {
+		case last.Op == vm.JUMP || last.Op == vm.JUMPI:
+			if n := len(block.Code); n > 1 && block.Code[n-2].Op.IsPush() {
+				target := new(big.Int).SetBytes(block.Code[n-2].Arg)
+				if target.IsUint64() && dests[target.Uint64()] {
+					block.Successors = append(block.Successors, target.Uint64())
+				} else {
+					a.InvalidJumps = append(a.InvalidJumps, last.PC)
+				}
+			} else {
+				block.Dynamic = true
+				block.Successors = append(block.Successors, a.JumpDests...)
+				a.DynamicJumps = append(a.DynamicJumps, last.PC)
+			}
+			if last.Op == vm.JUMPI && i+1 < len(a.Blocks) {
+				block.Successors = append(block.Successors, a.Blocks[i+1].Start)
+			}
+		case !halts(last.Op) && i+1 < len(a.Blocks):
+			block.Successors = append(block.Successors, a.Blocks[i+1].Start)
+		}
+	}
+}
+
+// index maps the start of the basic blocks to their position in the graph.
+func (a *Analysis) index() map[uint64]int {
+	index := make(map[uint64]int, len(a.Blocks))
+	for i, block := range a.Blocks {
+		index[block.Start] = i
+	}
+	return index
+}
+
+// reach marks all the basic blocks reachable from the entry of the code and
+// collects the ranges of those which are not.
+func (a *Analysis) reach() {
+	index := a.index()
+
+	queue := []*BasicBlock{a.Blocks[0]}
+	a.Blocks[0].Reachable = true
+	for len(queue) > 0 {
+		block := queue[0]
+		queue = queue[1:]
+
+		for _, succ := range block.Successors {
+			if next := a.Blocks[index[succ]]; !next.Reachable {
+				next.Reachable = true
+				queue = append(queue, next)
+			}
+		}
+	}
+	for _, block := range a.Blocks {
+		if block.Reachable {
+			continue
+		}
+		if n := len(a.Unreachable); n > 0 && a.Unreachable[n-1].End == block.Start {
+			a.Unreachable[n-1].End = block.End
+		} else {
+			a.Unreachable = append(a.Unreachable, CodeRange{Start: block.Start, End: block.End})
+		}
+	}
+}
+
+// scan looks through the reachable instructions for the function dispatcher
+// and for opcodes which warrant a closer review.
+func (a *Analysis) scan() {
+	var instrs []Instruction
+	for _, block := range a.Blocks {
+		if block.Reachable {
+			instrs = append(instrs, block.Code...)
+		}
+	}
+	seen := make(map[string]bool)
+	for i, in := range instrs {
+		switch in.Op {
+		case vm.SELFDESTRUCT, vm.DELEGATECALL, vm.CALLCODE:
+			a.Dangerous[in.Op.String()] = append(a.Dangerous[in.Op.String()], in.PC)
+
+		case vm.PUSH4:
+			// Dispatchers compare the selector and jump to the function if equal:
+			//   PUSH4 <selector> [DUPn] EQ PUSHn <entry> JUMPI
+			next := i + 1
+			if next < len(instrs) && instrs[next].Op >= vm.DUP1 && instrs[next].Op <= vm.DUP16 {
+				next++
+			}
+			if next+2 >= len(instrs) || instrs[next].Op != vm.EQ || !instrs[next+1].Op.IsPush() || instrs[next+2].Op != vm.JUMPI {
+				continue
+			}
+			entry := new(big.Int).SetBytes(instrs[next+1].Arg)
+			if !entry.IsUint64() || seen[string(in.Arg)] {
+				continue
+			}
+			seen[string(in.Arg)] = true
+			a.Selectors = append(a.Selectors, Selector{Selector: in.Arg, Entry: entry.Uint64()})
+		}
+	}
+}
+
+// bound computes an upper bound of the stack depth over all execution paths,
+// propagating the highest stack depth each basic block may be entered with.
+// Growth in loops is bounded only by the stack limit.
+func (a *Analysis) bound() {
+	var (
+		index  = a.index()
+		limit  = int(params.StackLimit)
+		entry  = make([]int, len(a.Blocks))
+		queued = make([]bool, len(a.Blocks))
+		queue  = []int{0}
+	)
+	queued[0] = true
+	for len(queue) > 0 {
+		i := queue[0]
+		queue, queued[i] = queue[1:], false
+
+		// Run through the block, tracking the highest depth reached
+		depth, peak := entry[i], entry[i]
+		for _, in := range a.Blocks[i].Code {
+			pop, push, _ := vm.StackEffect(in.Op)
+			if depth -= pop; depth < 0 {
+				depth = 0 // Stack underflow, execution aborts
+			}
+			if depth += push; depth > peak {
+				peak = depth
+			}
+		}
+		if peak > limit {
+			peak, a.Unbounded = limit, true
+		}
+		if peak > a.MaxStack {
+			a.MaxStack = peak
+		}
+		if depth > limit {
+			depth = limit
+		}
+		// Propagate the exit depth to the successors if higher than known so far
+		for _, succ := range a.Blocks[i].Successors {
+			if next := index[succ]; depth > entry[next] {
+				entry[next] = depth
+				if !queued[next] {
+					queue, queued[next] = append(queue, next), true
+				}
+			}
+		}
+	}
+}
+
+// WriteDot writes the control flow graph in Graphviz DOT format. Unreachable
+// blocks are drawn dashed, and jumps with a computed target lead to a single
+// node linked to all the valid jump destinations.
+func (a *Analysis) WriteDot(w io.Writer) error {
+	var b strings.Builder
+
+	b.WriteString("digraph evm {\n\tnode [shape=box fontname=monospace];\n")
+	for _, block := range a.Blocks {
+		lines := make([]string, len(block.Code))
+		for i, in := range block.Code {
+			lines[i] = in.String()
+		}
+		style := ""
+		if !block.Reachable {
+			style = " style=dashed"
+		}
+		fmt.Fprintf(&b, "\tb%d [label=\"%s\\l\"%s];\n", block.Start, strings.Join(lines, "\\l"), style)
+	}
+	if len(a.DynamicJumps) > 0 {
+		b.WriteString("\tdynamic [label=\"dynamic jump\" shape=ellipse];\n")
+		for _, dest := range a.JumpDests {
+			fmt.Fprintf(&b, "\tdynamic -> b%d [style=dotted];\n", dest)
+		}
+	}
+	for _, block := range a.Blocks {
+		if block.Dynamic {
+			fmt.Fprintf(&b, "\tb%d -> dynamic [style=dotted];\n", block.Start)
+		}
+		for i, succ := range block.Successors {
+			// Dynamic targets are all listed first, skip them in favour of the
+			// shared node
+			if block.Dynamic && i < len(a.JumpDests) {
+				continue
+			}
+			fmt.Fprintf(&b, "\tb%d -> b%d;\n", block.Start, succ)
+		}
+	}
+	b.WriteString("}\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,95 @@
+// Copyright 2019 The go-gclchaineum Authors
+// This file is part of the go-gclchaineum library.
+//
+// The go-gclchaineum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-gclchaineum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-gclchaineum library. If not, see <http://www.gnu.org/licenses/>.
+
+package asm
+
+import (
+	"bytes"
+	"encoding/hex"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gclchaineum/go-gclchaineum/common/hexutil"
+)
+
+// Tests the analysis of a minimal contract with a function dispatcher.
+func TestAnalyzeDispatcher(t *testing.T) {
+	code, _ := hex.DecodeString("" +
+		"600035" + // PUSH1 0x00 CALLDATALOAD
+		"60e01c" + // PUSH1 0xe0 SHR
+		"80" + // DUP1
+		"63aabbccdd" + // PUSH4 0xaabbccdd
+		"14" + // EQ
+		"601557" + // PUSH1 0x15 JUMPI
+		"600080fd" + // PUSH1 0x00 DUP1 REVERT
+		"30" + // ADDRESS (unreachable)
+		"5b33ff" + // JUMPDEST CALLER SELFDESTRUCT
+		"fe6100") // INVALID, truncated PUSH2
+
+	a := Analyze(code)
+	if len(a.Blocks) != 6 {
+		t.Fatalf("block count mismatch: have %d, want %d", len(a.Blocks), 6)
+	}
+	if want := []uint64{0x15}; !reflect.DeepEqual(a.JumpDests, want) {
+		t.Errorf("jumpdest mismatch: have %v, want %v", a.JumpDests, want)
+	}
+	if want := []CodeRange{{0x14, 0x15}, {0x18, 0x1b}}; !reflect.DeepEqual(a.Unreachable, want) {
+		t.Errorf("unreachable code mismatch: have %v, want %v", a.Unreachable, want)
+	}
+	if want := []Selector{{hexutil.Bytes{0xaa, 0xbb, 0xcc, 0xdd}, 0x15}}; !reflect.DeepEqual(a.Selectors, want) {
+		t.Errorf("selector mismatch: have %v, want %v", a.Selectors, want)
+	}
+	if want := map[string][]uint64{"SELFDESTRUCT": {0x17}}; !reflect.DeepEqual(a.Dangerous, want) {
+		t.Errorf("dangerous opcode mismatch: have %v, want %v", a.Dangerous, want)
+	}
+	if a.MaxStack != 3 || a.Unbounded {
+		t.Errorf("stack bound mismatch: have %d (unbounded %v), want %d", a.MaxStack, a.Unbounded, 3)
+	}
+	if len(a.DynamicJumps) != 0 || len(a.InvalidJumps) != 0 {
+		t.Errorf("unexpected unresolved jumps: dynamic %v, invalid %v", a.DynamicJumps, a.InvalidJumps)
+	}
+	var dot bytes.Buffer
+	if err := a.WriteDot(&dot); err != nil {
+		t.Fatalf("failed to write dot graph: %v", err)
+	}
+	if !strings.Contains(dot.String(), "b0 -> b21;") || !strings.Contains(dot.String(), "b20 [label=\"00014: ADDRESS\\l\" style=dashed];") {
+		t.Errorf("dot graph missing expected content:\n%s", dot.String())
+	}
+}
+
+// Tests that jumps with computed targets are assumed to reach all destinations.
+func TestAnalyzeDynamicJump(t *testing.T) {
+	code, _ := hex.DecodeString("6005805600" + "5b00") // PUSH1 5 DUP1 JUMP STOP JUMPDEST STOP
+
+	a := Analyze(code)
+	if want := []uint64{3}; !reflect.DeepEqual(a.DynamicJumps, want) {
+		t.Errorf("dynamic jump mismatch: have %v, want %v", a.DynamicJumps, want)
+	}
+	if want := []CodeRange{{4, 5}}; !reflect.DeepEqual(a.Unreachable, want) {
+		t.Errorf("unreachable code mismatch: have %v, want %v", a.Unreachable, want)
+	}
+}
+
+// Tests that stack growth in loops is bounded by the stack limit.
+func TestAnalyzeUnboundedStack(t *testing.T) {
+	code, _ := hex.DecodeString("5b6000600056") // JUMPDEST PUSH1 0 PUSH1 0 JUMP
+
+	a := Analyze(code)
+	if a.MaxStack != 1024 || !a.Unbounded {
+		t.Errorf("stack bound mismatch: have %d (unbounded %v), want %d (unbounded)", a.MaxStack, a.Unbounded, 1024)
+	}
+}
@@ -33,6 +33,7 @@ import (
 	"github.com/gclchaineum/go-gclchaineum/consensus"
 	"github.com/gclchaineum/go-gclchaineum/core/rawdb"
 	"github.com/gclchaineum/go-gclchaineum/core/state"
+	"github.com/gclchaineum/go-gclchaineum/core/state/snapshot"
 	"github.com/gclchaineum/go-gclchaineum/core/types"
 	"github.com/gclchaineum/go-gclchaineum/core/vm"
 	"github.com/gclchaineum/go-gclchaineum/crypto"
@@ -75,6 +76,7 @@ type CacheConfig struct {
 	TrieCleanLimit int           // Memory allowance (MB) to use for caching trie nodes in memory
 	TrieDirtyLimit int           // Memory limit (MB) at which to start flushing dirty trie nodes to disk
 	TrieTimeLimit  time.Duration // Time limit after which to flush the current in-memory trie to disk
+	SnapshotLimit  int           // Memory allowance (MB) to use for caching snapshot entries in memory (0 = snapshots disabled)
 }
 
 // BlockChain represents the canonical chain given a database with a genesis
@@ -116,6 +118,7 @@ type BlockChain struct {
 	currentBlock     atomic.Value // Current head of the block chain
 	currentFastBlock atomic.Value // Current head of the fast-sync chain (may be above the block chain!)
 
+	snaps         *snapshot.Tree // Snapshot tree for fast trie leaf access
 	stateCache    state.Database // State database to reuse between imports (contains state cache)
 	bodyCache     *lru.Cache     // Cache for the most recent block bodies
 	bodyRLPCache  *lru.Cache     // Cache for the most recent block bodies in RLP encoded format
@@ -201,6 +204,10 @@ func NewBlockChain(db gcldb.Database, cacheConfig *CacheConfig, chainConfig *par
 			}
 		}
 	}
+	// Load any existing snapshot, regenerating it if loading failed
+	if bc.cacheConfig.SnapshotLimit > 0 {
+		bc.snaps = snapshot.New(bc.db, bc.stateCache.TrieDB(), bc.cacheConfig.SnapshotLimit, bc.CurrentBlock().Root())
+	}
 	// Take ownership of this particular state
 	go bc.update()
 	return bc, nil
@@ -291,6 +298,11 @@ func (bc *BlockChain) SetHead(head uint64) error {
 	bc.hc.SetHead(head, delFn)
 	currentHeader := bc.hc.CurrentHeader()
 
+	// Discard any ancient chain segments above the new head
+	if err := rawdb.TruncateAncients(bc.db, currentHeader.Number.Uint64()+1); err != nil {
+		return err
+	}
+
 	// Clear out any stale content from the caches
 	bc.bodyCache.Purge()
 	bc.bodyRLPCache.Purge()
@@ -325,6 +337,10 @@ func (bc *BlockChain) SetHead(head uint64) error {
 	rawdb.WriteHeadBlockHash(bc.db, currentBlock.Hash())
 	rawdb.WriteHeadFastBlockHash(bc.db, currentFastBlock.Hash())
 
+	// The snapshot layers above the new head are gone, regenerate it
+	if bc.snaps != nil {
+		bc.snaps.Rebuild(currentBlock.Root())
+	}
 	return bc.loadLastState()
 }
 
@@ -400,7 +416,7 @@ func (bc *BlockChain) State() (*state.StateDB, error) {
 
 // StateAt returns a new mutable state based on a particular point in time.
 func (bc *BlockChain) StateAt(root common.Hash) (*state.StateDB, error) {
-	return state.New(root, bc.stateCache)
+	return state.NewWithSnapshot(root, bc.stateCache, bc.snaps)
 }
 
 // StateCache returns the caching database underpinning the blockchain instance.
@@ -699,6 +715,13 @@ func (bc *BlockChain) Stop() {
 
 	bc.wg.Wait()
 
+	// Flatten the snapshot into the disk layer, so it can be reused after a restart
+	if bc.snaps != nil {
+		if err := bc.snaps.Cap(bc.CurrentBlock().Root(), 0); err != nil {
+			log.Error("Failed to flatten state snapshot", "err", err)
+		}
+		bc.snaps.Close()
+	}
 	// Ensure the state of a recent block is also stored to disk before exiting.
 	// We're writing three different states to catch different restart scenarios:
 	//  - HEAD:     So we don't need to reprocess any blocks in the general case
@@ -1049,6 +1072,12 @@ func (bc *BlockChain) WriteBlockWithState(block *types.Block, receipts []*types.
 	// Set new head.
 	if status == CanonStatTy {
 		bc.insert(block)
+
+		// If the snapshot couldn't follow the new head (e.g. after a reorg deeper
+		// than the snapshot diff layers or a fast sync), regenerate it
+		if bc.snaps != nil && bc.snaps.Snapshot(block.Root()) == nil {
+			bc.snaps.Rebuild(block.Root())
+		}
 	}
 	bc.futureBlocks.Remove(block.Hash())
 	return status, nil
@@ -1197,7 +1226,7 @@ func (bc *BlockChain) insertChain(chain types.Blocks, verifySeals bool) (int, []
 		if parent == nil {
 			parent = bc.GetBlock(block.ParentHash(), block.NumberU64()-1)
 		}
-		state, err := state.New(parent.Root(), bc.stateCache)
+		state, err := state.NewWithSnapshot(parent.Root(), bc.stateCache, bc.snaps)
 		if err != nil {
 			return it.index, events, coalescedLogs, err
 		}
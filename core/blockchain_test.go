@@ -1538,3 +1538,100 @@ func TestLowDiffLongChain(t *testing.T) {
 		header = chain.GetHeader(header.ParentHash, number-1)
 	}
 }
+
+// Tests that the state snapshot follows the chain head across imports and
+// reorgs, serving the same data as the state trie, and that it's reused after
+// a restart.
+func TestSnapshotFollowsChain(t *testing.T) {
+	var (
+		db      = gcldb.NewMemDatabase()
+		key, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		address = crypto.PubkeyToAddress(key.PublicKey)
+		gspec   = &Genesis{
+			Config: params.TestChainConfig,
+			Alloc:  GenesisAlloc{address: {Balance: big.NewInt(10000000000000)}},
+		}
+		genesis = gspec.MustCommit(db)
+		signer  = types.NewEIP155Signer(gspec.Config.ChainID)
+		cache   = &CacheConfig{TrieCleanLimit: 256, TrieDirtyLimit: 256, TrieTimeLimit: 5 * time.Minute, SnapshotLimit: 16}
+	)
+	// Create contracts storing a value and transfer funds around
+	makeChain := func(n int, seed byte) []*types.Block {
+		gendb := gcldb.NewMemDatabase()
+		gspec.MustCommit(gendb)
+
+		blocks, _ := GenerateChain(gspec.Config, genesis, gclash.NewFaker(), gendb, n, func(i int, gen *BlockGen) {
+			gen.SetCoinbase(common.Address{seed})
+
+			tx, _ := types.SignTx(types.NewTransaction(gen.TxNonce(address), common.Address{seed, byte(i)}, big.NewInt(1000), params.TxGas, nil, nil), signer, key)
+			gen.AddTx(tx)
+
+			// PUSH1 i+1, PUSH1 0, SSTORE
+			tx, _ = types.SignTx(types.NewContractCreation(gen.TxNonce(address), new(big.Int), 100000, new(big.Int), []byte{0x60, byte(i + 1), 0x60, 0x00, 0x55}), signer, key)
+			gen.AddTx(tx)
+		})
+		return blocks
+	}
+	verify := func(chain *BlockChain) {
+		head := chain.CurrentBlock()
+		if chain.snaps.Snapshot(head.Root()) == nil {
+			t.Fatalf("no snapshot for head #%d", head.NumberU64())
+		}
+		snapdb, _ := chain.StateAt(head.Root())
+		triedb, _ := state.New(head.Root(), chain.stateCache)
+
+		addrs := []common.Address{address, common.Address{0x01}, common.Address{0x02}}
+		for nonce := uint64(1); nonce < triedb.GetNonce(address); nonce += 2 {
+			addrs = append(addrs, crypto.CreateAddress(address, nonce))
+		}
+		for i := 0; i < 8; i++ {
+			addrs = append(addrs, common.Address{0x01, byte(i)}, common.Address{0x02, byte(i)})
+		}
+		for _, addr := range addrs {
+			if have, want := snapdb.GetBalance(addr), triedb.GetBalance(addr); have.Cmp(want) != 0 {
+				t.Errorf("%x: balance mismatch: have %v, want %v", addr, have, want)
+			}
+			if have, want := snapdb.GetNonce(addr), triedb.GetNonce(addr); have != want {
+				t.Errorf("%x: nonce mismatch: have %v, want %v", addr, have, want)
+			}
+			if have, want := snapdb.GetState(addr, common.Hash{}), triedb.GetState(addr, common.Hash{}); have != want {
+				t.Errorf("%x: storage mismatch: have %x, want %x", addr, have, want)
+			}
+		}
+	}
+	chain, err := NewBlockChain(db, cache, gspec.Config, gclash.NewFaker(), vm.Config{}, nil)
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	if _, err := chain.InsertChain(makeChain(4, 0x01)); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	verify(chain)
+
+	// Reorg to a longer chain forking off at genesis
+	if _, err := chain.InsertChain(makeChain(6, 0x02)); err != nil {
+		t.Fatalf("failed to insert fork: %v", err)
+	}
+	if chain.CurrentBlock().NumberU64() != 6 {
+		t.Fatalf("reorg failed, head #%d", chain.CurrentBlock().NumberU64())
+	}
+	verify(chain)
+
+	// Restart the chain and ensure the flattened snapshot is reused
+	root := chain.CurrentBlock().Root()
+	chain.Stop()
+
+	if have := rawdb.ReadSnapshotRoot(db); have != root {
+		t.Fatalf("persisted snapshot root mismatch: have %x, want %x", have, root)
+	}
+	chain, err = NewBlockChain(db, cache, gspec.Config, gclash.NewFaker(), vm.Config{}, nil)
+	if err != nil {
+		t.Fatalf("failed to recreate chain: %v", err)
+	}
+	defer chain.Stop()
+
+	if rawdb.ReadSnapshotGenerator(db) != nil {
+		t.Fatalf("snapshot regenerated after restart")
+	}
+	verify(chain)
+}
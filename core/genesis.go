@@ -31,6 +31,7 @@ import (
 	"github.com/gclchaineum/go-gclchaineum/core/rawdb"
 	"github.com/gclchaineum/go-gclchaineum/core/state"
 	"github.com/gclchaineum/go-gclchaineum/core/types"
+	"github.com/gclchaineum/go-gclchaineum/core/vm"
 	"github.com/gclchaineum/go-gclchaineum/gcldb"
 	"github.com/gclchaineum/go-gclchaineum/log"
 	"github.com/gclchaineum/go-gclchaineum/params"
@@ -184,6 +185,12 @@ func SetupGenesisBlockWithOverride(db gcldb.Database, genesis *Genesis, constant
 		newcfg.ConstantinopleBlock = constantinopleOverride
 		newcfg.PetersburgBlock = constantinopleOverride
 	}
+	if err := vm.ValidatePrecompiles(newcfg); err != nil {
+		return newcfg, stored, err
+	}
+	if err := vm.ValidateOpcodeRestrictions(newcfg); err != nil {
+		return newcfg, stored, err
+	}
 	storedcfg := rawdb.ReadChainConfig(db, stored)
 	if storedcfg == nil {
 		log.Warn("Found genesis block without chain config")
@@ -272,6 +279,14 @@ func (g *Genesis) Commit(db gcldb.Database) (*types.Block, error) {
 	if block.Number().Sign() != 0 {
 		return nil, fmt.Errorf("can't commit genesis block with number > 0")
 	}
+	if g.Config != nil {
+		if err := vm.ValidatePrecompiles(g.Config); err != nil {
+			return nil, err
+		}
+		if err := vm.ValidateOpcodeRestrictions(g.Config); err != nil {
+			return nil, err
+		}
+	}
 	rawdb.WriteTd(db, block.Hash(), block.NumberU64(), g.Difficulty)
 	rawdb.WriteBlock(db, block)
 	rawdb.WriteReceipts(db, block.Hash(), block.NumberU64(), nil)
@@ -29,7 +29,10 @@ import (
 
 // ReadCanonicalHash retrieves the hash assigned to a canonical block number.
 func ReadCanonicalHash(db DatabaseReader, number uint64) common.Hash {
-	data, _ := db.Get(headerHashKey(number))
+	data := readAncient(db, freezerHashTable, number)
+	if len(data) == 0 {
+		data, _ = db.Get(headerHashKey(number))
+	}
 	if len(data) == 0 {
 		return common.Hash{}
 	}
@@ -128,12 +131,18 @@ func WriteFastTrieProgress(db DatabaseWriter, count uint64) {
 
 // ReadHeaderRLP retrieves a block header in its raw RLP database encoding.
 func ReadHeaderRLP(db DatabaseReader, hash common.Hash, number uint64) rlp.RawValue {
+	if isAncient(db, hash, number) {
+		return readAncient(db, freezerHeaderTable, number)
+	}
 	data, _ := db.Get(headerKey(number, hash))
 	return data
 }
 
 // HasHeader verifies the existence of a block header corresponding to the hash.
 func HasHeader(db DatabaseReader, hash common.Hash, number uint64) bool {
+	if isAncient(db, hash, number) {
+		return true
+	}
 	if has, err := db.Has(headerKey(number, hash)); !has || err != nil {
 		return false
 	}
@@ -190,6 +199,9 @@ func DeleteHeader(db DatabaseDeleter, hash common.Hash, number uint64) {
 
 // ReadBodyRLP retrieves the block body (transactions and uncles) in RLP encoding.
 func ReadBodyRLP(db DatabaseReader, hash common.Hash, number uint64) rlp.RawValue {
+	if isAncient(db, hash, number) {
+		return readAncient(db, freezerBodiesTable, number)
+	}
 	data, _ := db.Get(blockBodyKey(number, hash))
 	return data
 }
@@ -203,6 +215,9 @@ func WriteBodyRLP(db DatabaseWriter, hash common.Hash, number uint64, rlp rlp.Ra
 
 // HasBody verifies the existence of a block body corresponding to the hash.
 func HasBody(db DatabaseReader, hash common.Hash, number uint64) bool {
+	if isAncient(db, hash, number) {
+		return true
+	}
 	if has, err := db.Has(blockBodyKey(number, hash)); !has || err != nil {
 		return false
 	}
@@ -241,7 +256,12 @@ func DeleteBody(db DatabaseDeleter, hash common.Hash, number uint64) {
 
 // ReadTd retrieves a block's total difficulty corresponding to the hash.
 func ReadTd(db DatabaseReader, hash common.Hash, number uint64) *big.Int {
-	data, _ := db.Get(headerTDKey(number, hash))
+	var data []byte
+	if isAncient(db, hash, number) {
+		data = readAncient(db, freezerDifficultyTable, number)
+	} else {
+		data, _ = db.Get(headerTDKey(number, hash))
+	}
 	if len(data) == 0 {
 		return nil
 	}
@@ -274,6 +294,9 @@ func DeleteTd(db DatabaseDeleter, hash common.Hash, number uint64) {
 // HasReceipts verifies the existence of all the transaction receipts belonging
 // to a block.
 func HasReceipts(db DatabaseReader, hash common.Hash, number uint64) bool {
+	if isAncient(db, hash, number) {
+		return true
+	}
 	if has, err := db.Has(blockReceiptsKey(number, hash)); !has || err != nil {
 		return false
 	}
@@ -283,7 +306,12 @@ func HasReceipts(db DatabaseReader, hash common.Hash, number uint64) bool {
 // ReadReceipts retrieves all the transaction receipts belonging to a block.
 func ReadReceipts(db DatabaseReader, hash common.Hash, number uint64) types.Receipts {
 	// Retrieve the flattened receipt slice
-	data, _ := db.Get(blockReceiptsKey(number, hash))
+	var data []byte
+	if isAncient(db, hash, number) {
+		data = readAncient(db, freezerReceiptTable, number)
+	} else {
+		data, _ = db.Get(blockReceiptsKey(number, hash))
+	}
 	if len(data) == 0 {
 		return nil
 	}
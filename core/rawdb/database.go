@@ -0,0 +1,280 @@
+// Copyright 2019 The go-gclchaineum Authors
+// This file is part of the go-gclchaineum library.
+//
+// The go-gclchaineum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-gclchaineum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-gclchaineum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/gclchaineum/go-gclchaineum/common"
+	"github.com/gclchaineum/go-gclchaineum/gcldb"
+	"github.com/gclchaineum/go-gclchaineum/log"
+	"github.com/olekukonko/tablewriter"
+)
+
+// freezerdb is a database wrapper that enables freezer data retrievals.
+type freezerdb struct {
+	gcldb.Database
+	*freezer
+}
+
+// Close implements gcldb.Database, closing both the fast key-value store as
+// well as the slow ancient tables.
+func (frdb *freezerdb) Close() {
+	if err := frdb.freezer.Close(); err != nil {
+		log.Error("Failed to close ancient database", "err", err)
+	}
+	frdb.Database.Close()
+}
+
+// KeyValueStore returns the fast key-value store backing the freezer database.
+func (frdb *freezerdb) KeyValueStore() gcldb.Database {
+	return frdb.Database
+}
+
+// NewDatabaseWithFreezer creates a high level database on top of a given key-
+// value data store with a freezer moving immutable chain segments into cold
+// storage. The freezer is started in the background and is stopped when the
+// returned database is closed.
+func NewDatabaseWithFreezer(db gcldb.Database, freezer string, namespace string) (gcldb.Database, error) {
+	// Create the idle freezer instance
+	frdb, err := newFreezer(freezer, namespace)
+	if err != nil {
+		return nil, err
+	}
+	// Since the freezer can be stored separately from the user's key-value database,
+	// there's a fairly high probability that the user requests invalid combinations
+	// of the freezer and database. Ensure that we don't shoot ourselves in the foot
+	// by serving up conflicting data, leading to both datastores getting corrupted.
+	if kvgenesis := ReadCanonicalHash(db, 0); kvgenesis != (common.Hash{}) {
+		if frozen, _ := frdb.Ancients(); frozen > 0 {
+			// If the freezer already contains something, ensure that the genesis blocks
+			// match, otherwise we might mix up freezers across chains and destroy both
+			// the freezer and the key-value store.
+			if frgenesis, _ := frdb.Ancient(freezerHashTable, 0); kvgenesis != common.BytesToHash(frgenesis) {
+				frdb.Close()
+				return nil, fmt.Errorf("genesis mismatch: %#x (leveldb) != %#x (ancients)", kvgenesis, frgenesis)
+			}
+		}
+	}
+	// Freezer is consistent with the key-value database, permit combining the two
+	frdb.wg.Add(1)
+	go frdb.freeze(db)
+
+	return &freezerdb{
+		Database: db,
+		freezer:  frdb,
+	}, nil
+}
+
+// KeyValueStore returns the key-value store backing a chain database, unwrapping
+// any attached ancient store.
+func KeyValueStore(db gcldb.Database) gcldb.Database {
+	if frdb, ok := db.(*freezerdb); ok {
+		return frdb.KeyValueStore()
+	}
+	return db
+}
+
+// TruncateAncients discards all ancient chain data from the given item number
+// onward if the database has an ancient store attached.
+func TruncateAncients(db DatabaseReader, items uint64) error {
+	if adb, ok := db.(AncientWriter); ok {
+		return adb.TruncateAncients(items)
+	}
+	return nil
+}
+
+// readAncient retrieves an item of the given kind from the ancient store if
+// the database has one attached, returning nil otherwise.
+func readAncient(db DatabaseReader, kind string, number uint64) []byte {
+	if adb, ok := db.(AncientReader); ok {
+		data, _ := adb.Ancient(kind, number)
+		return data
+	}
+	return nil
+}
+
+// isAncient reports whether the block with the given hash and number has been
+// moved into the ancient store.
+func isAncient(db DatabaseReader, hash common.Hash, number uint64) bool {
+	data := readAncient(db, freezerHashTable, number)
+	return len(data) > 0 && common.BytesToHash(data) == hash
+}
+
+// DatabaseStat tracks the number and total size of the entries of a single
+// category in the database.
+type DatabaseStat struct {
+	Count uint64
+	Size  common.StorageSize
+}
+
+// add accounts for a single database entry of the given size.
+func (s *DatabaseStat) add(size common.StorageSize) {
+	s.Count++
+	s.Size += size
+}
+
+// InspectDatabase traverses the entire database and checks the size
+// of all different categories of data.
+func InspectDatabase(db gcldb.Database) error {
+	it := KeyValueStore(db).NewIterator()
+	defer it.Release()
+
+	var (
+		count  int64
+		start  = time.Now()
+		logged = time.Now()
+
+		// Key-value store statistics
+		headers         DatabaseStat
+		tds             DatabaseStat
+		canonicalHashes DatabaseStat
+		numHashPairings DatabaseStat
+		bodies          DatabaseStat
+		receipts        DatabaseStat
+		txLookups       DatabaseStat
+		bloomBits       DatabaseStat
+		preimages       DatabaseStat
+		accountSnaps    DatabaseStat
+		storageSnaps    DatabaseStat
+		tries           DatabaseStat
+		configs         DatabaseStat
+		metadata        DatabaseStat
+		chainIndexers   DatabaseStat
+		chtTries        DatabaseStat
+		bloomTries      DatabaseStat
+		unaccounted     DatabaseStat
+
+		// Totals
+		total common.StorageSize
+	)
+	// Inspect key-value database first.
+	for it.Next() {
+		var (
+			key  = it.Key()
+			size = common.StorageSize(len(key) + len(it.Value()))
+		)
+		total += size
+		switch {
+		case bytes.HasPrefix(key, headerPrefix) && len(key) == (len(headerPrefix)+8+common.HashLength+len(headerTDSuffix)):
+			tds.add(size)
+		case bytes.HasPrefix(key, headerPrefix) && len(key) == (len(headerPrefix)+8+len(headerHashSuffix)):
+			canonicalHashes.add(size)
+		case bytes.HasPrefix(key, headerPrefix) && len(key) == (len(headerPrefix)+8+common.HashLength):
+			headers.add(size)
+		case bytes.HasPrefix(key, headerNumberPrefix) && len(key) == (len(headerNumberPrefix)+common.HashLength):
+			numHashPairings.add(size)
+		case bytes.HasPrefix(key, blockBodyPrefix) && len(key) == (len(blockBodyPrefix)+8+common.HashLength):
+			bodies.add(size)
+		case bytes.HasPrefix(key, blockReceiptsPrefix) && len(key) == (len(blockReceiptsPrefix)+8+common.HashLength):
+			receipts.add(size)
+		case bytes.HasPrefix(key, txLookupPrefix) && len(key) == (len(txLookupPrefix)+common.HashLength):
+			txLookups.add(size)
+		case bytes.HasPrefix(key, bloomBitsPrefix) && len(key) == (len(bloomBitsPrefix)+10+common.HashLength):
+			bloomBits.add(size)
+		case bytes.HasPrefix(key, preimagePrefix) && len(key) == (len(preimagePrefix)+common.HashLength):
+			preimages.add(size)
+		case bytes.HasPrefix(key, SnapshotAccountPrefix) && len(key) == (len(SnapshotAccountPrefix)+common.HashLength):
+			accountSnaps.add(size)
+		case bytes.HasPrefix(key, SnapshotStoragePrefix) && len(key) == (len(SnapshotStoragePrefix)+2*common.HashLength):
+			storageSnaps.add(size)
+		case bytes.HasPrefix(key, configPrefix) && len(key) == (len(configPrefix)+common.HashLength):
+			configs.add(size)
+		case bytes.HasPrefix(key, BloomBitsIndexPrefix) || bytes.HasPrefix(key, []byte("chtIndex-")) || bytes.HasPrefix(key, []byte("bltIndex-")):
+			chainIndexers.add(size)
+		case bytes.HasPrefix(key, []byte("cht-")) || bytes.HasPrefix(key, []byte("chtRoot-")): // Defined in light/postprocess.go
+			chtTries.add(size)
+		case bytes.HasPrefix(key, []byte("blt-")) || bytes.HasPrefix(key, []byte("bltRoot-")): // Defined in light/postprocess.go
+			bloomTries.add(size)
+		case len(key) == common.HashLength:
+			tries.add(size)
+		default:
+			var accounted bool
+			for _, meta := range [][]byte{databaseVerisionKey, headHeaderKey, headBlockKey, headFastBlockKey, fastTrieProgressKey, snapshotRootKey, snapshotGeneratorKey} {
+				if bytes.Equal(key, meta) {
+					metadata.add(size)
+					accounted = true
+					break
+				}
+			}
+			if !accounted {
+				unaccounted.add(size)
+			}
+		}
+		count++
+		if count%1000 == 0 && time.Since(logged) > 8*time.Second {
+			log.Info("Inspecting database", "count", count, "elapsed", common.PrettyDuration(time.Since(start)))
+			logged = time.Now()
+		}
+	}
+	if err := it.Error(); err != nil {
+		return err
+	}
+	// Display the database statistic.
+	stats := [][]string{
+		{"Key-Value store", "Headers", headers.Size.String(), fmt.Sprint(headers.Count)},
+		{"Key-Value store", "Bodies", bodies.Size.String(), fmt.Sprint(bodies.Count)},
+		{"Key-Value store", "Receipts", receipts.Size.String(), fmt.Sprint(receipts.Count)},
+		{"Key-Value store", "Difficulties", tds.Size.String(), fmt.Sprint(tds.Count)},
+		{"Key-Value store", "Block number->hash", canonicalHashes.Size.String(), fmt.Sprint(canonicalHashes.Count)},
+		{"Key-Value store", "Block hash->number", numHashPairings.Size.String(), fmt.Sprint(numHashPairings.Count)},
+		{"Key-Value store", "Transaction lookups", txLookups.Size.String(), fmt.Sprint(txLookups.Count)},
+		{"Key-Value store", "Bloombit index", bloomBits.Size.String(), fmt.Sprint(bloomBits.Count)},
+		{"Key-Value store", "Trie preimages", preimages.Size.String(), fmt.Sprint(preimages.Count)},
+		{"Key-Value store", "Trie nodes and code", tries.Size.String(), fmt.Sprint(tries.Count)},
+		{"Key-Value store", "Account snapshot", accountSnaps.Size.String(), fmt.Sprint(accountSnaps.Count)},
+		{"Key-Value store", "Storage snapshot", storageSnaps.Size.String(), fmt.Sprint(storageSnaps.Count)},
+		{"Key-Value store", "Chain configs", configs.Size.String(), fmt.Sprint(configs.Count)},
+		{"Key-Value store", "Chain metadata", metadata.Size.String(), fmt.Sprint(metadata.Count)},
+		{"Key-Value store", "Chain indexers", chainIndexers.Size.String(), fmt.Sprint(chainIndexers.Count)},
+		{"Light client", "CHT trie nodes", chtTries.Size.String(), fmt.Sprint(chtTries.Count)},
+		{"Light client", "Bloom trie nodes", bloomTries.Size.String(), fmt.Sprint(bloomTries.Count)},
+		{"Key-Value store", "Unaccounted", unaccounted.Size.String(), fmt.Sprint(unaccounted.Count)},
+	}
+	// Inspect the ancient store too if one is attached
+	if adb, ok := db.(AncientReader); ok {
+		frozen, _ := adb.Ancients()
+		for _, table := range []struct{ kind, name string }{
+			{freezerHeaderTable, "Headers"},
+			{freezerBodiesTable, "Bodies"},
+			{freezerReceiptTable, "Receipts"},
+			{freezerDifficultyTable, "Difficulties"},
+			{freezerHashTable, "Block number->hash"},
+		} {
+			size, err := adb.AncientSize(table.kind)
+			if err != nil {
+				return err
+			}
+			total += common.StorageSize(size)
+			stats = append(stats, []string{"Ancient store", table.name, common.StorageSize(size).String(), fmt.Sprint(frozen)})
+		}
+	}
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetAutoFormatHeaders(false)
+	table.SetHeader([]string{"Database", "Category", "Size", "Items"})
+	table.SetFooter([]string{"", "Total", total.String(), " "})
+	table.AppendBulk(stats)
+	table.Render()
+
+	if unaccounted.Count > 0 {
+		log.Error("Database contains unaccounted data", "size", unaccounted.Size, "count", unaccounted.Count)
+	}
+	return nil
+}
@@ -0,0 +1,407 @@
+// Copyright 2019 The go-gclchaineum Authors
+// This file is part of the go-gclchaineum library.
+//
+// The go-gclchaineum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-gclchaineum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-gclchaineum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gclchaineum/go-gclchaineum/common"
+	"github.com/gclchaineum/go-gclchaineum/gcldb"
+	"github.com/gclchaineum/go-gclchaineum/log"
+	"github.com/gclchaineum/go-gclchaineum/metrics"
+	"github.com/gclchaineum/go-gclchaineum/params"
+)
+
+var (
+	// errUnknownTable is returned if the user attempts to read from a table that is
+	// not tracked by the freezer.
+	errUnknownTable = errors.New("unknown table")
+
+	// errOutOrderInsertion is returned if the user attempts to inject out-of-order
+	// binary blobs into the freezer.
+	errOutOrderInsertion = errors.New("the append operation is out-order")
+)
+
+const (
+	// freezerRecheckInterval is the frequency to check the key-value database for
+	// chain progression that might permit new blocks to be frozen into immutable
+	// storage.
+	freezerRecheckInterval = time.Minute
+
+	// freezerBatchLimit is the maximum number of blocks to freeze in one batch
+	// before doing an fsync and deleting it from the key-value store.
+	freezerBatchLimit = 30000
+)
+
+// freezer is an append-only database to store immutable chain data into flat
+// files. The append only nature ensures that disk writes are minimized and that
+// the key-value store doesn't need to compact ancient chain segments over and
+// over again.
+type freezer struct {
+	frozen uint64 // Number of blocks already frozen (atomic, keep 64 bit aligned)
+
+	tables map[string]*freezerTable // Data tables for storing everything
+	lock   sync.Mutex               // Mutex serialising appends and truncations
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// newFreezer creates a chain freezer that moves ancient chain data into
+// append-only flat file containers.
+func newFreezer(datadir string, namespace string) (*freezer, error) {
+	// Create the initial freezer object
+	var (
+		readMeter  = metrics.NewRegisteredMeter(namespace+"ancient/read", nil)
+		writeMeter = metrics.NewRegisteredMeter(namespace+"ancient/write", nil)
+	)
+	// Open all the supported data tables
+	freezer := &freezer{
+		tables: make(map[string]*freezerTable),
+		quit:   make(chan struct{}),
+	}
+	for name, disableSnappy := range freezerNoSnappy {
+		table, err := newTable(datadir, name, readMeter, writeMeter, disableSnappy)
+		if err != nil {
+			for _, table := range freezer.tables {
+				table.Close()
+			}
+			return nil, err
+		}
+		freezer.tables[name] = table
+	}
+	if err := freezer.repair(); err != nil {
+		for _, table := range freezer.tables {
+			table.Close()
+		}
+		return nil, err
+	}
+	log.Info("Opened ancient database", "database", datadir, "frozen", freezer.frozen)
+	return freezer, nil
+}
+
+// Close terminates the chain freezer, closing all the data files.
+func (f *freezer) Close() error {
+	select {
+	case <-f.quit:
+		return nil // already closed
+	default:
+		close(f.quit)
+	}
+	f.wg.Wait()
+
+	var errs []error
+	for _, table := range f.tables {
+		if err := table.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if errs != nil {
+		return fmt.Errorf("%v", errs)
+	}
+	return nil
+}
+
+// HasAncient returns an indicator whether the specified ancient data exists
+// in the freezer.
+func (f *freezer) HasAncient(kind string, number uint64) (bool, error) {
+	if table := f.tables[kind]; table != nil {
+		return table.has(number), nil
+	}
+	return false, nil
+}
+
+// Ancient retrieves an ancient binary blob from the append-only immutable files.
+func (f *freezer) Ancient(kind string, number uint64) ([]byte, error) {
+	if table := f.tables[kind]; table != nil {
+		return table.Retrieve(number)
+	}
+	return nil, errUnknownTable
+}
+
+// Ancients returns the length of the frozen items.
+func (f *freezer) Ancients() (uint64, error) {
+	return atomic.LoadUint64(&f.frozen), nil
+}
+
+// AncientSize returns the ancient size of the specified category.
+func (f *freezer) AncientSize(kind string) (uint64, error) {
+	if table := f.tables[kind]; table != nil {
+		return table.size()
+	}
+	return 0, errUnknownTable
+}
+
+// AppendAncient injects all binary blobs belong to block at the end of the
+// append-only immutable table files. All out-of-order injections are rejected.
+func (f *freezer) AppendAncient(number uint64, hash, header, body, receipts, td []byte) (err error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	// Ensure the binary blobs we are appending is continuous with freezer.
+	if atomic.LoadUint64(&f.frozen) != number {
+		return errOutOrderInsertion
+	}
+	// Rollback all inserted data if any insertion below failed to ensure
+	// the tables won't out of sync.
+	defer func() {
+		if err != nil {
+			for _, table := range f.tables {
+				if rerr := table.truncate(f.frozen); rerr != nil {
+					log.Crit("Failed to rollback ancient data", "err", rerr)
+				}
+			}
+		}
+	}()
+	// Inject all the components into the relevant data tables
+	if err := f.tables[freezerHashTable].Append(f.frozen, hash[:]); err != nil {
+		log.Error("Failed to append ancient hash", "number", f.frozen, "hash", hash, "err", err)
+		return err
+	}
+	if err := f.tables[freezerHeaderTable].Append(f.frozen, header); err != nil {
+		log.Error("Failed to append ancient header", "number", f.frozen, "hash", hash, "err", err)
+		return err
+	}
+	if err := f.tables[freezerBodiesTable].Append(f.frozen, body); err != nil {
+		log.Error("Failed to append ancient body", "number", f.frozen, "hash", hash, "err", err)
+		return err
+	}
+	if err := f.tables[freezerReceiptTable].Append(f.frozen, receipts); err != nil {
+		log.Error("Failed to append ancient receipts", "number", f.frozen, "hash", hash, "err", err)
+		return err
+	}
+	if err := f.tables[freezerDifficultyTable].Append(f.frozen, td); err != nil {
+		log.Error("Failed to append ancient difficulty", "number", f.frozen, "hash", hash, "err", err)
+		return err
+	}
+	atomic.AddUint64(&f.frozen, 1) // Only modify atomically
+	return nil
+}
+
+// TruncateAncients discards any recent data above the provided threshold number.
+func (f *freezer) TruncateAncients(items uint64) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	return f.truncate(items)
+}
+
+// truncate discards any recent data above the provided threshold number. The
+// caller must hold the freezer lock.
+func (f *freezer) truncate(items uint64) error {
+	if atomic.LoadUint64(&f.frozen) <= items {
+		return nil
+	}
+	for _, table := range f.tables {
+		if err := table.truncate(items); err != nil {
+			return err
+		}
+	}
+	atomic.StoreUint64(&f.frozen, items)
+	return nil
+}
+
+// Sync flushes all data tables to disk.
+func (f *freezer) Sync() error {
+	var errs []error
+	for _, table := range f.tables {
+		if err := table.Sync(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if errs != nil {
+		return fmt.Errorf("%v", errs)
+	}
+	return nil
+}
+
+// freeze is a background thread that periodically checks the blockchain for any
+// import progress and moves ancient data from the fast database into the freezer.
+//
+// This functionality is deliberately broken off from block importing to avoid
+// incurring additional data shuffling delays on block propagation.
+func (f *freezer) freeze(db gcldb.Database) {
+	defer f.wg.Done()
+
+	for {
+		// Retrieve the freezing threshold. In theory we're interested only in full
+		// blocks post-sync, but that would keep the live database enormous during
+		// dormant fast-syncs. Instead we track the full head block.
+		hash := ReadHeadBlockHash(db)
+		if hash == (common.Hash{}) {
+			log.Debug("Current full block hash unavailable") // new chain, empty database
+			if !f.sleep() {
+				return
+			}
+			continue
+		}
+		// The chain may be rewound concurrently, the frozen count is loaded
+		// atomically once and the batch is clamped to it after freezing.
+		number := ReadHeaderNumber(db, hash)
+		first := atomic.LoadUint64(&f.frozen)
+		switch {
+		case number == nil:
+			log.Error("Current full block number unavailable", "hash", hash)
+			if !f.sleep() {
+				return
+			}
+			continue
+
+		case *number < params.ImmutabilityThreshold:
+			log.Debug("Current full block not old enough", "number", *number, "hash", hash, "delay", params.ImmutabilityThreshold)
+			if !f.sleep() {
+				return
+			}
+			continue
+
+		case *number-params.ImmutabilityThreshold <= first:
+			log.Debug("Ancient blocks frozen already", "number", *number, "hash", hash, "frozen", first)
+			if !f.sleep() {
+				return
+			}
+			continue
+		}
+		// Seems we have data ready to be frozen, process in usable batches
+		limit := *number - params.ImmutabilityThreshold
+		if limit-first > freezerBatchLimit {
+			limit = first + freezerBatchLimit
+		}
+		var (
+			start    = time.Now()
+			ancients = make([]common.Hash, 0, limit-first)
+		)
+		for frozen := first; frozen < limit; frozen++ {
+			// Retrieves all the components of the canonical block
+			hash := ReadCanonicalHash(db, frozen)
+			if hash == (common.Hash{}) {
+				log.Error("Canonical hash missing, can't freeze", "number", frozen)
+				break
+			}
+			header := ReadHeaderRLP(db, hash, frozen)
+			if len(header) == 0 {
+				log.Error("Block header missing, can't freeze", "number", frozen, "hash", hash)
+				break
+			}
+			body := ReadBodyRLP(db, hash, frozen)
+			if len(body) == 0 {
+				log.Error("Block body missing, can't freeze", "number", frozen, "hash", hash)
+				break
+			}
+			receipts, _ := db.Get(blockReceiptsKey(frozen, hash))
+			if len(receipts) == 0 {
+				log.Error("Block receipts missing, can't freeze", "number", frozen, "hash", hash)
+				break
+			}
+			td, _ := db.Get(headerTDKey(frozen, hash))
+			if len(td) == 0 {
+				log.Error("Total difficulty missing, can't freeze", "number", frozen, "hash", hash)
+				break
+			}
+			log.Trace("Deep froze ancient block", "number", frozen, "hash", hash)
+			// Inject all the components into the relevant data tables, a rewind
+			// racing with the batch is rejected as an out of order insertion
+			if err := f.AppendAncient(frozen, hash[:], header, body, receipts, td); err != nil {
+				break
+			}
+			ancients = append(ancients, hash)
+		}
+		// Batch of blocks have been frozen, flush them before wiping from leveldb
+		if err := f.Sync(); err != nil {
+			log.Crit("Failed to flush frozen tables", "err", err)
+		}
+		// If the chain was rewound while freezing, only wipe what's still frozen
+		frozen := atomic.LoadUint64(&f.frozen)
+		if frozen < first {
+			ancients = ancients[:0]
+		} else if first+uint64(len(ancients)) > frozen {
+			ancients = ancients[:frozen-first]
+		}
+		// Wipe out all data from the active database. Side chain data at the frozen
+		// heights is left untouched, it is still reachable through its own hash.
+		// The genesis block is retained, it is needed to detect mismatching freezers.
+		batch := db.NewBatch()
+		for i := 0; i < len(ancients); i++ {
+			number := first + uint64(i)
+			if number == 0 {
+				continue
+			}
+			DeleteCanonicalHash(batch, number)
+			batch.Delete(headerKey(number, ancients[i]))
+			batch.Delete(headerTDKey(number, ancients[i]))
+			batch.Delete(blockBodyKey(number, ancients[i]))
+			batch.Delete(blockReceiptsKey(number, ancients[i]))
+			if batch.ValueSize() >= gcldb.IdealBatchSize {
+				if err := batch.Write(); err != nil {
+					log.Crit("Failed to delete frozen canonical blocks", "err", err)
+				}
+				batch.Reset()
+			}
+		}
+		if err := batch.Write(); err != nil {
+			log.Crit("Failed to delete frozen canonical blocks", "err", err)
+		}
+		// Log something friendly for the user
+		context := []interface{}{
+			"blocks", len(ancients), "elapsed", common.PrettyDuration(time.Since(start)), "number", first + uint64(len(ancients)) - 1,
+		}
+		if n := len(ancients); n > 0 {
+			context = append(context, []interface{}{"hash", ancients[n-1]}...)
+		}
+		log.Info("Deep froze chain segment", context...)
+
+		// Avoid database thrashing with tiny writes
+		if uint64(len(ancients)) < freezerBatchLimit {
+			if !f.sleep() {
+				return
+			}
+		}
+	}
+}
+
+// sleep waits for the freezer recheck interval to elapse, returning false if
+// the freezer was closed in the meantime.
+func (f *freezer) sleep() bool {
+	select {
+	case <-time.NewTimer(freezerRecheckInterval).C:
+		return true
+	case <-f.quit:
+		return false
+	}
+}
+
+// repair truncates all data tables to the same length.
+func (f *freezer) repair() error {
+	min := uint64(math.MaxUint64)
+	for _, table := range f.tables {
+		items := atomic.LoadUint64(&table.items)
+		if min > items {
+			min = items
+		}
+	}
+	for _, table := range f.tables {
+		if err := table.truncate(min); err != nil {
+			return err
+		}
+	}
+	atomic.StoreUint64(&f.frozen, min)
+	return nil
+}
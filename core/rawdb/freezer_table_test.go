@@ -0,0 +1,191 @@
+// Copyright 2019 The go-gclchaineum Authors
+// This file is part of the go-gclchaineum library.
+//
+// The go-gclchaineum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-gclchaineum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-gclchaineum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gclchaineum/go-gclchaineum/metrics"
+)
+
+func init() {
+	rand.Seed(time.Now().Unix())
+}
+
+// getChunk returns a chunk of data with the given size, filled with the byte b.
+func getChunk(size int, b int) []byte {
+	data := make([]byte, size)
+	for i := range data {
+		data[i] = byte(b)
+	}
+	return data
+}
+
+// Tests that basic appends and retrievals work, and that the data survives a
+// reopen of the table.
+func TestFreezerBasics(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "freezer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, nosnappy := range []bool{false, true} {
+		fname := fmt.Sprintf("basics-%v", nosnappy)
+		rm, wm := metrics.NewMeter(), metrics.NewMeter()
+
+		// Set a small max file size to force rolling over into new data files
+		f, err := newCustomTable(dir, fname, rm, wm, 50, nosnappy)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for x := 0; x < 255; x++ {
+			if err := f.Append(uint64(x), getChunk(15, x)); err != nil {
+				t.Fatalf("failed to append item %d: %v", x, err)
+			}
+		}
+		if err := f.Append(300, getChunk(15, 0)); err == nil {
+			t.Fatalf("out of order append accepted")
+		}
+		f.Close()
+
+		// Reopen and check all items are retrievable
+		f, err = newCustomTable(dir, fname, rm, wm, 50, nosnappy)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for y := 0; y < 255; y++ {
+			exp := getChunk(15, y)
+			got, err := f.Retrieve(uint64(y))
+			if err != nil {
+				t.Fatalf("failed to retrieve item %d: %v", y, err)
+			}
+			if !bytes.Equal(got, exp) {
+				t.Fatalf("item %d: have %x, want %x", y, got, exp)
+			}
+		}
+		if _, err := f.Retrieve(255); err != errOutOfBounds {
+			t.Fatalf("out of bounds retrieval: have %v, want %v", err, errOutOfBounds)
+		}
+		f.Close()
+	}
+}
+
+// Tests that a table which lost data in its head file gets repaired to the last
+// consistent item on open.
+func TestFreezerRepairDanglingHead(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "freezer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	rm, wm := metrics.NewMeter(), metrics.NewMeter()
+	fname := fmt.Sprintf("dangling_headtest-%d", rand.Uint64())
+
+	f, err := newCustomTable(dir, fname, rm, wm, 50, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for x := 0; x < 255; x++ {
+		f.Append(uint64(x), getChunk(15, x))
+	}
+	f.Close()
+
+	// Chop off the last few bytes of the head data file
+	fi, err := os.Stat(filepath.Join(dir, fmt.Sprintf("%s.0084.rdat", fname)))
+	if err != nil {
+		t.Fatalf("failed to stat head file: %v", err)
+	}
+	if err := os.Truncate(filepath.Join(dir, fmt.Sprintf("%s.0084.rdat", fname)), fi.Size()-4); err != nil {
+		t.Fatal(err)
+	}
+	// The last item should be gone, the one before should still be there
+	f, err = newCustomTable(dir, fname, rm, wm, 50, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	if _, err := f.Retrieve(254); err != errOutOfBounds {
+		t.Fatalf("dangling item retrievable: %v", err)
+	}
+	if got, err := f.Retrieve(253); err != nil || !bytes.Equal(got, getChunk(15, 253)) {
+		t.Fatalf("repaired item mismatch: have %x (%v), want %x", got, err, getChunk(15, 253))
+	}
+	// Appending should continue where the repair left off
+	if err := f.Append(254, getChunk(15, 254)); err != nil {
+		t.Fatalf("failed to append after repair: %v", err)
+	}
+}
+
+// Tests that truncating a table discards the tail items, including whole data
+// files, and that appends resume from the truncation point.
+func TestFreezerTruncate(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "freezer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	rm, wm := metrics.NewMeter(), metrics.NewMeter()
+	f, err := newCustomTable(dir, "truncation", rm, wm, 50, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	for x := 0; x < 30; x++ {
+		f.Append(uint64(x), getChunk(15, x))
+	}
+	if err := f.truncate(10); err != nil {
+		t.Fatalf("failed to truncate: %v", err)
+	}
+	if f.items != 10 {
+		t.Fatalf("item count mismatch: have %d, want %d", f.items, 10)
+	}
+	if _, err := f.Retrieve(10); err != errOutOfBounds {
+		t.Fatalf("truncated item retrievable: %v", err)
+	}
+	for x := 10; x < 20; x++ {
+		if err := f.Append(uint64(x), getChunk(15, 100+x)); err != nil {
+			t.Fatalf("failed to append item %d: %v", x, err)
+		}
+	}
+	for x := 0; x < 20; x++ {
+		exp := getChunk(15, x)
+		if x >= 10 {
+			exp = getChunk(15, 100+x)
+		}
+		if got, err := f.Retrieve(uint64(x)); err != nil || !bytes.Equal(got, exp) {
+			t.Fatalf("item %d: have %x (%v), want %x", x, got, err, exp)
+		}
+	}
+}
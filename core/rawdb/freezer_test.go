@@ -0,0 +1,123 @@
+// Copyright 2019 The go-gclchaineum Authors
+// This file is part of the go-gclchaineum library.
+//
+// The go-gclchaineum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-gclchaineum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-gclchaineum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"bytes"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"testing"
+
+	"github.com/gclchaineum/go-gclchaineum/common"
+	"github.com/gclchaineum/go-gclchaineum/core/types"
+	"github.com/gclchaineum/go-gclchaineum/gcldb"
+	"github.com/gclchaineum/go-gclchaineum/rlp"
+)
+
+// Tests that the chain accessors transparently serve blocks that were moved out
+// of the key-value store into the ancient store.
+func TestAncientBlockRetrieval(t *testing.T) {
+	dir, err := ioutil.TempDir("", "freezer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	f, err := newFreezer(dir, "")
+	if err != nil {
+		t.Fatalf("failed to create freezer: %v", err)
+	}
+	kvdb := gcldb.NewMemDatabase()
+	db := &freezerdb{Database: kvdb, freezer: f}
+	defer db.Close()
+
+	// Create a few blocks and move the first ones into the ancient store
+	var blocks []*types.Block
+	for i := 0; i < 4; i++ {
+		header := &types.Header{Number: big.NewInt(int64(i)), Extra: []byte("test block")}
+		if i > 0 {
+			header.ParentHash = blocks[i-1].Hash()
+		}
+		block := types.NewBlockWithHeader(header)
+		receipts := types.Receipts{types.NewReceipt(nil, false, uint64(i))}
+
+		WriteBlock(db, block)
+		WriteCanonicalHash(db, block.Hash(), block.NumberU64())
+		WriteTd(db, block.Hash(), block.NumberU64(), big.NewInt(int64(i+1)))
+		WriteReceipts(db, block.Hash(), block.NumberU64(), receipts)
+
+		blocks = append(blocks, block)
+	}
+	for i := 0; i < 2; i++ {
+		hash, number := blocks[i].Hash(), blocks[i].NumberU64()
+
+		header, _ := kvdb.Get(headerKey(number, hash))
+		body, _ := kvdb.Get(blockBodyKey(number, hash))
+		receipts, _ := kvdb.Get(blockReceiptsKey(number, hash))
+		td, _ := kvdb.Get(headerTDKey(number, hash))
+		if err := f.AppendAncient(number, hash[:], header, body, receipts, td); err != nil {
+			t.Fatalf("failed to freeze block %d: %v", number, err)
+		}
+		kvdb.Delete(headerKey(number, hash))
+		kvdb.Delete(headerHashKey(number))
+		kvdb.Delete(blockBodyKey(number, hash))
+		kvdb.Delete(blockReceiptsKey(number, hash))
+		kvdb.Delete(headerTDKey(number, hash))
+	}
+	if err := f.AppendAncient(3, blocks[3].Hash().Bytes(), nil, nil, nil, nil); err != errOutOrderInsertion {
+		t.Fatalf("out of order freeze: have %v, want %v", err, errOutOrderInsertion)
+	}
+	// Verify that both frozen and live blocks are retrievable
+	for i, block := range blocks {
+		hash, number := block.Hash(), block.NumberU64()
+
+		if have := ReadCanonicalHash(db, number); have != hash {
+			t.Errorf("block %d: canonical hash mismatch: have %x, want %x", i, have, hash)
+		}
+		if header := ReadHeader(db, hash, number); header == nil || header.Hash() != hash {
+			t.Errorf("block %d: header mismatch: have %v", i, header)
+		}
+		if have := ReadBlock(db, hash, number); have == nil || have.Hash() != hash {
+			t.Errorf("block %d: block mismatch: have %v", i, have)
+		}
+		if !HasHeader(db, hash, number) || !HasBody(db, hash, number) || !HasReceipts(db, hash, number) {
+			t.Errorf("block %d: existence check failed", i)
+		}
+		if td := ReadTd(db, hash, number); td == nil || td.Int64() != int64(i+1) {
+			t.Errorf("block %d: total difficulty mismatch: have %v, want %d", i, td, i+1)
+		}
+		receipts := ReadReceipts(db, hash, number)
+		if len(receipts) != 1 || receipts[0].CumulativeGasUsed != uint64(i) {
+			t.Errorf("block %d: receipts mismatch: have %v", i, receipts)
+		}
+		// Frozen blocks must not be served for unrelated hashes
+		if header := ReadHeader(db, common.Hash{0x01}, number); header != nil {
+			t.Errorf("block %d: header returned for unknown hash", i)
+		}
+	}
+	// Truncating the ancient store should drop the frozen blocks
+	if err := TruncateAncients(db, 1); err != nil {
+		t.Fatalf("failed to truncate ancients: %v", err)
+	}
+	if header := ReadHeader(db, blocks[1].Hash(), 1); header != nil {
+		t.Errorf("truncated header returned: %v", header)
+	}
+	if enc, _ := rlp.EncodeToBytes(blocks[0].Header()); !bytes.Equal(ReadHeaderRLP(db, blocks[0].Hash(), 0), enc) {
+		t.Errorf("retained header mismatch")
+	}
+}
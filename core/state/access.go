@@ -0,0 +1,114 @@
+// Copyright 2019 The go-gclchaineum Authors
+// This file is part of the go-gclchaineum library.
+//
+// The go-gclchaineum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-gclchaineum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-gclchaineum library. If not, see <http://www.gnu.org/licenses/>.
+
+package state
+
+import (
+	"math/big"
+
+	"github.com/gclchaineum/go-gclchaineum/common"
+)
+
+// AccessKind identifies the part of an account a state access touches.
+type AccessKind uint8
+
+const (
+	AccountAccess AccessKind = iota // Existence of the account itself
+	BalanceAccess                   // Balance of the account
+	NonceAccess                     // Nonce of the account
+	CodeAccess                      // Code of the account
+	StorageAccess                   // A single storage slot of the account
+)
+
+// AccessKey identifies a single piece of state accessed through a StateDB.
+type AccessKey struct {
+	Address common.Address
+	Kind    AccessKind
+	Slot    common.Hash // Storage slot, only set for StorageAccess
+}
+
+// AccessSet records the state read and written through a StateDB, along with
+// the state of the written accounts before their first modification. Writes
+// are recorded even if they are reverted later on.
+type AccessSet struct {
+	Reads  map[AccessKey]struct{}
+	Writes map[AccessKey]struct{}
+
+	existed  map[common.Address]bool     // Whgclchain written accounts existed before their first write
+	balances map[common.Address]*big.Int // Balances of written accounts before their first write
+}
+
+// NewAccessSet creates an empty access set.
+func NewAccessSet() *AccessSet {
+	return &AccessSet{
+		Reads:    make(map[AccessKey]struct{}),
+		Writes:   make(map[AccessKey]struct{}),
+		existed:  make(map[common.Address]bool),
+		balances: make(map[common.Address]*big.Int),
+	}
+}
+
+// Accounts returns the addresses of all the written accounts.
+func (a *AccessSet) Accounts() []common.Address {
+	addrs := make([]common.Address, 0, len(a.existed))
+	for addr := range a.existed {
+		addrs = append(addrs, addr)
+	}
+	return addrs
+}
+
+// Existed returns whether a written account existed before its first write.
+func (a *AccessSet) Existed(addr common.Address) bool {
+	return a.existed[addr]
+}
+
+// OriginalBalance returns the balance of a written account before its first write.
+func (a *AccessSet) OriginalBalance(addr common.Address) *big.Int {
+	if balance := a.balances[addr]; balance != nil {
+		return balance
+	}
+	return common.Big0
+}
+
+// SetAccessSet starts recording the state accessed through the StateDB into the
+// given set, or stops recording if it is nil. The set is not carried over into
+// copies of the state.
+func (self *StateDB) SetAccessSet(set *AccessSet) {
+	self.access = set
+}
+
+// recordRead adds a state read to the access set, if one is being recorded.
+func (self *StateDB) recordRead(addr common.Address, kind AccessKind, slot common.Hash) {
+	if self.access != nil {
+		self.access.Reads[AccessKey{addr, kind, slot}] = struct{}{}
+	}
+}
+
+// recordWrite adds a state write to the access set, if one is being recorded,
+// snapshotting the account the first time it is written.
+func (self *StateDB) recordWrite(addr common.Address, kind AccessKind, slot common.Hash) {
+	if self.access == nil {
+		return
+	}
+	if _, ok := self.access.existed[addr]; !ok {
+		obj := self.getStateObject(addr)
+		self.access.existed[addr] = obj != nil
+		if obj != nil {
+			self.access.balances[addr] = new(big.Int).Set(obj.Balance())
+		}
+	}
+	self.access.Writes[AccessKey{addr, kind, slot}] = struct{}{}
+}
@@ -0,0 +1,206 @@
+// Copyright 2019 The go-gclchaineum Authors
+// This file is part of the go-gclchaineum library.
+//
+// The go-gclchaineum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-gclchaineum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-gclchaineum library. If not, see <http://www.gnu.org/licenses/>.
+
+package state
+
+import (
+	"fmt"
+
+	"github.com/gclchaineum/go-gclchaineum/common"
+	"github.com/gclchaineum/go-gclchaineum/crypto"
+	"github.com/gclchaineum/go-gclchaineum/rlp"
+	"github.com/gclchaineum/go-gclchaineum/trie"
+)
+
+// emptyRoot is the known root hash of an empty trie.
+var emptyRoot = common.HexToHash("56e81f171bcc55a6ff8345e692c0f86e5b48e01b996cadc001622fb5e363b421")
+
+// DumpAccountState is the state of an account's fields at one side of a diff.
+type DumpAccountState struct {
+	Balance  string `json:"balance"`
+	Nonce    uint64 `json:"nonce"`
+	CodeHash string `json:"codeHash"`
+}
+
+// DumpStorageDiff is the change of a single storage slot. An empty side means
+// the slot was unset in that state.
+type DumpStorageDiff struct {
+	Before string `json:"before"`
+	After  string `json:"after"`
+}
+
+// DumpAccountDiff is the change of a single account between two states. A nil
+// side means the account didn't exist in that state.
+type DumpAccountDiff struct {
+	Before  *DumpAccountState          `json:"before"`
+	After   *DumpAccountState          `json:"after"`
+	Storage map[string]DumpStorageDiff `json:"storage"`
+}
+
+// DumpDiff is the difference between two states, containing every account
+// that was created, deleted or modified, along with its changed storage slots.
+type DumpDiff struct {
+	Before   string                     `json:"before"`
+	After    string                     `json:"after"`
+	Accounts map[string]DumpAccountDiff `json:"accounts"`
+}
+
+// DiffStates computes the difference between the states with the given roots.
+// Only the parts of the tries that differ are iterated, so the cost depends on
+// the size of the change rather than the size of the state.
+func DiffStates(db Database, before, after common.Hash) (DumpDiff, error) {
+	oldTrie, err := db.OpenTrie(before)
+	if err != nil {
+		return DumpDiff{}, err
+	}
+	newTrie, err := db.OpenTrie(after)
+	if err != nil {
+		return DumpDiff{}, err
+	}
+	diff := DumpDiff{
+		Before:   fmt.Sprintf("%x", before),
+		After:    fmt.Sprintf("%x", after),
+		Accounts: make(map[string]DumpAccountDiff),
+	}
+	err = diffTries(oldTrie, newTrie, func(key []byte, prev, post []byte) error {
+		var (
+			change = DumpAccountDiff{Storage: make(map[string]DumpStorageDiff)}
+			err    error
+
+			// A missing account is treated as having an empty storage trie
+			prevRoot, postRoot = emptyRoot, emptyRoot
+		)
+		if prev != nil {
+			if change.Before, prevRoot, err = dumpAccountState(prev); err != nil {
+				return err
+			}
+		}
+		if post != nil {
+			if change.After, postRoot, err = dumpAccountState(post); err != nil {
+				return err
+			}
+		}
+		if prevRoot != postRoot {
+			addrHash := crypto.Keccak256Hash(key)
+
+			oldStorage, err := db.OpenStorageTrie(addrHash, prevRoot)
+			if err != nil {
+				return err
+			}
+			newStorage, err := db.OpenStorageTrie(addrHash, postRoot)
+			if err != nil {
+				return err
+			}
+			err = diffTries(oldStorage, newStorage, func(key []byte, prev, post []byte) error {
+				var (
+					slot DumpStorageDiff
+					err  error
+				)
+				if prev != nil {
+					if slot.Before, err = dumpStorageValue(prev); err != nil {
+						return err
+					}
+				}
+				if post != nil {
+					if slot.After, err = dumpStorageValue(post); err != nil {
+						return err
+					}
+				}
+				change.Storage[common.Bytes2Hex(key)] = slot
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+		diff.Accounts[common.Bytes2Hex(key)] = change
+		return nil
+	})
+	return diff, err
+}
+
+// dumpAccountState decodes an account trie leaf into its dumpable fields and
+// its storage root.
+func dumpAccountState(blob []byte) (*DumpAccountState, common.Hash, error) {
+	var data Account
+	if err := rlp.DecodeBytes(blob, &data); err != nil {
+		return nil, common.Hash{}, err
+	}
+	state := &DumpAccountState{
+		Balance:  data.Balance.String(),
+		Nonce:    data.Nonce,
+		CodeHash: common.Bytes2Hex(data.CodeHash),
+	}
+	return state, data.Root, nil
+}
+
+// dumpStorageValue decodes a storage trie leaf into the hex form of the slot value.
+func dumpStorageValue(blob []byte) (string, error) {
+	_, content, _, err := rlp.Split(blob)
+	if err != nil {
+		return "", err
+	}
+	return common.Bytes2Hex(common.BytesToHash(content).Bytes()), nil
+}
+
+// diffTries invokes onDiff with the preimage of every key whose value differs
+// between the two tries, along with the old and new values (nil if missing).
+// Keys present in the new trie are reported in a first pass, keys that were
+// deleted in a second one.
+func diffTries(a, b Trie, onDiff func(key []byte, prev, post []byte) error) error {
+	// Iterate over all the new or modified keys
+	it, _ := trie.NewDifferenceIterator(a.NodeIterator(nil), b.NodeIterator(nil))
+	iter := trie.NewIterator(it)
+	for iter.Next() {
+		key := b.GetKey(iter.Key)
+		if key == nil {
+			return fmt.Errorf("no preimage found for hash %x", iter.Key)
+		}
+		prev, err := a.TryGet(key)
+		if err != nil {
+			return err
+		}
+		if len(prev) == 0 {
+			prev = nil
+		}
+		if err := onDiff(key, prev, iter.Value); err != nil {
+			return err
+		}
+	}
+	if iter.Err != nil {
+		return iter.Err
+	}
+	// Iterate over the keys that were deleted, skipping the modified ones
+	it, _ = trie.NewDifferenceIterator(b.NodeIterator(nil), a.NodeIterator(nil))
+	iter = trie.NewIterator(it)
+	for iter.Next() {
+		key := a.GetKey(iter.Key)
+		if key == nil {
+			return fmt.Errorf("no preimage found for hash %x", iter.Key)
+		}
+		post, err := b.TryGet(key)
+		if err != nil {
+			return err
+		}
+		if len(post) > 0 {
+			continue
+		}
+		if err := onDiff(key, iter.Value, nil); err != nil {
+			return err
+		}
+	}
+	return iter.Err
+}
@@ -0,0 +1,99 @@
+// Copyright 2019 The go-gclchaineum Authors
+// This file is part of the go-gclchaineum library.
+//
+// The go-gclchaineum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-gclchaineum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-gclchaineum library. If not, see <http://www.gnu.org/licenses/>.
+
+package state
+
+import (
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/gclchaineum/go-gclchaineum/common"
+	"github.com/gclchaineum/go-gclchaineum/crypto"
+	"github.com/gclchaineum/go-gclchaineum/gcldb"
+)
+
+// Tests that the state diff reports created, deleted and modified accounts and
+// storage slots, and nothing else.
+func TestDiffStates(t *testing.T) {
+	var (
+		db      = NewDatabase(gcldb.NewMemDatabase())
+		kept    = common.BytesToAddress([]byte{0x01})
+		changed = common.BytesToAddress([]byte{0x02})
+		deleted = common.BytesToAddress([]byte{0x03})
+		created = common.BytesToAddress([]byte{0x04})
+	)
+	state, _ := New(common.Hash{}, db)
+	state.SetBalance(kept, big.NewInt(1))
+	state.SetState(kept, common.Hash{0x01}, common.Hash{0x01})
+	state.SetBalance(changed, big.NewInt(2))
+	state.SetState(changed, common.Hash{0x01}, common.Hash{0x01})
+	state.SetState(changed, common.Hash{0x02}, common.Hash{0x02})
+	state.SetNonce(deleted, 3)
+	state.SetState(deleted, common.Hash{0x03}, common.Hash{0x03})
+	before, _ := state.Commit(false)
+
+	state, _ = New(before, db)
+	state.SetBalance(changed, big.NewInt(20))
+	state.SetState(changed, common.Hash{0x01}, common.Hash{0x10})
+	state.SetState(changed, common.Hash{0x02}, common.Hash{})
+	state.SetState(changed, common.Hash{0x03}, common.Hash{0x30})
+	state.Suicide(deleted)
+	state.SetCode(created, []byte{0x60})
+	state.SetState(created, common.Hash{0x04}, common.Hash{0x04})
+	after, _ := state.Commit(false)
+
+	diff, err := DiffStates(db, before, after)
+	if err != nil {
+		t.Fatalf("failed to diff states: %v", err)
+	}
+	slot := func(b byte) string { return common.Bytes2Hex(common.Hash{b}.Bytes()) }
+	want := map[string]DumpAccountDiff{
+		common.Bytes2Hex(changed.Bytes()): {
+			Before: &DumpAccountState{Balance: "2", CodeHash: common.Bytes2Hex(emptyCodeHash)},
+			After:  &DumpAccountState{Balance: "20", CodeHash: common.Bytes2Hex(emptyCodeHash)},
+			Storage: map[string]DumpStorageDiff{
+				slot(0x01): {Before: slot(0x01), After: slot(0x10)},
+				slot(0x02): {Before: slot(0x02)},
+				slot(0x03): {After: slot(0x30)},
+			},
+		},
+		common.Bytes2Hex(deleted.Bytes()): {
+			Before: &DumpAccountState{Balance: "0", Nonce: 3, CodeHash: common.Bytes2Hex(emptyCodeHash)},
+			Storage: map[string]DumpStorageDiff{
+				slot(0x03): {Before: slot(0x03)},
+			},
+		},
+		common.Bytes2Hex(created.Bytes()): {
+			After: &DumpAccountState{Balance: "0", CodeHash: common.Bytes2Hex(crypto.Keccak256([]byte{0x60}))},
+			Storage: map[string]DumpStorageDiff{
+				slot(0x04): {After: slot(0x04)},
+			},
+		},
+	}
+	if len(diff.Accounts) != len(want) {
+		t.Errorf("account count mismatch: have %d, want %d", len(diff.Accounts), len(want))
+	}
+	for addr, w := range want {
+		if have := diff.Accounts[addr]; !reflect.DeepEqual(have, w) {
+			t.Errorf("account %s: have %+v -> %+v %v, want %+v -> %+v %v", addr, have.Before, have.After, have.Storage, w.Before, w.After, w.Storage)
+		}
+	}
+	// Diffing a state with itself should yield nothing
+	if diff, err := DiffStates(db, after, after); err != nil || len(diff.Accounts) != 0 {
+		t.Errorf("self diff not empty: %v, %v", diff.Accounts, err)
+	}
+}
@@ -19,12 +19,22 @@ package state
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 
 	"github.com/gclchaineum/go-gclchaineum/common"
+	"github.com/gclchaineum/go-gclchaineum/common/hexutil"
 	"github.com/gclchaineum/go-gclchaineum/rlp"
 	"github.com/gclchaineum/go-gclchaineum/trie"
 )
 
+// DumpConfig selects which parts of the state are dumped.
+type DumpConfig struct {
+	SkipCode    bool   // Leave out the contract codes
+	SkipStorage bool   // Leave out the contract storages
+	Start       []byte // Hashed account key to start the dump at (inclusive)
+	Max         int    // Maximum number of accounts to dump (0 = unlimited)
+}
+
 type DumpAccount struct {
 	Balance  string            `json:"balance"`
 	Nonce    uint64            `json:"nonce"`
@@ -32,6 +42,8 @@ type DumpAccount struct {
 	CodeHash string            `json:"codeHash"`
 	Code     string            `json:"code"`
 	Storage  map[string]string `json:"storage"`
+	Address  *common.Address   `json:"address,omitempty"` // Set only in iterative dumps, nil if the preimage is unknown
+	Key      hexutil.Bytes     `json:"key,omitempty"`     // Hashed account key, set only in iterative dumps
 }
 
 type Dump struct {
@@ -39,14 +51,87 @@ type Dump struct {
 	Accounts map[string]DumpAccount `json:"accounts"`
 }
 
-func (self *StateDB) RawDump() Dump {
-	dump := Dump{
-		Root:     fmt.Sprintf("%x", self.trie.Hash()),
-		Accounts: make(map[string]DumpAccount),
+// IteratorDump is a page of the accounts in a state. Next is the hashed key of
+// the first account not included in the page, if any.
+type IteratorDump struct {
+	Root     string                 `json:"root"`
+	Accounts map[string]DumpAccount `json:"accounts"`
+	Next     hexutil.Bytes          `json:"next,omitempty"`
+}
+
+// dumpCollector receives the root and the accounts of a dumped state.
+type dumpCollector interface {
+	onRoot(root common.Hash) error
+	onAccount(key, addr []byte, account DumpAccount) error
+}
+
+func (d *Dump) onRoot(root common.Hash) error {
+	d.Root = fmt.Sprintf("%x", root)
+	return nil
+}
+
+func (d *Dump) onAccount(key, addr []byte, account DumpAccount) error {
+	d.Accounts[common.Bytes2Hex(addr)] = account
+	return nil
+}
+
+func (d *IteratorDump) onRoot(root common.Hash) error {
+	d.Root = fmt.Sprintf("%x", root)
+	return nil
+}
+
+func (d *IteratorDump) onAccount(key, addr []byte, account DumpAccount) error {
+	d.Accounts[common.Bytes2Hex(addr)] = account
+	return nil
+}
+
+// iterativeDump writes the dumped state as a stream of JSON objects, one per
+// line: the root first, followed by the accounts with their hashed keys and, if
+// the dump was cut short, the key to continue from.
+type iterativeDump struct {
+	*json.Encoder
+}
+
+func (d iterativeDump) onRoot(root common.Hash) error {
+	return d.Encode(struct {
+		Root string `json:"root"`
+	}{fmt.Sprintf("%x", root)})
+}
+
+func (d iterativeDump) onAccount(key, addr []byte, account DumpAccount) error {
+	if addr != nil {
+		address := common.BytesToAddress(addr)
+		account.Address = &address
+	}
+	account.Key = key
+	return d.Encode(account)
+}
+
+func (d iterativeDump) onNext(next []byte) error {
+	return d.Encode(struct {
+		Next hexutil.Bytes `json:"next"`
+	}{next})
+}
+
+// dump iterates over the accounts of the state selected by conf, feeding them
+// into the collector. It returns the hashed key of the next account when the
+// configured maximum is reached before the end of the state.
+func (self *StateDB) dump(c dumpCollector, conf *DumpConfig) ([]byte, error) {
+	if conf == nil {
+		conf = new(DumpConfig)
+	}
+	if err := c.onRoot(self.trie.Hash()); err != nil {
+		return nil, err
 	}
 
-	it := trie.NewIterator(self.trie.NodeIterator(nil))
+	var (
+		count int
+		it    = trie.NewIterator(self.trie.NodeIterator(conf.Start))
+	)
 	for it.Next() {
+		if conf.Max > 0 && count == conf.Max {
+			return common.CopyBytes(it.Key), nil
+		}
 		addr := self.trie.GetKey(it.Key)
 		var data Account
 		if err := rlp.DecodeBytes(it.Value, &data); err != nil {
@@ -59,18 +144,57 @@ func (self *StateDB) RawDump() Dump {
 			Nonce:    data.Nonce,
 			Root:     common.Bytes2Hex(data.Root[:]),
 			CodeHash: common.Bytes2Hex(data.CodeHash),
-			Code:     common.Bytes2Hex(obj.Code(self.db)),
-			Storage:  make(map[string]string),
 		}
-		storageIt := trie.NewIterator(obj.getTrie(self.db).NodeIterator(nil))
-		for storageIt.Next() {
-			account.Storage[common.Bytes2Hex(self.trie.GetKey(storageIt.Key))] = common.Bytes2Hex(storageIt.Value)
+		if !conf.SkipCode {
+			account.Code = common.Bytes2Hex(obj.Code(self.db))
+		}
+		if !conf.SkipStorage {
+			account.Storage = make(map[string]string)
+			storageIt := trie.NewIterator(obj.getTrie(self.db).NodeIterator(nil))
+			for storageIt.Next() {
+				account.Storage[common.Bytes2Hex(self.trie.GetKey(storageIt.Key))] = common.Bytes2Hex(storageIt.Value)
+			}
+		}
+		if err := c.onAccount(common.CopyBytes(it.Key), addr, account); err != nil {
+			return nil, err
 		}
-		dump.Accounts[common.Bytes2Hex(addr)] = account
+		count++
+	}
+	return nil, it.Err
+}
+
+// RawDump returns the entire state as a single object.
+func (self *StateDB) RawDump() Dump {
+	dump := Dump{
+		Accounts: make(map[string]DumpAccount),
 	}
+	self.dump(&dump, nil)
 	return dump
 }
 
+// IteratorDump returns the part of the state selected by conf, along with the
+// key to continue from in a subsequent call.
+func (self *StateDB) IteratorDump(conf *DumpConfig) (IteratorDump, error) {
+	dump := IteratorDump{
+		Accounts: make(map[string]DumpAccount),
+	}
+	next, err := self.dump(&dump, conf)
+	dump.Next = next
+	return dump, err
+}
+
+// IterativeDump streams the part of the state selected by conf into output,
+// one JSON object per line, without holding it in memory. If the configured
+// maximum is reached, the last line holds the key to continue from.
+func (self *StateDB) IterativeDump(conf *DumpConfig, output io.Writer) error {
+	dump := iterativeDump{json.NewEncoder(output)}
+	next, err := self.dump(dump, conf)
+	if err != nil || next == nil {
+		return err
+	}
+	return dump.onNext(next)
+}
+
 func (self *StateDB) Dump() []byte {
 	json, err := json.MarshalIndent(self.RawDump(), "", "    ")
 	if err != nil {
@@ -0,0 +1,193 @@
+// Copyright 2019 The go-gclchaineum Authors
+// This file is part of the go-gclchaineum library.
+//
+// The go-gclchaineum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-gclchaineum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-gclchaineum library. If not, see <http://www.gnu.org/licenses/>.
+
+package pruner
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+
+	"github.com/gclchaineum/go-gclchaineum/common"
+)
+
+// bloomMagic is the file header identifying a committed state bloom.
+var bloomMagic = []byte("gclstatebloom")
+
+// errBloomCorrupted is returned if a committed state bloom cannot be decoded.
+var errBloomCorrupted = errors.New("corrupted state bloom")
+
+// stateBloom is a bloom filter used during the state pruning to record all
+// trie nodes and contract codes belonging to the retained states. Any item
+// missing from the filter can be safely deleted, while false positives only
+// result in some stale data being kept around.
+//
+// Since the inserted items are all cryptographic hashes, the filter doesn't
+// rehash them but uses four 64 bit chunks of the hash as the bit positions.
+type stateBloom struct {
+	bits  []uint64      // Bit vector of the filter
+	roots []common.Hash // State roots the filter was generated for
+}
+
+// newStateBloomWithSize creates a state bloom filter of the given size in
+// megabytes.
+func newStateBloomWithSize(size uint64) *stateBloom {
+	if size == 0 {
+		size = 1
+	}
+	return &stateBloom{bits: make([]uint64, size*1024*1024/8)}
+}
+
+// positions returns the bit positions a hash maps to.
+func (bloom *stateBloom) positions(hash common.Hash) [4]uint64 {
+	var (
+		pos  [4]uint64
+		size = uint64(len(bloom.bits)) * 64
+	)
+	for i := 0; i < 4; i++ {
+		pos[i] = binary.BigEndian.Uint64(hash[i*8:]) % size
+	}
+	return pos
+}
+
+// Add marks a hash as present in the filter.
+func (bloom *stateBloom) Add(hash common.Hash) {
+	for _, pos := range bloom.positions(hash) {
+		bloom.bits[pos/64] |= 1 << (pos % 64)
+	}
+}
+
+// Contain reports whether the hash may be present in the filter. False
+// positives are possible, false negatives are not.
+func (bloom *stateBloom) Contain(hash common.Hash) bool {
+	for _, pos := range bloom.positions(hash) {
+		if bloom.bits[pos/64]&(1<<(pos%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Commit flushes the bloom filter to disk, tagging it with the state roots it
+// was generated for. The file is written to a temporary location first and
+// then atomically moved into place, so a crash can never leave a partially
+// written filter behind.
+func (bloom *stateBloom) Commit(filename string) error {
+	tmp := filename + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	if err := bloom.encode(w); err != nil {
+		f.Close()
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filename)
+}
+
+// encode serializes the filter into the given writer.
+func (bloom *stateBloom) encode(w io.Writer) error {
+	buf := make([]byte, 8)
+	if _, err := w.Write(bloomMagic); err != nil {
+		return err
+	}
+	binary.BigEndian.PutUint64(buf, uint64(len(bloom.roots)))
+	if _, err := w.Write(buf); err != nil {
+		return err
+	}
+	for _, root := range bloom.roots {
+		if _, err := w.Write(root[:]); err != nil {
+			return err
+		}
+	}
+	binary.BigEndian.PutUint64(buf, uint64(len(bloom.bits)))
+	if _, err := w.Write(buf); err != nil {
+		return err
+	}
+	for _, word := range bloom.bits {
+		binary.BigEndian.PutUint64(buf, word)
+		if _, err := w.Write(buf); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// newStateBloomFromDisk loads a previously committed state bloom filter.
+func newStateBloomFromDisk(filename string) (*stateBloom, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	stat, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	var (
+		size  = uint64(stat.Size())
+		r     = bufio.NewReader(f)
+		magic = make([]byte, len(bloomMagic))
+		buf   = make([]byte, 8)
+		bloom = new(stateBloom)
+	)
+	if _, err := io.ReadFull(r, magic); err != nil || string(magic) != string(bloomMagic) {
+		return nil, errBloomCorrupted
+	}
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return nil, errBloomCorrupted
+	}
+	roots := binary.BigEndian.Uint64(buf)
+	if header := uint64(len(bloomMagic)) + 16; size < header || roots > (size-header)/common.HashLength {
+		return nil, errBloomCorrupted
+	}
+	bloom.roots = make([]common.Hash, roots)
+	for i := range bloom.roots {
+		if _, err := io.ReadFull(r, bloom.roots[i][:]); err != nil {
+			return nil, errBloomCorrupted
+		}
+	}
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return nil, errBloomCorrupted
+	}
+	words := binary.BigEndian.Uint64(buf)
+	if words == 0 || uint64(len(bloomMagic))+16+roots*common.HashLength+words*8 != size {
+		return nil, errBloomCorrupted
+	}
+	bloom.bits = make([]uint64, words)
+	for i := range bloom.bits {
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, errBloomCorrupted
+		}
+		bloom.bits[i] = binary.BigEndian.Uint64(buf)
+	}
+	return bloom, nil
+}
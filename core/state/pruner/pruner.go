@@ -0,0 +1,333 @@
+// Copyright 2019 The go-gclchaineum Authors
+// This file is part of the go-gclchaineum library.
+//
+// The go-gclchaineum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-gclchaineum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-gclchaineum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package pruner implements offline pruning of stale state trie nodes.
+package pruner
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/gclchaineum/go-gclchaineum/common"
+	"github.com/gclchaineum/go-gclchaineum/core/rawdb"
+	"github.com/gclchaineum/go-gclchaineum/core/state"
+	"github.com/gclchaineum/go-gclchaineum/crypto"
+	"github.com/gclchaineum/go-gclchaineum/gcldb"
+	"github.com/gclchaineum/go-gclchaineum/log"
+	"github.com/gclchaineum/go-gclchaineum/rlp"
+	"github.com/gclchaineum/go-gclchaineum/trie"
+)
+
+const (
+	// bloomFilterName is the filename of the state bloom filter persisted
+	// between the marking and the sweeping phases of the pruning.
+	bloomFilterName = "statepruning.bf"
+
+	// DefaultRetain is the default number of recent block states kept by the
+	// pruner.
+	DefaultRetain = 128
+
+	// DefaultBloomSize is the default size of the state bloom filter in
+	// megabytes.
+	DefaultBloomSize = 2048
+)
+
+var (
+	// emptyRoot is the known root hash of an empty trie.
+	emptyRoot = common.HexToHash("56e81f171bcc55a6ff8345e692c0f86e5b48e01b996cadc001622fb5e363b421")
+
+	// emptyCode is the known hash of the empty EVM bytecode.
+	emptyCode = crypto.Keccak256(nil)
+
+	// errNoState is returned if none of the blocks to retain has its state
+	// available in the database.
+	errNoState = errors.New("no retainable state available")
+)
+
+// Pruner is an offline tool to prune the stale state with the help of a bloom
+// filter. The pruner marks every trie node and contract code reachable from
+// the state roots of the most recent blocks, then sweeps the database deleting
+// everything that wasn't marked.
+//
+// The marking result is committed to disk before anything is deleted, so the
+// pruning can always be resumed (with RecoverPruning) if it is interrupted in
+// the sweeping phase. An interruption in the marking phase is harmless, as no
+// data has been deleted yet.
+type Pruner struct {
+	db        gcldb.Database
+	datadir   string
+	bloomSize uint64
+	retain    uint64
+}
+
+// NewPruner creates a state pruner that retains the states of the last retain
+// blocks, using a bloom filter of bloomSize megabytes stored in datadir.
+func NewPruner(db gcldb.Database, datadir string, bloomSize uint64, retain uint64) *Pruner {
+	if retain == 0 {
+		retain = 1
+	}
+	return &Pruner{
+		db:        db,
+		datadir:   datadir,
+		bloomSize: bloomSize,
+		retain:    retain,
+	}
+}
+
+// Prune deletes all state trie nodes and contract codes that aren't reachable
+// from the retained block states. If a previous pruning was interrupted, it is
+// completed instead of starting a new one.
+func (p *Pruner) Prune() error {
+	filename := filepath.Join(p.datadir, bloomFilterName)
+	if common.FileExist(filename) {
+		log.Info("Resuming interrupted state pruning")
+		return RecoverPruning(p.datadir, p.db)
+	}
+	// Gather the state roots of the recent blocks that are available on disk
+	roots, err := p.retainedRoots()
+	if err != nil {
+		return err
+	}
+	// Mark every trie node and code reachable from the retained roots. The roots
+	// are processed oldest to newest, the first one being iterated fully and the
+	// rest only by their difference to the previous one.
+	var (
+		start  = time.Now()
+		bloom  = newStateBloomWithSize(p.bloomSize)
+		triedb = trie.NewDatabase(p.db)
+	)
+	for i := len(roots) - 1; i >= 0; i-- {
+		if i == len(roots)-1 {
+			if err := markState(p.db, roots[i], bloom); err != nil {
+				return err
+			}
+		} else {
+			if err := markStateDiff(triedb, roots[i+1], roots[i], bloom); err != nil {
+				return err
+			}
+		}
+		log.Info("Marked retained state", "root", roots[i], "elapsed", common.PrettyDuration(time.Since(start)))
+	}
+	bloom.roots = roots
+
+	// Persist the marking result so an interrupted sweep can be resumed
+	if err := bloom.Commit(filename); err != nil {
+		return err
+	}
+	log.Info("State bloom filter committed", "name", filename)
+
+	return sweep(p.db, bloom, filename)
+}
+
+// retainedRoots collects the state roots of the last retained blocks, newest
+// first, skipping any block whose state isn't persisted to disk.
+func (p *Pruner) retainedRoots() ([]common.Hash, error) {
+	headHash := rawdb.ReadHeadBlockHash(p.db)
+	if headHash == (common.Hash{}) {
+		return nil, errors.New("head block not found")
+	}
+	number := rawdb.ReadHeaderNumber(p.db, headHash)
+	if number == nil {
+		return nil, fmt.Errorf("head block %x number missing", headHash)
+	}
+	var (
+		roots []common.Hash
+		seen  = make(map[common.Hash]bool)
+	)
+	for n := *number; *number-n < p.retain; n-- {
+		hash := rawdb.ReadCanonicalHash(p.db, n)
+		if header := rawdb.ReadHeader(p.db, hash, n); header != nil && !seen[header.Root] {
+			if _, err := state.New(header.Root, state.NewDatabase(p.db)); err == nil {
+				roots = append(roots, header.Root)
+				seen[header.Root] = true
+			} else {
+				log.Debug("Skipping unavailable state", "number", n, "root", header.Root)
+			}
+		}
+		if n == 0 {
+			break
+		}
+	}
+	if len(roots) == 0 {
+		return nil, errNoState
+	}
+	log.Info("Selected states to retain", "head", *number, "blocks", p.retain, "states", len(roots))
+	return roots, nil
+}
+
+// markState adds every trie node and contract code of a state to the bloom.
+func markState(db gcldb.Database, root common.Hash, bloom *stateBloom) error {
+	statedb, err := state.New(root, state.NewDatabase(db))
+	if err != nil {
+		return err
+	}
+	var (
+		nodes  int
+		logged = time.Now()
+	)
+	it := state.NewNodeIterator(statedb)
+	for it.Next() {
+		if it.Hash != (common.Hash{}) {
+			bloom.Add(it.Hash)
+			nodes++
+		}
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Marking state", "root", root, "nodes", nodes)
+			logged = time.Now()
+		}
+	}
+	return it.Error
+}
+
+// markStateDiff adds every trie node and contract code of a state that isn't
+// also part of an already marked parent state to the bloom.
+func markStateDiff(triedb *trie.Database, parent common.Hash, root common.Hash, bloom *stateBloom) error {
+	oldTrie, err := trie.New(parent, triedb)
+	if err != nil {
+		return err
+	}
+	newTrie, err := trie.New(root, triedb)
+	if err != nil {
+		return err
+	}
+	it, _ := trie.NewDifferenceIterator(oldTrie.NodeIterator(nil), newTrie.NodeIterator(nil))
+	for it.Next(true) {
+		if hash := it.Hash(); hash != (common.Hash{}) {
+			bloom.Add(hash)
+		}
+		if !it.Leaf() {
+			continue
+		}
+		// A new or modified account was found, mark its code and storage
+		var account state.Account
+		if err := rlp.DecodeBytes(it.LeafBlob(), &account); err != nil {
+			return err
+		}
+		if !bytes.Equal(account.CodeHash, emptyCode) {
+			bloom.Add(common.BytesToHash(account.CodeHash))
+		}
+		if account.Root == emptyRoot {
+			continue
+		}
+		prevRoot := emptyRoot
+		if blob, err := oldTrie.TryGet(it.LeafKey()); err != nil {
+			return err
+		} else if len(blob) > 0 {
+			var prev state.Account
+			if err := rlp.DecodeBytes(blob, &prev); err != nil {
+				return err
+			}
+			prevRoot = prev.Root
+		}
+		if prevRoot == account.Root {
+			continue
+		}
+		oldStorage, err := trie.New(prevRoot, triedb)
+		if err != nil {
+			return err
+		}
+		newStorage, err := trie.New(account.Root, triedb)
+		if err != nil {
+			return err
+		}
+		sit, _ := trie.NewDifferenceIterator(oldStorage.NodeIterator(nil), newStorage.NodeIterator(nil))
+		for sit.Next(true) {
+			if hash := sit.Hash(); hash != (common.Hash{}) {
+				bloom.Add(hash)
+			}
+		}
+		if err := sit.Error(); err != nil {
+			return err
+		}
+	}
+	return it.Error()
+}
+
+// sweep deletes every trie node and contract code from the database that is
+// not contained in the bloom filter, removing the filter file when done.
+func sweep(db gcldb.Database, bloom *stateBloom, filename string) error {
+	var (
+		start   = time.Now()
+		logged  = time.Now()
+		count   int
+		size    common.StorageSize
+		batch   = db.NewBatch()
+		kvstore = rawdb.KeyValueStore(db)
+	)
+	it := kvstore.NewIterator()
+	defer it.Release()
+
+	for it.Next() {
+		key := it.Key()
+
+		// All state trie nodes and contract codes are keyed by their 32 byte
+		// hash, everything else in the database has a longer or shorter key.
+		if len(key) != common.HashLength {
+			continue
+		}
+		if bloom.Contain(common.BytesToHash(key)) {
+			continue
+		}
+		count++
+		size += common.StorageSize(len(key) + len(it.Value()))
+		if err := batch.Delete(key); err != nil {
+			return err
+		}
+		if batch.ValueSize() >= gcldb.IdealBatchSize {
+			if err := batch.Write(); err != nil {
+				return err
+			}
+			batch.Reset()
+		}
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Pruning state data", "nodes", count, "size", size, "elapsed", common.PrettyDuration(time.Since(start)))
+			logged = time.Now()
+		}
+	}
+	if err := it.Error(); err != nil {
+		return err
+	}
+	if err := batch.Write(); err != nil {
+		return err
+	}
+	// Everything was swept, the pruning is complete
+	if err := os.Remove(filename); err != nil {
+		return err
+	}
+	log.Info("Pruned state data", "nodes", count, "size", size, "elapsed", common.PrettyDuration(time.Since(start)))
+	return nil
+}
+
+// RecoverPruning completes a state pruning that was interrupted after the
+// marking phase. It must be called before anything else writes new state into
+// the database, otherwise the new data could be deleted. If no pruning was in
+// progress, it is a no-op.
+func RecoverPruning(datadir string, db gcldb.Database) error {
+	filename := filepath.Join(datadir, bloomFilterName)
+	if !common.FileExist(filename) {
+		return nil
+	}
+	bloom, err := newStateBloomFromDisk(filename)
+	if err != nil {
+		return err
+	}
+	log.Info("Resuming state pruning", "roots", len(bloom.roots))
+	return sweep(db, bloom, filename)
+}
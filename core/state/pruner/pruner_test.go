@@ -0,0 +1,159 @@
+// Copyright 2019 The go-gclchaineum Authors
+// This file is part of the go-gclchaineum library.
+//
+// The go-gclchaineum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-gclchaineum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-gclchaineum library. If not, see <http://www.gnu.org/licenses/>.
+
+package pruner
+
+import (
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gclchaineum/go-gclchaineum/common"
+	"github.com/gclchaineum/go-gclchaineum/core/rawdb"
+	"github.com/gclchaineum/go-gclchaineum/core/state"
+	"github.com/gclchaineum/go-gclchaineum/core/types"
+	"github.com/gclchaineum/go-gclchaineum/gcldb"
+)
+
+// makeTestChain creates a chain of headers in the database, each one referencing
+// a state where a few accounts, storage slots and codes were modified compared
+// to the parent.
+func makeTestChain(t *testing.T, db gcldb.Database, blocks int) []common.Hash {
+	var (
+		sdb   = state.NewDatabase(db)
+		root  common.Hash
+		roots []common.Hash
+	)
+	for i := 0; i < blocks; i++ {
+		statedb, err := state.New(root, sdb)
+		if err != nil {
+			t.Fatalf("block %d: failed to open state: %v", i, err)
+		}
+		for j := byte(0); j < 4; j++ {
+			addr := common.BytesToAddress([]byte{j})
+			statedb.AddBalance(addr, big.NewInt(int64(i+1)))
+			statedb.SetState(addr, common.Hash{j}, common.BigToHash(big.NewInt(int64(i))))
+		}
+		statedb.SetCode(common.BytesToAddress([]byte{0xff, byte(i)}), []byte{0x60, byte(i)})
+
+		if root, err = statedb.Commit(false); err != nil {
+			t.Fatalf("block %d: failed to commit state: %v", i, err)
+		}
+		if err := sdb.TrieDB().Commit(root, false); err != nil {
+			t.Fatalf("block %d: failed to flush state: %v", i, err)
+		}
+		header := &types.Header{Number: big.NewInt(int64(i)), Root: root}
+		rawdb.WriteHeader(db, header)
+		rawdb.WriteCanonicalHash(db, header.Hash(), uint64(i))
+		rawdb.WriteHeadBlockHash(db, header.Hash())
+
+		roots = append(roots, root)
+	}
+	return roots
+}
+
+// checkState iterates over the entire state and reports any missing data.
+func checkState(db gcldb.Database, root common.Hash) error {
+	statedb, err := state.New(root, state.NewDatabase(db))
+	if err != nil {
+		return err
+	}
+	it := state.NewNodeIterator(statedb)
+	for it.Next() {
+	}
+	return it.Error
+}
+
+// Tests that pruning retains the recent states intact and deletes the stale
+// ones.
+func TestPruneState(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pruner")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db := gcldb.NewMemDatabase()
+	roots := makeTestChain(t, db, 8)
+
+	if err := NewPruner(db, dir, 1, 3).Prune(); err != nil {
+		t.Fatalf("failed to prune state: %v", err)
+	}
+	for i, root := range roots[len(roots)-3:] {
+		if err := checkState(db, root); err != nil {
+			t.Errorf("retained state %d: %v", i, err)
+		}
+	}
+	for i, root := range roots[:len(roots)-3] {
+		if err := checkState(db, root); err == nil {
+			t.Errorf("stale state %d still available", i)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, bloomFilterName)); !os.IsNotExist(err) {
+		t.Errorf("bloom filter not removed: %v", err)
+	}
+}
+
+// Tests that an interrupted pruning is completed from the committed bloom
+// filter, using the retained roots from the time of the marking.
+func TestRecoverPruning(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pruner")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db := gcldb.NewMemDatabase()
+	roots := makeTestChain(t, db, 4)
+
+	// Simulate a crash right after the marking phase of the oldest state
+	bloom := newStateBloomWithSize(1)
+	if err := markState(db, roots[0], bloom); err != nil {
+		t.Fatalf("failed to mark state: %v", err)
+	}
+	bloom.roots = roots[:1]
+
+	filename := filepath.Join(dir, bloomFilterName)
+	if err := bloom.Commit(filename); err != nil {
+		t.Fatalf("failed to commit bloom: %v", err)
+	}
+	loaded, err := newStateBloomFromDisk(filename)
+	if err != nil {
+		t.Fatalf("failed to load bloom: %v", err)
+	}
+	if len(loaded.roots) != 1 || loaded.roots[0] != roots[0] {
+		t.Fatalf("bloom roots mismatch: have %x, want %x", loaded.roots, roots[:1])
+	}
+	// Recovery must finish the sweep even though the head moved on
+	if err := RecoverPruning(dir, db); err != nil {
+		t.Fatalf("failed to recover pruning: %v", err)
+	}
+	if err := checkState(db, roots[0]); err != nil {
+		t.Errorf("retained state: %v", err)
+	}
+	if err := checkState(db, roots[len(roots)-1]); err == nil {
+		t.Errorf("unmarked state still available")
+	}
+	if common.FileExist(filename) {
+		t.Errorf("bloom filter not removed")
+	}
+	// With no pruning in progress, recovery is a no-op
+	if err := RecoverPruning(dir, db); err != nil {
+		t.Errorf("failed to recover without pending pruning: %v", err)
+	}
+}
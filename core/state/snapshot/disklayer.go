@@ -0,0 +1,290 @@
+// Copyright 2019 The go-gclchaineum Authors
+// This file is part of the go-gclchaineum library.
+//
+// The go-gclchaineum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-gclchaineum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-gclchaineum library. If not, see <http://www.gnu.org/licenses/>.
+
+package snapshot
+
+import (
+	"bytes"
+	"sync"
+	"time"
+
+	"github.com/allegro/bigcache"
+	"github.com/gclchaineum/go-gclchaineum/common"
+	"github.com/gclchaineum/go-gclchaineum/core/rawdb"
+	"github.com/gclchaineum/go-gclchaineum/gcldb"
+	"github.com/gclchaineum/go-gclchaineum/log"
+	"github.com/gclchaineum/go-gclchaineum/trie"
+)
+
+// diskLayer is a low level persistent snapshot built on top of a key-value store.
+type diskLayer struct {
+	diskdb gcldb.Database     // Key-value store containing the base snapshot
+	triedb *trie.Database     // Trie node cache for reconstruction purposes
+	cache  *bigcache.BigCache // Cache to avoid hitting the disk for direct access
+
+	root  common.Hash // Root hash of the base snapshot
+	stale bool        // Signals that the layer became stale (state progressed)
+
+	genMarker  []byte             // Marker for the state that's indexed during initial layer generation
+	genPending <-chan struct{}    // Notification channel when the old snapshot data is wiped
+	genAbort   chan chan struct{} // Notification channel to abort generating the snapshot in this layer
+
+	lock sync.RWMutex
+}
+
+// newCleanCache creates the read cache of the disk layer with the given size in
+// megabytes, or nil if caching is disabled.
+func newCleanCache(size int) *bigcache.BigCache {
+	if size <= 0 {
+		return nil
+	}
+	cache, _ := bigcache.NewBigCache(bigcache.Config{
+		Shards:             1024,
+		LifeWindow:         time.Hour,
+		MaxEntriesInWindow: size * 1024,
+		MaxEntrySize:       512,
+		HardMaxCacheSize:   size,
+	})
+	return cache
+}
+
+// Root returns root hash for which this snapshot was made.
+func (dl *diskLayer) Root() common.Hash {
+	return dl.root
+}
+
+// Parent always returns nil as there's no layer below the disk.
+func (dl *diskLayer) Parent() snapshot {
+	return nil
+}
+
+// Stale return whether this layer has become stale (was flattened across) or if
+// it's still live.
+func (dl *diskLayer) Stale() bool {
+	dl.lock.RLock()
+	defer dl.lock.RUnlock()
+
+	return dl.stale
+}
+
+// covered reports whether the snapshot generation already reached the given
+// account hash. The caller must hold the layer lock.
+func (dl *diskLayer) covered(hash common.Hash) bool {
+	return dl.genMarker == nil || bytes.Compare(hash[:], dl.genMarker) <= 0
+}
+
+// AccountRLP directly retrieves the account RLP associated with a particular
+// hash in the snapshot.
+func (dl *diskLayer) AccountRLP(hash common.Hash) ([]byte, error) {
+	dl.lock.RLock()
+	defer dl.lock.RUnlock()
+
+	// If the layer was flattened into, consider it invalid (any live reference to
+	// the original should be marked as unusable).
+	if dl.stale {
+		return nil, ErrSnapshotStale
+	}
+	// If the layer is being generated, ensure the requested hash has already been
+	// covered by the generator.
+	if !dl.covered(hash) {
+		return nil, ErrNotCoveredYet
+	}
+	// Try to retrieve the account from the memory cache
+	if dl.cache != nil {
+		if blob, err := dl.cache.Get(string(hash[:])); err == nil {
+			snapshotCleanAccountHitMeter.Mark(1)
+			if len(blob) == 0 {
+				return nil, nil
+			}
+			return blob, nil
+		}
+		snapshotCleanAccountMissMeter.Mark(1)
+	}
+	// Cache doesn't contain account, pull from disk and cache for later
+	blob := rawdb.ReadAccountSnapshot(dl.diskdb, hash)
+	if dl.cache != nil {
+		dl.cache.Set(string(hash[:]), blob)
+	}
+	if len(blob) == 0 {
+		return nil, nil
+	}
+	return blob, nil
+}
+
+// Storage directly retrieves the storage data associated with a particular hash,
+// within a particular account.
+func (dl *diskLayer) Storage(accountHash, storageHash common.Hash) ([]byte, error) {
+	dl.lock.RLock()
+	defer dl.lock.RUnlock()
+
+	// If the layer was flattened into, consider it invalid (any live reference to
+	// the original should be marked as unusable).
+	if dl.stale {
+		return nil, ErrSnapshotStale
+	}
+	// If the layer is being generated, ensure the requested account (and all of
+	// its storage) has already been covered by the generator.
+	if !dl.covered(accountHash) {
+		return nil, ErrNotCoveredYet
+	}
+	key := string(append(accountHash[:], storageHash[:]...))
+
+	// Try to retrieve the storage slot from the memory cache
+	if dl.cache != nil {
+		if blob, err := dl.cache.Get(key); err == nil {
+			snapshotCleanStorageHitMeter.Mark(1)
+			if len(blob) == 0 {
+				return nil, nil
+			}
+			return blob, nil
+		}
+		snapshotCleanStorageMissMeter.Mark(1)
+	}
+	// Cache doesn't contain storage slot, pull from disk and cache for later
+	blob := rawdb.ReadStorageSnapshot(dl.diskdb, accountHash, storageHash)
+	if dl.cache != nil {
+		dl.cache.Set(key, blob)
+	}
+	if len(blob) == 0 {
+		return nil, nil
+	}
+	return blob, nil
+}
+
+// Update returns a new snapshot layer by applying the given changes on top of
+// the persistent layer.
+func (dl *diskLayer) Update(blockRoot common.Hash, destructs map[common.Hash]struct{}, accounts map[common.Hash][]byte, storage map[common.Hash]map[common.Hash][]byte) *diffLayer {
+	return newDiffLayer(dl, blockRoot, destructs, accounts, storage)
+}
+
+// abortGeneration stops the background generator of the layer, if any, waiting
+// until it persists its progress.
+func (dl *diskLayer) abortGeneration() {
+	if dl.genAbort == nil {
+		return
+	}
+	abort := make(chan struct{})
+	dl.genAbort <- abort
+	<-abort
+
+	dl.genAbort = nil
+}
+
+// diffToDisk merges a bottom-most diff into the persistent disk layer underneath
+// it. The old disk layer and the diff layer are both marked stale and the new
+// disk layer is returned. If the snapshot is still being generated, only the
+// already covered range is written and the generator is resumed on the new root.
+func diffToDisk(bottom *diffLayer) *diskLayer {
+	var (
+		base  = bottom.Parent().(*diskLayer)
+		batch = base.diskdb.NewBatch()
+	)
+	// Stop the generator so it doesn't write concurrently, then invalidate the
+	// base layer before touching the database.
+	base.abortGeneration()
+
+	base.lock.Lock()
+	if base.stale {
+		panic("parent disk layer is stale") // we've committed into the same base from two children, boo
+	}
+	base.stale = true
+	marker := base.genMarker
+	base.lock.Unlock()
+
+	covered := func(hash common.Hash) bool {
+		return marker == nil || bytes.Compare(hash[:], marker) <= 0
+	}
+	flush := func() {
+		if batch.ValueSize() < gcldb.IdealBatchSize {
+			return
+		}
+		if err := batch.Write(); err != nil {
+			log.Crit("Failed to write snapshot", "err", err)
+		}
+		batch.Reset()
+	}
+	// Invalidate the persisted root until the flattening is complete, so a crash
+	// midway forces a regeneration instead of leaving a corrupted snapshot.
+	rawdb.DeleteSnapshotRoot(batch)
+
+	// Delete all the accounts destructed in the diff, together with their storage
+	for hash := range bottom.destructSet {
+		if !covered(hash) {
+			continue
+		}
+		rawdb.DeleteAccountSnapshot(batch, hash)
+		if base.cache != nil {
+			base.cache.Set(string(hash[:]), nil)
+		}
+		it := rawdb.IterateStorageSnapshots(base.diskdb, hash)
+		for it.Next() {
+			key := it.Key()
+			batch.Delete(key)
+			if base.cache != nil {
+				base.cache.Delete(string(key[len(rawdb.SnapshotStoragePrefix):]))
+			}
+			flush()
+		}
+		it.Release()
+	}
+	// Push all updated accounts and storage slots into the database
+	for hash, data := range bottom.accountData {
+		if !covered(hash) {
+			continue
+		}
+		rawdb.WriteAccountSnapshot(batch, hash, data)
+		if base.cache != nil {
+			base.cache.Set(string(hash[:]), data)
+		}
+		flush()
+	}
+	for accountHash, storage := range bottom.storageData {
+		if !covered(accountHash) {
+			continue
+		}
+		for storageHash, data := range storage {
+			if len(data) > 0 {
+				rawdb.WriteStorageSnapshot(batch, accountHash, storageHash, data)
+			} else {
+				rawdb.DeleteStorageSnapshot(batch, accountHash, storageHash)
+			}
+			if base.cache != nil {
+				base.cache.Set(string(append(accountHash[:], storageHash[:]...)), data)
+			}
+		}
+		flush()
+	}
+	// Update the snapshot block marker and write any remainder data
+	rawdb.WriteSnapshotRoot(batch, bottom.root)
+	if err := batch.Write(); err != nil {
+		log.Crit("Failed to write leftover snapshot", "err", err)
+	}
+	res := &diskLayer{
+		diskdb:     base.diskdb,
+		triedb:     base.triedb,
+		cache:      base.cache,
+		root:       bottom.root,
+		genMarker:  marker,
+		genPending: base.genPending,
+	}
+	// If snapshot generation hasn't finished yet, continue it on the new root
+	if marker != nil {
+		res.genAbort = make(chan chan struct{})
+		go res.generate()
+	}
+	bottom.markStale()
+	return res
+}
@@ -0,0 +1,279 @@
+// Copyright 2019 The go-gclchaineum Authors
+// This file is part of the go-gclchaineum library.
+//
+// The go-gclchaineum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-gclchaineum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-gclchaineum library. If not, see <http://www.gnu.org/licenses/>.
+
+package snapshot
+
+import (
+	"bytes"
+	"math/big"
+	"time"
+
+	"github.com/gclchaineum/go-gclchaineum/common"
+	"github.com/gclchaineum/go-gclchaineum/core/rawdb"
+	"github.com/gclchaineum/go-gclchaineum/gcldb"
+	"github.com/gclchaineum/go-gclchaineum/log"
+	"github.com/gclchaineum/go-gclchaineum/rlp"
+	"github.com/gclchaineum/go-gclchaineum/trie"
+)
+
+// emptyRoot is the known root hash of an empty trie.
+var emptyRoot = common.HexToHash("56e81f171bcc55a6ff8345e692c0f86e5b48e01b996cadc001622fb5e363b421")
+
+// account is the consensus representation of accounts, needed by the generator
+// to find the storage trie of each account.
+type account struct {
+	Nonce    uint64
+	Balance  *big.Int
+	Root     common.Hash
+	CodeHash []byte
+}
+
+// wiper is a background deletion of all the snapshot data in the database.
+type wiper struct {
+	abort chan struct{} // Channel to interrupt the deletion
+	done  chan struct{} // Channel closed when the deletion finished or was aborted
+}
+
+// wipeSnapshot starts a goroutine to iterate over the entire key-value database
+// and delete all the data associated with the snapshot (accounts and storage).
+func wipeSnapshot(db gcldb.Database) *wiper {
+	w := &wiper{
+		abort: make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+	go func() {
+		defer close(w.done)
+
+		start := time.Now()
+		if err := wipeKeyRange(db, rawdb.SnapshotAccountPrefix, len(rawdb.SnapshotAccountPrefix)+common.HashLength, w.abort); err != nil {
+			log.Error("Failed to wipe account snapshot", "err", err)
+			return
+		}
+		if err := wipeKeyRange(db, rawdb.SnapshotStoragePrefix, len(rawdb.SnapshotStoragePrefix)+2*common.HashLength, w.abort); err != nil {
+			log.Error("Failed to wipe storage snapshot", "err", err)
+			return
+		}
+		log.Info("Deleted state snapshot leftovers", "elapsed", common.PrettyDuration(time.Since(start)))
+	}()
+	return w
+}
+
+// stop interrupts the deletion and waits until it terminates.
+func (w *wiper) stop() {
+	select {
+	case <-w.abort:
+	default:
+		close(w.abort)
+	}
+	<-w.done
+}
+
+// wipeKeyRange deletes a range of keys from the database starting with prefix
+// and having a specific total key length. The length is needed to avoid deleting
+// trie nodes whose hashes happen to start with the same prefix.
+func wipeKeyRange(db gcldb.Database, prefix []byte, keylen int, abort chan struct{}) error {
+	batch := db.NewBatch()
+	it := db.NewIteratorWithPrefix(prefix)
+	defer it.Release()
+
+	for it.Next() {
+		if key := it.Key(); len(key) == keylen {
+			if err := batch.Delete(common.CopyBytes(key)); err != nil {
+				return err
+			}
+		}
+		if batch.ValueSize() >= gcldb.IdealBatchSize {
+			if err := batch.Write(); err != nil {
+				return err
+			}
+			batch.Reset()
+
+			select {
+			case <-abort:
+				return errAborted
+			default:
+			}
+		}
+	}
+	if err := it.Error(); err != nil {
+		return err
+	}
+	return batch.Write()
+}
+
+// generateSnapshot regenerates a brand new snapshot based on an existing state
+// database and head block asynchronously. The snapshot is returned immediately
+// and generation is continued in the background until done. The generation only
+// starts after the pending channel is closed, signalling that any old snapshot
+// data was deleted.
+func generateSnapshot(diskdb gcldb.Database, triedb *trie.Database, cache int, root common.Hash, pending <-chan struct{}) *diskLayer {
+	batch := diskdb.NewBatch()
+	rawdb.WriteSnapshotRoot(batch, root)
+	rawdb.WriteSnapshotGenerator(batch, []byte{})
+	if err := batch.Write(); err != nil {
+		log.Crit("Failed to write initialized state marker", "err", err)
+	}
+	base := &diskLayer{
+		diskdb:     diskdb,
+		triedb:     triedb,
+		cache:      newCleanCache(cache),
+		root:       root,
+		genMarker:  []byte{}, // Initialized but empty!
+		genPending: pending,
+		genAbort:   make(chan chan struct{}),
+	}
+	go base.generate()
+	return base
+}
+
+// generate is a background thread that iterates over the state and storage tries
+// and constructs a state snapshot. All the data retrieved is written into the
+// database, with the progress marker updated in the same batch so that an
+// interrupted generation can be resumed.
+//
+// The generator only gets interrupted between two accounts, so an account and
+// all of its storage are always covered at the same time.
+func (dl *diskLayer) generate() {
+	// Wait until the leftovers of any old snapshot are deleted
+	if dl.genPending != nil {
+		select {
+		case <-dl.genPending:
+		case abort := <-dl.genAbort:
+			abort <- struct{}{}
+			return
+		}
+	}
+	dl.lock.RLock()
+	marker := dl.genMarker
+	dl.lock.RUnlock()
+
+	var (
+		batch    = dl.diskdb.NewBatch()
+		start    = time.Now()
+		logged   = time.Now()
+		accounts uint64
+		slots    uint64
+	)
+	// commit flushes the batch to disk, moving the generation marker forward
+	commit := func() {
+		rawdb.WriteSnapshotGenerator(batch, marker)
+		if err := batch.Write(); err != nil {
+			log.Crit("Failed to write snapshot", "err", err)
+		}
+		batch.Reset()
+
+		dl.lock.Lock()
+		dl.genMarker = marker
+		dl.lock.Unlock()
+	}
+	// fail stops the generation, leaving the uncovered range to be served from
+	// the tries. The generator is restarted when the next diff is flattened.
+	fail := func(err error) {
+		commit()
+		log.Error("State snapshot generation failed", "root", dl.root, "at", common.BytesToHash(marker), "err", err)
+
+		abort := <-dl.genAbort
+		abort <- struct{}{}
+	}
+	accTrie, err := trie.New(dl.root, dl.triedb)
+	if err != nil {
+		fail(err)
+		return
+	}
+	it := trie.NewIterator(accTrie.NodeIterator(marker))
+	for it.Next() {
+		// The marker itself was already generated if resuming
+		if bytes.Equal(it.Key, marker) {
+			continue
+		}
+		accountHash := common.BytesToHash(it.Key)
+
+		var acc account
+		if err := rlp.DecodeBytes(it.Value, &acc); err != nil {
+			log.Crit("Invalid account encountered during snapshot creation", "err", err)
+		}
+		// Drop any storage leftover from an interrupted generation of the account
+		sit := rawdb.IterateStorageSnapshots(dl.diskdb, accountHash)
+		for sit.Next() {
+			batch.Delete(common.CopyBytes(sit.Key()))
+		}
+		sit.Release()
+
+		// Generate the storage of the account, then the account itself
+		if acc.Root != emptyRoot {
+			storeTrie, err := trie.New(acc.Root, dl.triedb)
+			if err != nil {
+				fail(err)
+				return
+			}
+			storeIt := trie.NewIterator(storeTrie.NodeIterator(nil))
+			for storeIt.Next() {
+				rawdb.WriteStorageSnapshot(batch, accountHash, common.BytesToHash(storeIt.Key), storeIt.Value)
+				slots++
+
+				// Flush large storages without moving the marker, the account is
+				// not covered until all of its slots are written.
+				if batch.ValueSize() >= gcldb.IdealBatchSize {
+					if err := batch.Write(); err != nil {
+						log.Crit("Failed to write snapshot", "err", err)
+					}
+					batch.Reset()
+				}
+			}
+			if storeIt.Err != nil {
+				fail(storeIt.Err)
+				return
+			}
+		}
+		rawdb.WriteAccountSnapshot(batch, accountHash, it.Value)
+		accounts++
+		marker = accountHash.Bytes()
+
+		// Persist the progress if the batch is large or the generation needs to stop
+		select {
+		case abort := <-dl.genAbort:
+			commit()
+			abort <- struct{}{}
+			return
+		default:
+		}
+		if batch.ValueSize() >= gcldb.IdealBatchSize {
+			commit()
+		}
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Generating state snapshot", "at", accountHash, "accounts", accounts, "slots", slots, "elapsed", common.PrettyDuration(time.Since(start)))
+			logged = time.Now()
+		}
+	}
+	if it.Err != nil {
+		fail(it.Err)
+		return
+	}
+	// Snapshot fully generated, set the marker to nil
+	rawdb.DeleteSnapshotGenerator(batch)
+	if err := batch.Write(); err != nil {
+		log.Crit("Failed to write snapshot", "err", err)
+	}
+	dl.lock.Lock()
+	dl.genMarker = nil
+	dl.lock.Unlock()
+
+	log.Info("Generated state snapshot", "accounts", accounts, "slots", slots, "elapsed", common.PrettyDuration(time.Since(start)))
+
+	// Someone will be looking for us, wait it out
+	abort := <-dl.genAbort
+	abort <- struct{}{}
+}
@@ -0,0 +1,314 @@
+// Copyright 2019 The go-gclchaineum Authors
+// This file is part of the go-gclchaineum library.
+//
+// The go-gclchaineum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-gclchaineum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-gclchaineum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package snapshot implements a flat, dynamic dump of the state for fast reads.
+package snapshot
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/gclchaineum/go-gclchaineum/common"
+	"github.com/gclchaineum/go-gclchaineum/core/rawdb"
+	"github.com/gclchaineum/go-gclchaineum/gcldb"
+	"github.com/gclchaineum/go-gclchaineum/log"
+	"github.com/gclchaineum/go-gclchaineum/metrics"
+	"github.com/gclchaineum/go-gclchaineum/trie"
+)
+
+var (
+	snapshotCleanAccountHitMeter  = metrics.NewRegisteredMeter("state/snapshot/clean/account/hit", nil)
+	snapshotCleanAccountMissMeter = metrics.NewRegisteredMeter("state/snapshot/clean/account/miss", nil)
+	snapshotCleanStorageHitMeter  = metrics.NewRegisteredMeter("state/snapshot/clean/storage/hit", nil)
+	snapshotCleanStorageMissMeter = metrics.NewRegisteredMeter("state/snapshot/clean/storage/miss", nil)
+
+	// ErrSnapshotStale is returned from data accessors if the underlying snapshot
+	// layer had been invalidated due to the chain progressing forward far enough
+	// to not maintain the layer's original state.
+	ErrSnapshotStale = errors.New("snapshot stale")
+
+	// ErrNotCoveredYet is returned from data accessors if the underlying snapshot
+	// is being generated currently and the requested data item is not yet in the
+	// range of accounts covered.
+	ErrNotCoveredYet = errors.New("not covered yet")
+
+	// errSnapshotCycle is returned if a snapshot is attempted to be inserted
+	// that forms a cycle in the snapshot tree.
+	errSnapshotCycle = errors.New("snapshot cycle")
+
+	// errAborted is returned if a background operation on the snapshot was
+	// interrupted.
+	errAborted = errors.New("aborted")
+)
+
+// Snapshot represents the functionality supported by a snapshot storage layer.
+type Snapshot interface {
+	// Root returns the root hash for which this snapshot was made.
+	Root() common.Hash
+
+	// AccountRLP directly retrieves the RLP encoded account associated with a
+	// particular hash in the snapshot. A nil result means the account does not
+	// exist.
+	AccountRLP(hash common.Hash) ([]byte, error)
+
+	// Storage directly retrieves the RLP encoded storage slot associated with a
+	// particular hash, within a particular account. A nil result means the slot
+	// is empty.
+	Storage(accountHash, storageHash common.Hash) ([]byte, error)
+}
+
+// snapshot is the internal version of the snapshot data layer that supports some
+// additional methods compared to the public API.
+type snapshot interface {
+	Snapshot
+
+	// Parent returns the subsequent layer of a snapshot, or nil if the base was
+	// reached.
+	Parent() snapshot
+
+	// Update creates a new layer on top of the existing snapshot diff tree with
+	// the specified data items.
+	Update(blockRoot common.Hash, destructs map[common.Hash]struct{}, accounts map[common.Hash][]byte, storage map[common.Hash]map[common.Hash][]byte) *diffLayer
+
+	// Stale returns whether this layer has become stale (was flattened across) or
+	// if it's still live.
+	Stale() bool
+}
+
+// Tree is a collection of all known layers of the state snapshot, arranged into
+// a tree on top of a single persistent disk layer. Diff layers are kept in
+// memory for the recent blocks and are flattened into the disk layer as the
+// chain progresses. Layers on abandoned side chains are dropped during the
+// flattening, so reorgs shallower than the in-memory layers are served from the
+// snapshot directly, while deeper ones require a rebuild.
+//
+// The goal of a state snapshot is twofold: to allow direct access to account and
+// storage data to avoid expensive multi-level trie lookups; and to allow sorted,
+// cheap iteration of the account/storage tries for sync aid.
+type Tree struct {
+	diskdb gcldb.Database           // Persistent database to store the snapshot
+	triedb *trie.Database           // In-memory cache to access the trie through
+	cache  int                      // Megabytes permitted to use for read caches
+	layers map[common.Hash]snapshot // Collection of all known layers
+	wiper  *wiper                   // Deletion of a previous snapshot in progress
+	lock   sync.RWMutex
+}
+
+// New attempts to load an already existing snapshot from a persistent key-value
+// store, ensuring that the root of the snapshot matches the expected one.
+//
+// If the snapshot is missing or inconsistent, the entirety is deleted and will
+// be reconstructed from scratch based on the tries in the key-value store, on a
+// background thread.
+func New(diskdb gcldb.Database, triedb *trie.Database, cache int, root common.Hash) *Tree {
+	snap := &Tree{
+		diskdb: diskdb,
+		triedb: triedb,
+		cache:  cache,
+		layers: make(map[common.Hash]snapshot),
+	}
+	base := loadSnapshot(diskdb, triedb, cache, root)
+	if base == nil {
+		snap.Rebuild(root)
+		return snap
+	}
+	if base.genMarker != nil {
+		base.genAbort = make(chan chan struct{})
+		go base.generate()
+	}
+	snap.layers[root] = base
+	return snap
+}
+
+// Snapshot retrieves a snapshot belonging to the given block root, or nil if no
+// snapshot is maintained for that block.
+func (t *Tree) Snapshot(blockRoot common.Hash) Snapshot {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	if layer, ok := t.layers[blockRoot]; ok {
+		return layer
+	}
+	return nil
+}
+
+// Update adds a new snapshot into the tree, if that can be linked to an existing
+// old parent. It is disallowed to insert a disk layer (the origin of all).
+func (t *Tree) Update(blockRoot common.Hash, parentRoot common.Hash, destructs map[common.Hash]struct{}, accounts map[common.Hash][]byte, storage map[common.Hash]map[common.Hash][]byte) error {
+	// Reject noop updates to avoid self-loops in the snapshot tree. This is a
+	// special case that can only happen for Clique networks where empty blocks
+	// don't modify the state (0 block subsidy).
+	if blockRoot == parentRoot {
+		return errSnapshotCycle
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	// The same state may be reached multiple times (e.g. a block being reimported),
+	// keep the existing layer in that case.
+	if _, ok := t.layers[blockRoot]; ok {
+		return nil
+	}
+	parent, ok := t.layers[parentRoot]
+	if !ok {
+		return fmt.Errorf("parent [%#x] snapshot missing", parentRoot)
+	}
+	t.layers[blockRoot] = parent.Update(blockRoot, destructs, accounts, storage)
+	return nil
+}
+
+// Cap traverses downwards the snapshot tree from a head block hash until the
+// number of allowed layers are crossed. All layers beyond the permitted number
+// are flattened downwards into the disk layer, and all layers not descending
+// from the new disk layer (i.e. abandoned side chains) are dropped.
+func (t *Tree) Cap(root common.Hash, layers int) error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	// Retrieve the head snapshot to cap from
+	snap, ok := t.layers[root]
+	if !ok {
+		return fmt.Errorf("snapshot [%#x] missing", root)
+	}
+	// Collect the diff layers from the head down to the disk layer
+	var path []*diffLayer
+	for layer := snap; ; {
+		diff, ok := layer.(*diffLayer)
+		if !ok {
+			break
+		}
+		path = append(path, diff)
+		layer = diff.Parent()
+	}
+	if len(path) <= layers {
+		return nil
+	}
+	// Flatten the excess layers into the disk layer, bottom first
+	var base *diskLayer
+	for len(path) > layers {
+		base = diffToDisk(path[len(path)-1])
+		path = path[:len(path)-1]
+		if len(path) > 0 {
+			path[len(path)-1].setParent(base)
+		}
+	}
+	// Drop all the layers that were flattened or ended up on a dead branch
+	remaining := map[common.Hash]snapshot{base.root: base}
+	for root, layer := range t.layers {
+		diff, ok := layer.(*diffLayer)
+		if !ok {
+			continue
+		}
+		if descendsFrom(diff, base) {
+			remaining[root] = diff
+		} else {
+			diff.markStale()
+		}
+	}
+	t.layers = remaining
+	return nil
+}
+
+// descendsFrom reports whether the diff layer is still a live descendant of the
+// given disk layer.
+func descendsFrom(diff *diffLayer, base *diskLayer) bool {
+	var layer snapshot = diff
+	for {
+		if layer.Stale() {
+			return false
+		}
+		diff, ok := layer.(*diffLayer)
+		if !ok {
+			return layer == snapshot(base)
+		}
+		layer = diff.Parent()
+	}
+}
+
+// Rebuild wipes all available snapshot data from the persistent database and
+// discards all caches and diff layers. Afterwards, it starts a new snapshot
+// generator with the given root hash.
+func (t *Tree) Rebuild(root common.Hash) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	// Invalidate all the current layers, stopping any running generation
+	for _, layer := range t.layers {
+		switch layer := layer.(type) {
+		case *diskLayer:
+			layer.abortGeneration()
+			layer.lock.Lock()
+			layer.stale = true
+			layer.lock.Unlock()
+
+		case *diffLayer:
+			layer.markStale()
+		}
+	}
+	// Start generating a new snapshot from scratch on a background thread. The
+	// generator will wait until the old data is wiped from the database.
+	if t.wiper != nil {
+		t.wiper.stop()
+	}
+	log.Info("Rebuilding state snapshot", "root", root)
+	t.wiper = wipeSnapshot(t.diskdb)
+	t.layers = map[common.Hash]snapshot{
+		root: generateSnapshot(t.diskdb, t.triedb, t.cache, root, t.wiper.done),
+	}
+}
+
+// Close stops all background processing of the snapshot tree. The persisted
+// disk layer is retained and reused on the next startup if it matches the chain
+// head at that time, so callers should flatten the diff layers beforehand.
+func (t *Tree) Close() {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	for _, layer := range t.layers {
+		if base, ok := layer.(*diskLayer); ok {
+			base.abortGeneration()
+		}
+	}
+	if t.wiper != nil {
+		t.wiper.stop()
+		t.wiper = nil
+	}
+}
+
+// loadSnapshot loads the persisted disk layer if its root matches the expected
+// one. Nil is returned if the snapshot is missing, mismatched or was not yet
+// cleaned up from a previous generation.
+func loadSnapshot(diskdb gcldb.Database, triedb *trie.Database, cache int, root common.Hash) *diskLayer {
+	if baseRoot := rawdb.ReadSnapshotRoot(diskdb); baseRoot != root {
+		if baseRoot != (common.Hash{}) {
+			log.Warn("State snapshot is not for the chain head", "snapshot", baseRoot, "head", root)
+		}
+		return nil
+	}
+	marker := rawdb.ReadSnapshotGenerator(diskdb)
+	if marker != nil && len(marker) == 0 {
+		return nil // generation didn't start, the wipe of the old data might be pending
+	}
+	log.Info("Loaded state snapshot", "root", root, "complete", marker == nil)
+	return &diskLayer{
+		diskdb:    diskdb,
+		triedb:    triedb,
+		cache:     newCleanCache(cache),
+		root:      root,
+		genMarker: marker,
+	}
+}
@@ -0,0 +1,284 @@
+// Copyright 2019 The go-gclchaineum Authors
+// This file is part of the go-gclchaineum library.
+//
+// The go-gclchaineum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-gclchaineum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-gclchaineum library. If not, see <http://www.gnu.org/licenses/>.
+
+package snapshot
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/gclchaineum/go-gclchaineum/common"
+	"github.com/gclchaineum/go-gclchaineum/core/rawdb"
+	"github.com/gclchaineum/go-gclchaineum/crypto"
+	"github.com/gclchaineum/go-gclchaineum/gcldb"
+	"github.com/gclchaineum/go-gclchaineum/rlp"
+	"github.com/gclchaineum/go-gclchaineum/trie"
+)
+
+// makeTestState creates a state trie with the given number of accounts, every
+// second one having a few storage slots, and flushes it to disk.
+func makeTestState(t *testing.T, db gcldb.Database, accounts int) (common.Hash, *trie.Database) {
+	triedb := trie.NewDatabase(db)
+	accTrie, _ := trie.NewSecure(common.Hash{}, triedb, 0)
+
+	for i := 0; i < accounts; i++ {
+		acc := account{Nonce: uint64(i), Balance: big.NewInt(int64(i)), Root: emptyRoot, CodeHash: crypto.Keccak256(nil)}
+		if i%2 == 0 {
+			storeTrie, _ := trie.NewSecure(common.Hash{}, triedb, 0)
+			for j := 1; j <= 3; j++ {
+				value, _ := rlp.EncodeToBytes([]byte{byte(i), byte(j)})
+				storeTrie.Update([]byte{byte(j)}, value)
+			}
+			root, err := storeTrie.Commit(nil)
+			if err != nil {
+				t.Fatalf("failed to commit storage trie: %v", err)
+			}
+			if err := triedb.Commit(root, false); err != nil {
+				t.Fatalf("failed to flush storage trie: %v", err)
+			}
+			acc.Root = root
+		}
+		blob, _ := rlp.EncodeToBytes(&acc)
+		accTrie.Update([]byte{byte(i)}, blob)
+	}
+	root, err := accTrie.Commit(nil)
+	if err != nil {
+		t.Fatalf("failed to commit account trie: %v", err)
+	}
+	if err := triedb.Commit(root, false); err != nil {
+		t.Fatalf("failed to flush account trie: %v", err)
+	}
+	return root, triedb
+}
+
+// waitGeneration blocks until the disk layer of the snapshot tree is generated.
+func waitGeneration(t *testing.T, tree *Tree) {
+	for i := 0; i < 500; i++ {
+		tree.lock.RLock()
+		done := false
+		for _, layer := range tree.layers {
+			if base, ok := layer.(*diskLayer); ok {
+				base.lock.RLock()
+				done = base.genMarker == nil
+				base.lock.RUnlock()
+			}
+		}
+		tree.lock.RUnlock()
+		if done {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("snapshot generation timed out")
+}
+
+// hashKey returns the snapshot key of a raw trie key.
+func hashKey(key byte) common.Hash {
+	return crypto.Keccak256Hash([]byte{key})
+}
+
+// Tests that a snapshot is generated from the state trie, and that an existing
+// snapshot is reused if it matches the requested root.
+func TestGeneration(t *testing.T) {
+	db := gcldb.NewMemDatabase()
+	root, triedb := makeTestState(t, db, 16)
+
+	tree := New(db, triedb, 1, root)
+	waitGeneration(t, tree)
+	tree.Close()
+
+	snap := tree.Snapshot(root)
+	accTrie, _ := trie.NewSecure(root, triedb, 0)
+	for i := 0; i < 16; i++ {
+		blob, err := snap.AccountRLP(hashKey(byte(i)))
+		if err != nil {
+			t.Fatalf("account %d: failed to retrieve: %v", i, err)
+		}
+		if want := accTrie.Get([]byte{byte(i)}); !bytes.Equal(blob, want) {
+			t.Fatalf("account %d: blob mismatch: have %x, want %x", i, blob, want)
+		}
+		for j := 1; j <= 3; j++ {
+			slot, err := snap.Storage(hashKey(byte(i)), hashKey(byte(j)))
+			if err != nil {
+				t.Fatalf("account %d slot %d: failed to retrieve: %v", i, j, err)
+			}
+			var want []byte
+			if i%2 == 0 {
+				want, _ = rlp.EncodeToBytes([]byte{byte(i), byte(j)})
+			}
+			if !bytes.Equal(slot, want) {
+				t.Fatalf("account %d slot %d: blob mismatch: have %x, want %x", i, j, slot, want)
+			}
+		}
+	}
+	if blob, err := snap.AccountRLP(hashKey(0xff)); blob != nil || err != nil {
+		t.Fatalf("missing account: have %x, %v", blob, err)
+	}
+	// Reopening the snapshot on the same root should reuse it
+	if rawdb.ReadSnapshotGenerator(db) != nil {
+		t.Fatalf("generator marker left after completion")
+	}
+	tree = New(db, triedb, 1, root)
+	if base := tree.layers[root].(*diskLayer); base.genMarker != nil {
+		t.Fatalf("complete snapshot regenerated")
+	}
+	tree.Close()
+
+	// Reopening on a different root should discard it
+	tree = New(db, triedb, 1, common.Hash{0x01})
+	defer tree.Close()
+
+	if tree.Snapshot(root) != nil {
+		t.Fatalf("mismatching snapshot loaded")
+	}
+}
+
+// Tests that diff layers shadow their parents, and that capping the tree flattens
+// the old layers into the disk and drops the dead branches.
+func TestDiffLayers(t *testing.T) {
+	db := gcldb.NewMemDatabase()
+	root, triedb := makeTestState(t, db, 4)
+
+	tree := New(db, triedb, 1, root)
+	defer tree.Close()
+	waitGeneration(t, tree)
+
+	// Destruct account 0, modify account 1 and clear a slot of account 2
+	var (
+		r1, r2, r1b = common.Hash{0x01}, common.Hash{0x02}, common.Hash{0x03}
+		destructs   = map[common.Hash]struct{}{hashKey(0): {}}
+		accounts    = map[common.Hash][]byte{hashKey(1): []byte("account 1")}
+		storage     = map[common.Hash]map[common.Hash][]byte{hashKey(2): {hashKey(1): nil}}
+	)
+	if err := tree.Update(r1, root, destructs, accounts, storage); err != nil {
+		t.Fatalf("failed to create diff layer: %v", err)
+	}
+	if err := tree.Update(r2, r1, nil, map[common.Hash][]byte{hashKey(3): []byte("account 3")}, nil); err != nil {
+		t.Fatalf("failed to create diff layer: %v", err)
+	}
+	if err := tree.Update(r1b, root, nil, map[common.Hash][]byte{hashKey(1): []byte("side account 1")}, nil); err != nil {
+		t.Fatalf("failed to create side diff layer: %v", err)
+	}
+	if err := tree.Update(r2, common.Hash{0xff}, nil, nil, nil); err != nil {
+		t.Fatalf("existing layer rejected: %v", err)
+	}
+	if err := tree.Update(common.Hash{0x04}, common.Hash{0xff}, nil, nil, nil); err == nil {
+		t.Fatalf("layer with unknown parent accepted")
+	}
+	check := func(snap Snapshot) {
+		if blob, err := snap.AccountRLP(hashKey(0)); blob != nil || err != nil {
+			t.Errorf("destructed account: have %x, %v", blob, err)
+		}
+		if blob, err := snap.Storage(hashKey(0), hashKey(1)); blob != nil || err != nil {
+			t.Errorf("destructed storage: have %x, %v", blob, err)
+		}
+		if blob, _ := snap.AccountRLP(hashKey(1)); string(blob) != "account 1" {
+			t.Errorf("modified account: have %q", blob)
+		}
+		if blob, err := snap.Storage(hashKey(2), hashKey(1)); blob != nil || err != nil {
+			t.Errorf("deleted slot: have %x, %v", blob, err)
+		}
+		want, _ := rlp.EncodeToBytes([]byte{2, 2})
+		if blob, _ := snap.Storage(hashKey(2), hashKey(2)); !bytes.Equal(blob, want) {
+			t.Errorf("untouched slot: have %x, want %x", blob, want)
+		}
+	}
+	check(tree.Snapshot(r1))
+	check(tree.Snapshot(r2))
+	if blob, _ := tree.Snapshot(r2).AccountRLP(hashKey(3)); string(blob) != "account 3" {
+		t.Errorf("top account: have %q", blob)
+	}
+	if blob, _ := tree.Snapshot(r1).AccountRLP(hashKey(3)); string(blob) == "account 3" {
+		t.Errorf("child modification leaked into parent")
+	}
+	// Flatten the first layer into the disk, the side branch should be dropped
+	var (
+		base = tree.Snapshot(root)
+		side = tree.Snapshot(r1b)
+	)
+	if err := tree.Cap(r2, 1); err != nil {
+		t.Fatalf("failed to cap tree: %v", err)
+	}
+	if len(tree.layers) != 2 {
+		t.Errorf("layer count mismatch: have %d, want %d", len(tree.layers), 2)
+	}
+	if _, ok := tree.Snapshot(r1).(*diskLayer); !ok {
+		t.Fatalf("flattened layer is not on disk")
+	}
+	if _, err := base.AccountRLP(hashKey(1)); err != ErrSnapshotStale {
+		t.Errorf("old disk layer: have %v, want %v", err, ErrSnapshotStale)
+	}
+	if _, err := side.AccountRLP(hashKey(1)); err != ErrSnapshotStale {
+		t.Errorf("side layer: have %v, want %v", err, ErrSnapshotStale)
+	}
+	check(tree.Snapshot(r1))
+	check(tree.Snapshot(r2))
+
+	if rawdb.ReadSnapshotRoot(db) != r1 {
+		t.Errorf("persisted root mismatch: have %x, want %x", rawdb.ReadSnapshotRoot(db), r1)
+	}
+	if blob := rawdb.ReadStorageSnapshot(db, hashKey(0), hashKey(1)); blob != nil {
+		t.Errorf("destructed storage left on disk: %x", blob)
+	}
+}
+
+// Tests that a disk layer being generated only serves and accepts data that's
+// already covered by the generator.
+func TestGenerationMarker(t *testing.T) {
+	var (
+		db     = gcldb.NewMemDatabase()
+		low    = common.Hash{0x01}
+		high   = common.Hash{0xf0}
+		marker = common.Hash{0x80}
+	)
+	base := &diskLayer{
+		diskdb:    db,
+		triedb:    trie.NewDatabase(db),
+		root:      common.Hash{0xaa},
+		genMarker: marker[:],
+	}
+	rawdb.WriteAccountSnapshot(db, low, []byte("low"))
+	rawdb.WriteAccountSnapshot(db, high, []byte("high"))
+
+	if blob, err := base.AccountRLP(low); err != nil || string(blob) != "low" {
+		t.Errorf("covered account: have %q, %v", blob, err)
+	}
+	if _, err := base.AccountRLP(high); err != ErrNotCoveredYet {
+		t.Errorf("uncovered account: have %v, want %v", err, ErrNotCoveredYet)
+	}
+	if _, err := base.Storage(high, low); err != ErrNotCoveredYet {
+		t.Errorf("uncovered storage: have %v, want %v", err, ErrNotCoveredYet)
+	}
+	// Flattening a diff should only write the covered range
+	diff := newDiffLayer(base, common.Hash{0xbb}, nil, map[common.Hash][]byte{
+		low:  []byte("new low"),
+		high: []byte("new high"),
+	}, nil)
+	res := diffToDisk(diff)
+	res.abortGeneration()
+
+	if blob := rawdb.ReadAccountSnapshot(db, low); string(blob) != "new low" {
+		t.Errorf("covered account not flattened: have %q", blob)
+	}
+	if blob := rawdb.ReadAccountSnapshot(db, high); string(blob) != "high" {
+		t.Errorf("uncovered account flattened: have %q", blob)
+	}
+	if !bytes.Equal(res.genMarker, marker[:]) {
+		t.Errorf("generation marker mismatch: have %x, want %x", res.genMarker, marker)
+	}
+}
@@ -18,10 +18,14 @@ package state
 
 import (
 	"bytes"
+	"encoding/json"
+	"fmt"
 	"math/big"
+	"strings"
 	"testing"
 
 	"github.com/gclchaineum/go-gclchaineum/common"
+	"github.com/gclchaineum/go-gclchaineum/common/hexutil"
 	"github.com/gclchaineum/go-gclchaineum/crypto"
 	"github.com/gclchaineum/go-gclchaineum/gcldb"
 	checker "gopkg.in/check.v1"
@@ -86,6 +90,72 @@ func (s *StateSuite) TestDump(c *checker.C) {
 	}
 }
 
+func (s *StateSuite) TestIteratorDump(c *checker.C) {
+	for i := byte(0); i < 5; i++ {
+		addr := toAddr([]byte{i + 1})
+		s.state.SetBalance(addr, big.NewInt(int64(i)))
+		s.state.SetCode(addr, []byte{i})
+		s.state.SetState(addr, common.Hash{i}, common.Hash{i + 1})
+	}
+	s.state.Commit(false)
+
+	// Page through the state and check every account is returned exactly once
+	var (
+		seen  = make(map[string]bool)
+		conf  = &DumpConfig{SkipCode: true, Max: 2}
+		pages int
+	)
+	for {
+		dump, err := s.state.IteratorDump(conf)
+		c.Assert(err, checker.IsNil)
+		c.Assert(len(dump.Accounts) <= 2, checker.Equals, true)
+		for addr, account := range dump.Accounts {
+			c.Assert(seen[addr], checker.Equals, false)
+			c.Assert(account.Code, checker.Equals, "")
+			c.Assert(len(account.Storage), checker.Equals, 1)
+			seen[addr] = true
+		}
+		pages++
+		if dump.Next == nil {
+			break
+		}
+		conf.Start = dump.Next
+	}
+	c.Assert(len(seen), checker.Equals, 5)
+	c.Assert(pages, checker.Equals, 3)
+
+	// Stream the whole state and check it contains the root and all accounts
+	var buf bytes.Buffer
+	c.Assert(s.state.IterativeDump(&DumpConfig{SkipStorage: true}, &buf), checker.IsNil)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	c.Assert(len(lines), checker.Equals, 6)
+	c.Assert(lines[0], checker.Equals, fmt.Sprintf(`{"root":"%x"}`, s.state.IntermediateRoot(false)))
+	for _, line := range lines[1:] {
+		var account DumpAccount
+		c.Assert(json.Unmarshal([]byte(line), &account), checker.IsNil)
+		c.Assert(account.Address, checker.NotNil)
+		c.Assert(seen[common.Bytes2Hex(account.Address.Bytes())], checker.Equals, true)
+		c.Assert([]byte(account.Key), checker.DeepEquals, crypto.Keccak256(account.Address.Bytes()))
+		c.Assert(account.Code, checker.Not(checker.Equals), "")
+		c.Assert(account.Storage, checker.IsNil)
+	}
+	// Stream a limited page and check it ends with the key to continue from
+	buf.Reset()
+	c.Assert(s.state.IterativeDump(&DumpConfig{Max: 2}, &buf), checker.IsNil)
+
+	lines = strings.Split(strings.TrimSpace(buf.String()), "\n")
+	c.Assert(len(lines), checker.Equals, 4)
+
+	var next struct {
+		Next hexutil.Bytes `json:"next"`
+	}
+	c.Assert(json.Unmarshal([]byte(lines[3]), &next), checker.IsNil)
+	dump, err := s.state.IteratorDump(&DumpConfig{Max: 2})
+	c.Assert(err, checker.IsNil)
+	c.Assert(next.Next, checker.DeepEquals, dump.Next)
+}
+
 func (s *StateSuite) SetUpTest(c *checker.C) {
 	s.db = gcldb.NewMemDatabase()
 	s.state, _ = New(common.Hash{}, NewDatabase(s.db))
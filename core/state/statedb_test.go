@@ -435,3 +435,58 @@ func TestCopyOfCopy(t *testing.T) {
 		t.Fatalf("2nd copy fail, expected 42, got %v", got)
 	}
 }
+
+// TestSetStorage tests that replacing the storage of an account hides all its
+// original slots, while still allowing further modifications and copies.
+func TestSetStorage(t *testing.T) {
+	sdb, _ := New(common.Hash{}, NewDatabase(gcldb.NewMemDatabase()))
+	addr := common.HexToAddress("aaaa")
+	sdb.SetState(addr, common.HexToHash("01"), common.HexToHash("11"))
+	sdb.SetState(addr, common.HexToHash("02"), common.HexToHash("22"))
+
+	sdb.SetStorage(addr, map[common.Hash]common.Hash{common.HexToHash("02"): common.HexToHash("33")})
+	if got := sdb.GetState(addr, common.HexToHash("01")); got != (common.Hash{}) {
+		t.Fatalf("replaced slot not cleared: have %x, want empty", got)
+	}
+	if got := sdb.GetState(addr, common.HexToHash("02")); got != common.HexToHash("33") {
+		t.Fatalf("replaced slot mismatch: have %x, want %x", got, common.HexToHash("33"))
+	}
+	sdb.SetState(addr, common.HexToHash("03"), common.HexToHash("44"))
+
+	cpy := sdb.Copy()
+	if got := cpy.GetState(addr, common.HexToHash("03")); got != common.HexToHash("44") {
+		t.Fatalf("copied slot mismatch: have %x, want %x", got, common.HexToHash("44"))
+	}
+	if got := cpy.GetCommittedState(addr, common.HexToHash("01")); got != (common.Hash{}) {
+		t.Fatalf("copied committed slot not cleared: have %x, want empty", got)
+	}
+}
+
+// TestSetStorageRevert tests that modifications on top of a replaced storage are
+// journaled and reverted, while the replacement remains the committed state.
+func TestSetStorageRevert(t *testing.T) {
+	sdb, _ := New(common.Hash{}, NewDatabase(gcldb.NewMemDatabase()))
+	addr := common.HexToAddress("aaaa")
+	key := common.HexToHash("01")
+
+	sdb.SetStorage(addr, map[common.Hash]common.Hash{key: common.HexToHash("11")})
+
+	snapshot := sdb.Snapshot()
+	sdb.SetState(addr, key, common.HexToHash("22"))
+	if got := sdb.GetState(addr, key); got != common.HexToHash("22") {
+		t.Fatalf("modified slot mismatch: have %x, want %x", got, common.HexToHash("22"))
+	}
+	if got := sdb.GetCommittedState(addr, key); got != common.HexToHash("11") {
+		t.Fatalf("committed slot mismatch: have %x, want %x", got, common.HexToHash("11"))
+	}
+	sdb.RevertToSnapshot(snapshot)
+	if got := sdb.GetState(addr, key); got != common.HexToHash("11") {
+		t.Fatalf("reverted slot mismatch: have %x, want %x", got, common.HexToHash("11"))
+	}
+	// Finalising folds the modifications into the replaced storage
+	sdb.SetState(addr, key, common.HexToHash("33"))
+	sdb.Finalise(false)
+	if got := sdb.GetCommittedState(addr, key); got != common.HexToHash("33") {
+		t.Fatalf("finalised slot mismatch: have %x, want %x", got, common.HexToHash("33"))
+	}
+}
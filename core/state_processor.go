@@ -27,18 +27,28 @@ import (
 	"github.com/gclchaineum/go-gclchaineum/params"
 )
 
+// ProcessorChain is the subset of the blockchain's functionality needed by the
+// state processor: header retrieval for the EVM and the consensus engine for
+// finalizing blocks. It allows processing blocks without a full blockchain.
+type ProcessorChain interface {
+	consensus.ChainReader
+
+	// Engine retrieves the chain's consensus engine.
+	Engine() consensus.Engine
+}
+
 // StateProcessor is a basic Processor, which takes care of transitioning
 // state from one point to another.
 //
 // StateProcessor implements Processor.
 type StateProcessor struct {
 	config *params.ChainConfig // Chain configuration options
-	bc     *BlockChain         // Canonical block chain
+	bc     ProcessorChain      // Canonical block chain
 	engine consensus.Engine    // Consensus engine used for block rewards
 }
 
 // NewStateProcessor initialises a new StateProcessor.
-func NewStateProcessor(config *params.ChainConfig, bc *BlockChain, engine consensus.Engine) *StateProcessor {
+func NewStateProcessor(config *params.ChainConfig, bc ProcessorChain, engine consensus.Engine) *StateProcessor {
 	return &StateProcessor{
 		config: config,
 		bc:     bc,
@@ -65,10 +75,26 @@ func (p *StateProcessor) Process(block *types.Block, statedb *state.StateDB, cfg
 	if p.config.DAOForkSupport && p.config.DAOForkBlock != nil && p.config.DAOForkBlock.Cmp(block.Number()) == 0 {
 		misc.ApplyDAOHardFork(statedb)
 	}
+	// Speculatively execute the transactions in parallel if requested, the
+	// results are validated and applied in order below
+	var spec *speculator
+	if cfg.ParallelExecution && !cfg.Debug && len(block.Transactions()) > 1 {
+		spec = newSpeculator(p.config, p.bc, block, statedb, cfg)
+		defer spec.stop()
+	}
 	// Iterate over and process the individual transactions
 	for i, tx := range block.Transactions() {
 		statedb.Prepare(tx.Hash(), block.Hash(), i)
-		receipt, _, err := ApplyTransaction(p.config, p.bc, nil, gp, statedb, header, tx, usedGas, cfg)
+
+		var (
+			receipt *types.Receipt
+			err     error
+		)
+		if spec != nil {
+			receipt, err = spec.apply(i, gp, statedb, usedGas)
+		} else {
+			receipt, _, err = ApplyTransaction(p.config, p.bc, nil, gp, statedb, header, tx, usedGas, cfg)
+		}
 		if err != nil {
 			return nil, nil, 0, err
 		}
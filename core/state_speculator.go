@@ -0,0 +1,272 @@
+// Copyright 2019 The go-gclchaineum Authors
+// This file is part of the go-gclchaineum library.
+//
+// The go-gclchaineum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-gclchaineum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-gclchaineum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"math/big"
+	"runtime"
+
+	"github.com/gclchaineum/go-gclchaineum/common"
+	"github.com/gclchaineum/go-gclchaineum/core/state"
+	"github.com/gclchaineum/go-gclchaineum/core/types"
+	"github.com/gclchaineum/go-gclchaineum/core/vm"
+	"github.com/gclchaineum/go-gclchaineum/metrics"
+	"github.com/gclchaineum/go-gclchaineum/params"
+)
+
+var (
+	speculationHitMeter  = metrics.NewRegisteredMeter("chain/speculation/hits", nil)
+	speculationMissMeter = metrics.NewRegisteredMeter("chain/speculation/misses", nil)
+)
+
+// speculation is the outcome of executing a transaction on a private copy of
+// the state at the start of its block.
+type speculation struct {
+	done    chan struct{}    // Closed when the execution finished
+	state   *state.StateDB   // Private state the transaction was executed on
+	access  *state.AccessSet // State read and written by the transaction
+	receipt *types.Receipt   // Receipt of the execution, nil if it failed
+}
+
+// speculator executes the transactions of a block concurrently, each on its own
+// copy of the state, while tracking the state they read and write. The results
+// are then applied in order, re-executing any transaction that read state which
+// was modified by a preceding one. The outcome is identical to processing the
+// transactions sequentially.
+type speculator struct {
+	config *params.ChainConfig
+	bc     ChainContext
+	block  *types.Block
+	header *types.Header
+	cfg    vm.Config
+
+	specs []*speculation
+	quit  chan struct{}
+
+	written  map[state.AccessKey]struct{} // State written by the already applied transactions
+	touched  map[common.Address]struct{}  // Accounts written by the already applied transactions
+	replaced map[common.Address]struct{}  // Accounts created or deleted by the already applied transactions
+}
+
+// newSpeculator starts executing the transactions of the block on copies of the
+// given state, which must not have any changes of the block's transactions yet.
+func newSpeculator(config *params.ChainConfig, bc ChainContext, block *types.Block, statedb *state.StateDB, cfg vm.Config) *speculator {
+	var (
+		txs   = block.Transactions()
+		base  = statedb.Copy()
+		tasks = make(chan int, len(txs))
+	)
+	s := &speculator{
+		config:   config,
+		bc:       bc,
+		block:    block,
+		header:   block.Header(),
+		cfg:      cfg,
+		specs:    make([]*speculation, len(txs)),
+		quit:     make(chan struct{}),
+		written:  make(map[state.AccessKey]struct{}),
+		touched:  make(map[common.Address]struct{}),
+		replaced: make(map[common.Address]struct{}),
+	}
+	for i := range txs {
+		s.specs[i] = &speculation{
+			done:   make(chan struct{}),
+			state:  base.Copy(),
+			access: state.NewAccessSet(),
+		}
+		tasks <- i
+	}
+	close(tasks)
+
+	workers := runtime.NumCPU()
+	if workers > len(txs) {
+		workers = len(txs)
+	}
+	for i := 0; i < workers; i++ {
+		go s.loop(tasks)
+	}
+	return s
+}
+
+// loop executes the transactions fed through the tasks channel until it's
+// drained, skipping the executions once the speculator is stopped.
+func (s *speculator) loop(tasks chan int) {
+	for i := range tasks {
+		spec := s.specs[i]
+		select {
+		case <-s.quit:
+		default:
+			tx := s.block.Transactions()[i]
+
+			spec.state.Prepare(tx.Hash(), s.block.Hash(), i)
+			spec.state.SetAccessSet(spec.access)
+			receipt, _, err := ApplyTransaction(s.config, s.bc, nil, new(GasPool).AddGas(s.block.GasLimit()), spec.state, s.header, tx, new(uint64), s.cfg)
+			spec.state.SetAccessSet(nil)
+			if err == nil {
+				spec.receipt = receipt
+			}
+		}
+		close(spec.done)
+	}
+}
+
+// stop aborts any speculative executions not yet started.
+func (s *speculator) stop() {
+	close(s.quit)
+}
+
+// apply applies the i-th transaction of the block to the state, reusing the
+// result of its speculative execution if it is still valid.
+func (s *speculator) apply(i int, gp *GasPool, statedb *state.StateDB, usedGas *uint64) (*types.Receipt, error) {
+	spec := s.specs[i]
+	<-spec.done
+
+	if spec.receipt == nil || s.conflicts(spec) {
+		speculationMissMeter.Mark(1)
+
+		access := state.NewAccessSet()
+		statedb.SetAccessSet(access)
+		receipt, _, err := ApplyTransaction(s.config, s.bc, nil, gp, statedb, s.header, s.block.Transactions()[i], usedGas, s.cfg)
+		statedb.SetAccessSet(nil)
+		if err != nil {
+			return nil, err
+		}
+		s.record(access, statedb)
+		return receipt, nil
+	}
+	speculationHitMeter.Mark(1)
+
+	// The speculative execution is valid, check it against the block gas limit
+	// the same way as the sequential execution would do
+	tx := s.block.Transactions()[i]
+	if err := gp.SubGas(tx.Gas()); err != nil {
+		return nil, err
+	}
+	gp.AddGas(tx.Gas() - spec.receipt.GasUsed)
+
+	// Apply the state changes and finalise them the same way as ApplyTransaction
+	s.merge(spec, statedb)
+	s.record(spec.access, spec.state)
+
+	var root []byte
+	if s.config.IsByzantium(s.header.Number) {
+		statedb.Finalise(true)
+	} else {
+		root = statedb.IntermediateRoot(s.config.IsEIP158(s.header.Number)).Bytes()
+	}
+	*usedGas += spec.receipt.GasUsed
+
+	// Rebuild the receipt with the block wide cumulative fields
+	receipt := new(types.Receipt)
+	*receipt = *spec.receipt
+	receipt.PostState = root
+	receipt.CumulativeGasUsed = *usedGas
+
+	for _, log := range spec.state.GetLogs(tx.Hash()) {
+		statedb.AddLog(log)
+	}
+	receipt.Logs = statedb.GetLogs(tx.Hash())
+	receipt.Bloom = types.CreateBloom(types.Receipts{receipt})
+
+	return receipt, nil
+}
+
+// conflicts reports whether a speculative execution depends on state modified
+// by the already applied transactions.
+func (s *speculator) conflicts(spec *speculation) bool {
+	for key := range spec.access.Reads {
+		if _, ok := s.replaced[key.Address]; ok {
+			return true
+		}
+		if _, ok := s.written[key]; ok {
+			return true
+		}
+		// Existence and emptiness depend on all fields of the account
+		if _, ok := s.touched[key.Address]; ok && key.Kind == state.AccountAccess {
+			return true
+		}
+	}
+	for _, addr := range spec.access.Accounts() {
+		if _, ok := s.replaced[addr]; ok {
+			return true
+		}
+		// Creating or deleting an account overrides all its fields
+		_, touched := s.touched[addr]
+		if touched && (spec.access.Existed(addr) != spec.state.Exist(addr) || s.recreates(spec.access, addr)) {
+			return true
+		}
+	}
+	return false
+}
+
+// recreates reports whether an access set contains an explicit creation or
+// destruction of the given account.
+func (s *speculator) recreates(access *state.AccessSet, addr common.Address) bool {
+	_, ok := access.Writes[state.AccessKey{Address: addr, Kind: state.AccountAccess}]
+	return ok
+}
+
+// record adds the state written by an applied transaction to the set of state
+// later speculative executions must not depend on.
+func (s *speculator) record(access *state.AccessSet, final *state.StateDB) {
+	for key := range access.Writes {
+		s.written[key] = struct{}{}
+		s.touched[key.Address] = struct{}{}
+	}
+	for _, addr := range access.Accounts() {
+		if s.recreates(access, addr) || access.Existed(addr) != final.Exist(addr) {
+			s.replaced[addr] = struct{}{}
+		}
+	}
+}
+
+// merge copies the state written by a speculative execution into the state.
+// Balances are applied as deltas, as they can be modified without being read,
+// e.g. by the fee payments to the coinbase.
+func (s *speculator) merge(spec *speculation, statedb *state.StateDB) {
+	for _, addr := range spec.access.Accounts() {
+		switch {
+		case !spec.state.Exist(addr):
+			// Only delete accounts the transaction saw, others were created by
+			// preceding transactions and merely touched by this one
+			if spec.access.Existed(addr) {
+				statedb.Suicide(addr)
+			}
+		case !spec.access.Existed(addr):
+			statedb.SetNonce(addr, spec.state.GetNonce(addr))
+		}
+	}
+	for key := range spec.access.Writes {
+		if !spec.state.Exist(key.Address) {
+			continue
+		}
+		switch key.Kind {
+		case state.BalanceAccess:
+			balance := spec.state.GetBalance(key.Address)
+			statedb.AddBalance(key.Address, new(big.Int).Sub(balance, spec.access.OriginalBalance(key.Address)))
+		case state.NonceAccess:
+			statedb.SetNonce(key.Address, spec.state.GetNonce(key.Address))
+		case state.CodeAccess:
+			statedb.SetCode(key.Address, spec.state.GetCode(key.Address))
+		case state.StorageAccess:
+			statedb.SetState(key.Address, key.Slot, spec.state.GetState(key.Address, key.Slot))
+		}
+	}
+	for hash, preimage := range spec.state.Preimages() {
+		statedb.AddPreimage(hash, preimage)
+	}
+}
@@ -0,0 +1,161 @@
+// Copyright 2019 The go-gclchaineum Authors
+// This file is part of the go-gclchaineum library.
+//
+// The go-gclchaineum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-gclchaineum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-gclchaineum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"crypto/ecdsa"
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/gclchaineum/go-gclchaineum/common"
+	"github.com/gclchaineum/go-gclchaineum/common/hexutil"
+	"github.com/gclchaineum/go-gclchaineum/consensus/gclash"
+	"github.com/gclchaineum/go-gclchaineum/core/state"
+	"github.com/gclchaineum/go-gclchaineum/core/types"
+	"github.com/gclchaineum/go-gclchaineum/core/vm"
+	"github.com/gclchaineum/go-gclchaineum/crypto"
+	"github.com/gclchaineum/go-gclchaineum/gcldb"
+	"github.com/gclchaineum/go-gclchaineum/params"
+)
+
+// Tests that speculative parallel execution produces the same state, receipts
+// and logs as the sequential one, for both independent and conflicting
+// transactions.
+func TestSpeculativeExecution(t *testing.T) {
+	t.Run("byzantium", func(t *testing.T) { testSpeculativeExecution(t, params.TestChainConfig) })
+	t.Run("eip158", func(t *testing.T) {
+		testSpeculativeExecution(t, &params.ChainConfig{
+			ChainID:        big.NewInt(1),
+			HomesteadBlock: new(big.Int),
+			EIP150Block:    new(big.Int),
+			EIP155Block:    new(big.Int),
+			EIP158Block:    new(big.Int),
+		})
+	})
+}
+
+func testSpeculativeExecution(t *testing.T, config *params.ChainConfig) {
+	var (
+		counter    = common.HexToAddress("0xc0") // Increments slot 0
+		keyed      = common.HexToAddress("0xc1") // Stores the call value in the caller's slot and logs
+		reader     = common.HexToAddress("0xc2") // Stores the balance of the coinbase
+		destructor = common.HexToAddress("0xc3") // Self-destructs to the caller
+		coinbase   = common.HexToAddress("0xcb")
+
+		keys  = make([]*ecdsa.PrivateKey, 6)
+		alloc = GenesisAlloc{
+			counter:    {Code: hexutil.MustDecode("0x60005460010160005500"), Balance: new(big.Int)},
+			keyed:      {Code: hexutil.MustDecode("0x34335560006000a000"), Balance: new(big.Int)},
+			reader:     {Code: hexutil.MustDecode("0x413160005500"), Balance: new(big.Int)},
+			destructor: {Code: hexutil.MustDecode("0x33ff"), Balance: big.NewInt(1000)},
+		}
+		signer = types.NewEIP155Signer(config.ChainID)
+	)
+	for i := range keys {
+		keys[i], _ = crypto.GenerateKey()
+		alloc[crypto.PubkeyToAddress(keys[i].PublicKey)] = GenesisAccount{Balance: big.NewInt(1000000000)}
+	}
+	var (
+		db      = gcldb.NewMemDatabase()
+		gspec   = &Genesis{Config: config, Alloc: alloc}
+		genesis = gspec.MustCommit(db)
+	)
+	blocks, _ := GenerateChain(config, genesis, gclash.NewFaker(), db, 4, func(i int, block *BlockGen) {
+		block.SetCoinbase(coinbase)
+
+		tx := func(key *ecdsa.PrivateKey, to *common.Address, value, price int64, data []byte) {
+			nonce := block.TxNonce(crypto.PubkeyToAddress(key.PublicKey))
+			var raw *types.Transaction
+			if to == nil {
+				raw = types.NewContractCreation(nonce, big.NewInt(value), 100000, big.NewInt(price), data)
+			} else {
+				raw = types.NewTransaction(nonce, *to, big.NewInt(value), 100000, big.NewInt(price), data)
+			}
+			signed, err := types.SignTx(raw, signer, key)
+			if err != nil {
+				t.Fatalf("failed to sign transaction: %v", err)
+			}
+			block.AddTx(signed)
+		}
+		for k, key := range keys {
+			switch (i + k) % 6 {
+			case 0:
+				shared := common.HexToAddress("0xee")
+				tx(key, &shared, 1, 1, nil)
+			case 1:
+				tx(key, &counter, 0, 1, nil)
+			case 2:
+				tx(key, &keyed, int64(k+1), 1, nil)
+				tx(key, &keyed, int64(k+2), 1, nil)
+			case 3:
+				tx(key, &reader, 0, 1, nil)
+			case 4:
+				empty := common.Address{0xe0, byte(k)}
+				tx(key, &empty, 0, 0, nil)
+			case 5:
+				tx(key, nil, 5, 1, hexutil.MustDecode("0x6001600055"))
+			}
+		}
+		if i == 1 {
+			tx(keys[0], &destructor, 0, 1, nil)
+			tx(keys[1], &destructor, 10, 1, nil)
+		}
+	})
+	chain, _ := NewBlockChain(db, nil, config, gclash.NewFaker(), vm.Config{}, nil)
+	defer chain.Stop()
+
+	processor := NewStateProcessor(config, chain, chain.Engine())
+	for i, block := range blocks {
+		parent := genesis
+		if i > 0 {
+			parent = blocks[i-1]
+		}
+		seqdb, _ := state.New(parent.Root(), state.NewDatabase(db))
+		pardb, _ := state.New(parent.Root(), state.NewDatabase(db))
+
+		seqReceipts, seqLogs, seqGas, err := processor.Process(block, seqdb, vm.Config{})
+		if err != nil {
+			t.Fatalf("block %d: sequential processing failed: %v", i, err)
+		}
+		parReceipts, parLogs, parGas, err := processor.Process(block, pardb, vm.Config{ParallelExecution: true})
+		if err != nil {
+			t.Fatalf("block %d: parallel processing failed: %v", i, err)
+		}
+		if root := pardb.IntermediateRoot(config.IsEIP158(block.Number())); root != block.Root() {
+			t.Errorf("block %d: state root mismatch: have %x, want %x", i, root, block.Root())
+		}
+		if parGas != seqGas {
+			t.Errorf("block %d: gas used mismatch: have %d, want %d", i, parGas, seqGas)
+		}
+		if !reflect.DeepEqual(parReceipts, seqReceipts) {
+			t.Errorf("block %d: receipt mismatch", i)
+		}
+		if !reflect.DeepEqual(parLogs, seqLogs) {
+			t.Errorf("block %d: log mismatch", i)
+		}
+	}
+	// Import the chain with parallel execution to run the full block validation
+	db = gcldb.NewMemDatabase()
+	gspec.MustCommit(db)
+	parallel, _ := NewBlockChain(db, nil, config, gclash.NewFaker(), vm.Config{ParallelExecution: true}, nil)
+	defer parallel.Stop()
+
+	if n, err := parallel.InsertChain(blocks); err != nil {
+		t.Fatalf("block %d: failed to import with parallel execution: %v", n, err)
+	}
+}
@@ -0,0 +1,145 @@
+// Copyright 2019 The go-gclchaineum Authors
+// This file is part of the go-gclchaineum library.
+//
+// The go-gclchaineum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-gclchaineum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-gclchaineum library. If not, see <http://www.gnu.org/licenses/>.
+
+package stateless
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/gclchaineum/go-gclchaineum/common"
+	"github.com/gclchaineum/go-gclchaineum/consensus"
+	"github.com/gclchaineum/go-gclchaineum/core"
+	"github.com/gclchaineum/go-gclchaineum/core/types"
+	"github.com/gclchaineum/go-gclchaineum/params"
+)
+
+// recordingChain is a chain wrapper that records every header retrieved from it,
+// which during block processing are the ancestors accessed by BLOCKHASH.
+type recordingChain struct {
+	core.ProcessorChain
+
+	headers map[common.Hash]*types.Header
+	lock    sync.Mutex
+}
+
+// newRecordingChain wraps a chain to record the accessed headers.
+func newRecordingChain(chain core.ProcessorChain) *recordingChain {
+	return &recordingChain{
+		ProcessorChain: chain,
+		headers:        make(map[common.Hash]*types.Header),
+	}
+}
+
+// record stores a retrieved header, if any, and returns it.
+func (c *recordingChain) record(header *types.Header) *types.Header {
+	if header != nil {
+		c.lock.Lock()
+		c.headers[header.Hash()] = header
+		c.lock.Unlock()
+	}
+	return header
+}
+
+// GetHeader retrieves a block header by hash and number, recording it.
+func (c *recordingChain) GetHeader(hash common.Hash, number uint64) *types.Header {
+	return c.record(c.ProcessorChain.GetHeader(hash, number))
+}
+
+// GetHeaderByNumber retrieves a block header by number, recording it.
+func (c *recordingChain) GetHeaderByNumber(number uint64) *types.Header {
+	return c.record(c.ProcessorChain.GetHeaderByNumber(number))
+}
+
+// GetHeaderByHash retrieves a block header by hash, recording it.
+func (c *recordingChain) GetHeaderByHash(hash common.Hash) *types.Header {
+	return c.record(c.ProcessorChain.GetHeaderByHash(hash))
+}
+
+// GetBlock is not supported during recording, as a witness can't prove bodies.
+func (c *recordingChain) GetBlock(hash common.Hash, number uint64) *types.Block {
+	return nil
+}
+
+// witnessChain is a chain backed only by the headers contained in a witness.
+type witnessChain struct {
+	config  *params.ChainConfig
+	engine  consensus.Engine
+	headers []*types.Header // Contiguous ancestors, newest (parent) first
+	hashes  map[common.Hash]*types.Header
+}
+
+// newWitnessChain creates a chain from the headers of a witness, ensuring they
+// form a contiguous chain of ancestors of the given block.
+func newWitnessChain(config *params.ChainConfig, engine consensus.Engine, block *types.Block, headers []*types.Header) (*witnessChain, error) {
+	if len(headers) == 0 {
+		return nil, errors.New("witness contains no parent header")
+	}
+	chain := &witnessChain{
+		config:  config,
+		engine:  engine,
+		headers: headers,
+		hashes:  make(map[common.Hash]*types.Header, len(headers)),
+	}
+	var (
+		number = block.NumberU64()
+		hash   = block.ParentHash()
+	)
+	for i, header := range headers {
+		if header.Number.Uint64()+1 != number || header.Hash() != hash {
+			return nil, fmt.Errorf("witness header %d (#%d %x) is not an ancestor of block #%d", i, header.Number, header.Hash(), block.NumberU64())
+		}
+		chain.hashes[hash] = header
+		number, hash = header.Number.Uint64(), header.ParentHash
+	}
+	return chain, nil
+}
+
+// Config retrieves the chain configuration.
+func (c *witnessChain) Config() *params.ChainConfig { return c.config }
+
+// Engine retrieves the consensus engine.
+func (c *witnessChain) Engine() consensus.Engine { return c.engine }
+
+// CurrentHeader retrieves the parent of the block being executed.
+func (c *witnessChain) CurrentHeader() *types.Header { return c.headers[0] }
+
+// GetHeader retrieves a witness header by hash and number.
+func (c *witnessChain) GetHeader(hash common.Hash, number uint64) *types.Header {
+	if header := c.hashes[hash]; header != nil && header.Number.Uint64() == number {
+		return header
+	}
+	return nil
+}
+
+// GetHeaderByNumber retrieves a witness header by number.
+func (c *witnessChain) GetHeaderByNumber(number uint64) *types.Header {
+	if parent := c.headers[0].Number.Uint64(); number <= parent && parent-number < uint64(len(c.headers)) {
+		return c.headers[parent-number]
+	}
+	return nil
+}
+
+// GetHeaderByHash retrieves a witness header by hash.
+func (c *witnessChain) GetHeaderByHash(hash common.Hash) *types.Header {
+	return c.hashes[hash]
+}
+
+// GetBlock is not supported, as a witness contains no block bodies.
+func (c *witnessChain) GetBlock(hash common.Hash, number uint64) *types.Block {
+	return nil
+}
@@ -0,0 +1,86 @@
+// Copyright 2019 The go-gclchaineum Authors
+// This file is part of the go-gclchaineum library.
+//
+// The go-gclchaineum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-gclchaineum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-gclchaineum library. If not, see <http://www.gnu.org/licenses/>.
+
+package stateless
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/gclchaineum/go-gclchaineum/common"
+	"github.com/gclchaineum/go-gclchaineum/core/state"
+	"github.com/gclchaineum/go-gclchaineum/trie"
+)
+
+// recordingDatabase is a state database wrapper that records every trie node
+// and contract code loaded through it.
+//
+// Tries are always opened from scratch instead of reusing any cached ones of the
+// wrapped database, otherwise already resolved nodes would be missed.
+type recordingDatabase struct {
+	state.Database
+
+	nodes *trie.Recorder
+	codes map[common.Hash][]byte
+	lock  sync.Mutex
+}
+
+// newRecordingDatabase wraps a state database to record the accessed state.
+func newRecordingDatabase(db state.Database) *recordingDatabase {
+	return &recordingDatabase{
+		Database: db,
+		nodes:    trie.NewRecorder(),
+		codes:    make(map[common.Hash][]byte),
+	}
+}
+
+// OpenTrie opens the main account trie, recording the nodes accessed.
+func (db *recordingDatabase) OpenTrie(root common.Hash) (state.Trie, error) {
+	return trie.NewSecureWithRecorder(root, db.TrieDB(), 0, db.nodes)
+}
+
+// OpenStorageTrie opens the storage trie of an account, recording the nodes accessed.
+func (db *recordingDatabase) OpenStorageTrie(addrHash, root common.Hash) (state.Trie, error) {
+	return trie.NewSecureWithRecorder(root, db.TrieDB(), 0, db.nodes)
+}
+
+// CopyTrie returns an independent copy of the given trie, sharing the recorder.
+func (db *recordingDatabase) CopyTrie(t state.Trie) state.Trie {
+	switch t := t.(type) {
+	case *trie.SecureTrie:
+		return t.Copy()
+	default:
+		panic(fmt.Errorf("unknown trie type %T", t))
+	}
+}
+
+// ContractCode retrieves a particular contract's code, recording it.
+func (db *recordingDatabase) ContractCode(addrHash, codeHash common.Hash) ([]byte, error) {
+	code, err := db.Database.ContractCode(addrHash, codeHash)
+	if err == nil {
+		db.lock.Lock()
+		db.codes[codeHash] = common.CopyBytes(code)
+		db.lock.Unlock()
+	}
+	return code, err
+}
+
+// ContractCodeSize retrieves a particular contract's code size. The whole code
+// is recorded, as a stateless verifier can only derive the size from it.
+func (db *recordingDatabase) ContractCodeSize(addrHash, codeHash common.Hash) (int, error) {
+	code, err := db.ContractCode(addrHash, codeHash)
+	return len(code), err
+}
@@ -0,0 +1,97 @@
+// Copyright 2019 The go-gclchaineum Authors
+// This file is part of the go-gclchaineum library.
+//
+// The go-gclchaineum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-gclchaineum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-gclchaineum library. If not, see <http://www.gnu.org/licenses/>.
+
+package stateless
+
+import (
+	"fmt"
+
+	"github.com/gclchaineum/go-gclchaineum/consensus"
+	"github.com/gclchaineum/go-gclchaineum/core"
+	"github.com/gclchaineum/go-gclchaineum/core/state"
+	"github.com/gclchaineum/go-gclchaineum/core/types"
+	"github.com/gclchaineum/go-gclchaineum/core/vm"
+	"github.com/gclchaineum/go-gclchaineum/params"
+)
+
+// Record re-executes a block on top of its parent state, read from db, and
+// collects every trie node, contract code and ancestor header accessed during
+// the process into a witness.
+//
+// The block is fully processed, including the consensus engine's finalization
+// and the state root calculation, so the witness also covers the nodes needed
+// to apply the modifications. Recording fails if the resulting state doesn't
+// match the block.
+func Record(chain core.ProcessorChain, db state.Database, block *types.Block) (*Witness, error) {
+	if block.NumberU64() == 0 {
+		return nil, fmt.Errorf("genesis block has no witness")
+	}
+	parent := chain.GetHeader(block.ParentHash(), block.NumberU64()-1)
+	if parent == nil {
+		return nil, fmt.Errorf("parent %#x not found", block.ParentHash())
+	}
+	var (
+		recdb    = newRecordingDatabase(db)
+		recchain = newRecordingChain(chain)
+	)
+	statedb, err := state.New(parent.Root, recdb)
+	if err != nil {
+		return nil, err
+	}
+	if err := process(chain.Config(), recchain, block, statedb); err != nil {
+		return nil, err
+	}
+	return newWitness(parent, recchain.headers, recdb.codes, recdb.nodes.Nodes()), nil
+}
+
+// Execute verifies a block statelessly: it re-executes the block on top of the
+// parent state proven by the witness and checks that the resulting state root,
+// receipts and gas usage match the ones in the block header.
+//
+// An error is returned if the block is invalid or the witness is incomplete.
+// The block header itself (e.g. its seal) is not verified, that's left to the
+// caller.
+func Execute(config *params.ChainConfig, engine consensus.Engine, block *types.Block, witness *Witness) error {
+	chain, err := newWitnessChain(config, engine, block, witness.Headers)
+	if err != nil {
+		return err
+	}
+	statedb, err := state.New(witness.Parent().Root, state.NewDatabase(witness.database()))
+	if err != nil {
+		return fmt.Errorf("witness missing parent state: %v", err)
+	}
+	return process(config, chain, block, statedb)
+}
+
+// process runs a block on top of the given state and validates the outcome
+// against the block header.
+func process(config *params.ChainConfig, chain core.ProcessorChain, block *types.Block, statedb *state.StateDB) error {
+	processor := core.NewStateProcessor(config, chain, chain.Engine())
+
+	receipts, _, usedGas, err := processor.Process(block, statedb, vm.Config{})
+	if err == nil {
+		// ValidateState only depends on the chain configuration, no chain is needed
+		validator := core.NewBlockValidator(config, nil, chain.Engine())
+		err = validator.ValidateState(block, nil, statedb, receipts, usedGas)
+	}
+	// Missing trie nodes or codes don't abort the execution, they are only
+	// reported through the state database. Surface them over any derived
+	// failure, as they are the root cause.
+	if dberr := statedb.Error(); dberr != nil {
+		return dberr
+	}
+	return err
+}
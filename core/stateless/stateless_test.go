@@ -0,0 +1,171 @@
+// Copyright 2019 The go-gclchaineum Authors
+// This file is part of the go-gclchaineum library.
+//
+// The go-gclchaineum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-gclchaineum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-gclchaineum library. If not, see <http://www.gnu.org/licenses/>.
+
+package stateless
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/gclchaineum/go-gclchaineum/common"
+	"github.com/gclchaineum/go-gclchaineum/consensus/gclash"
+	"github.com/gclchaineum/go-gclchaineum/core"
+	"github.com/gclchaineum/go-gclchaineum/core/types"
+	"github.com/gclchaineum/go-gclchaineum/core/vm"
+	"github.com/gclchaineum/go-gclchaineum/crypto"
+	"github.com/gclchaineum/go-gclchaineum/gcldb"
+	"github.com/gclchaineum/go-gclchaineum/params"
+	"github.com/gclchaineum/go-gclchaineum/rlp"
+)
+
+var (
+	testKey, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	testAddress = crypto.PubkeyToAddress(testKey.PublicKey)
+
+	// testContract increments its first storage slot, stores the hash of the block
+	// three levels up in its second slot and its own code size in the third one.
+	testContract = common.HexToAddress("0xc0de")
+	testCode     = common.FromHex("600054600101600055600343034060015530" + "3b60025500")
+)
+
+// newTestChain creates a blockchain with the given number of blocks, each of
+// which calls the test contract and sends funds to a fresh account.
+func newTestChain(t *testing.T, blocks int) *core.BlockChain {
+	var (
+		db     = gcldb.NewMemDatabase()
+		engine = gclash.NewFaker()
+		gspec  = &core.Genesis{
+			Config: params.TestChainConfig,
+			Alloc: core.GenesisAlloc{
+				testAddress:  {Balance: big.NewInt(1000000000000000000)},
+				testContract: {Balance: new(big.Int), Code: testCode, Storage: map[common.Hash]common.Hash{{}: common.BytesToHash([]byte{0x01})}},
+			},
+		}
+		genesis = gspec.MustCommit(db)
+		signer  = types.HomesteadSigner{}
+	)
+	chain, err := core.NewBlockChain(db, &core.CacheConfig{Disabled: true}, gspec.Config, engine, vm.Config{}, nil)
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	parent := genesis
+	for i := 0; i < blocks; i++ {
+		block, _ := core.GenerateChain(gspec.Config, parent, engine, db, 1, func(n int, b *core.BlockGen) {
+			call, _ := types.SignTx(types.NewTransaction(b.TxNonce(testAddress), testContract, new(big.Int), 100000, big.NewInt(1), nil), signer, testKey)
+			b.AddTxWithChain(chain, call)
+
+			recipient := common.BigToAddress(big.NewInt(int64(0x1000 + i)))
+			send, _ := types.SignTx(types.NewTransaction(b.TxNonce(testAddress), recipient, big.NewInt(1), params.TxGas, big.NewInt(1), nil), signer, testKey)
+			b.AddTxWithChain(chain, send)
+		})
+		if _, err := chain.InsertChain(block); err != nil {
+			t.Fatalf("failed to insert block %d: %v", i+1, err)
+		}
+		parent = block[0]
+	}
+	return chain
+}
+
+// Tests that a recorded witness is enough to execute and verify its block, and
+// that it survives an RLP round trip.
+func TestWitnessExecution(t *testing.T) {
+	chain := newTestChain(t, 6)
+	defer chain.Stop()
+
+	for n := uint64(1); n <= chain.CurrentBlock().NumberU64(); n++ {
+		block := chain.GetBlockByNumber(n)
+
+		witness, err := Record(chain, chain.StateCache(), block)
+		if err != nil {
+			t.Fatalf("block %d: failed to record witness: %v", n, err)
+		}
+		if witness.Parent().Hash() != block.ParentHash() {
+			t.Errorf("block %d: parent header mismatch", n)
+		}
+		// BLOCKHASH(n-3) needs the parent and grandparent, the latter containing
+		// the hash requested. Before block 3 the lookup underflows.
+		want := 1
+		if n > 2 {
+			want = 2
+		}
+		if len(witness.Headers) != want {
+			t.Errorf("block %d: header count mismatch: have %d, want %d", n, len(witness.Headers), want)
+		}
+		if len(witness.Codes) != 1 {
+			t.Errorf("block %d: code count mismatch: have %d, want 1", n, len(witness.Codes))
+		}
+		if err := Execute(chain.Config(), chain.Engine(), block, witness); err != nil {
+			t.Errorf("block %d: stateless execution failed: %v", n, err)
+		}
+		blob, err := rlp.EncodeToBytes(witness)
+		if err != nil {
+			t.Fatalf("block %d: failed to encode witness: %v", n, err)
+		}
+		decoded := new(Witness)
+		if err := rlp.DecodeBytes(blob, decoded); err != nil {
+			t.Fatalf("block %d: failed to decode witness: %v", n, err)
+		}
+		if err := Execute(chain.Config(), chain.Engine(), block, decoded); err != nil {
+			t.Errorf("block %d: stateless execution of decoded witness failed: %v", n, err)
+		}
+	}
+}
+
+// Tests that blocks are rejected if their witness is incomplete or doesn't
+// belong to them.
+func TestWitnessIncomplete(t *testing.T) {
+	chain := newTestChain(t, 6)
+	defer chain.Stop()
+
+	block := chain.CurrentBlock()
+	record := func() *Witness {
+		witness, err := Record(chain, chain.StateCache(), block)
+		if err != nil {
+			t.Fatalf("failed to record witness: %v", err)
+		}
+		return witness
+	}
+	// Dropping any of the trie nodes must fail the execution
+	for i := range record().State {
+		witness := record()
+		witness.State = append(witness.State[:i], witness.State[i+1:]...)
+		if err := Execute(chain.Config(), chain.Engine(), block, witness); err == nil {
+			t.Errorf("missing trie node %d: execution succeeded", i)
+		}
+	}
+	// Dropping the contract code must fail the execution
+	witness := record()
+	witness.Codes = nil
+	if err := Execute(chain.Config(), chain.Engine(), block, witness); err == nil {
+		t.Errorf("missing code: execution succeeded")
+	}
+	// Dropping an ancestor header makes BLOCKHASH return a different result
+	witness = record()
+	witness.Headers = witness.Headers[:len(witness.Headers)-1]
+	if err := Execute(chain.Config(), chain.Engine(), block, witness); err == nil {
+		t.Errorf("missing ancestor: execution succeeded")
+	}
+	// Replacing an ancestor header must be detected
+	witness = record()
+	witness.Headers[1] = chain.GetHeaderByNumber(1)
+	if err := Execute(chain.Config(), chain.Engine(), block, witness); err == nil {
+		t.Errorf("forged ancestor: execution succeeded")
+	}
+	// Executing a different block with the witness must fail
+	if err := Execute(chain.Config(), chain.Engine(), chain.GetBlockByNumber(block.NumberU64()-1), record()); err == nil {
+		t.Errorf("mismatching block: execution succeeded")
+	}
+}
@@ -0,0 +1,99 @@
+// Copyright 2019 The go-gclchaineum Authors
+// This file is part of the go-gclchaineum library.
+//
+// The go-gclchaineum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-gclchaineum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-gclchaineum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package stateless implements the recording of block witnesses and the
+// verification of blocks against them without access to the full state.
+package stateless
+
+import (
+	"bytes"
+	"sort"
+
+	"github.com/gclchaineum/go-gclchaineum/common"
+	"github.com/gclchaineum/go-gclchaineum/common/hexutil"
+	"github.com/gclchaineum/go-gclchaineum/core/types"
+	"github.com/gclchaineum/go-gclchaineum/crypto"
+	"github.com/gclchaineum/go-gclchaineum/gcldb"
+)
+
+// Witness contains everything needed to execute a block without a local state:
+// the trie nodes and contract codes touched during its execution and the chain
+// of ancestor headers accessed, starting with the block's parent.
+type Witness struct {
+	Headers []*types.Header `json:"headers"` // Parent header first, followed by the older ancestors accessed
+	Codes   []hexutil.Bytes `json:"codes"`   // Contract codes accessed during execution
+	State   []hexutil.Bytes `json:"state"`   // Trie nodes accessed during execution
+}
+
+// newWitness assembles a witness from the recorded data, ordering everything
+// deterministically so the same block always yields the same witness.
+func newWitness(parent *types.Header, ancestors map[common.Hash]*types.Header, codes map[common.Hash][]byte, nodes map[common.Hash][]byte) *Witness {
+	witness := &Witness{
+		Headers: []*types.Header{parent},
+		Codes:   sortedBlobs(codes),
+		State:   sortedBlobs(nodes),
+	}
+	var headers []*types.Header
+	for hash, header := range ancestors {
+		if hash != parent.Hash() {
+			headers = append(headers, header)
+		}
+	}
+	sort.Slice(headers, func(i, j int) bool {
+		return headers[i].Number.Cmp(headers[j].Number) > 0
+	})
+	witness.Headers = append(witness.Headers, headers...)
+	return witness
+}
+
+// sortedBlobs returns the values of a hash keyed blob set ordered by key.
+func sortedBlobs(blobs map[common.Hash][]byte) []hexutil.Bytes {
+	hashes := make([]common.Hash, 0, len(blobs))
+	for hash := range blobs {
+		hashes = append(hashes, hash)
+	}
+	sort.Slice(hashes, func(i, j int) bool {
+		return bytes.Compare(hashes[i][:], hashes[j][:]) < 0
+	})
+	sorted := make([]hexutil.Bytes, len(hashes))
+	for i, hash := range hashes {
+		sorted[i] = blobs[hash]
+	}
+	return sorted
+}
+
+// Parent returns the header of the block the witness was recorded on top of,
+// or nil if the witness contains no headers at all.
+func (w *Witness) Parent() *types.Header {
+	if len(w.Headers) == 0 {
+		return nil
+	}
+	return w.Headers[0]
+}
+
+// database creates an in-memory key-value store holding the trie nodes and
+// contract codes of the witness, keyed by their hashes the same way as in a
+// full node's database.
+func (w *Witness) database() gcldb.Database {
+	db := gcldb.NewMemDatabase()
+	for _, code := range w.Codes {
+		db.Put(crypto.Keccak256(code), code)
+	}
+	for _, node := range w.State {
+		db.Put(crypto.Keccak256(node), node)
+	}
+	return db
+}
@@ -0,0 +1,279 @@
+// Copyright 2019 The go-gclchaineum Authors
+// This file is part of the go-gclchaineum library.
+//
+// The go-gclchaineum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-gclchaineum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-gclchaineum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/gclchaineum/go-gclchaineum/common"
+	"github.com/gclchaineum/go-gclchaineum/core/types"
+	"github.com/gclchaineum/go-gclchaineum/log"
+)
+
+var (
+	// ErrSenderNotAllowed is returned if the sender of a transaction is denied
+	// or missing from the allowlist of the transaction pool policy.
+	ErrSenderNotAllowed = errors.New("sender not allowed")
+
+	// ErrCreationNotAllowed is returned if a transaction attempts to create a
+	// contract from an account not permitted to do so.
+	ErrCreationNotAllowed = errors.New("contract creation not allowed")
+
+	// ErrPolicyDataSize is returned if the calldata of a transaction exceeds the
+	// limit of the transaction pool policy.
+	ErrPolicyDataSize = errors.New("calldata exceeds policy limit")
+
+	// ErrRateLimited is returned if the sender of a transaction submitted more
+	// transactions than permitted within the rate limiting period.
+	ErrRateLimited = errors.New("sender rate limited")
+)
+
+// TxPolicy is an admission rule of the transaction pool, evaluated for every
+// newly submitted transaction that passed the basic validity checks. Reorged
+// transactions reinjected into the pool and those restored from disk are not
+// subject to the policies. Policies are called with the pool lock held, so they
+// are never invoked concurrently by the same pool.
+type TxPolicy interface {
+	// Validate returns an error if the transaction sent by the given account
+	// must not be admitted into the pool.
+	Validate(tx *types.Transaction, from common.Address, local bool) error
+}
+
+// TxPolicyRecorder is a stateful admission rule, which is additionally notified
+// of the submitted transactions the pool actually inserted, as transactions
+// passing the policies may still be rejected (e.g. as underpriced).
+type TxPolicyRecorder interface {
+	TxPolicy
+
+	// Admitted is called after a transaction accepted by Validate has been
+	// inserted into the pool.
+	Admitted(tx *types.Transaction, from common.Address, local bool)
+}
+
+// TxPolicyConfig are the configuration parameters of the built-in transaction
+// pool admission policies. The zero value admits every transaction.
+type TxPolicyConfig struct {
+	Allowlist []common.Address `json:"allowlist" toml:",omitempty"` // Senders permitted to submit transactions (empty = everyone)
+	Denylist  []common.Address `json:"denylist" toml:",omitempty"`  // Senders never permitted to submit transactions
+
+	RestrictCreation bool             `json:"restrictCreation"`           // Whether contract creation is limited to the creators below
+	Creators         []common.Address `json:"creators" toml:",omitempty"` // Senders permitted to create contracts if restricted
+
+	MaxDataSize uint64        `json:"maxDataSize"` // Maximum calldata size of a transaction in bytes (0 = unlimited)
+	RateLimit   uint64        `json:"rateLimit"`   // Maximum number of transactions per sender within a rate period (0 = unlimited)
+	RatePeriod  time.Duration `json:"ratePeriod"`  // Time period the rate limit applies to
+}
+
+// sanitize checks the provided policy configuration and changes anything that's
+// unreasonable or unworkable.
+func (config *TxPolicyConfig) sanitize() TxPolicyConfig {
+	conf := *config
+	if conf.RateLimit > 0 && conf.RatePeriod <= 0 {
+		log.Warn("Sanitizing invalid txpool rate period", "provided", conf.RatePeriod, "updated", time.Minute)
+		conf.RatePeriod = time.Minute
+	}
+	return conf
+}
+
+// validate checks that the policy configuration is usable.
+func (config *TxPolicyConfig) validate() error {
+	if config.RateLimit > 0 && config.RatePeriod <= 0 {
+		return errors.New("rate limit requires a positive rate period")
+	}
+	return nil
+}
+
+// build creates the built-in policies enabled by the configuration.
+func (config *TxPolicyConfig) build() []TxPolicy {
+	var policies []TxPolicy
+	if len(config.Allowlist) > 0 || len(config.Denylist) > 0 {
+		policies = append(policies, &senderPolicy{
+			allow: newAddressSet(config.Allowlist),
+			deny:  newAddressSet(config.Denylist),
+		})
+	}
+	if config.RestrictCreation {
+		policies = append(policies, &creationPolicy{creators: newAddressSet(config.Creators)})
+	}
+	if config.MaxDataSize > 0 {
+		policies = append(policies, dataSizePolicy(config.MaxDataSize))
+	}
+	if config.RateLimit > 0 {
+		policies = append(policies, newRatePolicy(config.RateLimit, config.RatePeriod))
+	}
+	return policies
+}
+
+// MarshalJSON encodes the configuration with the rate period as a duration
+// string (e.g. "1m30s") instead of nanoseconds.
+func (config TxPolicyConfig) MarshalJSON() ([]byte, error) {
+	type policyConfig TxPolicyConfig
+	enc := struct {
+		policyConfig
+		RatePeriod string `json:"ratePeriod"`
+	}{policyConfig: policyConfig(config)}
+	if config.RatePeriod != 0 {
+		enc.RatePeriod = config.RatePeriod.String()
+	}
+	return json.Marshal(enc)
+}
+
+// UnmarshalJSON decodes the configuration, accepting the rate period as a
+// duration string.
+func (config *TxPolicyConfig) UnmarshalJSON(input []byte) error {
+	type policyConfig TxPolicyConfig
+	dec := struct {
+		*policyConfig
+		RatePeriod string `json:"ratePeriod"`
+	}{policyConfig: (*policyConfig)(config)}
+	if err := json.Unmarshal(input, &dec); err != nil {
+		return err
+	}
+	config.RatePeriod = 0
+	if dec.RatePeriod != "" {
+		period, err := time.ParseDuration(dec.RatePeriod)
+		if err != nil {
+			return err
+		}
+		config.RatePeriod = period
+	}
+	return nil
+}
+
+// newAddressSet creates a lookup set from a list of addresses.
+func newAddressSet(addrs []common.Address) map[common.Address]struct{} {
+	set := make(map[common.Address]struct{}, len(addrs))
+	for _, addr := range addrs {
+		set[addr] = struct{}{}
+	}
+	return set
+}
+
+// senderPolicy rejects transactions from denied senders and, if an allowlist
+// is configured, from any sender not on it.
+type senderPolicy struct {
+	allow map[common.Address]struct{}
+	deny  map[common.Address]struct{}
+}
+
+// Validate implements TxPolicy.
+func (p *senderPolicy) Validate(tx *types.Transaction, from common.Address, local bool) error {
+	if _, ok := p.deny[from]; ok {
+		return ErrSenderNotAllowed
+	}
+	if len(p.allow) > 0 {
+		if _, ok := p.allow[from]; !ok {
+			return ErrSenderNotAllowed
+		}
+	}
+	return nil
+}
+
+// creationPolicy rejects contract creations from senders not permitted to
+// deploy contracts.
+type creationPolicy struct {
+	creators map[common.Address]struct{}
+}
+
+// Validate implements TxPolicy.
+func (p *creationPolicy) Validate(tx *types.Transaction, from common.Address, local bool) error {
+	if tx.To() != nil {
+		return nil
+	}
+	if _, ok := p.creators[from]; !ok {
+		return ErrCreationNotAllowed
+	}
+	return nil
+}
+
+// dataSizePolicy rejects transactions with calldata larger than the limit.
+type dataSizePolicy uint64
+
+// Validate implements TxPolicy.
+func (p dataSizePolicy) Validate(tx *types.Transaction, from common.Address, local bool) error {
+	if uint64(len(tx.Data())) > uint64(p) {
+		return ErrPolicyDataSize
+	}
+	return nil
+}
+
+// rateWindow tracks the transactions admitted from a sender within the current
+// rate limiting period.
+type rateWindow struct {
+	start time.Time
+	count uint64
+}
+
+// ratePolicy limits the number of transactions admitted from each sender within
+// fixed time periods.
+type ratePolicy struct {
+	limit   uint64
+	period  time.Duration
+	windows map[common.Address]*rateWindow
+	swept   time.Time        // Last time expired windows were dropped
+	now     func() time.Time // Clock source, replaceable in tests
+}
+
+// newRatePolicy creates a rate limiting policy admitting at most limit
+// transactions per sender within each period.
+func newRatePolicy(limit uint64, period time.Duration) *ratePolicy {
+	return &ratePolicy{
+		limit:   limit,
+		period:  period,
+		windows: make(map[common.Address]*rateWindow),
+		swept:   time.Now(),
+		now:     time.Now,
+	}
+}
+
+// Validate implements TxPolicy, rejecting the transaction if the sender has
+// already used up its admissions within the current period.
+func (p *ratePolicy) Validate(tx *types.Transaction, from common.Address, local bool) error {
+	if window := p.window(from); window.count >= p.limit {
+		return ErrRateLimited
+	}
+	return nil
+}
+
+// Admitted implements TxPolicyRecorder, counting the transaction against the
+// limit of the sender.
+func (p *ratePolicy) Admitted(tx *types.Transaction, from common.Address, local bool) {
+	p.window(from).count++
+}
+
+// window returns the rate limiting window of the sender for the current period.
+func (p *ratePolicy) window(from common.Address) *rateWindow {
+	now := p.now()
+
+	// Drop the windows of inactive senders every once in a while
+	if now.Sub(p.swept) >= p.period {
+		for addr, window := range p.windows {
+			if now.Sub(window.start) >= p.period {
+				delete(p.windows, addr)
+			}
+		}
+		p.swept = now
+	}
+	window := p.windows[from]
+	if window == nil || now.Sub(window.start) >= p.period {
+		window = &rateWindow{start: now}
+		p.windows[from] = window
+	}
+	return window
+}
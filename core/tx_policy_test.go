@@ -0,0 +1,264 @@
+// Copyright 2019 The go-gclchaineum Authors
+// This file is part of the go-gclchaineum library.
+//
+// The go-gclchaineum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-gclchaineum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-gclchaineum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"crypto/ecdsa"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gclchaineum/go-gclchaineum/common"
+	"github.com/gclchaineum/go-gclchaineum/core/state"
+	"github.com/gclchaineum/go-gclchaineum/core/types"
+	"github.com/gclchaineum/go-gclchaineum/crypto"
+	"github.com/gclchaineum/go-gclchaineum/event"
+	"github.com/gclchaineum/go-gclchaineum/gcldb"
+	"github.com/gclchaineum/go-gclchaineum/params"
+)
+
+// errBlockedNonce is returned by the custom test policy.
+var errBlockedNonce = errors.New("blocked nonce")
+
+// nonceTestPolicy is a custom policy rejecting transactions with a given nonce.
+type nonceTestPolicy uint64
+
+func (p nonceTestPolicy) Validate(tx *types.Transaction, from common.Address, local bool) error {
+	if tx.Nonce() == uint64(p) {
+		return errBlockedNonce
+	}
+	return nil
+}
+
+// Tests that the built-in and custom admission policies are enforced and that
+// the built-in ones can be replaced at runtime.
+func TestTransactionPolicies(t *testing.T) {
+	t.Parallel()
+
+	var (
+		allowed, _ = crypto.GenerateKey()
+		denied, _  = crypto.GenerateKey()
+		creator, _ = crypto.GenerateKey()
+	)
+	config := testTxPoolConfig
+	config.Policy = TxPolicyConfig{
+		Denylist:         []common.Address{crypto.PubkeyToAddress(denied.PublicKey)},
+		RestrictCreation: true,
+		Creators:         []common.Address{crypto.PubkeyToAddress(creator.PublicKey)},
+		MaxDataSize:      16,
+	}
+	config.Policies = []TxPolicy{nonceTestPolicy(5)}
+
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(gcldb.NewMemDatabase()))
+	blockchain := &testBlockChain{statedb, 1000000, new(event.Feed)}
+
+	pool := NewTxPool(config, params.TestChainConfig, blockchain)
+	defer pool.Stop()
+
+	for _, key := range []*ecdsa.PrivateKey{allowed, denied, creator} {
+		pool.currentState.AddBalance(crypto.PubkeyToAddress(key.PublicKey), big.NewInt(1000000000))
+	}
+	create := func(nonce uint64, key *ecdsa.PrivateKey) *types.Transaction {
+		tx, _ := types.SignTx(types.NewContractCreation(nonce, new(big.Int), 100000, big.NewInt(1), nil), types.HomesteadSigner{}, key)
+		return tx
+	}
+	call := func(nonce uint64, data []byte, key *ecdsa.PrivateKey) *types.Transaction {
+		tx, _ := types.SignTx(types.NewTransaction(nonce, common.Address{}, new(big.Int), 100000, big.NewInt(1), data), types.HomesteadSigner{}, key)
+		return tx
+	}
+	tests := []struct {
+		tx  *types.Transaction
+		err error
+	}{
+		{transaction(0, 100000, allowed), nil},
+		{transaction(0, 100000, denied), ErrSenderNotAllowed},
+		{create(1, allowed), ErrCreationNotAllowed},
+		{create(0, creator), nil},
+		{call(1, make([]byte, 16), allowed), nil},
+		{call(2, make([]byte, 17), allowed), ErrPolicyDataSize},
+		{transaction(5, 100000, allowed), errBlockedNonce},
+	}
+	for i, tt := range tests {
+		if err := pool.AddRemote(tt.tx); err != tt.err {
+			t.Errorf("test %d: admission error mismatch: have %v, want %v", i, err, tt.err)
+		}
+	}
+	// Replace the built-in policies with an allowlist, keeping the custom one
+	if err := pool.SetTxPolicy(TxPolicyConfig{RateLimit: 1}); err == nil {
+		t.Fatalf("rate limit without period accepted")
+	}
+	policy := TxPolicyConfig{Allowlist: []common.Address{crypto.PubkeyToAddress(denied.PublicKey)}}
+	if err := pool.SetTxPolicy(policy); err != nil {
+		t.Fatalf("failed to update policies: %v", err)
+	}
+	if have := pool.TxPolicy(); !reflect.DeepEqual(have, policy) {
+		t.Errorf("policy mismatch: have %+v, want %+v", have, policy)
+	}
+	if err := pool.AddRemote(transaction(2, 100000, allowed)); err != ErrSenderNotAllowed {
+		t.Errorf("disallowed sender error mismatch: have %v, want %v", err, ErrSenderNotAllowed)
+	}
+	if err := pool.AddRemote(transaction(0, 100000, denied)); err != nil {
+		t.Errorf("failed to add allowed transaction: %v", err)
+	}
+	if err := pool.AddRemote(transaction(5, 100000, denied)); err != errBlockedNonce {
+		t.Errorf("custom policy error mismatch: have %v, want %v", err, errBlockedNonce)
+	}
+	if pending, _ := pool.Stats(); pending != 4 {
+		t.Errorf("pending transactions mismatch: have %d, want %d", pending, 4)
+	}
+	if err := validateTxPoolInternals(pool); err != nil {
+		t.Fatalf("pool internal state corrupted: %v", err)
+	}
+}
+
+// Tests that the rate limiting policy admits a limited number of transactions
+// per sender within each period, counting only the admitted ones.
+func TestTransactionPolicyRateLimit(t *testing.T) {
+	var (
+		policy = newRatePolicy(2, time.Minute)
+		now    = time.Now()
+		first  = common.Address{0x01}
+		second = common.Address{0x02}
+		tx     = types.NewTransaction(0, common.Address{}, new(big.Int), 0, new(big.Int), nil)
+	)
+	policy.now = func() time.Time { return now }
+
+	admit := func(from common.Address) error {
+		if err := policy.Validate(tx, from, false); err != nil {
+			return err
+		}
+		policy.Admitted(tx, from, false)
+		return nil
+	}
+	// Validating without admitting must not count against the limit
+	for i := 0; i < 3; i++ {
+		if err := policy.Validate(tx, first, false); err != nil {
+			t.Fatalf("validation %d: failed to validate: %v", i, err)
+		}
+	}
+	for i := 0; i < 2; i++ {
+		if err := admit(first); err != nil {
+			t.Fatalf("transaction %d: failed to admit: %v", i, err)
+		}
+	}
+	if err := admit(first); err != ErrRateLimited {
+		t.Fatalf("error mismatch: have %v, want %v", err, ErrRateLimited)
+	}
+	if err := admit(second); err != nil {
+		t.Fatalf("failed to admit other sender: %v", err)
+	}
+	// Once the period elapses, the sender may submit again and idle senders
+	// are forgotten
+	now = now.Add(time.Minute)
+	if err := admit(first); err != nil {
+		t.Fatalf("failed to admit after period: %v", err)
+	}
+	if len(policy.windows) != 1 {
+		t.Errorf("tracked senders mismatch: have %d, want %d", len(policy.windows), 1)
+	}
+}
+
+// Tests that the pool only counts inserted transactions against the rate limit
+// and applies the policies to new submissions only, not to transactions being
+// reinjected or restored.
+func TestTransactionPolicyAdmission(t *testing.T) {
+	t.Parallel()
+
+	var (
+		key, _    = crypto.GenerateKey()
+		denied, _ = crypto.GenerateKey()
+	)
+	config := testTxPoolConfig
+	config.Policy = TxPolicyConfig{
+		Denylist:   []common.Address{crypto.PubkeyToAddress(denied.PublicKey)},
+		RateLimit:  2,
+		RatePeriod: time.Hour,
+	}
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(gcldb.NewMemDatabase()))
+	blockchain := &testBlockChain{statedb, 1000000, new(event.Feed)}
+
+	pool := NewTxPool(config, params.TestChainConfig, blockchain)
+	defer pool.Stop()
+
+	for _, key := range []*ecdsa.PrivateKey{key, denied} {
+		pool.currentState.AddBalance(crypto.PubkeyToAddress(key.PublicKey), big.NewInt(1000000000))
+	}
+	// Rejected replacements must not use up the rate limit
+	if err := pool.AddRemote(pricedTransaction(0, 100000, big.NewInt(10), key)); err != nil {
+		t.Fatalf("failed to add transaction: %v", err)
+	}
+	if err := pool.AddRemote(pricedTransaction(0, 100001, big.NewInt(10), key)); err != ErrReplaceUnderpriced {
+		t.Fatalf("replacement error mismatch: have %v, want %v", err, ErrReplaceUnderpriced)
+	}
+	if err := pool.AddRemote(pricedTransaction(1, 100000, big.NewInt(10), key)); err != nil {
+		t.Fatalf("failed to add transaction after rejected replacement: %v", err)
+	}
+	if err := pool.AddRemote(pricedTransaction(2, 100000, big.NewInt(10), key)); err != ErrRateLimited {
+		t.Fatalf("rate limit error mismatch: have %v, want %v", err, ErrRateLimited)
+	}
+	// Transactions reinjected or restored bypass the policies and the rate limit
+	errs := pool.addTxs([]*types.Transaction{
+		pricedTransaction(2, 100000, big.NewInt(10), key),
+		pricedTransaction(0, 100000, big.NewInt(10), denied),
+	}, false, false)
+	for i, err := range errs {
+		if err != nil {
+			t.Errorf("restored transaction %d: failed to add: %v", i, err)
+		}
+	}
+	if err := pool.AddRemote(pricedTransaction(1, 100000, big.NewInt(10), denied)); err != ErrSenderNotAllowed {
+		t.Errorf("denied sender error mismatch: have %v, want %v", err, ErrSenderNotAllowed)
+	}
+	if pending, _ := pool.Stats(); pending != 4 {
+		t.Errorf("pending transactions mismatch: have %d, want %d", pending, 4)
+	}
+	if err := validateTxPoolInternals(pool); err != nil {
+		t.Fatalf("pool internal state corrupted: %v", err)
+	}
+}
+
+// Tests that the policy configuration round-trips through JSON with the rate
+// period as a duration string.
+func TestTxPolicyConfigJSON(t *testing.T) {
+	config := TxPolicyConfig{
+		Denylist:    []common.Address{{0x01}},
+		MaxDataSize: 1024,
+		RateLimit:   10,
+		RatePeriod:  90 * time.Second,
+	}
+	blob, err := json.Marshal(config)
+	if err != nil {
+		t.Fatalf("failed to encode config: %v", err)
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(blob, &fields); err != nil {
+		t.Fatalf("failed to decode fields: %v", err)
+	}
+	if fields["ratePeriod"] != "1m30s" {
+		t.Errorf("rate period mismatch: have %v, want %v", fields["ratePeriod"], "1m30s")
+	}
+	var decoded TxPolicyConfig
+	if err := json.Unmarshal(blob, &decoded); err != nil {
+		t.Fatalf("failed to decode config: %v", err)
+	}
+	if !reflect.DeepEqual(decoded, config) {
+		t.Errorf("config mismatch: have %+v, want %+v", decoded, config)
+	}
+}
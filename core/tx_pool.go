@@ -111,6 +111,40 @@ const (
 	TxStatusIncluded
 )
 
+// TxQueuedReason is the reason a queued transaction is not executable.
+type TxQueuedReason uint
+
+const (
+	TxQueuedUnknown            TxQueuedReason = iota // Executable, awaiting promotion
+	TxQueuedNonceGap                                 // A lower nonce of the account is missing
+	TxQueuedInsufficientFunds                        // Balance can't cover its cost
+	TxQueuedReplaceUnderpriced                       // Overlaps an executable transaction without the price bump
+	TxQueuedStale                                    // Nonce already used by the account, awaiting removal
+)
+
+// String implements fmt.Stringer.
+func (reason TxQueuedReason) String() string {
+	switch reason {
+	case TxQueuedNonceGap:
+		return "nonce gap"
+	case TxQueuedInsufficientFunds:
+		return "insufficient funds"
+	case TxQueuedReplaceUnderpriced:
+		return "replacement underpriced"
+	case TxQueuedStale:
+		return "stale nonce"
+	default:
+		return "unknown"
+	}
+}
+
+// TxQueuedInfo is a queued transaction along with the reason it is not executable.
+type TxQueuedInfo struct {
+	Tx           *types.Transaction
+	Reason       TxQueuedReason
+	MissingNonce uint64 // First missing nonce of the account for nonce gaps
+}
+
 // blockChain provides the state of blockchain and current gas limit to do
 // some pre checks in tx pool and event subscribers.
 type blockChain interface {
@@ -128,6 +162,9 @@ type TxPoolConfig struct {
 	Journal   string           // Journal of local transactions to survive node restarts
 	Rejournal time.Duration    // Time interval to regenerate the local transaction journal
 
+	Snapshot   string        // Snapshot of all pending and queued transactions to survive node restarts (empty = disabled)
+	Resnapshot time.Duration // Time interval to regenerate the transaction snapshot
+
 	PriceLimit uint64 // Minimum gas price to enforce for acceptance into the pool
 	PriceBump  uint64 // Minimum price bump percentage to replace an already existing transaction (nonce)
 
@@ -137,6 +174,9 @@ type TxPoolConfig struct {
 	GlobalQueue  uint64 // Maximum number of non-executable transaction slots for all accounts
 
 	Lifetime time.Duration // Maximum amount of time non-executable transaction are queued
+
+	Policy   TxPolicyConfig // Built-in admission policies, reloadable at runtime
+	Policies []TxPolicy     `toml:"-"` // Custom admission policies evaluated after the built-in ones
 }
 
 // DefaultTxPoolConfig contains the default configurations for the transaction
@@ -145,6 +185,8 @@ var DefaultTxPoolConfig = TxPoolConfig{
 	Journal:   "transactions.rlp",
 	Rejournal: time.Hour,
 
+	Resnapshot: 10 * time.Minute,
+
 	PriceLimit: 1,
 	PriceBump:  10,
 
@@ -164,6 +206,10 @@ func (config *TxPoolConfig) sanitize() TxPoolConfig {
 		log.Warn("Sanitizing invalid txpool journal time", "provided", conf.Rejournal, "updated", time.Second)
 		conf.Rejournal = time.Second
 	}
+	if conf.Resnapshot < time.Second {
+		log.Warn("Sanitizing invalid txpool snapshot time", "provided", conf.Resnapshot, "updated", time.Second)
+		conf.Resnapshot = time.Second
+	}
 	if conf.PriceLimit < 1 {
 		log.Warn("Sanitizing invalid txpool price limit", "provided", conf.PriceLimit, "updated", DefaultTxPoolConfig.PriceLimit)
 		conf.PriceLimit = DefaultTxPoolConfig.PriceLimit
@@ -192,6 +238,7 @@ func (config *TxPoolConfig) sanitize() TxPoolConfig {
 		log.Warn("Sanitizing invalid txpool lifetime", "provided", conf.Lifetime, "updated", DefaultTxPoolConfig.Lifetime)
 		conf.Lifetime = DefaultTxPoolConfig.Lifetime
 	}
+	conf.Policy = conf.Policy.sanitize()
 	return conf
 }
 
@@ -212,6 +259,7 @@ type TxPool struct {
 	chainHeadCh  chan ChainHeadEvent
 	chainHeadSub event.Subscription
 	signer       types.Signer
+	policies     []TxPolicy
 	mu           sync.RWMutex
 
 	currentState  *state.StateDB      // Current state in the blockchain head
@@ -221,6 +269,8 @@ type TxPool struct {
 	locals  *accountSet // Set of local transaction to exempt from eviction rules
 	journal *txJournal  // Journal of local transaction to back up to disk
 
+	snapshot *txSnapshot // Snapshot of all transactions to back up to disk
+
 	pending map[common.Address]*txList   // All currently processable transactions
 	queue   map[common.Address]*txList   // Queued but non-processable transactions
 	beats   map[common.Address]time.Time // Last heartbeat from each known account
@@ -229,7 +279,8 @@ type TxPool struct {
 
 	wg sync.WaitGroup // for shutdown sync
 
-	homestead bool
+	homestead bool // Fork indicator whether we are in the homestead stage
+	istanbul  bool // Fork indicator whether we are in the istanbul stage
 }
 
 // NewTxPool creates a new transaction pool to gather, sort and filter inbound
@@ -251,6 +302,7 @@ func NewTxPool(config TxPoolConfig, chainconfig *params.ChainConfig, chain block
 		chainHeadCh: make(chan ChainHeadEvent, chainHeadChanSize),
 		gasPrice:    new(big.Int).SetUint64(config.PriceLimit),
 	}
+	pool.policies = append(config.Policy.build(), config.Policies...)
 	pool.locals = newAccountSet(pool.signer)
 	for _, addr := range config.Locals {
 		log.Info("Setting new local account", "address", addr)
@@ -263,13 +315,21 @@ func NewTxPool(config TxPoolConfig, chainconfig *params.ChainConfig, chain block
 	if !config.NoLocals && config.Journal != "" {
 		pool.journal = newTxJournal(config.Journal)
 
-		if err := pool.journal.load(pool.AddLocals); err != nil {
+		if err := pool.journal.load(pool.addRestored); err != nil {
 			log.Warn("Failed to load transaction journal", "err", err)
 		}
 		if err := pool.journal.rotate(pool.local()); err != nil {
 			log.Warn("Failed to rotate transaction journal", "err", err)
 		}
 	}
+	// If snapshotting is enabled, reload all previously pooled transactions
+	if config.Snapshot != "" {
+		pool.snapshot = newTxSnapshot(config.Snapshot)
+
+		if err := pool.snapshot.load(pool.addSnapshotted); err != nil {
+			log.Warn("Failed to load transaction snapshot", "err", err)
+		}
+	}
 	// Subscribe events from blockchain
 	pool.chainHeadSub = pool.chain.SubscribeChainHeadEvent(pool.chainHeadCh)
 
@@ -298,6 +358,9 @@ func (pool *TxPool) loop() {
 	journal := time.NewTicker(pool.config.Rejournal)
 	defer journal.Stop()
 
+	snapshot := time.NewTicker(pool.config.Resnapshot)
+	defer snapshot.Stop()
+
 	// Track the previous head headers for transaction reorgs
 	head := pool.chain.CurrentBlock()
 
@@ -358,6 +421,10 @@ func (pool *TxPool) loop() {
 				}
 				pool.mu.Unlock()
 			}
+
+		// Handle transaction snapshot regeneration
+		case <-snapshot.C:
+			pool.saveSnapshot()
 		}
 	}
 }
@@ -434,10 +501,14 @@ func (pool *TxPool) reset(oldHead, newHead *types.Header) {
 	pool.pendingState = state.ManageState(statedb)
 	pool.currentMaxGas = newHead.GasLimit
 
+	// Update the fork rules affecting transaction validation for the next block
+	next := new(big.Int).Add(newHead.Number, big.NewInt(1))
+	pool.istanbul = pool.chainconfig.IsIstanbul(next)
+
 	// Inject any transactions discarded due to reorgs
 	log.Debug("Reinjecting stale transactions", "count", len(reinject))
 	senderCacher.recover(pool.signer, reinject)
-	pool.addTxsLocked(reinject, false)
+	pool.addTxsLocked(reinject, false, false)
 
 	// validate the pool of pending transactions, this will remove
 	// any transactions that have been included in the block or
@@ -467,6 +538,7 @@ func (pool *TxPool) Stop() {
 	if pool.journal != nil {
 		pool.journal.close()
 	}
+	pool.saveSnapshot()
 	log.Info("Transaction pool stopped")
 }
 
@@ -497,6 +569,32 @@ func (pool *TxPool) SetGasPrice(price *big.Int) {
 	log.Info("Transaction pool price threshold updated", "price", price)
 }
 
+// TxPolicy returns the configuration of the built-in admission policies.
+func (pool *TxPool) TxPolicy() TxPolicyConfig {
+	pool.mu.RLock()
+	defer pool.mu.RUnlock()
+
+	return pool.config.Policy
+}
+
+// SetTxPolicy replaces the built-in admission policies of the transaction pool,
+// keeping any custom ones. Transactions already in the pool are not affected.
+func (pool *TxPool) SetTxPolicy(config TxPolicyConfig) error {
+	if err := config.validate(); err != nil {
+		return err
+	}
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	pool.config.Policy = config
+	pool.policies = append(config.build(), pool.config.Policies...)
+
+	log.Info("Transaction pool policies updated", "allowed", len(config.Allowlist), "denied", len(config.Denylist),
+		"creators", len(config.Creators), "restrict", config.RestrictCreation, "maxdata", config.MaxDataSize,
+		"ratelimit", config.RateLimit, "rateperiod", config.RatePeriod)
+	return nil
+}
+
 // State returns the virtual managed state of the transaction pool.
 func (pool *TxPool) State() *state.ManagedState {
 	pool.mu.RLock()
@@ -545,6 +643,58 @@ func (pool *TxPool) Content() (map[common.Address]types.Transactions, map[common
 	return pending, queued
 }
 
+// ContentFrom retrieves the data content of the transaction pool for a single
+// account, returning its pending and queued transactions sorted by nonce, along
+// with the reason each queued one is not executable.
+func (pool *TxPool) ContentFrom(addr common.Address) (types.Transactions, []TxQueuedInfo) {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	var pending types.Transactions
+	if list := pool.pending[addr]; list != nil {
+		pending = list.Flatten()
+	}
+	var queued []TxQueuedInfo
+	if list := pool.queue[addr]; list != nil {
+		queued = pool.diagnoseQueued(addr, list)
+	}
+	return pending, queued
+}
+
+// diagnoseQueued determines why each of the queued transactions of an account
+// is not executable. Like the pool when promoting, each transaction is checked
+// against the balance individually, and those unaffordable leave a nonce gap
+// blocking the ones above.
+func (pool *TxPool) diagnoseQueued(addr common.Address, queue *txList) []TxQueuedInfo {
+	var (
+		next    = pool.pendingState.GetNonce(addr)
+		balance = pool.currentState.GetBalance(addr)
+		pending = pool.pending[addr]
+		txs     = queue.Flatten()
+	)
+
+	infos := make([]TxQueuedInfo, len(txs))
+	for i, tx := range txs {
+		infos[i].Tx = tx
+
+		switch {
+		case tx.Nonce() < next:
+			if pending != nil && pending.Overlaps(tx) {
+				infos[i].Reason = TxQueuedReplaceUnderpriced
+			} else {
+				infos[i].Reason = TxQueuedStale
+			}
+		case tx.Cost().Cmp(balance) > 0:
+			infos[i].Reason = TxQueuedInsufficientFunds
+		case tx.Nonce() > next:
+			infos[i].Reason, infos[i].MissingNonce = TxQueuedNonceGap, next
+		default:
+			next++
+		}
+	}
+	return infos
+}
+
 // Pending retrieves all currently processable transactions, grouped by origin
 // account and sorted by nonce. The returned transaction set is a copy and can be
 // freely modified by calling code.
@@ -583,6 +733,62 @@ func (pool *TxPool) local() map[common.Address]types.Transactions {
 	return txs
 }
 
+// snapshotted retrieves all pending and queued transactions along with their
+// local status, sorted by nonce within each account.
+func (pool *TxPool) snapshotted() []snapshotTx {
+	var txs []snapshotTx
+	for addr, list := range pool.pending {
+		local := pool.locals.contains(addr)
+		for _, tx := range list.Flatten() {
+			txs = append(txs, snapshotTx{Tx: tx, Local: local})
+		}
+	}
+	for addr, list := range pool.queue {
+		local := pool.locals.contains(addr)
+		for _, tx := range list.Flatten() {
+			txs = append(txs, snapshotTx{Tx: tx, Local: local})
+		}
+	}
+	return txs
+}
+
+// saveSnapshot regenerates the transaction snapshot on disk, if enabled.
+func (pool *TxPool) saveSnapshot() {
+	if pool.snapshot == nil {
+		return
+	}
+	// Flattening the lists caches their sorted contents, so a read lock is not enough
+	pool.mu.Lock()
+	txs := pool.snapshotted()
+	pool.mu.Unlock()
+
+	if err := pool.snapshot.save(txs); err != nil {
+		log.Warn("Failed to save transaction snapshot", "err", err)
+	}
+}
+
+// addSnapshotted revalidates and injects transactions loaded from the snapshot
+// into the pool, keeping their local status but bypassing the admission policies.
+// Transactions already known (e.g. restored from the local journal) are skipped
+// silently.
+func (pool *TxPool) addSnapshotted(txs []*types.Transaction, local bool) []error {
+	var (
+		errs    = make([]error, len(txs))
+		unknown = make([]*types.Transaction, 0, len(txs))
+		indices = make([]int, 0, len(txs))
+	)
+	for i, tx := range txs {
+		if pool.Get(tx.Hash()) == nil {
+			unknown = append(unknown, tx)
+			indices = append(indices, i)
+		}
+	}
+	for i, err := range pool.addTxs(unknown, local && !pool.config.NoLocals, false) {
+		errs[indices[i]] = err
+	}
+	return errs
+}
+
 // validateTx checks whgclchain a transaction is valid according to the consensus
 // rules and adheres to some heuristic limits of the local node (price and size).
 func (pool *TxPool) validateTx(tx *types.Transaction, local bool) error {
@@ -618,7 +824,7 @@ func (pool *TxPool) validateTx(tx *types.Transaction, local bool) error {
 	if pool.currentState.GetBalance(from).Cmp(tx.Cost()) < 0 {
 		return ErrInsufficientFunds
 	}
-	intrGas, err := IntrinsicGas(tx.Data(), tx.To() == nil, pool.homestead)
+	intrGas, err := IntrinsicGas(tx.Data(), tx.To() == nil, pool.homestead, pool.istanbul)
 	if err != nil {
 		return err
 	}
@@ -628,6 +834,27 @@ func (pool *TxPool) validateTx(tx *types.Transaction, local bool) error {
 	return nil
 }
 
+// validatePolicies checks a newly submitted transaction against the admission
+// policies of the pool.
+func (pool *TxPool) validatePolicies(tx *types.Transaction, from common.Address, local bool) error {
+	for _, policy := range pool.policies {
+		if err := policy.Validate(tx, from, local); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// recordAdmission notifies the stateful admission policies of a newly submitted
+// transaction having been inserted into the pool.
+func (pool *TxPool) recordAdmission(tx *types.Transaction, from common.Address, local bool) {
+	for _, policy := range pool.policies {
+		if recorder, ok := policy.(TxPolicyRecorder); ok {
+			recorder.Admitted(tx, from, local)
+		}
+	}
+}
+
 // add validates a transaction and inserts it into the non-executable queue for
 // later pending promotion and execution. If the transaction is a replacement for
 // an already pending or queued one, it overwrites the previous and returns this
@@ -636,7 +863,10 @@ func (pool *TxPool) validateTx(tx *types.Transaction, local bool) error {
 // If a newly added transaction is marked as local, its sending account will be
 // whitelisted, preventing any associated transaction from being dropped out of
 // the pool due to pricing constraints.
-func (pool *TxPool) add(tx *types.Transaction, local bool) (bool, error) {
+//
+// Only newly submitted transactions are subject to the admission policies, not
+// the ones reinjected after a reorg or restored from disk.
+func (pool *TxPool) add(tx *types.Transaction, local, submitted bool) (bool, error) {
 	// If the transaction is already known, discard it
 	hash := tx.Hash()
 	if pool.all.Get(hash) != nil {
@@ -649,6 +879,13 @@ func (pool *TxPool) add(tx *types.Transaction, local bool) (bool, error) {
 		invalidTxCounter.Inc(1)
 		return false, err
 	}
+	from, _ := types.Sender(pool.signer, tx) // already validated
+	if submitted {
+		if err := pool.validatePolicies(tx, from, local || pool.locals.contains(from)); err != nil {
+			log.Trace("Discarding transaction rejected by policy", "hash", hash, "err", err)
+			return false, err
+		}
+	}
 	// If the transaction pool is full, discard underpriced transactions
 	if uint64(pool.all.Count()) >= pool.config.GlobalSlots+pool.config.GlobalQueue {
 		// If the new transaction is underpriced, don't accept it
@@ -666,7 +903,6 @@ func (pool *TxPool) add(tx *types.Transaction, local bool) (bool, error) {
 		}
 	}
 	// If the transaction is replacing an already pending one, do directly
-	from, _ := types.Sender(pool.signer, tx) // already validated
 	if list := pool.pending[from]; list != nil && list.Overlaps(tx) {
 		// Nonce already pending, check if required price bump is met
 		inserted, old := list.Add(tx, pool.config.PriceBump)
@@ -683,7 +919,9 @@ func (pool *TxPool) add(tx *types.Transaction, local bool) (bool, error) {
 		pool.all.Add(tx)
 		pool.priced.Put(tx)
 		pool.journalTx(from, tx)
-
+		if submitted {
+			pool.recordAdmission(tx, from, local || pool.locals.contains(from))
+		}
 		log.Trace("Pooled new executable transaction", "hash", hash, "from", from, "to", tx.To())
 
 		// We've directly injected a replacement transaction, notify subsystems
@@ -704,7 +942,9 @@ func (pool *TxPool) add(tx *types.Transaction, local bool) (bool, error) {
 		}
 	}
 	pool.journalTx(from, tx)
-
+	if submitted {
+		pool.recordAdmission(tx, from, local || pool.locals.contains(from))
+	}
 	log.Trace("Pooled new future transaction", "hash", hash, "from", from, "to", tx.To())
 	return replace, nil
 }
@@ -806,14 +1046,20 @@ func (pool *TxPool) AddRemote(tx *types.Transaction) error {
 // marking the senders as a local ones in the mean time, ensuring they go around
 // the local pricing constraints.
 func (pool *TxPool) AddLocals(txs []*types.Transaction) []error {
-	return pool.addTxs(txs, !pool.config.NoLocals)
+	return pool.addTxs(txs, !pool.config.NoLocals, true)
 }
 
 // AddRemotes enqueues a batch of transactions into the pool if they are valid.
 // If the senders are not among the locally tracked ones, full pricing constraints
 // will apply.
 func (pool *TxPool) AddRemotes(txs []*types.Transaction) []error {
-	return pool.addTxs(txs, false)
+	return pool.addTxs(txs, false, true)
+}
+
+// addRestored enqueues a batch of local transactions restored from the journal,
+// bypassing the admission policies applied on submission.
+func (pool *TxPool) addRestored(txs []*types.Transaction) []error {
+	return pool.addTxs(txs, !pool.config.NoLocals, false)
 }
 
 // addTx enqueues a single transaction into the pool if it is valid.
@@ -822,7 +1068,7 @@ func (pool *TxPool) addTx(tx *types.Transaction, local bool) error {
 	defer pool.mu.Unlock()
 
 	// Try to inject the transaction and update any state
-	replace, err := pool.add(tx, local)
+	replace, err := pool.add(tx, local, true)
 	if err != nil {
 		return err
 	}
@@ -835,23 +1081,23 @@ func (pool *TxPool) addTx(tx *types.Transaction, local bool) error {
 }
 
 // addTxs attempts to queue a batch of transactions if they are valid.
-func (pool *TxPool) addTxs(txs []*types.Transaction, local bool) []error {
+func (pool *TxPool) addTxs(txs []*types.Transaction, local, submitted bool) []error {
 	pool.mu.Lock()
 	defer pool.mu.Unlock()
 
-	return pool.addTxsLocked(txs, local)
+	return pool.addTxsLocked(txs, local, submitted)
 }
 
 // addTxsLocked attempts to queue a batch of transactions if they are valid,
 // whilst assuming the transaction pool lock is already held.
-func (pool *TxPool) addTxsLocked(txs []*types.Transaction, local bool) []error {
+func (pool *TxPool) addTxsLocked(txs []*types.Transaction, local, submitted bool) []error {
 	// Add the batch of transactions, tracking the accepted ones
 	dirty := make(map[common.Address]struct{})
 	errs := make([]error, len(txs))
 
 	for i, tx := range txs {
 		var replace bool
-		if replace, errs[i] = pool.add(tx, local); errs[i] == nil && !replace {
+		if replace, errs[i] = pool.add(tx, local, submitted); errs[i] == nil && !replace {
 			from, _ := types.Sender(pool.signer, tx) // already validated
 			dirty[from] = struct{}{}
 		}
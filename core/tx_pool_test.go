@@ -23,6 +23,7 @@ import (
 	"math/big"
 	"math/rand"
 	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -344,14 +345,14 @@ func TestTransactionChainFork(t *testing.T) {
 	resetState()
 
 	tx := transaction(0, 100000, key)
-	if _, err := pool.add(tx, false); err != nil {
+	if _, err := pool.add(tx, false, true); err != nil {
 		t.Error("didn't expect error", err)
 	}
 	pool.removeTx(tx.Hash(), true)
 
 	// reset the pool's internal state
 	resetState()
-	if _, err := pool.add(tx, false); err != nil {
+	if _, err := pool.add(tx, false, true); err != nil {
 		t.Error("didn't expect error", err)
 	}
 }
@@ -378,10 +379,10 @@ func TestTransactionDoubleNonce(t *testing.T) {
 	tx3, _ := types.SignTx(types.NewTransaction(0, common.Address{}, big.NewInt(100), 1000000, big.NewInt(1), nil), signer, key)
 
 	// Add the first two transaction, ensure higher priced stays only
-	if replace, err := pool.add(tx1, false); err != nil || replace {
+	if replace, err := pool.add(tx1, false, true); err != nil || replace {
 		t.Errorf("first transaction insert failed (%v) or reported replacement (%v)", err, replace)
 	}
-	if replace, err := pool.add(tx2, false); err != nil || !replace {
+	if replace, err := pool.add(tx2, false, true); err != nil || !replace {
 		t.Errorf("second transaction insert failed (%v) or not reported replacement (%v)", err, replace)
 	}
 	pool.promoteExecutables([]common.Address{addr})
@@ -392,7 +393,7 @@ func TestTransactionDoubleNonce(t *testing.T) {
 		t.Errorf("transaction mismatch: have %x, want %x", tx.Hash(), tx2.Hash())
 	}
 	// Add the third transaction and ensure it's not saved (smaller price)
-	pool.add(tx3, false)
+	pool.add(tx3, false, true)
 	pool.promoteExecutables([]common.Address{addr})
 	if pool.pending[addr].Len() != 1 {
 		t.Error("expected 1 pending transactions, got", pool.pending[addr].Len())
@@ -415,7 +416,7 @@ func TestTransactionMissingNonce(t *testing.T) {
 	addr := crypto.PubkeyToAddress(key.PublicKey)
 	pool.currentState.AddBalance(addr, big.NewInt(100000000000000))
 	tx := transaction(1, 100000, key)
-	if _, err := pool.add(tx, false); err != nil {
+	if _, err := pool.add(tx, false, true); err != nil {
 		t.Error("didn't expect error", err)
 	}
 	if len(pool.pending) != 0 {
@@ -1680,6 +1681,138 @@ func testTransactionJournaling(t *testing.T, nolocals bool) {
 	pool.Stop()
 }
 
+// Tests that both local and remote transactions are persisted into the snapshot
+// and revalidated on restart, keeping their local status.
+func TestTransactionSnapshotting(t *testing.T)        { testTransactionSnapshotting(t, false) }
+func TestTransactionSnapshottingJournal(t *testing.T) { testTransactionSnapshotting(t, true) }
+
+func testTransactionSnapshotting(t *testing.T, journal bool) {
+	t.Parallel()
+
+	// Create a temporary directory for the snapshot and journal
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Create the original pool to inject transaction into the snapshot
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(gcldb.NewMemDatabase()))
+	blockchain := &testBlockChain{statedb, 1000000, new(event.Feed)}
+
+	config := testTxPoolConfig
+	config.Snapshot = filepath.Join(dir, "snapshot.rlp")
+	if journal {
+		config.Journal = filepath.Join(dir, "journal.rlp")
+	}
+	pool := NewTxPool(config, params.TestChainConfig, blockchain)
+
+	local, _ := crypto.GenerateKey()
+	remote, _ := crypto.GenerateKey()
+
+	pool.currentState.AddBalance(crypto.PubkeyToAddress(local.PublicKey), big.NewInt(1000000000))
+	pool.currentState.AddBalance(crypto.PubkeyToAddress(remote.PublicKey), big.NewInt(1000000000))
+
+	// Add pending local and remote transactions, and a gapped remote one
+	if err := pool.AddLocal(pricedTransaction(0, 100000, big.NewInt(1), local)); err != nil {
+		t.Fatalf("failed to add local transaction: %v", err)
+	}
+	if err := pool.AddLocal(pricedTransaction(1, 100000, big.NewInt(1), local)); err != nil {
+		t.Fatalf("failed to add local transaction: %v", err)
+	}
+	if err := pool.AddRemote(pricedTransaction(0, 100000, big.NewInt(1), remote)); err != nil {
+		t.Fatalf("failed to add remote transaction: %v", err)
+	}
+	if err := pool.AddRemote(pricedTransaction(2, 100000, big.NewInt(1), remote)); err != nil {
+		t.Fatalf("failed to add remote transaction: %v", err)
+	}
+	if pending, queued := pool.Stats(); pending != 3 || queued != 1 {
+		t.Fatalf("transaction counts mismatched: have %d/%d, want %d/%d", pending, queued, 3, 1)
+	}
+	// Terminate the old pool, bump the local nonce, create a new pool and ensure
+	// the still valid transactions survive
+	pool.Stop()
+	statedb.SetNonce(crypto.PubkeyToAddress(local.PublicKey), 1)
+	blockchain = &testBlockChain{statedb, 1000000, new(event.Feed)}
+
+	pool = NewTxPool(config, params.TestChainConfig, blockchain)
+	defer pool.Stop()
+
+	if pending, queued := pool.Stats(); pending != 2 || queued != 1 {
+		t.Fatalf("transaction counts mismatched: have %d/%d, want %d/%d", pending, queued, 2, 1)
+	}
+	if !pool.locals.contains(crypto.PubkeyToAddress(local.PublicKey)) {
+		t.Errorf("local account not restored as local")
+	}
+	if pool.locals.contains(crypto.PubkeyToAddress(remote.PublicKey)) {
+		t.Errorf("remote account restored as local")
+	}
+	if err := validateTxPoolInternals(pool); err != nil {
+		t.Fatalf("pool internal state corrupted: %v", err)
+	}
+}
+
+// Tests that the pool reports why queued transactions of an account are not
+// executable.
+func TestTransactionQueuedDiagnostics(t *testing.T) {
+	t.Parallel()
+
+	pool, key := setupTxPool()
+	defer pool.Stop()
+
+	account, _ := deriveSender(transaction(0, 0, key))
+	pool.currentState.AddBalance(account, big.NewInt(1000000))
+	pool.currentState.SetNonce(account, 1)
+	pool.lockedReset(nil, nil)
+
+	if err := pool.AddRemote(pricedTransaction(1, 100000, big.NewInt(1), key)); err != nil {
+		t.Fatalf("failed to add pending transaction: %v", err)
+	}
+	// Inject transactions the pool wouldn't keep queued on its own: a stale one,
+	// one overlapping the pending one, an executable one affordable on its own
+	// but not along with the pending one, one exceeding the balance and one
+	// behind the nonce gap left by it
+	pool.mu.Lock()
+	for _, tx := range []*types.Transaction{
+		pricedTransaction(0, 100000, big.NewInt(1), key),
+		pricedTransaction(1, 100001, big.NewInt(1), key),
+		pricedTransaction(2, 100000, big.NewInt(9), key),
+		pricedTransaction(3, 100000, big.NewInt(10), key),
+		pricedTransaction(5, 100000, big.NewInt(1), key),
+	} {
+		pool.enqueueTx(tx.Hash(), tx)
+	}
+	pool.mu.Unlock()
+
+	pending, queued := pool.ContentFrom(account)
+	if len(pending) != 1 || pending[0].Nonce() != 1 {
+		t.Fatalf("pending transactions mismatch: have %v", pending)
+	}
+	want := []struct {
+		nonce   uint64
+		reason  TxQueuedReason
+		missing uint64
+	}{
+		{0, TxQueuedStale, 0},
+		{1, TxQueuedReplaceUnderpriced, 0},
+		{2, TxQueuedUnknown, 0},
+		{3, TxQueuedInsufficientFunds, 0},
+		{5, TxQueuedNonceGap, 3},
+	}
+	if len(queued) != len(want) {
+		t.Fatalf("queued transactions mismatch: have %d, want %d", len(queued), len(want))
+	}
+	for i, info := range queued {
+		if info.Tx.Nonce() != want[i].nonce || info.Reason != want[i].reason || info.MissingNonce != want[i].missing {
+			t.Errorf("queued %d: diagnostics mismatch: have nonce %d, %v, missing %d; want nonce %d, %v, missing %d",
+				i, info.Tx.Nonce(), info.Reason, info.MissingNonce, want[i].nonce, want[i].reason, want[i].missing)
+		}
+	}
+	if pending, queued := pool.ContentFrom(common.Address{0x01}); len(pending) != 0 || len(queued) != 0 {
+		t.Errorf("unknown account content mismatch: have %d/%d", len(pending), len(queued))
+	}
+}
+
 // TestTransactionStatusCheck tests that the pool can correctly retrieve the
 // pending status of individual transactions.
 func TestTransactionStatusCheck(t *testing.T) {
@@ -0,0 +1,132 @@
+// Copyright 2019 The go-gclchaineum Authors
+// This file is part of the go-gclchaineum library.
+//
+// The go-gclchaineum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-gclchaineum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-gclchaineum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"bufio"
+	"io"
+	"os"
+
+	"github.com/gclchaineum/go-gclchaineum/core/types"
+	"github.com/gclchaineum/go-gclchaineum/log"
+	"github.com/gclchaineum/go-gclchaineum/rlp"
+)
+
+// snapshotTx is a transaction stored in the snapshot along with whether it was
+// tracked as local by the pool.
+type snapshotTx struct {
+	Tx    *types.Transaction
+	Local bool
+}
+
+// txSnapshot is a full dump of the pending and queued transactions of the pool,
+// allowing both local and remote transactions to survive node restarts. Unlike
+// the journal, it is not appended to, rather regenerated as a whole.
+type txSnapshot struct {
+	path string // Filesystem path to store the transactions at
+}
+
+// newTxSnapshot creates a new transaction snapshot stored at the given path.
+func newTxSnapshot(path string) *txSnapshot {
+	return &txSnapshot{
+		path: path,
+	}
+}
+
+// load parses a transaction snapshot from disk, injecting its contents into the
+// pool through the specified callback, which is expected to revalidate them.
+func (snapshot *txSnapshot) load(add func(txs []*types.Transaction, local bool) []error) error {
+	// Skip the parsing if the snapshot file doesn't exist at all
+	if _, err := os.Stat(snapshot.path); os.IsNotExist(err) {
+		return nil
+	}
+	input, err := os.Open(snapshot.path)
+	if err != nil {
+		return err
+	}
+	defer input.Close()
+
+	var (
+		stream = rlp.NewStream(bufio.NewReader(input), 0)
+		total  int
+		loaded int
+		batch  types.Transactions
+		local  bool
+	)
+	// Inject consecutive transactions of the same locality in batches, keeping
+	// the nonce ordering of the snapshot
+	flush := func() {
+		for _, err := range add(batch, local) {
+			if err != nil {
+				log.Debug("Failed to add snapshotted transaction", "err", err)
+			} else {
+				loaded++
+			}
+		}
+		batch = batch[:0]
+	}
+	var failure error
+	for {
+		entry := new(snapshotTx)
+		if err = stream.Decode(entry); err != nil {
+			if err != io.EOF {
+				failure = err
+			}
+			break
+		}
+		total++
+
+		if len(batch) > 0 && (entry.Local != local || len(batch) >= 1024) {
+			flush()
+		}
+		batch, local = append(batch, entry.Tx), entry.Local
+	}
+	if len(batch) > 0 {
+		flush()
+	}
+	log.Info("Loaded transaction snapshot", "transactions", total, "loaded", loaded, "dropped", total-loaded)
+
+	return failure
+}
+
+// save regenerates the transaction snapshot from the given transactions.
+func (snapshot *txSnapshot) save(txs []snapshotTx) error {
+	output, err := os.OpenFile(snapshot.path+".new", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	writer := bufio.NewWriter(output)
+	for i := range txs {
+		if err = rlp.Encode(writer, &txs[i]); err != nil {
+			output.Close()
+			return err
+		}
+	}
+	if err = writer.Flush(); err != nil {
+		output.Close()
+		return err
+	}
+	if err = output.Close(); err != nil {
+		return err
+	}
+	// Replace the previous snapshot with the newly generated one
+	if err = os.Rename(snapshot.path+".new", snapshot.path); err != nil {
+		return err
+	}
+	log.Info("Regenerated transaction snapshot", "transactions", len(txs))
+	return nil
+}
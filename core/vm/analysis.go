@@ -16,6 +16,25 @@
 
 package vm
 
+import (
+	"github.com/gclchaineum/go-gclchaineum/common"
+	"github.com/gclchaineum/go-gclchaineum/metrics"
+	"github.com/hashicorp/golang-lru"
+)
+
+// jumpdestCacheLimit is the maximum number of code bitmaps kept in the cache.
+// A bitmap takes an eighth of its code's size, at most 3KB for 24KB of code.
+const jumpdestCacheLimit = 4096
+
+var (
+	// jumpdestCache holds the JUMPDEST analyses of recently executed code keyed
+	// by code hash, shared by all interpreters of the process.
+	jumpdestCache, _ = lru.New(jumpdestCacheLimit)
+
+	jumpdestHitMeter  = metrics.NewRegisteredMeter("vm/jumpdests/hits", nil)
+	jumpdestMissMeter = metrics.NewRegisteredMeter("vm/jumpdests/misses", nil)
+)
+
 // bitvec is a bit vector which maps bytes in a program.
 // An unset bit means the byte is an opcode, a set bit means
 // it's data (i.e. argument of PUSHxx).
@@ -60,3 +79,33 @@ func codeBitmap(code []byte) bitvec {
 	}
 	return bits
 }
+
+// cachedCodeBitmap returns the bitmap of the code with the given hash from the
+// cache, collecting and adding it if it's missing. The returned bitmap is shared
+// and must not be modified.
+func cachedCodeBitmap(hash common.Hash, code []byte) bitvec {
+	if bits, ok := jumpdestCache.Get(hash); ok {
+		jumpdestHitMeter.Mark(1)
+		return bits.(bitvec)
+	}
+	jumpdestMissMeter.Mark(1)
+
+	bits := codeBitmap(code)
+	jumpdestCache.Add(hash, bits)
+	return bits
+}
+
+// JumpDests returns the positions of all the valid jump destinations in the
+// code, that is the JUMPDEST opcodes which are not part of a PUSH argument.
+func JumpDests(code []byte) []uint64 {
+	var (
+		dests []uint64
+		bits  = codeBitmap(code)
+	)
+	for pc := uint64(0); pc < uint64(len(code)); pc++ {
+		if OpCode(code[pc]) == JUMPDEST && bits.codeSegment(pc) {
+			dests = append(dests, pc)
+		}
+	}
+	return dests
+}
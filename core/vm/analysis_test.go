@@ -17,8 +17,12 @@
 package vm
 
 import (
+	"math/big"
+	"reflect"
+	"sync"
 	"testing"
 
+	"github.com/gclchaineum/go-gclchaineum/common"
 	"github.com/gclchaineum/go-gclchaineum/crypto"
 )
 
@@ -55,6 +59,76 @@ func TestJumpDestAnalysis(t *testing.T) {
 	}
 }
 
+func TestJumpDests(t *testing.T) {
+	tests := []struct {
+		code  []byte
+		dests []uint64
+	}{
+		{nil, nil},
+		{[]byte{byte(JUMPDEST), byte(PUSH1), byte(JUMPDEST), byte(JUMPDEST)}, []uint64{0, 3}},
+		{[]byte{byte(PUSH2), byte(JUMPDEST), byte(JUMPDEST), byte(STOP), byte(JUMPDEST)}, []uint64{4}},
+		{[]byte{byte(PUSH32), byte(JUMPDEST)}, nil},
+	}
+	for i, test := range tests {
+		if dests := JumpDests(test.code); !reflect.DeepEqual(dests, test.dests) {
+			t.Errorf("test %d: jumpdest mismatch: have %v, want %v", i, dests, test.dests)
+		}
+	}
+}
+
+// Tests that the JUMPDEST analysis of code with a known hash is shared by all
+// contracts running it, including concurrently.
+func TestJumpdestCache(t *testing.T) {
+	var (
+		code = []byte{byte(PUSH1), byte(JUMPDEST), byte(JUMPDEST), byte(PUSH1), 0x01, byte(JUMPDEST)}
+		hash = crypto.Keccak256Hash(code)
+		addr = common.BytesToAddress([]byte("contract"))
+	)
+	jumpdestCache.Remove(hash)
+
+	var (
+		wg       sync.WaitGroup
+		analyses = make([]bitvec, 8)
+	)
+	for i := range analyses {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			contract := NewContract(AccountRef(addr), AccountRef(addr), new(big.Int), 0)
+			contract.SetCallCode(&addr, hash, code)
+			if contract.validJumpdest(big.NewInt(1)) || !contract.validJumpdest(big.NewInt(2)) || !contract.validJumpdest(big.NewInt(5)) {
+				t.Errorf("contract %d: jumpdest validation mismatch", i)
+			}
+			analyses[i] = contract.analysis
+		}(i)
+	}
+	wg.Wait()
+
+	cached, ok := jumpdestCache.Get(hash)
+	if !ok {
+		t.Fatalf("analysis not cached")
+	}
+	if !reflect.DeepEqual(cached.(bitvec), codeBitmap(code)) {
+		t.Fatalf("cached analysis mismatch")
+	}
+	// Once cached, all contracts must share the same analysis
+	contract := NewContract(AccountRef(addr), AccountRef(addr), new(big.Int), 0)
+	contract.SetCallCode(&addr, hash, code)
+	contract.validJumpdest(big.NewInt(2))
+	if &contract.analysis[0] != &cached.(bitvec)[0] {
+		t.Errorf("analysis not shared")
+	}
+	// Code without a hash must not be cached
+	initcode := append([]byte{byte(PUSH1), 0x02}, code...)
+	contract = NewContract(AccountRef(addr), AccountRef(addr), new(big.Int), 0)
+	contract.Code = initcode
+	contract.validJumpdest(big.NewInt(4))
+	if jumpdestCache.Contains(crypto.Keccak256Hash(initcode)) {
+		t.Errorf("analysis of unhashed code cached")
+	}
+}
+
 func BenchmarkJumpdestAnalysis_1200k(bench *testing.B) {
 	// 1.4 ms
 	code := make([]byte, 1200000)
@@ -30,10 +30,7 @@ type ContractRef interface {
 // AccountRef implements ContractRef.
 //
 // Account references are used during EVM initialisation and
-// it's primary use is to fetch addresses. Removing this object
-// proves difficult because of the cached jump destinations which
-// are fetched from the parent contract (i.e. the caller), which
-// is a ContractRef.
+// it's primary use is to fetch addresses.
 type AccountRef common.Address
 
 // Address casts AccountRef to a Address
@@ -49,8 +46,7 @@ type Contract struct {
 	caller        ContractRef
 	self          ContractRef
 
-	jumpdests map[common.Hash]bitvec // Aggregated result of JUMPDEST analysis.
-	analysis  bitvec                 // Locally cached result of JUMPDEST analysis
+	analysis bitvec // Locally cached result of JUMPDEST analysis
 
 	Code     []byte
 	CodeHash common.Hash
@@ -65,13 +61,6 @@ type Contract struct {
 func NewContract(caller ContractRef, object ContractRef, value *big.Int, gas uint64) *Contract {
 	c := &Contract{CallerAddress: caller.Address(), caller: caller, self: object}
 
-	if parent, ok := caller.(*Contract); ok {
-		// Reuse JUMPDEST analysis from parent context if available.
-		c.jumpdests = parent.jumpdests
-	} else {
-		c.jumpdests = make(map[common.Hash]bitvec)
-	}
-
 	// Gas should be a pointer so it can safely be reduced through the run
 	// This pointer will be off the state transition
 	c.Gas = gas
@@ -92,24 +81,17 @@ func (c *Contract) validJumpdest(dest *big.Int) bool {
 	if OpCode(c.Code[udest]) != JUMPDEST {
 		return false
 	}
-	// Do we have a contract hash already?
-	if c.CodeHash != (common.Hash{}) {
-		// Does parent context have the analysis?
-		analysis, exist := c.jumpdests[c.CodeHash]
-		if !exist {
-			// Do the analysis and save in parent context
-			// We do not need to store it in c.analysis
-			analysis = codeBitmap(c.Code)
-			c.jumpdests[c.CodeHash] = analysis
-		}
-		return analysis.codeSegment(udest)
-	}
-	// We don't have the code hash, most likely a piece of initcode not already
-	// in state trie. In that case, we do an analysis, and save it locally, so
-	// we don't have to recalculate it for every JUMP instruction in the execution
-	// However, we don't save it within the parent context
+	// Save the analysis locally, so we don't have to look it up or recalculate
+	// it for every JUMP instruction in the execution
 	if c.analysis == nil {
-		c.analysis = codeBitmap(c.Code)
+		// Do we have a contract hash already? Then the analysis is shared
+		// process wide, otherwise it's most likely a piece of initcode not
+		// already in state trie, which isn't worth caching.
+		if c.CodeHash != (common.Hash{}) {
+			c.analysis = cachedCodeBitmap(c.CodeHash, c.Code)
+		} else {
+			c.analysis = codeBitmap(c.Code)
+		}
 	}
 	return c.analysis.codeSegment(udest)
 }
@@ -18,14 +18,19 @@ package vm
 
 import (
 	"crypto/sha256"
+	"encoding/binary"
 	"errors"
+	"fmt"
 	"math/big"
 
 	"github.com/gclchaineum/go-gclchaineum/common"
 	"github.com/gclchaineum/go-gclchaineum/common/math"
 	"github.com/gclchaineum/go-gclchaineum/crypto"
+	"github.com/gclchaineum/go-gclchaineum/crypto/blake2b"
 	"github.com/gclchaineum/go-gclchaineum/crypto/bn256"
+	"github.com/gclchaineum/go-gclchaineum/crypto/sm3"
 	"github.com/gclchaineum/go-gclchaineum/params"
+	"golang.org/x/crypto/ed25519"
 	"golang.org/x/crypto/ripemd160"
 )
 
@@ -54,9 +59,81 @@ var PrecompiledContractsByzantium = map[common.Address]PrecompiledContract{
 	common.BytesToAddress([]byte{3}): &ripemd160hash{},
 	common.BytesToAddress([]byte{4}): &dataCopy{},
 	common.BytesToAddress([]byte{5}): &bigModExp{},
-	common.BytesToAddress([]byte{6}): &bn256Add{},
-	common.BytesToAddress([]byte{7}): &bn256ScalarMul{},
-	common.BytesToAddress([]byte{8}): &bn256Pairing{},
+	common.BytesToAddress([]byte{6}): &bn256AddByzantium{},
+	common.BytesToAddress([]byte{7}): &bn256ScalarMulByzantium{},
+	common.BytesToAddress([]byte{8}): &bn256PairingByzantium{},
+}
+
+// PrecompiledContractsIstanbul contains the default set of pre-compiled Gclchain
+// contracts used in the Istanbul release.
+var PrecompiledContractsIstanbul = map[common.Address]PrecompiledContract{
+	common.BytesToAddress([]byte{1}): &ecrecover{},
+	common.BytesToAddress([]byte{2}): &sha256hash{},
+	common.BytesToAddress([]byte{3}): &ripemd160hash{},
+	common.BytesToAddress([]byte{4}): &dataCopy{},
+	common.BytesToAddress([]byte{5}): &bigModExp{},
+	common.BytesToAddress([]byte{6}): &bn256AddIstanbul{},
+	common.BytesToAddress([]byte{7}): &bn256ScalarMulIstanbul{},
+	common.BytesToAddress([]byte{8}): &bn256PairingIstanbul{},
+	common.BytesToAddress([]byte{9}): &blake2F{},
+}
+
+// optionalPrecompiles is the registry of native contracts that aren't part of
+// any fork, but which a chain configuration may enable at a custom address and
+// activation block. The constructors receive the gas schedule from the config.
+var optionalPrecompiles = map[string]func(base, word uint64) PrecompiledContract{
+	"ed25519Verify": func(base, word uint64) PrecompiledContract { return &ed25519Verify{base, word} },
+	"sm3":           func(base, word uint64) PrecompiledContract { return &sm3hash{base, word} },
+}
+
+// ValidatePrecompiles checks that all the optional pre-compiled contracts of a
+// chain configuration exist in the registry and that they neither collide with
+// each other, nor shadow any of the fork defined ones.
+func ValidatePrecompiles(config *params.ChainConfig) error {
+	seen := make(map[common.Address]bool)
+	for _, p := range config.Precompiles {
+		if _, ok := optionalPrecompiles[p.Name]; !ok {
+			return fmt.Errorf("unknown precompile %q", p.Name)
+		}
+		if PrecompiledContractsIstanbul[p.Address] != nil {
+			return fmt.Errorf("precompile %q shadows built-in contract at %x", p.Name, p.Address)
+		}
+		if seen[p.Address] {
+			return fmt.Errorf("duplicate precompile address %x", p.Address)
+		}
+		seen[p.Address] = true
+	}
+	return nil
+}
+
+// activePrecompiles returns the set of pre-compiled contracts active at the
+// given block, including the optional ones enabled by the chain configuration.
+func activePrecompiles(config *params.ChainConfig, num *big.Int) map[common.Address]PrecompiledContract {
+	precompiles := PrecompiledContractsHomestead
+	if config.IsByzantium(num) {
+		precompiles = PrecompiledContractsByzantium
+	}
+	if config.IsIstanbul(num) {
+		precompiles = PrecompiledContractsIstanbul
+	}
+	// Avoid copying the shared fork sets if there's nothing to add to them
+	var optional []*params.PrecompileConfig
+	for _, p := range config.Precompiles {
+		if p.IsActive(num) && optionalPrecompiles[p.Name] != nil {
+			optional = append(optional, p)
+		}
+	}
+	if len(optional) == 0 {
+		return precompiles
+	}
+	active := make(map[common.Address]PrecompiledContract, len(precompiles)+len(optional))
+	for addr, p := range precompiles {
+		active[addr] = p
+	}
+	for _, p := range optional {
+		active[p.Address] = optionalPrecompiles[p.Name](p.BaseGas, p.WordGas)
+	}
+	return active
 }
 
 // RunPrecompiledContract runs and evaluates the output of a precompiled contract.
@@ -271,15 +348,9 @@ func newTwistPoint(blob []byte) (*bn256.G2, error) {
 	return p, nil
 }
 
-// bn256Add implements a native elliptic curve point addition.
-type bn256Add struct{}
-
-// RequiredGas returns the gas required to execute the pre-compiled contract.
-func (c *bn256Add) RequiredGas(input []byte) uint64 {
-	return params.Bn256AddGas
-}
-
-func (c *bn256Add) Run(input []byte) ([]byte, error) {
+// runBn256Add implements the Bn256Add precompile, referenced by both
+// Byzantium and Istanbul operations.
+func runBn256Add(input []byte) ([]byte, error) {
 	x, err := newCurvePoint(getData(input, 0, 64))
 	if err != nil {
 		return nil, err
@@ -293,15 +364,35 @@ func (c *bn256Add) Run(input []byte) ([]byte, error) {
 	return res.Marshal(), nil
 }
 
-// bn256ScalarMul implements a native elliptic curve scalar multiplication.
-type bn256ScalarMul struct{}
+// bn256AddIstanbul implements a native elliptic curve point addition conforming to
+// Istanbul consensus rules.
+type bn256AddIstanbul struct{}
 
 // RequiredGas returns the gas required to execute the pre-compiled contract.
-func (c *bn256ScalarMul) RequiredGas(input []byte) uint64 {
-	return params.Bn256ScalarMulGas
+func (c *bn256AddIstanbul) RequiredGas(input []byte) uint64 {
+	return params.Bn256AddGasIstanbul
 }
 
-func (c *bn256ScalarMul) Run(input []byte) ([]byte, error) {
+func (c *bn256AddIstanbul) Run(input []byte) ([]byte, error) {
+	return runBn256Add(input)
+}
+
+// bn256AddByzantium implements a native elliptic curve point addition
+// conforming to Byzantium consensus rules.
+type bn256AddByzantium struct{}
+
+// RequiredGas returns the gas required to execute the pre-compiled contract.
+func (c *bn256AddByzantium) RequiredGas(input []byte) uint64 {
+	return params.Bn256AddGasByzantium
+}
+
+func (c *bn256AddByzantium) Run(input []byte) ([]byte, error) {
+	return runBn256Add(input)
+}
+
+// runBn256ScalarMul implements the Bn256ScalarMul precompile, referenced by
+// both Byzantium and Istanbul operations.
+func runBn256ScalarMul(input []byte) ([]byte, error) {
 	p, err := newCurvePoint(getData(input, 0, 64))
 	if err != nil {
 		return nil, err
@@ -311,6 +402,32 @@ func (c *bn256ScalarMul) Run(input []byte) ([]byte, error) {
 	return res.Marshal(), nil
 }
 
+// bn256ScalarMulIstanbul implements a native elliptic curve scalar
+// multiplication conforming to Istanbul consensus rules.
+type bn256ScalarMulIstanbul struct{}
+
+// RequiredGas returns the gas required to execute the pre-compiled contract.
+func (c *bn256ScalarMulIstanbul) RequiredGas(input []byte) uint64 {
+	return params.Bn256ScalarMulGasIstanbul
+}
+
+func (c *bn256ScalarMulIstanbul) Run(input []byte) ([]byte, error) {
+	return runBn256ScalarMul(input)
+}
+
+// bn256ScalarMulByzantium implements a native elliptic curve scalar
+// multiplication conforming to Byzantium consensus rules.
+type bn256ScalarMulByzantium struct{}
+
+// RequiredGas returns the gas required to execute the pre-compiled contract.
+func (c *bn256ScalarMulByzantium) RequiredGas(input []byte) uint64 {
+	return params.Bn256ScalarMulGasByzantium
+}
+
+func (c *bn256ScalarMulByzantium) Run(input []byte) ([]byte, error) {
+	return runBn256ScalarMul(input)
+}
+
 var (
 	// true32Byte is returned if the bn256 pairing check succeeds.
 	true32Byte = []byte{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1}
@@ -322,15 +439,9 @@ var (
 	errBadPairingInput = errors.New("bad elliptic curve pairing size")
 )
 
-// bn256Pairing implements a pairing pre-compile for the bn256 curve
-type bn256Pairing struct{}
-
-// RequiredGas returns the gas required to execute the pre-compiled contract.
-func (c *bn256Pairing) RequiredGas(input []byte) uint64 {
-	return params.Bn256PairingBaseGas + uint64(len(input)/192)*params.Bn256PairingPerPointGas
-}
-
-func (c *bn256Pairing) Run(input []byte) ([]byte, error) {
+// runBn256Pairing implements the Bn256Pairing precompile, referenced by both
+// Byzantium and Istanbul operations.
+func runBn256Pairing(input []byte) ([]byte, error) {
 	// Handle some corner cases cheaply
 	if len(input)%192 > 0 {
 		return nil, errBadPairingInput
@@ -358,3 +469,153 @@ func (c *bn256Pairing) Run(input []byte) ([]byte, error) {
 	}
 	return false32Byte, nil
 }
+
+// bn256PairingIstanbul implements a pairing pre-compile for the bn256 curve
+// conforming to Istanbul consensus rules.
+type bn256PairingIstanbul struct{}
+
+// RequiredGas returns the gas required to execute the pre-compiled contract.
+func (c *bn256PairingIstanbul) RequiredGas(input []byte) uint64 {
+	return params.Bn256PairingBaseGasIstanbul + uint64(len(input)/192)*params.Bn256PairingPerPointGasIstanbul
+}
+
+func (c *bn256PairingIstanbul) Run(input []byte) ([]byte, error) {
+	return runBn256Pairing(input)
+}
+
+// bn256PairingByzantium implements a pairing pre-compile for the bn256 curve
+// conforming to Byzantium consensus rules.
+type bn256PairingByzantium struct{}
+
+// RequiredGas returns the gas required to execute the pre-compiled contract.
+func (c *bn256PairingByzantium) RequiredGas(input []byte) uint64 {
+	return params.Bn256PairingBaseGasByzantium + uint64(len(input)/192)*params.Bn256PairingPerPointGasByzantium
+}
+
+func (c *bn256PairingByzantium) Run(input []byte) ([]byte, error) {
+	return runBn256Pairing(input)
+}
+
+// blake2F implements the EIP-152 precompile exposing the BLAKE2b compression
+// function F.
+type blake2F struct{}
+
+// RequiredGas returns the gas required to execute the pre-compiled contract,
+// which is the number of compression rounds requested.
+func (c *blake2F) RequiredGas(input []byte) uint64 {
+	// If the input is malformed, we can't calculate the gas, return 0 and let the
+	// actual call choke and fault.
+	if len(input) != blake2FInputLength {
+		return 0
+	}
+	return uint64(binary.BigEndian.Uint32(input[0:4]))
+}
+
+const (
+	blake2FInputLength        = 213
+	blake2FFinalBlockBytes    = byte(1)
+	blake2FNonFinalBlockBytes = byte(0)
+)
+
+var (
+	errBlake2FInvalidInputLength = errors.New("invalid input length")
+	errBlake2FInvalidFinalFlag   = errors.New("invalid final flag")
+)
+
+func (c *blake2F) Run(input []byte) ([]byte, error) {
+	// Make sure the input is valid (correct length and final flag)
+	if len(input) != blake2FInputLength {
+		return nil, errBlake2FInvalidInputLength
+	}
+	if input[212] != blake2FNonFinalBlockBytes && input[212] != blake2FFinalBlockBytes {
+		return nil, errBlake2FInvalidFinalFlag
+	}
+	// Parse the input into the Blake2b call parameters
+	var (
+		rounds = binary.BigEndian.Uint32(input[0:4])
+		final  = (input[212] == blake2FFinalBlockBytes)
+
+		h [8]uint64
+		m [16]uint64
+		t [2]uint64
+	)
+	for i := 0; i < 8; i++ {
+		offset := 4 + i*8
+		h[i] = binary.LittleEndian.Uint64(input[offset : offset+8])
+	}
+	for i := 0; i < 16; i++ {
+		offset := 68 + i*8
+		m[i] = binary.LittleEndian.Uint64(input[offset : offset+8])
+	}
+	t[0] = binary.LittleEndian.Uint64(input[196:204])
+	t[1] = binary.LittleEndian.Uint64(input[204:212])
+
+	// Execute the compression function, extract and return the result
+	blake2b.F(&h, m, t, final, rounds)
+
+	output := make([]byte, 64)
+	for i := 0; i < 8; i++ {
+		offset := i * 8
+		binary.LittleEndian.PutUint64(output[offset:offset+8], h[i])
+	}
+	return output, nil
+}
+
+// wordGas calculates the gas of an optional precompile charging a base price
+// and a price for every 32 byte word of input, capping at the maximum on
+// overflow as the gas schedule comes from user configuration.
+func wordGas(input []byte, base, word uint64) uint64 {
+	gas, overflow := math.SafeMul(uint64(len(input)+31)/32, word)
+	if overflow {
+		return math.MaxUint64
+	}
+	if gas, overflow = math.SafeAdd(gas, base); overflow {
+		return math.MaxUint64
+	}
+	return gas
+}
+
+var errEd25519InvalidInputLength = errors.New("invalid input length")
+
+// ed25519Verify implements Ed25519 signature verification as an optional native
+// contract. The input is the 32 byte public key, followed by the 64 byte
+// signature and the signed message. The output is a 32 byte word set to 1 if
+// the signature is valid, 0 otherwise.
+type ed25519Verify struct {
+	baseGas, wordGas uint64
+}
+
+// RequiredGas returns the gas required to execute the pre-compiled contract.
+func (c *ed25519Verify) RequiredGas(input []byte) uint64 {
+	return wordGas(input, c.baseGas, c.wordGas)
+}
+
+func (c *ed25519Verify) Run(input []byte) ([]byte, error) {
+	if len(input) < ed25519.PublicKeySize+ed25519.SignatureSize {
+		return nil, errEd25519InvalidInputLength
+	}
+	var (
+		pubkey = input[:ed25519.PublicKeySize]
+		sig    = input[ed25519.PublicKeySize : ed25519.PublicKeySize+ed25519.SignatureSize]
+		msg    = input[ed25519.PublicKeySize+ed25519.SignatureSize:]
+	)
+	if ed25519.Verify(pubkey, msg, sig) {
+		return true32Byte, nil
+	}
+	return false32Byte, nil
+}
+
+// sm3hash implements the SM3 hash function as an optional native contract.
+type sm3hash struct {
+	baseGas, wordGas uint64
+}
+
+// RequiredGas returns the gas required to execute the pre-compiled contract.
+func (c *sm3hash) RequiredGas(input []byte) uint64 {
+	return wordGas(input, c.baseGas, c.wordGas)
+}
+
+func (c *sm3hash) Run(input []byte) ([]byte, error) {
+	h := sm3.Sum(input)
+	return h[:], nil
+}
@@ -17,11 +17,15 @@
 package vm
 
 import (
+	"bytes"
+	"crypto/rand"
 	"fmt"
 	"math/big"
 	"testing"
 
 	"github.com/gclchaineum/go-gclchaineum/common"
+	"github.com/gclchaineum/go-gclchaineum/params"
+	"golang.org/x/crypto/ed25519"
 )
 
 // precompiledTest defines the input/output pairs for precompiled contract tests.
@@ -32,6 +36,14 @@ type precompiledTest struct {
 	noBenchmark     bool // Benchmark primarily the worst-cases
 }
 
+// precompiledFailureTest defines the input/error pairs for precompiled
+// contract failure tests.
+type precompiledFailureTest struct {
+	input         string
+	expectedError error
+	name          string
+}
+
 // modexpTests are the test and benchmark data for the modexp precompiled contract.
 var modexpTests = []precompiledTest{
 	{
@@ -336,8 +348,60 @@ var bn256PairingTests = []precompiledTest{
 	},
 }
 
+// EIP-152 test vectors
+var blake2FMalformedInputTests = []precompiledFailureTest{
+	{
+		input:         "",
+		expectedError: errBlake2FInvalidInputLength,
+		name:          "vector 0: empty input",
+	},
+	{
+		input:         "00000c48c9bdf267e6096a3ba7ca8485ae67bb2bf894fe72f36e3cf1361d5f3af54fa5d182e6ad7f520e511f6c3e2b8c68059b6bbd41fbabd9831f79217e1319cde05b61626300000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000300000000000000000000000000000001",
+		expectedError: errBlake2FInvalidInputLength,
+		name:          "vector 1: less than 213 bytes input",
+	},
+	{
+		input:         "000000000c48c9bdf267e6096a3ba7ca8485ae67bb2bf894fe72f36e3cf1361d5f3af54fa5d182e6ad7f520e511f6c3e2b8c68059b6bbd41fbabd9831f79217e1319cde05b61626300000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000300000000000000000000000000000001",
+		expectedError: errBlake2FInvalidInputLength,
+		name:          "vector 2: more than 213 bytes input",
+	},
+	{
+		input:         "0000000c48c9bdf267e6096a3ba7ca8485ae67bb2bf894fe72f36e3cf1361d5f3af54fa5d182e6ad7f520e511f6c3e2b8c68059b6bbd41fbabd9831f79217e1319cde05b61626300000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000300000000000000000000000000000002",
+		expectedError: errBlake2FInvalidFinalFlag,
+		name:          "vector 3: malformed final block indicator flag",
+	},
+}
+
+var blake2FTests = []precompiledTest{
+	{
+		input:    "0000000048c9bdf267e6096a3ba7ca8485ae67bb2bf894fe72f36e3cf1361d5f3af54fa5d182e6ad7f520e511f6c3e2b8c68059b6bbd41fbabd9831f79217e1319cde05b61626300000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000300000000000000000000000000000001",
+		expected: "08c9bcf367e6096a3ba7ca8485ae67bb2bf894fe72f36e3cf1361d5f3af54fa5d282e6ad7f520e511f6c3e2b8c68059b9442be0454267ce079217e1319cde05b",
+		name:     "vector 4",
+	},
+	{
+		input:    "0000000c48c9bdf267e6096a3ba7ca8485ae67bb2bf894fe72f36e3cf1361d5f3af54fa5d182e6ad7f520e511f6c3e2b8c68059b6bbd41fbabd9831f79217e1319cde05b61626300000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000300000000000000000000000000000001",
+		expected: "ba80a53f981c4d0d6a2797b69f12f6e94c212f14685ac4b74b12bb6fdbffa2d17d87c5392aab792dc252d5de4533cc9518d38aa8dbf1925ab92386edd4009923",
+		name:     "vector 5",
+	},
+	{
+		input:    "0000000c48c9bdf267e6096a3ba7ca8485ae67bb2bf894fe72f36e3cf1361d5f3af54fa5d182e6ad7f520e511f6c3e2b8c68059b6bbd41fbabd9831f79217e1319cde05b61626300000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000300000000000000000000000000000000",
+		expected: "75ab69d3190a562c51aef8d88f1c2775876944407270c42c9844252c26d2875298743e7f6d5ea2f2d3e8d226039cd31b4e426ac4f2d3d666a610c2116fde4735",
+		name:     "vector 6",
+	},
+	{
+		input:    "0000000148c9bdf267e6096a3ba7ca8485ae67bb2bf894fe72f36e3cf1361d5f3af54fa5d182e6ad7f520e511f6c3e2b8c68059b6bbd41fbabd9831f79217e1319cde05b61626300000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000300000000000000000000000000000001",
+		expected: "b63a380cb2897d521994a85234ee2c181b5f844d2c624c002677e9703449d2fba551b3a8333bcdf5f2f7e08993d53923de3d64fcc68c034e717b9293fed7a421",
+		name:     "vector 7",
+	},
+	{
+		input:    "0000006448c9bdf267e6096a3ba7ca8485ae67bb2bf894fe72f36e3cf1361d5f3af54fa5d182e6ad7f520e511f6c3e2b8c68059b6bbd41fbabd9831f79217e1319cde05b61626300000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000300000000000000000000000000000001",
+		expected: "8136972eb0cc05f3df5ec98ac64b7e9557bad14711f15fde8f42834cf6bbab481b81b1b3c0bd703f65694e763975d05c55c731bfa1afdd3a118dcbf4ef7c2845",
+		name:     "vector 8",
+	},
+}
+
 func testPrecompiled(addr string, test precompiledTest, t *testing.T) {
-	p := PrecompiledContractsByzantium[common.HexToAddress(addr)]
+	p := PrecompiledContractsIstanbul[common.HexToAddress(addr)]
 	in := common.Hex2Bytes(test.input)
 	contract := NewContract(AccountRef(common.HexToAddress("1337")),
 		nil, new(big.Int), p.RequiredGas(in))
@@ -350,11 +414,25 @@ func testPrecompiled(addr string, test precompiledTest, t *testing.T) {
 	})
 }
 
+func testPrecompiledFailure(addr string, test precompiledFailureTest, t *testing.T) {
+	p := PrecompiledContractsIstanbul[common.HexToAddress(addr)]
+	in := common.Hex2Bytes(test.input)
+	contract := NewContract(AccountRef(common.HexToAddress("31337")),
+		nil, new(big.Int), p.RequiredGas(in))
+
+	t.Run(test.name, func(t *testing.T) {
+		_, err := RunPrecompiledContract(p, in, contract)
+		if err != test.expectedError {
+			t.Errorf("Expected error [%v], got [%v]", test.expectedError, err)
+		}
+	})
+}
+
 func benchmarkPrecompiled(addr string, test precompiledTest, bench *testing.B) {
 	if test.noBenchmark {
 		return
 	}
-	p := PrecompiledContractsByzantium[common.HexToAddress(addr)]
+	p := PrecompiledContractsIstanbul[common.HexToAddress(addr)]
 	in := common.Hex2Bytes(test.input)
 	reqGas := p.RequiredGas(in)
 	contract := NewContract(AccountRef(common.HexToAddress("1337")),
@@ -481,3 +559,94 @@ func BenchmarkPrecompiledBn256Pairing(bench *testing.B) {
 		benchmarkPrecompiled("08", test, bench)
 	}
 }
+
+func TestPrecompiledBlake2F(t *testing.T) {
+	for _, test := range blake2FTests {
+		testPrecompiled("09", test, t)
+	}
+}
+
+func BenchmarkPrecompiledBlake2F(bench *testing.B) {
+	for _, test := range blake2FTests {
+		benchmarkPrecompiled("09", test, bench)
+	}
+}
+
+func TestPrecompileBlake2FMalformedInput(t *testing.T) {
+	for _, test := range blake2FMalformedInputTests {
+		testPrecompiledFailure("09", test, t)
+	}
+}
+
+// Tests that optional precompiles are only activated at their configured block
+// and that they charge the gas schedule declared in the chain config.
+func TestOptionalPrecompiles(t *testing.T) {
+	var (
+		sm3addr = common.HexToAddress("0100")
+		edaddr  = common.HexToAddress("0101")
+		config  = *params.TestChainConfig
+	)
+	config.Precompiles = []*params.PrecompileConfig{
+		{Name: "sm3", Address: sm3addr, Block: big.NewInt(5), BaseGas: 50, WordGas: 10},
+		{Name: "ed25519Verify", Address: edaddr, Block: big.NewInt(0), BaseGas: 2000, WordGas: 5},
+	}
+	if err := ValidatePrecompiles(&config); err != nil {
+		t.Fatalf("failed to validate precompiles: %v", err)
+	}
+	if p := activePrecompiles(&config, big.NewInt(4))[sm3addr]; p != nil {
+		t.Fatalf("sm3 precompile active before its activation block")
+	}
+	p := activePrecompiles(&config, big.NewInt(5))[sm3addr]
+	if p == nil {
+		t.Fatalf("sm3 precompile inactive at its activation block")
+	}
+	if gas := p.RequiredGas([]byte("abc")); gas != 60 {
+		t.Errorf("sm3 gas mismatch: have %d, want %d", gas, 60)
+	}
+	res, err := p.Run([]byte("abc"))
+	if err != nil {
+		t.Fatalf("failed to run sm3: %v", err)
+	}
+	if have, want := common.Bytes2Hex(res), "66c7f0f462eeedd9d1f2d46bdc10e4e24167c4875cf2f7a2297da02b8f4ba8e0"; have != want {
+		t.Errorf("sm3 output mismatch: have %s, want %s", have, want)
+	}
+	// Sign a message and check that only the correct signature is accepted
+	pub, priv, _ := ed25519.GenerateKey(rand.Reader)
+	msg := []byte("gclchain")
+	input := append(append(append([]byte{}, pub...), ed25519.Sign(priv, msg)...), msg...)
+
+	p = activePrecompiles(&config, big.NewInt(0))[edaddr]
+	if res, err := p.Run(input); err != nil || !bytes.Equal(res, true32Byte) {
+		t.Errorf("valid signature rejected: %x, %v", res, err)
+	}
+	input[len(input)-1] ^= 0xff
+	if res, err := p.Run(input); err != nil || !bytes.Equal(res, false32Byte) {
+		t.Errorf("invalid signature accepted: %x, %v", res, err)
+	}
+	if _, err := p.Run(input[:64]); err != errEd25519InvalidInputLength {
+		t.Errorf("short input error mismatch: have %v, want %v", err, errEd25519InvalidInputLength)
+	}
+}
+
+// Tests that invalid optional precompile configurations are rejected.
+func TestValidatePrecompiles(t *testing.T) {
+	tests := []struct {
+		precompiles []*params.PrecompileConfig
+		fail        bool
+	}{
+		{[]*params.PrecompileConfig{{Name: "sm3", Address: common.HexToAddress("0100")}}, false},
+		{[]*params.PrecompileConfig{{Name: "unknown", Address: common.HexToAddress("0100")}}, true},
+		{[]*params.PrecompileConfig{{Name: "sm3", Address: common.HexToAddress("02")}}, true},
+		{[]*params.PrecompileConfig{
+			{Name: "sm3", Address: common.HexToAddress("0100")},
+			{Name: "ed25519Verify", Address: common.HexToAddress("0100")},
+		}, true},
+	}
+	for i, tt := range tests {
+		config := *params.TestChainConfig
+		config.Precompiles = tt.precompiles
+		if err := ValidatePrecompiles(&config); (err != nil) != tt.fail {
+			t.Errorf("test %d: validation failure mismatch: have %v, want failure %v", i, err, tt.fail)
+		}
+	}
+}
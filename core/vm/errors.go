@@ -16,7 +16,10 @@
 
 package vm
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+)
 
 // List execution errors
 var (
@@ -27,4 +30,15 @@ var (
 	ErrInsufficientBalance      = errors.New("insufficient balance for transfer")
 	ErrContractAddressCollision = errors.New("contract address collision")
 	ErrNoCompatibleInterpreter  = errors.New("no compatible interpreter")
+	ErrGasSentry                = errors.New("not enough gas for reentrancy sentry")
 )
+
+// ErrRestrictedOpcode is returned when executing an opcode that the chain
+// configuration disables for the current transaction origin and contract.
+type ErrRestrictedOpcode struct {
+	Op OpCode
+}
+
+func (e *ErrRestrictedOpcode) Error() string {
+	return fmt.Sprintf("opcode %v restricted by chain configuration", e.Op)
+}
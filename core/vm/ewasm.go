@@ -0,0 +1,754 @@
+// Copyright 2019 The go-gclchaineum Authors
+// This file is part of the go-gclchaineum library.
+//
+// The go-gclchaineum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-gclchaineum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-gclchaineum library. If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/gclchaineum/go-gclchaineum/common"
+	"github.com/gclchaineum/go-gclchaineum/common/math"
+	"github.com/gclchaineum/go-gclchaineum/core/types"
+	"github.com/gclchaineum/go-gclchaineum/core/vm/wasm"
+	"github.com/gclchaineum/go-gclchaineum/params"
+)
+
+const (
+	ewasmMaxPages     = 1024                                  // Maximum linear memory of a contract, 64 MiB
+	ewasmPageGas      = wasm.PageSize / 32 * params.MemoryGas // Gas per memory page, priced like EVM memory words
+	ewasmMaxCallDepth = 1024                                  // Maximum depth of nested function calls within a contract
+)
+
+var (
+	errEWASMInvalidContract = errors.New("ewasm: invalid contract")
+
+	// Sentinel errors of the host functions halting the execution
+	errEWASMFinish = errors.New("ewasm: finish")
+	errEWASMRevert = errors.New("ewasm: revert")
+)
+
+// EWASMInterpreter runs eWASM contracts: WebAssembly modules exporting a main
+// function and their memory, which interact with the chain through the host
+// functions of the Ethereum Environment Interface (EEI) imported from the
+// "ethereum" namespace.
+//
+// Every executed instruction costs one gas on top of the costs of the host
+// functions, which are priced like their EVM counterparts, so contracts need
+// no injected metering.
+type EWASMInterpreter struct {
+	evm      *EVM
+	cfg      Config
+	gasTable params.GasTable
+
+	readOnly   bool                               // Whether to throw on stateful modifications
+	returnData []byte                             // Last call's return data for subsequent reuse
+	restricted map[OpCode]map[common.Address]bool // Allowlists of the opcodes restricted by the chain
+}
+
+// NewEWASMInterpreter returns a new instance of the eWASM interpreter.
+func NewEWASMInterpreter(evm *EVM, cfg Config) *EWASMInterpreter {
+	return &EWASMInterpreter{
+		evm:        evm,
+		cfg:        cfg,
+		gasTable:   evm.ChainConfig().GasTable(evm.BlockNumber),
+		restricted: activeRestrictions(evm.ChainConfig(), evm.BlockNumber),
+	}
+}
+
+// CanRun tells if the contract, passed as an argument, can be
+// run by the current interpreter.
+func (in *EWASMInterpreter) CanRun(code []byte) bool {
+	return wasm.IsModule(code)
+}
+
+// Run instantiates the contract's module and calls its main function. The
+// return data is set by the finish and revert host functions.
+func (in *EWASMInterpreter) Run(contract *Contract, input []byte, readOnly bool) (ret []byte, err error) {
+	// Increment the call depth which is restricted to 1024
+	in.evm.depth++
+	defer func() { in.evm.depth-- }()
+
+	// Make sure the readOnly is only set if we aren't in readOnly yet, including
+	// static calls entered through the other interpreters
+	if (readOnly || in.evm.readOnly()) && !in.readOnly {
+		in.readOnly = true
+		defer func() { in.readOnly = false }()
+	}
+	in.returnData = nil
+	contract.Input = input
+
+	module, err := wasm.Decode(contract.Code)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateEWASM(module); err != nil {
+		return nil, err
+	}
+	if module.Memory != nil && !contract.UseGas(uint64(module.Memory.Min)*ewasmPageGas) {
+		return nil, ErrOutOfGas
+	}
+	env := &eei{in: in, contract: contract}
+	instance, err := wasm.Instantiate(module, wasm.Config{
+		Resolver:     env.resolve,
+		UseGas:       contract.UseGas,
+		MaxCallDepth: ewasmMaxCallDepth,
+		MaxPages:     ewasmMaxPages,
+		PageGas:      ewasmPageGas,
+	})
+	if err != nil {
+		return nil, err
+	}
+	env.instance = instance
+
+	switch _, err = instance.Invoke("main"); err {
+	case nil:
+		return nil, nil
+	case errEWASMFinish:
+		return env.ret, nil
+	case errEWASMRevert:
+		return env.ret, errExecutionReverted
+	case wasm.ErrOutOfGas:
+		return nil, ErrOutOfGas
+	default:
+		return nil, err
+	}
+}
+
+// validateEWASM checks that a module adheres to the eWASM contract interface:
+// it must export exactly a main function without parameters and results and
+// its memory, and must not have a start function.
+func validateEWASM(m *wasm.Module) error {
+	if len(m.Exports) != 2 {
+		return fmt.Errorf("%v: must export only main and memory", errEWASMInvalidContract)
+	}
+	main, ok := m.Exports["main"]
+	if !ok || main.Kind != wasm.ExternalFunction || !m.FuncType(main.Index).Equal(wasm.FuncType{}) {
+		return fmt.Errorf("%v: missing main function", errEWASMInvalidContract)
+	}
+	if memory, ok := m.Exports["memory"]; !ok || memory.Kind != wasm.ExternalMemory {
+		return fmt.Errorf("%v: missing exported memory", errEWASMInvalidContract)
+	}
+	if m.Start != nil {
+		return fmt.Errorf("%v: start function not allowed", errEWASMInvalidContract)
+	}
+	return nil
+}
+
+// eei implements the host functions of the Ethereum Environment Interface for
+// a single contract execution.
+type eei struct {
+	in       *EWASMInterpreter
+	contract *Contract
+	instance *wasm.Instance
+	ret      []byte // Data passed to finish or revert
+}
+
+// eeiFunc is the implementation of a host function along with its signature.
+type eeiFunc struct {
+	params  []wasm.ValueType
+	results []wasm.ValueType
+	fn      func(e *eei, args []uint64) (uint64, error)
+}
+
+var (
+	i32 = wasm.I32
+	i64 = wasm.I64
+)
+
+// eeiFuncs are the host functions importable from the "ethereum" namespace.
+var eeiFuncs = map[string]eeiFunc{
+	"useGas":              {[]wasm.ValueType{i64}, nil, (*eei).useGas},
+	"getGasLeft":          {nil, []wasm.ValueType{i64}, (*eei).getGasLeft},
+	"getAddress":          {[]wasm.ValueType{i32}, nil, (*eei).getAddress},
+	"getExternalBalance":  {[]wasm.ValueType{i32, i32}, nil, (*eei).getExternalBalance},
+	"getBlockHash":        {[]wasm.ValueType{i64, i32}, []wasm.ValueType{i32}, (*eei).getBlockHash},
+	"call":                {[]wasm.ValueType{i64, i32, i32, i32, i32}, []wasm.ValueType{i32}, (*eei).callValue},
+	"callCode":            {[]wasm.ValueType{i64, i32, i32, i32, i32}, []wasm.ValueType{i32}, (*eei).callCode},
+	"callDelegate":        {[]wasm.ValueType{i64, i32, i32, i32}, []wasm.ValueType{i32}, (*eei).callDelegate},
+	"callStatic":          {[]wasm.ValueType{i64, i32, i32, i32}, []wasm.ValueType{i32}, (*eei).callStatic},
+	"callDataCopy":        {[]wasm.ValueType{i32, i32, i32}, nil, (*eei).callDataCopy},
+	"getCallDataSize":     {nil, []wasm.ValueType{i32}, (*eei).getCallDataSize},
+	"storageStore":        {[]wasm.ValueType{i32, i32}, nil, (*eei).storageStore},
+	"storageLoad":         {[]wasm.ValueType{i32, i32}, nil, (*eei).storageLoad},
+	"getCaller":           {[]wasm.ValueType{i32}, nil, (*eei).getCaller},
+	"getCallValue":        {[]wasm.ValueType{i32}, nil, (*eei).getCallValue},
+	"codeCopy":            {[]wasm.ValueType{i32, i32, i32}, nil, (*eei).codeCopy},
+	"getCodeSize":         {nil, []wasm.ValueType{i32}, (*eei).getCodeSize},
+	"getBlockCoinbase":    {[]wasm.ValueType{i32}, nil, (*eei).getBlockCoinbase},
+	"create":              {[]wasm.ValueType{i32, i32, i32, i32}, []wasm.ValueType{i32}, (*eei).create},
+	"getBlockDifficulty":  {[]wasm.ValueType{i32}, nil, (*eei).getBlockDifficulty},
+	"externalCodeCopy":    {[]wasm.ValueType{i32, i32, i32, i32}, nil, (*eei).externalCodeCopy},
+	"getExternalCodeSize": {[]wasm.ValueType{i32}, []wasm.ValueType{i32}, (*eei).getExternalCodeSize},
+	"getBlockGasLimit":    {nil, []wasm.ValueType{i64}, (*eei).getBlockGasLimit},
+	"getTxGasPrice":       {[]wasm.ValueType{i32}, nil, (*eei).getTxGasPrice},
+	"log":                 {[]wasm.ValueType{i32, i32, i32, i32, i32, i32, i32}, nil, (*eei).log},
+	"getBlockNumber":      {nil, []wasm.ValueType{i64}, (*eei).getBlockNumber},
+	"getTxOrigin":         {[]wasm.ValueType{i32}, nil, (*eei).getTxOrigin},
+	"finish":              {[]wasm.ValueType{i32, i32}, nil, (*eei).finish},
+	"revert":              {[]wasm.ValueType{i32, i32}, nil, (*eei).revert},
+	"getReturnDataSize":   {nil, []wasm.ValueType{i32}, (*eei).getReturnDataSize},
+	"returnDataCopy":      {[]wasm.ValueType{i32, i32, i32}, nil, (*eei).returnDataCopy},
+	"selfDestruct":        {[]wasm.ValueType{i32}, nil, (*eei).selfDestruct},
+	"getBlockTimestamp":   {nil, []wasm.ValueType{i64}, (*eei).getBlockTimestamp},
+}
+
+// resolve binds the functions imported by a contract to the EEI.
+func (e *eei) resolve(module, name string, typ wasm.FuncType) (wasm.HostFunc, error) {
+	f, ok := eeiFuncs[name]
+	if module != "ethereum" || !ok {
+		return nil, fmt.Errorf("%v: unknown import %s.%s", errEWASMInvalidContract, module, name)
+	}
+	if !typ.Equal(wasm.FuncType{Params: f.params, Results: f.results}) {
+		return nil, fmt.Errorf("%v: import %s.%s has signature %v", errEWASMInvalidContract, module, name, typ)
+	}
+	return func(args []uint64) (uint64, error) { return f.fn(e, args) }, nil
+}
+
+// enforceRestrictions fails if the chain restricts the opcode equivalent to a
+// host function, unless the transaction origin or the contract is allowlisted.
+func (e *eei) enforceRestrictions(op OpCode) error {
+	if allowed, ok := e.in.restricted[op]; ok {
+		if !allowed[e.in.evm.Origin] && !allowed[e.contract.Address()] {
+			return &ErrRestrictedOpcode{Op: op}
+		}
+	}
+	return nil
+}
+
+// charge consumes gas from the contract, failing if there is not enough left.
+func (e *eei) charge(gas uint64) error {
+	if !e.contract.UseGas(gas) {
+		return ErrOutOfGas
+	}
+	return nil
+}
+
+// chargeCopy consumes the gas of copying length bytes into the memory.
+func (e *eei) chargeCopy(base uint64, length uint64) error {
+	return e.charge(base + toWordSize(length)*params.CopyGas)
+}
+
+// memory returns the range of the contract's memory at the given offset.
+func (e *eei) memory(offset, length uint64) ([]byte, error) {
+	mem := e.instance.Memory()
+	offset, length = uint64(uint32(offset)), uint64(uint32(length))
+	if offset+length > uint64(len(mem)) {
+		return nil, wasm.ErrMemoryAccess
+	}
+	return mem[offset : offset+length], nil
+}
+
+// read returns a copy of the range of the contract's memory.
+func (e *eei) read(offset, length uint64) ([]byte, error) {
+	mem, err := e.memory(offset, length)
+	if err != nil {
+		return nil, err
+	}
+	return common.CopyBytes(mem), nil
+}
+
+// write stores the data in the contract's memory at the given offset.
+func (e *eei) write(offset uint64, data []byte) error {
+	mem, err := e.memory(offset, uint64(len(data)))
+	if err != nil {
+		return err
+	}
+	copy(mem, data)
+	return nil
+}
+
+func (e *eei) readAddress(offset uint64) (common.Address, error) {
+	data, err := e.memory(offset, common.AddressLength)
+	return common.BytesToAddress(data), err
+}
+
+// readLittleEndian reads an unsigned little endian integer of the given size.
+func (e *eei) readLittleEndian(offset uint64, size int) (*big.Int, error) {
+	data, err := e.read(offset, uint64(size))
+	if err != nil {
+		return nil, err
+	}
+	for i, j := 0, len(data)-1; i < j; i, j = i+1, j-1 {
+		data[i], data[j] = data[j], data[i]
+	}
+	return new(big.Int).SetBytes(data), nil
+}
+
+// writeLittleEndian stores the lowest size bytes of the value in little endian
+// byte order.
+func (e *eei) writeLittleEndian(offset uint64, value *big.Int, size int) error {
+	data := math.PaddedBigBytes(value, size)
+	data = common.CopyBytes(data[len(data)-size:])
+	for i, j := 0, len(data)-1; i < j; i, j = i+1, j-1 {
+		data[i], data[j] = data[j], data[i]
+	}
+	return e.write(offset, data)
+}
+
+func (e *eei) useGas(args []uint64) (uint64, error) {
+	return 0, e.charge(args[0])
+}
+
+func (e *eei) getGasLeft(args []uint64) (uint64, error) {
+	if err := e.charge(GasQuickStep); err != nil {
+		return 0, err
+	}
+	return e.contract.Gas, nil
+}
+
+func (e *eei) getAddress(args []uint64) (uint64, error) {
+	if err := e.charge(GasQuickStep); err != nil {
+		return 0, err
+	}
+	return 0, e.write(args[0], e.contract.Address().Bytes())
+}
+
+func (e *eei) getExternalBalance(args []uint64) (uint64, error) {
+	if err := e.charge(e.in.gasTable.Balance); err != nil {
+		return 0, err
+	}
+	addr, err := e.readAddress(args[0])
+	if err != nil {
+		return 0, err
+	}
+	return 0, e.writeLittleEndian(args[1], e.in.evm.StateDB.GetBalance(addr), 16)
+}
+
+func (e *eei) getBlockHash(args []uint64) (uint64, error) {
+	if err := e.charge(GasExtStep); err != nil {
+		return 0, err
+	}
+	var (
+		num     = new(big.Int).SetUint64(args[0])
+		current = e.in.evm.BlockNumber
+	)
+	if num.Cmp(current) >= 0 || num.Cmp(new(big.Int).Sub(current, common.Big257)) <= 0 {
+		return 1, nil
+	}
+	return 0, e.write(args[1], e.in.evm.GetHash(args[0]).Bytes())
+}
+
+func (e *eei) callValue(args []uint64) (uint64, error) {
+	return e.call(CALL, args)
+}
+
+func (e *eei) callCode(args []uint64) (uint64, error) {
+	return e.call(CALLCODE, args)
+}
+
+func (e *eei) callDelegate(args []uint64) (uint64, error) {
+	return e.call(DELEGATECALL, args)
+}
+
+func (e *eei) callStatic(args []uint64) (uint64, error) {
+	return e.call(STATICCALL, args)
+}
+
+// call performs a message call of the given kind, returning 0 on success, 1 on
+// failure and 2 if the callee reverted.
+func (e *eei) call(kind OpCode, args []uint64) (uint64, error) {
+	evm, value := e.in.evm, new(big.Int)
+
+	addr, err := e.readAddress(args[1])
+	if err != nil {
+		return 0, err
+	}
+	if kind == CALL || kind == CALLCODE {
+		if value, err = e.readLittleEndian(args[2], 16); err != nil {
+			return 0, err
+		}
+		args = append(args[:2], args[3:]...)
+	}
+	input, err := e.read(args[2], args[3])
+	if err != nil {
+		return 0, err
+	}
+	// Charge the call the same way as its EVM counterpart
+	cost := e.in.gasTable.Calls
+	if value.Sign() != 0 {
+		if kind == CALL && e.in.readOnly {
+			return 0, errWriteProtection
+		}
+		cost += params.CallValueTransferGas
+	}
+	if kind == CALL {
+		if evm.chainRules.IsEIP158 {
+			if value.Sign() != 0 && evm.StateDB.Empty(addr) {
+				cost += params.CallNewAccountGas
+			}
+		} else if !evm.StateDB.Exist(addr) {
+			cost += params.CallNewAccountGas
+		}
+	}
+	if err := e.charge(cost); err != nil {
+		return 0, err
+	}
+	gas, err := callGas(e.in.gasTable, e.contract.Gas, 0, new(big.Int).SetUint64(args[0]))
+	if err != nil {
+		return 0, err
+	}
+	if err := e.charge(gas); err != nil {
+		return 0, err
+	}
+	if value.Sign() != 0 {
+		gas += params.CallStipend
+	}
+	var (
+		ret      []byte
+		leftover uint64
+	)
+	switch {
+	case kind == CALL && e.in.readOnly:
+		// Static calls must remain static across interpreters
+		ret, leftover, err = evm.StaticCall(e.contract, addr, input, gas)
+	case kind == CALL:
+		ret, leftover, err = evm.Call(e.contract, addr, input, gas, value)
+	case kind == CALLCODE:
+		ret, leftover, err = evm.CallCode(e.contract, addr, input, gas, value)
+	case kind == DELEGATECALL:
+		ret, leftover, err = evm.DelegateCall(e.contract, addr, input, gas)
+	default:
+		ret, leftover, err = evm.StaticCall(e.contract, addr, input, gas)
+	}
+	e.contract.Gas += leftover
+	e.in.returnData = ret
+
+	switch err {
+	case nil:
+		return 0, nil
+	case errExecutionReverted:
+		return 2, nil
+	default:
+		return 1, nil
+	}
+}
+
+func (e *eei) callDataCopy(args []uint64) (uint64, error) {
+	if err := e.chargeCopy(GasFastestStep, args[2]); err != nil {
+		return 0, err
+	}
+	return 0, e.write(args[0], getData(e.contract.Input, args[1], args[2]))
+}
+
+func (e *eei) getCallDataSize(args []uint64) (uint64, error) {
+	if err := e.charge(GasQuickStep); err != nil {
+		return 0, err
+	}
+	return uint64(len(e.contract.Input)), nil
+}
+
+// storageStore writes a storage slot, charged by the legacy SSTORE rules.
+func (e *eei) storageStore(args []uint64) (uint64, error) {
+	if e.in.readOnly {
+		return 0, errWriteProtection
+	}
+	key, err := e.memory(args[0], common.HashLength)
+	if err != nil {
+		return 0, err
+	}
+	val, err := e.memory(args[1], common.HashLength)
+	if err != nil {
+		return 0, err
+	}
+	var (
+		db      = e.in.evm.StateDB
+		slot    = common.BytesToHash(key)
+		value   = common.BytesToHash(val)
+		current = db.GetState(e.contract.Address(), slot)
+	)
+	switch {
+	case current == (common.Hash{}) && value != (common.Hash{}):
+		err = e.charge(params.SstoreSetGas)
+	case current != (common.Hash{}) && value == (common.Hash{}):
+		if err = e.charge(params.SstoreClearGas); err == nil {
+			db.AddRefund(params.SstoreRefundGas)
+		}
+	default:
+		err = e.charge(params.SstoreResetGas)
+	}
+	if err != nil {
+		return 0, err
+	}
+	db.SetState(e.contract.Address(), slot, value)
+	return 0, nil
+}
+
+func (e *eei) storageLoad(args []uint64) (uint64, error) {
+	if err := e.charge(e.in.gasTable.SLoad); err != nil {
+		return 0, err
+	}
+	key, err := e.memory(args[0], common.HashLength)
+	if err != nil {
+		return 0, err
+	}
+	value := e.in.evm.StateDB.GetState(e.contract.Address(), common.BytesToHash(key))
+	return 0, e.write(args[1], value.Bytes())
+}
+
+func (e *eei) getCaller(args []uint64) (uint64, error) {
+	if err := e.charge(GasQuickStep); err != nil {
+		return 0, err
+	}
+	return 0, e.write(args[0], e.contract.Caller().Bytes())
+}
+
+func (e *eei) getCallValue(args []uint64) (uint64, error) {
+	if err := e.charge(GasQuickStep); err != nil {
+		return 0, err
+	}
+	return 0, e.writeLittleEndian(args[0], e.contract.Value(), 16)
+}
+
+func (e *eei) codeCopy(args []uint64) (uint64, error) {
+	if err := e.chargeCopy(GasFastestStep, args[2]); err != nil {
+		return 0, err
+	}
+	return 0, e.write(args[0], getData(e.contract.Code, args[1], args[2]))
+}
+
+func (e *eei) getCodeSize(args []uint64) (uint64, error) {
+	if err := e.charge(GasQuickStep); err != nil {
+		return 0, err
+	}
+	return uint64(len(e.contract.Code)), nil
+}
+
+func (e *eei) getBlockCoinbase(args []uint64) (uint64, error) {
+	if err := e.charge(GasQuickStep); err != nil {
+		return 0, err
+	}
+	return 0, e.write(args[0], e.in.evm.Coinbase.Bytes())
+}
+
+// create deploys a contract, returning 0 and writing its address on success, 1
+// on failure and 2 if the initialisation code reverted.
+func (e *eei) create(args []uint64) (uint64, error) {
+	if err := e.enforceRestrictions(CREATE); err != nil {
+		return 0, err
+	}
+	if e.in.readOnly {
+		return 0, errWriteProtection
+	}
+	value, err := e.readLittleEndian(args[0], 16)
+	if err != nil {
+		return 0, err
+	}
+	input, err := e.read(args[1], args[2])
+	if err != nil {
+		return 0, err
+	}
+	if err := e.charge(params.CreateGas); err != nil {
+		return 0, err
+	}
+	evm := e.in.evm
+	gas := e.contract.Gas
+	if evm.chainRules.IsEIP150 {
+		gas -= gas / 64
+	}
+	e.contract.UseGas(gas)
+
+	ret, addr, leftover, err := evm.Create(e.contract, input, gas, value)
+	e.contract.Gas += leftover
+	e.in.returnData = nil
+
+	switch {
+	case err == nil || (err == ErrCodeStoreOutOfGas && !evm.chainRules.IsHomestead):
+		return 0, e.write(args[3], addr.Bytes())
+	case err == errExecutionReverted:
+		e.in.returnData = ret
+		return 2, nil
+	default:
+		return 1, nil
+	}
+}
+
+func (e *eei) getBlockDifficulty(args []uint64) (uint64, error) {
+	if err := e.charge(GasQuickStep); err != nil {
+		return 0, err
+	}
+	return 0, e.writeLittleEndian(args[0], e.in.evm.Difficulty, 32)
+}
+
+func (e *eei) externalCodeCopy(args []uint64) (uint64, error) {
+	if err := e.chargeCopy(e.in.gasTable.ExtcodeCopy, args[3]); err != nil {
+		return 0, err
+	}
+	addr, err := e.readAddress(args[0])
+	if err != nil {
+		return 0, err
+	}
+	return 0, e.write(args[1], getData(e.in.evm.StateDB.GetCode(addr), args[2], args[3]))
+}
+
+func (e *eei) getExternalCodeSize(args []uint64) (uint64, error) {
+	if err := e.charge(e.in.gasTable.ExtcodeSize); err != nil {
+		return 0, err
+	}
+	addr, err := e.readAddress(args[0])
+	if err != nil {
+		return 0, err
+	}
+	return uint64(e.in.evm.StateDB.GetCodeSize(addr)), nil
+}
+
+func (e *eei) getBlockGasLimit(args []uint64) (uint64, error) {
+	if err := e.charge(GasQuickStep); err != nil {
+		return 0, err
+	}
+	return e.in.evm.GasLimit, nil
+}
+
+func (e *eei) getTxGasPrice(args []uint64) (uint64, error) {
+	if err := e.charge(GasQuickStep); err != nil {
+		return 0, err
+	}
+	return 0, e.writeLittleEndian(args[0], e.in.evm.GasPrice, 16)
+}
+
+func (e *eei) log(args []uint64) (uint64, error) {
+	if e.in.readOnly {
+		return 0, errWriteProtection
+	}
+	count := uint64(uint32(args[2]))
+	if count > 4 {
+		return 0, fmt.Errorf("ewasm: log with %d topics", count)
+	}
+	length := uint64(uint32(args[1]))
+	if err := e.charge(params.LogGas + count*params.LogTopicGas + length*params.LogDataGas); err != nil {
+		return 0, err
+	}
+	data, err := e.read(args[0], length)
+	if err != nil {
+		return 0, err
+	}
+	topics := make([]common.Hash, count)
+	for i := range topics {
+		topic, err := e.memory(args[3+i], common.HashLength)
+		if err != nil {
+			return 0, err
+		}
+		topics[i] = common.BytesToHash(topic)
+	}
+	e.in.evm.StateDB.AddLog(&types.Log{
+		Address: e.contract.Address(),
+		Topics:  topics,
+		Data:    data,
+		// This is a non-consensus field, but assigned here because
+		// core/state doesn't know the current block number.
+		BlockNumber: e.in.evm.BlockNumber.Uint64(),
+	})
+	return 0, nil
+}
+
+func (e *eei) getBlockNumber(args []uint64) (uint64, error) {
+	if err := e.charge(GasQuickStep); err != nil {
+		return 0, err
+	}
+	return e.in.evm.BlockNumber.Uint64(), nil
+}
+
+func (e *eei) getTxOrigin(args []uint64) (uint64, error) {
+	if err := e.charge(GasQuickStep); err != nil {
+		return 0, err
+	}
+	return 0, e.write(args[0], e.in.evm.Origin.Bytes())
+}
+
+func (e *eei) finish(args []uint64) (uint64, error) {
+	ret, err := e.read(args[0], args[1])
+	if err != nil {
+		return 0, err
+	}
+	e.ret = ret
+	return 0, errEWASMFinish
+}
+
+func (e *eei) revert(args []uint64) (uint64, error) {
+	ret, err := e.read(args[0], args[1])
+	if err != nil {
+		return 0, err
+	}
+	e.ret = ret
+	return 0, errEWASMRevert
+}
+
+func (e *eei) getReturnDataSize(args []uint64) (uint64, error) {
+	if err := e.charge(GasQuickStep); err != nil {
+		return 0, err
+	}
+	return uint64(len(e.in.returnData)), nil
+}
+
+func (e *eei) returnDataCopy(args []uint64) (uint64, error) {
+	if err := e.chargeCopy(GasFastestStep, args[2]); err != nil {
+		return 0, err
+	}
+	offset, length := uint64(uint32(args[1])), uint64(uint32(args[2]))
+	if offset+length > uint64(len(e.in.returnData)) {
+		return 0, errReturnDataOutOfBounds
+	}
+	return 0, e.write(args[0], e.in.returnData[offset:offset+length])
+}
+
+// selfDestruct destroys the contract, sending its balance to the beneficiary,
+// and halts the execution.
+func (e *eei) selfDestruct(args []uint64) (uint64, error) {
+	if err := e.enforceRestrictions(SELFDESTRUCT); err != nil {
+		return 0, err
+	}
+	if e.in.readOnly {
+		return 0, errWriteProtection
+	}
+	beneficiary, err := e.readAddress(args[0])
+	if err != nil {
+		return 0, err
+	}
+	var (
+		db      = e.in.evm.StateDB
+		self    = e.contract.Address()
+		balance = db.GetBalance(self)
+		cost    = e.in.gasTable.Suicide
+	)
+	if e.in.evm.chainRules.IsEIP150 {
+		if e.in.evm.chainRules.IsEIP158 {
+			if db.Empty(beneficiary) && balance.Sign() != 0 {
+				cost += e.in.gasTable.CreateBySuicide
+			}
+		} else if !db.Exist(beneficiary) {
+			cost += e.in.gasTable.CreateBySuicide
+		}
+	}
+	if err := e.charge(cost); err != nil {
+		return 0, err
+	}
+	if !db.HasSuicided(self) {
+		db.AddRefund(params.SuicideRefundGas)
+	}
+	db.AddBalance(beneficiary, balance)
+	db.Suicide(self)
+
+	e.ret = nil
+	return 0, errEWASMFinish
+}
+
+func (e *eei) getBlockTimestamp(args []uint64) (uint64, error) {
+	if err := e.charge(GasQuickStep); err != nil {
+		return 0, err
+	}
+	return e.in.evm.Time.Uint64(), nil
+}
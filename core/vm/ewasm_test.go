@@ -0,0 +1,278 @@
+// Copyright 2019 The go-gclchaineum Authors
+// This file is part of the go-gclchaineum library.
+//
+// The go-gclchaineum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-gclchaineum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-gclchaineum library. If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/gclchaineum/go-gclchaineum/common"
+	"github.com/gclchaineum/go-gclchaineum/core/state"
+	"github.com/gclchaineum/go-gclchaineum/core/vm/wasm"
+	"github.com/gclchaineum/go-gclchaineum/gcldb"
+	"github.com/gclchaineum/go-gclchaineum/params"
+)
+
+// ewasmImport is an EEI function imported by a test contract.
+type ewasmImport struct {
+	name            string
+	params, results []byte
+}
+
+func ewasmLEB(v uint64) []byte {
+	var out []byte
+	for ; v >= 0x80; v >>= 7 {
+		out = append(out, byte(v)|0x80)
+	}
+	return append(out, byte(v))
+}
+
+func ewasmSection(id byte, items ...[]byte) []byte {
+	content := ewasmLEB(uint64(len(items)))
+	for _, item := range items {
+		content = append(content, item...)
+	}
+	return append(append([]byte{id}, ewasmLEB(uint64(len(content)))...), content...)
+}
+
+func ewasmBytes(data []byte) []byte {
+	return append(ewasmLEB(uint64(len(data))), data...)
+}
+
+// ewasmContract assembles an eWASM contract importing the given EEI functions,
+// whose main function runs the code. The data is placed at memory offset 0.
+func ewasmContract(imports []ewasmImport, data []byte, code ...byte) []byte {
+	var types, imps [][]byte
+	for i, imp := range imports {
+		types = append(types, append(append([]byte{0x60}, ewasmBytes(imp.params)...), ewasmBytes(imp.results)...))
+		imps = append(imps, append(append(append(ewasmBytes([]byte("ethereum")), ewasmBytes([]byte(imp.name))...), 0x00), ewasmLEB(uint64(i))...))
+	}
+	types = append(types, []byte{0x60, 0x00, 0x00})
+
+	main := ewasmLEB(uint64(len(imports)))
+	module := append([]byte{}, wasm.Magic...)
+	module = append(module, ewasmSection(1, types...)...)
+	module = append(module, ewasmSection(2, imps...)...)
+	module = append(module, ewasmSection(3, ewasmLEB(uint64(len(imports))))...)
+	module = append(module, ewasmSection(5, []byte{0x00, 0x01})...)
+	module = append(module, ewasmSection(7, append(append(ewasmBytes([]byte("main")), 0x00), main...), append(ewasmBytes([]byte("memory")), 0x02, 0x00))...)
+	module = append(module, ewasmSection(10, ewasmBytes(append([]byte{0x00}, append(code, 0x0b)...)))...)
+	if len(data) > 0 {
+		module = append(module, ewasmSection(11, append([]byte{0x00, 0x41, 0x00, 0x0b}, ewasmBytes(data)...))...)
+	}
+	return module
+}
+
+var (
+	eeiStorageStore = ewasmImport{"storageStore", []byte{0x7f, 0x7f}, nil}
+	eeiLog          = ewasmImport{"log", []byte{0x7f, 0x7f, 0x7f, 0x7f, 0x7f, 0x7f, 0x7f}, nil}
+	eeiFinish       = ewasmImport{"finish", []byte{0x7f, 0x7f}, nil}
+	eeiRevert       = ewasmImport{"revert", []byte{0x7f, 0x7f}, nil}
+)
+
+// ewasmStoreContract stores 42 in slot 1, logs and returns "hello".
+func ewasmStoreContract() []byte {
+	data := append(common.LeftPadBytes([]byte{1}, 32), common.LeftPadBytes([]byte{42}, 32)...)
+	data = append(data, "hello"...)
+
+	return ewasmContract([]ewasmImport{eeiStorageStore, eeiLog, eeiFinish}, data,
+		0x41, 0x00, 0x41, 0x20, 0x10, 0x00, // storageStore(0, 32)
+		0x41, 0xc0, 0x00, 0x41, 0x05, 0x41, 0x01, 0x41, 0x00, 0x41, 0x00, 0x41, 0x00, 0x41, 0x00, 0x10, 0x01, // log(64, 5, 1, 0, 0, 0, 0)
+		0x41, 0xc0, 0x00, 0x41, 0x05, 0x10, 0x02, // finish(64, 5)
+	)
+}
+
+func newEWASMTestEVM() (*EVM, *state.StateDB) {
+	config := *params.AllEthashProtocolChanges
+	config.EWASMBlock = new(big.Int)
+
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(gcldb.NewMemDatabase()))
+	vmctx := Context{
+		CanTransfer: func(StateDB, common.Address, *big.Int) bool { return true },
+		Transfer:    func(StateDB, common.Address, common.Address, *big.Int) {},
+		BlockNumber: big.NewInt(1),
+	}
+	return NewEVM(vmctx, statedb, &config, Config{}), statedb
+}
+
+// Tests that eWASM contracts can access the storage, emit logs and return or
+// revert with data, with their execution metered.
+func TestEWASMCall(t *testing.T) {
+	var (
+		evm, statedb = newEWASMTestEVM()
+		origin       = common.BytesToAddress([]byte("origin"))
+		store        = common.BytesToAddress([]byte("store"))
+		revert       = common.BytesToAddress([]byte("revert"))
+	)
+	statedb.SetCode(store, ewasmStoreContract())
+	statedb.SetCode(revert, ewasmContract([]ewasmImport{eeiRevert}, []byte("oops"), 0x41, 0x00, 0x41, 0x04, 0x10, 0x00))
+
+	ret, left, err := evm.Call(AccountRef(origin), store, nil, 100000, new(big.Int))
+	if err != nil {
+		t.Fatalf("failed to call contract: %v", err)
+	}
+	if string(ret) != "hello" {
+		t.Errorf("return data mismatch: have %q, want %q", ret, "hello")
+	}
+	if used := 100000 - left; used <= params.SstoreSetGas {
+		t.Errorf("gas usage too low: %d", used)
+	}
+	if value := statedb.GetState(store, common.BytesToHash([]byte{1})); value != common.BytesToHash([]byte{42}) {
+		t.Errorf("storage mismatch: have %x, want 42", value)
+	}
+	if logs := statedb.Logs(); len(logs) != 1 || string(logs[0].Data) != "hello" || logs[0].Topics[0] != common.BytesToHash([]byte{1}) {
+		t.Errorf("log mismatch: have %v", logs)
+	}
+	// Reverts must return their data and keep the remaining gas
+	ret, left, err = evm.Call(AccountRef(origin), revert, nil, 100000, new(big.Int))
+	if err != errExecutionReverted || string(ret) != "oops" || left == 0 {
+		t.Errorf("revert mismatch: have %q, %d gas left, %v", ret, left, err)
+	}
+	// Running out of gas must consume everything
+	if _, left, err = evm.Call(AccountRef(origin), store, nil, 1000, new(big.Int)); err != ErrOutOfGas || left != 0 {
+		t.Errorf("out of gas mismatch: have %d gas left, %v", left, err)
+	}
+}
+
+// Tests that eWASM contracts can be deployed by eWASM initialisation code.
+func TestEWASMCreate(t *testing.T) {
+	var (
+		evm, statedb = newEWASMTestEVM()
+		origin       = common.BytesToAddress([]byte("origin"))
+		runtime      = ewasmStoreContract()
+	)
+	size := ewasmLEB(uint64(len(runtime)))
+	init := ewasmContract([]ewasmImport{eeiFinish}, runtime, append(append([]byte{0x41, 0x00, 0x41}, size...), 0x10, 0x00)...)
+
+	_, addr, _, err := evm.Create(AccountRef(origin), init, 1000000, new(big.Int))
+	if err != nil {
+		t.Fatalf("failed to deploy contract: %v", err)
+	}
+	if code := statedb.GetCode(addr); !bytes.Equal(code, runtime) {
+		t.Fatalf("deployed code mismatch")
+	}
+	if ret, _, err := evm.Call(AccountRef(origin), addr, nil, 100000, new(big.Int)); err != nil || string(ret) != "hello" {
+		t.Errorf("deployed contract call mismatch: have %q, %v", ret, err)
+	}
+}
+
+// Tests that static calls protect eWASM contracts from state modifications,
+// including calls nested in the EVM.
+func TestEWASMStaticCall(t *testing.T) {
+	var (
+		evm, statedb = newEWASMTestEVM()
+		origin       = common.BytesToAddress([]byte("origin"))
+		store        = common.BytesToAddress([]byte("store"))
+		proxy        = common.BytesToAddress([]byte("proxy"))
+	)
+	statedb.SetCode(store, ewasmStoreContract())
+
+	// The proxy calls the store contract and returns whether the call succeeded
+	code := []byte{byte(PUSH1), 0x20, byte(PUSH1), 0x00, byte(PUSH1), 0x00, byte(PUSH1), 0x00, byte(PUSH1), 0x00, byte(PUSH20)}
+	code = append(code, store.Bytes()...)
+	code = append(code, byte(GAS), byte(CALL), byte(PUSH1), 0x00, byte(MSTORE), byte(PUSH1), 0x20, byte(PUSH1), 0x00, byte(RETURN))
+	statedb.SetCode(proxy, code)
+
+	if _, _, err := evm.StaticCall(AccountRef(origin), store, nil, 100000); err != errWriteProtection {
+		t.Errorf("static call error mismatch: have %v, want %v", err, errWriteProtection)
+	}
+	ret, _, err := evm.StaticCall(AccountRef(origin), proxy, nil, 100000)
+	if err != nil {
+		t.Fatalf("failed to call proxy: %v", err)
+	}
+	if new(big.Int).SetBytes(ret).Sign() != 0 {
+		t.Errorf("nested call succeeded in static context")
+	}
+	if value := statedb.GetState(store, common.BytesToHash([]byte{1})); value != (common.Hash{}) {
+		t.Errorf("storage modified in static context: %x", value)
+	}
+	ret, _, err = evm.Call(AccountRef(origin), proxy, nil, 100000, new(big.Int))
+	if err != nil || new(big.Int).SetBytes(ret).Uint64() != 1 {
+		t.Errorf("nested call mismatch: have %x, %v", ret, err)
+	}
+}
+
+// Tests that modules violating the eWASM contract interface are rejected.
+func TestEWASMInvalidContract(t *testing.T) {
+	var (
+		evm, statedb = newEWASMTestEVM()
+		origin       = common.BytesToAddress([]byte("origin"))
+		bogus        = common.BytesToAddress([]byte("bogus"))
+	)
+	statedb.SetCode(bogus, ewasmContract([]ewasmImport{{"bogus", nil, nil}}, nil))
+
+	if _, _, err := evm.Call(AccountRef(origin), bogus, nil, 100000, new(big.Int)); err == nil {
+		t.Errorf("expected error for unknown import")
+	}
+}
+
+// Tests that the opcode restrictions of the chain apply to the equivalent host
+// functions of eWASM contracts.
+func TestEWASMOpcodeRestrictions(t *testing.T) {
+	var (
+		admin    = common.BytesToAddress([]byte("admin"))
+		user     = common.BytesToAddress([]byte("user"))
+		destruct = common.BytesToAddress([]byte("destruct"))
+		factory  = common.BytesToAddress([]byte("factory"))
+		config   = *params.AllEthashProtocolChanges
+	)
+	config.EWASMBlock = new(big.Int)
+	config.OpcodeRestrictions = []*params.OpcodeRestriction{
+		{Opcode: "CREATE", Block: new(big.Int), Allowlist: []common.Address{admin}},
+		{Opcode: "SELFDESTRUCT", Block: new(big.Int), Allowlist: []common.Address{admin}},
+	}
+	var (
+		selfDestruct = ewasmImport{"selfDestruct", []byte{0x7f}, nil}
+		create       = ewasmImport{"create", []byte{0x7f, 0x7f, 0x7f, 0x7f}, []byte{0x7f}}
+	)
+	tests := []struct {
+		origin     common.Address
+		contract   common.Address
+		op         OpCode
+		restricted bool
+	}{
+		{user, destruct, SELFDESTRUCT, true},
+		{admin, destruct, SELFDESTRUCT, false},
+		{user, factory, CREATE, true},
+		{admin, factory, CREATE, false},
+	}
+	for i, tt := range tests {
+		statedb, _ := state.New(common.Hash{}, state.NewDatabase(gcldb.NewMemDatabase()))
+		statedb.SetCode(destruct, ewasmContract([]ewasmImport{selfDestruct}, nil, 0x41, 0x00, 0x10, 0x00))
+		statedb.SetCode(factory, ewasmContract([]ewasmImport{create}, nil, 0x41, 0x00, 0x41, 0x10, 0x41, 0x00, 0x41, 0x20, 0x10, 0x00, 0x1a))
+
+		vmctx := Context{
+			CanTransfer: func(StateDB, common.Address, *big.Int) bool { return true },
+			Transfer:    func(StateDB, common.Address, common.Address, *big.Int) {},
+			Origin:      tt.origin,
+			BlockNumber: big.NewInt(1),
+		}
+		evm := NewEVM(vmctx, statedb, &config, Config{})
+
+		_, _, err := evm.Call(AccountRef(tt.origin), tt.contract, nil, 1000000, new(big.Int))
+		if restricted, ok := err.(*ErrRestrictedOpcode); ok != tt.restricted || (ok && restricted.Op != tt.op) {
+			t.Errorf("test %d: restriction mismatch: have %v, want restricted %v", i, err, tt.restricted)
+		}
+		if tt.op == SELFDESTRUCT && statedb.HasSuicided(destruct) == tt.restricted {
+			t.Errorf("test %d: self-destruct mismatch: have %v, want %v", i, !tt.restricted, tt.restricted)
+		}
+		if tt.op == CREATE && (statedb.GetNonce(factory) > 0) == tt.restricted {
+			t.Errorf("test %d: creation mismatch: have %v, want %v", i, !tt.restricted, tt.restricted)
+		}
+	}
+}
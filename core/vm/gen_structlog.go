@@ -27,6 +27,7 @@ func (s StructLog) MarshalJSON() ([]byte, error) {
 		Depth         int                         `json:"depth"`
 		RefundCounter uint64                      `json:"refund"`
 		Err           error                       `json:"-"`
+		Source        *SourcePosition             `json:"source,omitempty"`
 		OpName        string                      `json:"opName"`
 		ErrorString   string                      `json:"error"`
 	}
@@ -47,6 +48,7 @@ func (s StructLog) MarshalJSON() ([]byte, error) {
 	enc.Depth = s.Depth
 	enc.RefundCounter = s.RefundCounter
 	enc.Err = s.Err
+	enc.Source = s.Source
 	enc.OpName = s.OpName()
 	enc.ErrorString = s.ErrorString()
 	return json.Marshal(&enc)
@@ -66,6 +68,7 @@ func (s *StructLog) UnmarshalJSON(input []byte) error {
 		Depth         *int                        `json:"depth"`
 		RefundCounter *uint64                     `json:"refund"`
 		Err           error                       `json:"-"`
+		Source        *SourcePosition             `json:"source,omitempty"`
 	}
 	var dec StructLog
 	if err := json.Unmarshal(input, &dec); err != nil {
@@ -107,5 +110,8 @@ func (s *StructLog) UnmarshalJSON(input []byte) error {
 	if dec.Err != nil {
 		s.Err = dec.Err
 	}
+	if dec.Source != nil {
+		s.Source = dec.Source
+	}
 	return nil
 }
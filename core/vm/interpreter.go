@@ -46,6 +46,10 @@ type Config struct {
 	EWASMInterpreter string
 	// Type of the EVM interpreter
 	EVMInterpreter string
+
+	// ParallelExecution enables the speculative parallel execution of the
+	// transactions of a block by the state processor
+	ParallelExecution bool
 }
 
 // Interpreter is used to run Gclchain based contracts and will utilise the
@@ -91,6 +95,8 @@ type EVMInterpreter struct {
 
 	readOnly   bool   // Whgclchain to throw on stateful modifications
 	returnData []byte // Last CALL's return data for subsequent reuse
+
+	restricted map[OpCode]map[common.Address]bool // Allowlists of the opcodes restricted by the chain config
 }
 
 // NewEVMInterpreter returns a new instance of the Interpreter.
@@ -100,6 +106,8 @@ func NewEVMInterpreter(evm *EVM, cfg Config) *EVMInterpreter {
 	// we'll set the default jump table.
 	if !cfg.JumpTable[STOP].valid {
 		switch {
+		case evm.ChainConfig().IsIstanbul(evm.BlockNumber):
+			cfg.JumpTable = istanbulInstructionSet
 		case evm.ChainConfig().IsConstantinople(evm.BlockNumber):
 			cfg.JumpTable = constantinopleInstructionSet
 		case evm.ChainConfig().IsByzantium(evm.BlockNumber):
@@ -112,13 +120,19 @@ func NewEVMInterpreter(evm *EVM, cfg Config) *EVMInterpreter {
 	}
 
 	return &EVMInterpreter{
-		evm:      evm,
-		cfg:      cfg,
-		gasTable: evm.ChainConfig().GasTable(evm.BlockNumber),
+		evm:        evm,
+		cfg:        cfg,
+		gasTable:   evm.ChainConfig().GasTable(evm.BlockNumber),
+		restricted: activeRestrictions(evm.ChainConfig(), evm.BlockNumber),
 	}
 }
 
-func (in *EVMInterpreter) enforceRestrictions(op OpCode, operation operation, stack *Stack) error {
+func (in *EVMInterpreter) enforceRestrictions(op OpCode, operation operation, stack *Stack, contract *Contract) error {
+	if allowed, ok := in.restricted[op]; ok {
+		if !allowed[in.evm.Origin] && !allowed[contract.Address()] {
+			return &ErrRestrictedOpcode{Op: op}
+		}
+	}
 	if in.evm.chainRules.IsByzantium {
 		if in.readOnly {
 			// If the interpreter is operating in readonly mode, make sure no
@@ -154,8 +168,9 @@ func (in *EVMInterpreter) Run(contract *Contract, input []byte, readOnly bool) (
 	defer func() { in.evm.depth-- }()
 
 	// Make sure the readOnly is only set if we aren't in readOnly yet.
-	// This makes also sure that the readOnly flag isn't removed for child calls.
-	if readOnly && !in.readOnly {
+	// This makes also sure that the readOnly flag isn't removed for child calls,
+	// including the ones entered through the other interpreters.
+	if (readOnly || in.evm.readOnly()) && !in.readOnly {
 		in.readOnly = true
 		defer func() { in.readOnly = false }()
 	}
@@ -220,7 +235,7 @@ func (in *EVMInterpreter) Run(contract *Contract, input []byte, readOnly bool) (
 			return nil, err
 		}
 		// If the operation is valid, enforce and write restrictions
-		if err := in.enforceRestrictions(op, operation, stack); err != nil {
+		if err := in.enforceRestrictions(op, operation, stack, contract); err != nil {
 			return nil, err
 		}
 
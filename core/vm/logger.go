@@ -27,6 +27,7 @@ import (
 	"github.com/gclchaineum/go-gclchaineum/common/hexutil"
 	"github.com/gclchaineum/go-gclchaineum/common/math"
 	"github.com/gclchaineum/go-gclchaineum/core/types"
+	"github.com/gclchaineum/go-gclchaineum/crypto"
 )
 
 // Storage represents a contract's storage.
@@ -49,6 +50,60 @@ type LogConfig struct {
 	DisableStorage bool // disable storage capture
 	Debug          bool // print output during capture end
 	Limit          int  // maximum length of output, but zero means unlimited
+
+	Sources SourceLocator `json:"-"` // annotates logs with source positions, if set
+
+	// Source locator of the code of the last located contract, nil if unknown
+	frame   *Contract
+	locator func(pc uint64) (file string, line int, function string, ok bool)
+}
+
+// SourceLocator resolves program counters within contract code to positions in
+// the sources the code was compiled from.
+type SourceLocator interface {
+	// Locator returns a function resolving the program counters within the code
+	// with the given hash, or nil if the code wasn't compiled from the sources.
+	Locator(codeHash common.Hash) func(pc uint64) (file string, line int, function string, ok bool)
+}
+
+// SourcePosition is the location in the contract sources an instruction was
+// compiled from.
+type SourcePosition struct {
+	File     string `json:"file"`
+	Line     int    `json:"line"`
+	Function string `json:"function,omitempty"`
+}
+
+// String formats the position as file:line, followed by the function if known.
+func (p *SourcePosition) String() string {
+	if p.Function == "" {
+		return fmt.Sprintf("%s:%d", p.File, p.Line)
+	}
+	return fmt.Sprintf("%s:%d (%s)", p.File, p.Line, p.Function)
+}
+
+// locate resolves the source position of the instruction at pc, if a source
+// locator is configured. The code of a contract is only looked up when its
+// frame is entered or returned to, not on every step.
+func (cfg *LogConfig) locate(contract *Contract, pc uint64) *SourcePosition {
+	if cfg.Sources == nil {
+		return nil
+	}
+	if cfg.frame != contract {
+		hash := contract.CodeHash
+		if hash == (common.Hash{}) {
+			hash = crypto.Keccak256Hash(contract.Code)
+		}
+		cfg.frame, cfg.locator = contract, cfg.Sources.Locator(hash)
+	}
+	if cfg.locator == nil {
+		return nil
+	}
+	file, line, function, ok := cfg.locator(pc)
+	if !ok {
+		return nil
+	}
+	return &SourcePosition{File: file, Line: line, Function: function}
 }
 
 //go:generate gencodec -type StructLog -field-override structLogMarshaling -out gen_structlog.go
@@ -67,6 +122,7 @@ type StructLog struct {
 	Depth         int                         `json:"depth"`
 	RefundCounter uint64                      `json:"refund"`
 	Err           error                       `json:"-"`
+	Source        *SourcePosition             `json:"source,omitempty"`
 }
 
 // overrides for gencodec
@@ -178,7 +234,7 @@ func (l *StructLogger) CaptureState(env *EVM, pc uint64, op OpCode, gas, cost ui
 		storage = l.changedValues[contract.Address()].Copy()
 	}
 	// create a new snaptshot of the EVM.
-	log := StructLog{pc, op, gas, cost, mem, memory.Len(), stck, storage, depth, env.StateDB.GetRefund(), err}
+	log := StructLog{pc, op, gas, cost, mem, memory.Len(), stck, storage, depth, env.StateDB.GetRefund(), err, l.cfg.locate(contract, pc)}
 
 	l.logs = append(l.logs, log)
 	return nil
@@ -219,6 +275,9 @@ func WriteTrace(writer io.Writer, logs []StructLog) {
 		if log.Err != nil {
 			fmt.Fprintf(writer, " ERROR: %v", log.Err)
 		}
+		if log.Source != nil {
+			fmt.Fprintf(writer, " at %v", log.Source)
+		}
 		fmt.Fprintln(writer)
 
 		if len(log.Stack) > 0 {
@@ -57,6 +57,7 @@ func (l *JSONLogger) CaptureState(env *EVM, pc uint64, op OpCode, gas, cost uint
 		Depth:         depth,
 		RefundCounter: env.StateDB.GetRefund(),
 		Err:           err,
+		Source:        l.cfg.locate(contract, pc),
 	}
 	if !l.cfg.DisableMemory {
 		log.Memory = memory.Data()
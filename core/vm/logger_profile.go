@@ -0,0 +1,245 @@
+// Copyright 2019 The go-gclchaineum Authors
+// This file is part of the go-gclchaineum library.
+//
+// The go-gclchaineum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-gclchaineum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-gclchaineum library. If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"fmt"
+	"io"
+	"math/big"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gclchaineum/go-gclchaineum/common"
+	"github.com/gclchaineum/go-gclchaineum/common/hexutil"
+)
+
+// ProfileFrame is the gas breakdown of a single call frame of a transaction.
+type ProfileFrame struct {
+	Type     string            `json:"type"`               // Opcode which entered the frame (CALL, CREATE, ...)
+	Address  common.Address    `json:"address"`            // Address of the code executing in the frame
+	Selector hexutil.Bytes     `json:"selector,omitempty"` // 4 byte function selector of the call input
+	Ops      map[string]uint64 `json:"ops,omitempty"`      // Gas used by the frame's own opcodes
+	Self     uint64            `json:"self"`               // Gas used by the frame's own opcodes in total
+	Total    uint64            `json:"total"`              // Gas used by the frame, including sub-calls
+	Calls    []*ProfileFrame   `json:"calls,omitempty"`    // Sub-calls made by the frame
+
+	pending *profileOp // Last opcode executed, waiting for its real gas usage
+}
+
+// profileOp is an opcode whose gas usage is only known once execution returns
+// to its frame, as calls forward, and later refund, gas to their callees.
+type profileOp struct {
+	op    OpCode // Opcode being executed
+	gas   uint64 // Gas available before executing the opcode
+	cost  uint64 // Gas cost reported for the opcode
+	inner uint64 // Gas used by the sub-calls spawned by the opcode
+}
+
+// Label returns the name of the frame in flamegraph stacks, formatted as the
+// address and function selector of the call.
+func (f *ProfileFrame) Label() string {
+	label := strings.ToLower(f.Address.Hex())
+	if len(f.Selector) > 0 {
+		label += ":" + f.Selector.String()
+	}
+	if f.Type != "CALL" {
+		label += "(" + f.Type + ")"
+	}
+	return label
+}
+
+// charge attributes gas used by an opcode to the frame.
+func (f *ProfileFrame) charge(op OpCode, gas uint64) {
+	if gas == 0 {
+		return
+	}
+	if f.Ops == nil {
+		f.Ops = make(map[string]uint64)
+	}
+	f.Ops[op.String()] += gas
+	f.Self += gas
+	f.Total += gas
+}
+
+// settle charges the pending opcode of the frame with the given gas usage,
+// excluding the amount already accounted for in the sub-calls it spawned.
+func (f *ProfileFrame) settle(used uint64) {
+	if f.pending == nil {
+		return
+	}
+	if used > f.pending.inner {
+		f.charge(f.pending.op, used-f.pending.inner)
+	}
+	f.pending = nil
+}
+
+// GasProfiler is an EVM tracer which builds a per call frame, per opcode gas
+// breakdown of a transaction, exportable in folded stack format for flamegraphs.
+//
+// The gas of an opcode is measured by the gas left when execution next reaches
+// its frame, so that calls are charged only the gas not used by their callees.
+type GasProfiler struct {
+	root  *ProfileFrame   // Outermost frame of the transaction
+	stack []*ProfileFrame // Frames currently executing, indexed by call depth
+}
+
+// NewGasProfiler creates a new EVM tracer that profiles gas usage by call frame
+// and opcode.
+func NewGasProfiler() *GasProfiler {
+	return new(GasProfiler)
+}
+
+// CaptureStart implements the Tracer interface to initialize the outermost frame.
+func (p *GasProfiler) CaptureStart(from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) error {
+	p.root = &ProfileFrame{Type: CALL.String(), Address: to}
+	if create {
+		p.root.Type = CREATE.String()
+	} else if len(input) >= 4 {
+		p.root.Selector = common.CopyBytes(input[:4])
+	}
+	p.stack = []*ProfileFrame{p.root}
+	return nil
+}
+
+// CaptureState implements the Tracer interface to account a single step of VM
+// execution to the frame at the given call depth.
+func (p *GasProfiler) CaptureState(env *EVM, pc uint64, op OpCode, gas, cost uint64, memory *Memory, stack *Stack, contract *Contract, depth int, err error) error {
+	if p.root == nil {
+		return nil
+	}
+	// Close any frames returned from and open the frame entered, if any
+	p.unwind(depth)
+	if depth > len(p.stack) {
+		parent := p.stack[len(p.stack)-1]
+
+		frame := &ProfileFrame{Type: CALL.String(), Address: contract.Address()}
+		if parent.pending != nil {
+			frame.Type = parent.pending.op.String()
+		}
+		if contract.CodeAddr != nil {
+			frame.Address = *contract.CodeAddr
+		}
+		if len(contract.Input) >= 4 {
+			frame.Selector = common.CopyBytes(contract.Input[:4])
+		}
+		parent.Calls = append(parent.Calls, frame)
+		p.stack = append(p.stack, frame)
+	}
+	// Settle the previous opcode of the frame and start tracking the current one
+	frame := p.stack[len(p.stack)-1]
+	if frame.pending != nil {
+		var used uint64
+		if frame.pending.gas > gas {
+			used = frame.pending.gas - gas
+		}
+		frame.settle(used)
+	}
+	frame.pending = &profileOp{op: op, gas: gas, cost: cost}
+
+	// If the opcode failed before running, the remaining gas is consumed
+	if err != nil {
+		p.fail(frame, gas, err)
+	}
+	return nil
+}
+
+// CaptureFault implements the Tracer interface to account an opcode failing
+// during execution.
+func (p *GasProfiler) CaptureFault(env *EVM, pc uint64, op OpCode, gas, cost uint64, memory *Memory, stack *Stack, contract *Contract, depth int, err error) error {
+	if p.root == nil || depth != len(p.stack) {
+		return nil
+	}
+	p.fail(p.stack[len(p.stack)-1], gas, err)
+	return nil
+}
+
+// fail settles the pending opcode of a frame whose execution was aborted. Apart
+// from reverts, all the gas left in the frame is consumed by the failure.
+func (p *GasProfiler) fail(frame *ProfileFrame, gas uint64, err error) {
+	if frame.pending == nil {
+		return
+	}
+	if err == errExecutionReverted {
+		frame.settle(frame.pending.cost)
+	} else {
+		frame.settle(gas)
+	}
+}
+
+// CaptureEnd is called after the call finishes to finalize the profile.
+func (p *GasProfiler) CaptureEnd(output []byte, gasUsed uint64, t time.Duration, err error) error {
+	p.unwind(0)
+	return nil
+}
+
+// unwind closes all the frames deeper than the given call depth, charging their
+// last opcodes with their reported cost and their totals to the callers.
+func (p *GasProfiler) unwind(depth int) {
+	for len(p.stack) > depth && len(p.stack) > 0 {
+		frame := p.stack[len(p.stack)-1]
+		if frame.pending != nil {
+			frame.settle(frame.pending.cost)
+		}
+		p.stack = p.stack[:len(p.stack)-1]
+
+		if len(p.stack) > 0 {
+			parent := p.stack[len(p.stack)-1]
+			if parent.pending != nil {
+				parent.pending.inner += frame.Total
+			}
+			parent.Total += frame.Total
+		}
+	}
+}
+
+// Profile returns the gas breakdown of the outermost call frame, or nil if no
+// transaction was traced.
+func (p *GasProfiler) Profile() *ProfileFrame {
+	return p.root
+}
+
+// WriteFolded writes the profile in Brendan Gregg's folded stack format, one
+// line per call path and opcode, suitable as input for flamegraph generators.
+func (p *GasProfiler) WriteFolded(w io.Writer) error {
+	if p.root == nil {
+		return nil
+	}
+	return writeFolded(w, "", p.root)
+}
+
+// writeFolded writes the folded stacks of a frame and its sub-calls.
+func writeFolded(w io.Writer, prefix string, frame *ProfileFrame) error {
+	path := prefix + frame.Label()
+
+	ops := make([]string, 0, len(frame.Ops))
+	for op := range frame.Ops {
+		ops = append(ops, op)
+	}
+	sort.Strings(ops)
+	for _, op := range ops {
+		if _, err := fmt.Fprintf(w, "%s;%s %d\n", path, op, frame.Ops[op]); err != nil {
+			return err
+		}
+	}
+	for _, call := range frame.Calls {
+		if err := writeFolded(w, path+";", call); err != nil {
+			return err
+		}
+	}
+	return nil
+}
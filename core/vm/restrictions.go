@@ -0,0 +1,63 @@
+// Copyright 2019 The go-gclchaineum Authors
+// This file is part of the go-gclchaineum library.
+//
+// The go-gclchaineum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-gclchaineum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-gclchaineum library. If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/gclchaineum/go-gclchaineum/common"
+	"github.com/gclchaineum/go-gclchaineum/params"
+)
+
+// ValidateOpcodeRestrictions checks that all the opcode restrictions of a chain
+// configuration name a known opcode and that no opcode is restricted twice.
+func ValidateOpcodeRestrictions(config *params.ChainConfig) error {
+	seen := make(map[OpCode]bool)
+	for _, r := range config.OpcodeRestrictions {
+		op, ok := stringToOp[r.Opcode]
+		if !ok {
+			return fmt.Errorf("unknown restricted opcode %q", r.Opcode)
+		}
+		if seen[op] {
+			return fmt.Errorf("duplicate restriction of opcode %v", op)
+		}
+		seen[op] = true
+	}
+	return nil
+}
+
+// activeRestrictions returns the allowlists of the opcodes restricted by the
+// chain configuration at the given block, or nil if there are none.
+func activeRestrictions(config *params.ChainConfig, num *big.Int) map[OpCode]map[common.Address]bool {
+	var restricted map[OpCode]map[common.Address]bool
+	for _, r := range config.OpcodeRestrictions {
+		op, ok := stringToOp[r.Opcode]
+		if !ok || !r.IsActive(num) {
+			continue
+		}
+		if restricted == nil {
+			restricted = make(map[OpCode]map[common.Address]bool)
+		}
+		allowed := make(map[common.Address]bool, len(r.Allowlist))
+		for _, addr := range r.Allowlist {
+			allowed[addr] = true
+		}
+		restricted[op] = allowed
+	}
+	return restricted
+}
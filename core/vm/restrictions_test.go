@@ -0,0 +1,95 @@
+// Copyright 2019 The go-gclchaineum Authors
+// This file is part of the go-gclchaineum library.
+//
+// The go-gclchaineum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-gclchaineum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-gclchaineum library. If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/gclchaineum/go-gclchaineum/common"
+	"github.com/gclchaineum/go-gclchaineum/core/state"
+	"github.com/gclchaineum/go-gclchaineum/gcldb"
+	"github.com/gclchaineum/go-gclchaineum/params"
+)
+
+// Tests that restricted opcodes fail from their activation block on, unless the
+// transaction origin or the executing contract is allowlisted.
+func TestOpcodeRestrictions(t *testing.T) {
+	var (
+		admin    = common.BytesToAddress([]byte("admin"))
+		user     = common.BytesToAddress([]byte("user"))
+		factory  = common.BytesToAddress([]byte("factory"))
+		contract = common.BytesToAddress([]byte("contract"))
+		config   = *params.AllEthashProtocolChanges
+	)
+	config.OpcodeRestrictions = []*params.OpcodeRestriction{
+		{Opcode: "SELFDESTRUCT", Block: big.NewInt(10), Allowlist: []common.Address{admin, factory}},
+	}
+	if err := ValidateOpcodeRestrictions(&config); err != nil {
+		t.Fatalf("failed to validate opcode restrictions: %v", err)
+	}
+	tests := []struct {
+		number     int64
+		origin     common.Address
+		address    common.Address
+		restricted bool
+	}{
+		{9, user, contract, false},
+		{10, user, contract, true},
+		{10, admin, contract, false},
+		{10, user, factory, false},
+	}
+	for i, tt := range tests {
+		statedb, _ := state.New(common.Hash{}, state.NewDatabase(gcldb.NewMemDatabase()))
+		statedb.SetCode(tt.address, []byte{byte(CALLER), byte(SELFDESTRUCT)})
+
+		vmctx := Context{
+			CanTransfer: func(StateDB, common.Address, *big.Int) bool { return true },
+			Transfer:    func(StateDB, common.Address, common.Address, *big.Int) {},
+			Origin:      tt.origin,
+			BlockNumber: big.NewInt(tt.number),
+		}
+		vmenv := NewEVM(vmctx, statedb, &config, Config{})
+
+		_, _, err := vmenv.Call(AccountRef(tt.origin), tt.address, nil, 100000, new(big.Int))
+		if restricted, ok := err.(*ErrRestrictedOpcode); ok != tt.restricted || (ok && restricted.Op != SELFDESTRUCT) {
+			t.Errorf("test %d: restriction mismatch: have %v, want restricted %v", i, err, tt.restricted)
+		}
+		if destructed := statedb.HasSuicided(tt.address); destructed == tt.restricted {
+			t.Errorf("test %d: self-destruct mismatch: have %v, want %v", i, destructed, !tt.restricted)
+		}
+	}
+}
+
+// Tests that invalid opcode restriction configurations are rejected.
+func TestValidateOpcodeRestrictions(t *testing.T) {
+	tests := []struct {
+		restrictions []*params.OpcodeRestriction
+		fail         bool
+	}{
+		{[]*params.OpcodeRestriction{{Opcode: "CREATE2"}, {Opcode: "SELFDESTRUCT"}}, false},
+		{[]*params.OpcodeRestriction{{Opcode: "SUICIDE"}}, true},
+		{[]*params.OpcodeRestriction{{Opcode: "CREATE2"}, {Opcode: "CREATE2"}}, true},
+	}
+	for i, tt := range tests {
+		config := *params.TestChainConfig
+		config.OpcodeRestrictions = tt.restrictions
+		if err := ValidateOpcodeRestrictions(&config); (err != nil) != tt.fail {
+			t.Errorf("test %d: validation failure mismatch: have %v, want failure %v", i, err, tt.fail)
+		}
+	}
+}
@@ -203,3 +203,67 @@ func BenchmarkEVM_CREATE2_1200(bench *testing.B) {
 	// initcode size 1200K, repeatedly calls CREATE2 and then modifies the mem contents
 	benchmarkEVM_Create(bench, "5b5862124f80600080f5600152600056")
 }
+
+// Tests that the gas profiler attributes all the gas used by a transaction to
+// the right call frames, including the gas burnt by failing sub-calls.
+func TestGasProfiler(t *testing.T) {
+	state, _ := state.New(common.Hash{}, state.NewDatabase(gcldb.NewMemDatabase()))
+
+	// Contract 0x0a calls 0x0b with the 0xdeadbeef selector, then 0x0c with a gas
+	// allowance of 0x1000, which is fully burnt by an invalid opcode.
+	state.SetCode(common.HexToAddress("0x0a"), []byte{
+		byte(vm.PUSH4), 0xde, 0xad, 0xbe, 0xef,
+		byte(vm.PUSH1), 0,
+		byte(vm.MSTORE),
+		byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.PUSH1), 4, byte(vm.PUSH1), 28, byte(vm.PUSH1), 0,
+		byte(vm.PUSH1), 0x0b,
+		byte(vm.GAS),
+		byte(vm.CALL),
+		byte(vm.POP),
+		byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.PUSH1), 0,
+		byte(vm.PUSH1), 0x0c,
+		byte(vm.PUSH2), 0x10, 0x00,
+		byte(vm.CALL),
+		byte(vm.POP),
+		byte(vm.STOP),
+	})
+	state.SetCode(common.HexToAddress("0x0b"), []byte{
+		byte(vm.PUSH1), 1,
+		byte(vm.PUSH1), 0,
+		byte(vm.SSTORE),
+		byte(vm.STOP),
+	})
+	state.SetCode(common.HexToAddress("0x0c"), []byte{0xfe})
+
+	profiler := vm.NewGasProfiler()
+	cfg := &Config{State: state, GasLimit: 1000000, EVMConfig: vm.Config{Debug: true, Tracer: profiler}}
+	if _, leftOverGas, err := Call(common.HexToAddress("0x0a"), nil, cfg); err != nil {
+		t.Fatalf("failed to execute call: %v", err)
+	} else if have, want := profiler.Profile().Total, cfg.GasLimit-leftOverGas; have != want {
+		t.Errorf("total gas mismatch: have %d, want %d", have, want)
+	}
+	root := profiler.Profile()
+	if len(root.Calls) != 2 {
+		t.Fatalf("sub-call count mismatch: have %d, want %d", len(root.Calls), 2)
+	}
+	if have, want := root.Calls[0].Selector.String(), "0xdeadbeef"; have != want {
+		t.Errorf("selector mismatch: have %s, want %s", have, want)
+	}
+	if have, want := root.Calls[0].Ops["SSTORE"], params.SstoreSetGas; have != want {
+		t.Errorf("SSTORE gas mismatch: have %d, want %d", have, want)
+	}
+	if have, want := root.Calls[1].Total, uint64(0x1000); have != want {
+		t.Errorf("failed call gas mismatch: have %d, want %d", have, want)
+	}
+	if root.Self+root.Calls[0].Total+root.Calls[1].Total != root.Total {
+		t.Errorf("frame gas doesn't add up: self %d, calls %d+%d, total %d", root.Self, root.Calls[0].Total, root.Calls[1].Total, root.Total)
+	}
+	var folded strings.Builder
+	if err := profiler.WriteFolded(&folded); err != nil {
+		t.Fatalf("failed to write folded stacks: %v", err)
+	}
+	want := "0x000000000000000000000000000000000000000a;0x000000000000000000000000000000000000000b:0xdeadbeef;SSTORE 20000\n"
+	if !strings.Contains(folded.String(), want) {
+		t.Errorf("folded stacks missing %q:\n%s", want, folded.String())
+	}
+}
@@ -22,23 +22,22 @@ import (
 	"github.com/gclchaineum/go-gclchaineum/params"
 )
 
-func makeStackFunc(pop, push int) stackValidationFunc {
-	return func(stack *Stack) error {
-		if err := stack.require(pop); err != nil {
-			return err
-		}
-
-		if stack.len()+push-pop > int(params.StackLimit) {
-			return fmt.Errorf("stack limit reached %d (%d)", stack.len(), params.StackLimit)
-		}
-		return nil
+// validateStack checks that the stack holds the items popped by the operation
+// and that the items pushed by it don't exceed the stack limit.
+func (op *operation) validateStack(stack *Stack) error {
+	if err := stack.require(op.pops); err != nil {
+		return err
 	}
+	if stack.len()+op.pushes-op.pops > int(params.StackLimit) {
+		return fmt.Errorf("stack limit reached %d (%d)", stack.len(), params.StackLimit)
+	}
+	return nil
 }
 
-func makeDupStackFunc(n int) stackValidationFunc {
-	return makeStackFunc(n, n+1)
-}
-
-func makeSwapStackFunc(n int) stackValidationFunc {
-	return makeStackFunc(n, n)
+// StackEffect returns the number of items the opcode pops off and pushes onto
+// the stack, as recorded in the newest instruction set. The last return value
+// is false if the opcode is undefined.
+func StackEffect(op OpCode) (pop, push int, ok bool) {
+	operation := istanbulInstructionSet[op]
+	return operation.pops, operation.pushes, operation.valid
 }
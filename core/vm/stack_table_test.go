@@ -0,0 +1,60 @@
+// Copyright 2019 The go-gclchaineum Authors
+// This file is part of the go-gclchaineum library.
+//
+// The go-gclchaineum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-gclchaineum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-gclchaineum library. If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/gclchaineum/go-gclchaineum/params"
+)
+
+// Tests that the reported stack effects of all opcodes agree with the stack
+// validation done by the interpreter.
+func TestStackEffect(t *testing.T) {
+	sized := func(n int) *Stack {
+		return &Stack{data: make([]*big.Int, n)}
+	}
+	for i := 0; i < 256; i++ {
+		op := OpCode(i)
+		pop, push, ok := StackEffect(op)
+
+		operation := istanbulInstructionSet[op]
+		if ok != operation.valid {
+			t.Errorf("%v: validity mismatch: have %v, want %v", op, ok, operation.valid)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if pop > 0 && operation.validateStack(sized(pop-1)) == nil {
+			t.Errorf("%v: accepted with %d items, reported pop %d", op, pop-1, pop)
+		}
+		if err := operation.validateStack(sized(pop)); err != nil {
+			t.Errorf("%v: rejected with %d items, reported pop %d: %v", op, pop, pop, err)
+		}
+		if growth := push - pop; growth > 0 {
+			limit := int(params.StackLimit) - growth
+			if err := operation.validateStack(sized(limit)); err != nil {
+				t.Errorf("%v: rejected with %d items, reported growth %d: %v", op, limit, growth, err)
+			}
+			if operation.validateStack(sized(limit+1)) == nil {
+				t.Errorf("%v: accepted with %d items, reported growth %d", op, limit+1, growth)
+			}
+		}
+	}
+}
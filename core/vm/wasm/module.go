@@ -0,0 +1,665 @@
+// Copyright 2019 The go-gclchaineum Authors
+// This file is part of the go-gclchaineum library.
+//
+// The go-gclchaineum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-gclchaineum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-gclchaineum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package wasm implements a WebAssembly interpreter for the deterministic,
+// integer only subset of the MVP specification used by eWASM contracts.
+package wasm
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Magic is the preamble of binary WebAssembly modules, including the version.
+var Magic = []byte{0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00}
+
+// ErrInvalidModule is returned when decoding a malformed or unsupported module.
+var ErrInvalidModule = errors.New("invalid wasm module")
+
+// ValueType is the type of a WebAssembly value.
+type ValueType byte
+
+const (
+	I32 ValueType = 0x7f
+	I64 ValueType = 0x7e
+)
+
+// Kinds of imported and exported definitions.
+const (
+	ExternalFunction byte = 0x00
+	ExternalTable    byte = 0x01
+	ExternalMemory   byte = 0x02
+	ExternalGlobal   byte = 0x03
+)
+
+// PageSize is the size of a linear memory page.
+const PageSize = 65536
+
+// maxTableSize is the maximum number of elements of a table.
+const maxTableSize = 1 << 16
+
+// FuncType is the signature of a function.
+type FuncType struct {
+	Params  []ValueType
+	Results []ValueType
+}
+
+// Equal reports whether two signatures are identical.
+func (t FuncType) Equal(other FuncType) bool {
+	return bytes.Equal(valueBytes(t.Params), valueBytes(other.Params)) && bytes.Equal(valueBytes(t.Results), valueBytes(other.Results))
+}
+
+func (t FuncType) String() string {
+	return fmt.Sprintf("%v -> %v", t.Params, t.Results)
+}
+
+func (t ValueType) String() string {
+	switch t {
+	case I32:
+		return "i32"
+	case I64:
+		return "i64"
+	}
+	return fmt.Sprintf("type(0x%x)", byte(t))
+}
+
+func valueBytes(types []ValueType) []byte {
+	b := make([]byte, len(types))
+	for i, t := range types {
+		b[i] = byte(t)
+	}
+	return b
+}
+
+// Import is a function imported from the host. Only function imports are
+// supported.
+type Import struct {
+	Module string
+	Name   string
+	Type   uint32 // Index into the type section
+}
+
+// Export is a definition exported by the module.
+type Export struct {
+	Kind  byte
+	Index uint32
+}
+
+// Limits are the initial and maximum size of a table or memory.
+type Limits struct {
+	Min    uint32
+	Max    uint32
+	HasMax bool
+}
+
+// Global is a global variable defined by the module.
+type Global struct {
+	Type    ValueType
+	Mutable bool
+	Init    uint64
+}
+
+// Segment initialises a range of the table or of the linear memory.
+type Segment struct {
+	Offset uint32
+	Funcs  []uint32 // Function indices of a table segment
+	Data   []byte   // Bytes of a memory segment
+}
+
+// Function is a function defined by the module.
+type Function struct {
+	Type   uint32
+	Locals []ValueType
+	code   []instr
+}
+
+// Module is a decoded WebAssembly module.
+type Module struct {
+	Types     []FuncType
+	Imports   []Import
+	Functions []Function
+	Table     *Limits
+	Memory    *Limits
+	Globals   []Global
+	Exports   map[string]Export
+	Start     *uint32
+	Elements  []Segment
+	Data      []Segment
+}
+
+// instr is a decoded instruction with its immediates.
+type instr struct {
+	op    byte
+	arity uint8     // Result count of block, loop and if
+	typ   ValueType // Result type of block, loop and if, zero if none
+	a     uint64    // First immediate, or the index of the matching end for blocks
+	b     uint32    // Second immediate, or the index of the else for if
+	table []uint32  // Labels of br_table, with the default as the last entry
+}
+
+// IsModule reports whether the code starts with the WebAssembly preamble.
+func IsModule(code []byte) bool {
+	return bytes.HasPrefix(code, Magic)
+}
+
+// Decode parses and validates the structure of a binary module.
+func Decode(code []byte) (*Module, error) {
+	if !IsModule(code) {
+		return nil, fmt.Errorf("%v: missing preamble", ErrInvalidModule)
+	}
+	var (
+		r       = &reader{buf: code, pos: len(Magic)}
+		m       = &Module{Exports: make(map[string]Export)}
+		funcs   []uint32
+		last    byte
+		decoded bool
+	)
+	for r.pos < len(r.buf) {
+		id := r.byte()
+		size := r.u32()
+		if r.err != nil {
+			break
+		}
+		if int(size) > len(r.buf)-r.pos {
+			return nil, fmt.Errorf("%v: section %d exceeds the module", ErrInvalidModule, id)
+		}
+		section := &reader{buf: r.buf[:r.pos+int(size)], pos: r.pos}
+		r.pos += int(size)
+
+		if id != 0 {
+			if id <= last {
+				return nil, fmt.Errorf("%v: section %d out of order", ErrInvalidModule, id)
+			}
+			last = id
+		}
+		switch id {
+		case 0:
+			continue // Custom sections carry no semantics
+		case 1:
+			decodeTypes(section, m)
+		case 2:
+			decodeImports(section, m)
+		case 3:
+			for n := section.u32(); n > 0 && section.err == nil; n-- {
+				funcs = append(funcs, section.u32())
+			}
+		case 4:
+			if n := section.u32(); n > 1 {
+				section.fail("multiple tables")
+			} else if n == 1 {
+				if section.byte() != 0x70 {
+					section.fail("unsupported table element type")
+				}
+				limits := section.limits()
+				m.Table = &limits
+			}
+		case 5:
+			if n := section.u32(); n > 1 {
+				section.fail("multiple memories")
+			} else if n == 1 {
+				limits := section.limits()
+				m.Memory = &limits
+			}
+		case 6:
+			for n := section.u32(); n > 0 && section.err == nil; n-- {
+				global := Global{Type: section.valueType(), Mutable: section.byte() == 1}
+				global.Init = section.constExpr(global.Type)
+				m.Globals = append(m.Globals, global)
+			}
+		case 7:
+			for n := section.u32(); n > 0 && section.err == nil; n-- {
+				name := section.name()
+				export := Export{Kind: section.byte(), Index: section.u32()}
+				if _, ok := m.Exports[name]; ok {
+					section.fail("duplicate export " + name)
+				}
+				m.Exports[name] = export
+			}
+		case 8:
+			start := section.u32()
+			m.Start = &start
+		case 9:
+			for n := section.u32(); n > 0 && section.err == nil; n-- {
+				if section.u32() != 0 {
+					section.fail("unknown table")
+				}
+				seg := Segment{Offset: uint32(section.constExpr(I32))}
+				for k := section.u32(); k > 0 && section.err == nil; k-- {
+					seg.Funcs = append(seg.Funcs, section.u32())
+				}
+				m.Elements = append(m.Elements, seg)
+			}
+		case 10:
+			n := section.u32()
+			if int(n) != len(funcs) {
+				section.fail("function and code section mismatch")
+			}
+			for i := 0; i < int(n) && section.err == nil; i++ {
+				m.Functions = append(m.Functions, decodeFunction(section, funcs[i]))
+			}
+			decoded = true
+		case 11:
+			for n := section.u32(); n > 0 && section.err == nil; n-- {
+				if section.u32() != 0 {
+					section.fail("unknown memory")
+				}
+				seg := Segment{Offset: uint32(section.constExpr(I32))}
+				seg.Data = section.bytes(int(section.u32()))
+				m.Data = append(m.Data, seg)
+			}
+		default:
+			section.fail(fmt.Sprintf("unknown section %d", id))
+		}
+		if section.err == nil && section.pos != len(section.buf) {
+			section.fail(fmt.Sprintf("trailing bytes in section %d", id))
+		}
+		if section.err != nil {
+			return nil, section.err
+		}
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	if len(funcs) > 0 && !decoded {
+		return nil, fmt.Errorf("%v: missing code section", ErrInvalidModule)
+	}
+	if err := m.validate(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// validate checks the cross references between the sections of the module.
+func (m *Module) validate() error {
+	funcs := uint64(len(m.Imports) + len(m.Functions))
+	for _, imp := range m.Imports {
+		if int(imp.Type) >= len(m.Types) {
+			return fmt.Errorf("%v: import %s.%s has unknown type", ErrInvalidModule, imp.Module, imp.Name)
+		}
+	}
+	for i, fn := range m.Functions {
+		if int(fn.Type) >= len(m.Types) {
+			return fmt.Errorf("%v: function %d has unknown type", ErrInvalidModule, i)
+		}
+		for _, in := range fn.code {
+			switch in.op {
+			case opCall:
+				if in.a >= funcs {
+					return fmt.Errorf("%v: function %d calls unknown function", ErrInvalidModule, i)
+				}
+			case opCallIndirect:
+				if in.a >= uint64(len(m.Types)) || m.Table == nil {
+					return fmt.Errorf("%v: function %d has invalid indirect call", ErrInvalidModule, i)
+				}
+			case opGlobalGet, opGlobalSet:
+				if in.a >= uint64(len(m.Globals)) || (in.op == opGlobalSet && !m.Globals[in.a].Mutable) {
+					return fmt.Errorf("%v: function %d accesses invalid global", ErrInvalidModule, i)
+				}
+			case opLocalGet, opLocalSet, opLocalTee:
+				if in.a >= uint64(len(m.Types[fn.Type].Params)+len(fn.Locals)) {
+					return fmt.Errorf("%v: function %d accesses unknown local", ErrInvalidModule, i)
+				}
+			case opMemorySize, opMemoryGrow:
+				if m.Memory == nil {
+					return fmt.Errorf("%v: function %d accesses missing memory", ErrInvalidModule, i)
+				}
+			default:
+				if in.op >= opI32Load && in.op <= opI64Store32 && m.Memory == nil {
+					return fmt.Errorf("%v: function %d accesses missing memory", ErrInvalidModule, i)
+				}
+			}
+		}
+		if err := m.validateFunction(&m.Functions[i]); err != nil {
+			return fmt.Errorf("%v: function %d: %v", ErrInvalidModule, i, err)
+		}
+	}
+	for name, export := range m.Exports {
+		var ok bool
+		switch export.Kind {
+		case ExternalFunction:
+			ok = uint64(export.Index) < funcs
+		case ExternalTable:
+			ok = m.Table != nil && export.Index == 0
+		case ExternalMemory:
+			ok = m.Memory != nil && export.Index == 0
+		case ExternalGlobal:
+			ok = int(export.Index) < len(m.Globals)
+		}
+		if !ok {
+			return fmt.Errorf("%v: invalid export %s", ErrInvalidModule, name)
+		}
+	}
+	if m.Start != nil {
+		if uint64(*m.Start) >= funcs {
+			return fmt.Errorf("%v: unknown start function", ErrInvalidModule)
+		}
+		if typ := m.FuncType(*m.Start); len(typ.Params) > 0 || len(typ.Results) > 0 {
+			return fmt.Errorf("%v: start function has parameters or results", ErrInvalidModule)
+		}
+	}
+	if m.Table != nil && m.Table.Min > maxTableSize {
+		return fmt.Errorf("%v: table of %d elements exceeds the limit", ErrInvalidModule, m.Table.Min)
+	}
+	for _, seg := range m.Elements {
+		if m.Table == nil {
+			return fmt.Errorf("%v: element segment without table", ErrInvalidModule)
+		}
+		for _, idx := range seg.Funcs {
+			if uint64(idx) >= funcs {
+				return fmt.Errorf("%v: element segment references unknown function", ErrInvalidModule)
+			}
+		}
+	}
+	if len(m.Data) > 0 && m.Memory == nil {
+		return fmt.Errorf("%v: data segment without memory", ErrInvalidModule)
+	}
+	return nil
+}
+
+// FuncType returns the signature of the function with the given index, which
+// counts the imported functions first.
+func (m *Module) FuncType(idx uint32) FuncType {
+	if int(idx) < len(m.Imports) {
+		return m.Types[m.Imports[idx].Type]
+	}
+	return m.Types[m.Functions[int(idx)-len(m.Imports)].Type]
+}
+
+func decodeTypes(r *reader, m *Module) {
+	for n := r.u32(); n > 0 && r.err == nil; n-- {
+		if r.byte() != 0x60 {
+			r.fail("invalid function type")
+			return
+		}
+		var t FuncType
+		for k := r.u32(); k > 0 && r.err == nil; k-- {
+			t.Params = append(t.Params, r.valueType())
+		}
+		for k := r.u32(); k > 0 && r.err == nil; k-- {
+			t.Results = append(t.Results, r.valueType())
+		}
+		if len(t.Results) > 1 {
+			r.fail("multiple results")
+		}
+		m.Types = append(m.Types, t)
+	}
+}
+
+func decodeImports(r *reader, m *Module) {
+	for n := r.u32(); n > 0 && r.err == nil; n-- {
+		imp := Import{Module: r.name(), Name: r.name()}
+		if kind := r.byte(); kind != ExternalFunction {
+			r.fail(fmt.Sprintf("unsupported import %s.%s", imp.Module, imp.Name))
+			return
+		}
+		imp.Type = r.u32()
+		m.Imports = append(m.Imports, imp)
+	}
+}
+
+func decodeFunction(r *reader, typ uint32) Function {
+	size := r.u32()
+	if r.err != nil || int(size) > len(r.buf)-r.pos {
+		r.fail("function body exceeds the section")
+		return Function{}
+	}
+	body := &reader{buf: r.buf[:r.pos+int(size)], pos: r.pos}
+	r.pos += int(size)
+
+	fn := Function{Type: typ}
+	for n := body.u32(); n > 0 && body.err == nil; n-- {
+		count, vt := body.u32(), body.valueType()
+		if uint64(len(fn.Locals))+uint64(count) > math.MaxUint16 {
+			body.fail("too many locals")
+			break
+		}
+		for i := uint32(0); i < count; i++ {
+			fn.Locals = append(fn.Locals, vt)
+		}
+	}
+	fn.code = decodeCode(body)
+	if body.err != nil {
+		r.err = body.err
+	}
+	return fn
+}
+
+// decodeCode decodes the instructions of a function body, resolving the
+// matching else and end of every block.
+func decodeCode(r *reader) []instr {
+	var (
+		code  []instr
+		open  []int
+		ended bool
+	)
+	for r.pos < len(r.buf) && r.err == nil {
+		if ended {
+			r.fail("instructions after function end")
+			break
+		}
+		in := instr{op: r.byte()}
+		switch in.op {
+		case opBlock, opLoop, opIf:
+			switch bt := r.byte(); bt {
+			case 0x40:
+			case byte(I32), byte(I64):
+				in.arity, in.typ = 1, ValueType(bt)
+			default:
+				r.fail("unsupported block type")
+			}
+			open = append(open, len(code))
+		case opElse:
+			if len(open) == 0 || code[open[len(open)-1]].op != opIf || code[open[len(open)-1]].b != 0 {
+				r.fail("unexpected else")
+				break
+			}
+			code[open[len(open)-1]].b = uint32(len(code))
+		case opEnd:
+			if len(open) == 0 {
+				ended = true
+				break
+			}
+			start := open[len(open)-1]
+			open = open[:len(open)-1]
+			code[start].a = uint64(len(code))
+			if code[start].op == opIf && code[start].b != 0 {
+				code[code[start].b].a = uint64(len(code))
+			}
+		case opBr, opBrIf, opCall, opLocalGet, opLocalSet, opLocalTee, opGlobalGet, opGlobalSet:
+			in.a = uint64(r.u32())
+		case opBrTable:
+			n := r.u32()
+			if uint64(n) > uint64(len(r.buf)-r.pos) {
+				r.fail("branch table exceeds the function")
+				break
+			}
+			for i := uint32(0); i <= n && r.err == nil; i++ {
+				in.table = append(in.table, r.u32())
+			}
+		case opCallIndirect:
+			in.a = uint64(r.u32())
+			if r.byte() != 0 {
+				r.fail("unknown table")
+			}
+		case opMemorySize, opMemoryGrow:
+			if r.byte() != 0 {
+				r.fail("unknown memory")
+			}
+		case opI32Const:
+			in.a = uint64(uint32(r.s32()))
+		case opI64Const:
+			in.a = uint64(r.s64())
+		case opUnreachable, opNop, opReturn, opDrop, opSelect:
+		default:
+			switch {
+			case in.op >= opI32Load && in.op <= opI64Store32:
+				if isFloatMemoryOp(in.op) {
+					r.fail(fmt.Sprintf("unsupported instruction 0x%x", in.op))
+					break
+				}
+				r.u32() // Alignment hint, irrelevant for the interpreter
+				in.a = uint64(r.u32())
+			case isIntegerOp(in.op):
+			default:
+				r.fail(fmt.Sprintf("unsupported instruction 0x%x", in.op))
+			}
+		}
+		code = append(code, in)
+	}
+	if r.err == nil && (!ended || len(open) > 0) {
+		r.fail("missing function end")
+	}
+	return code
+}
+
+// reader decodes the primitive values of the binary format, remembering the
+// first error encountered.
+type reader struct {
+	buf []byte
+	pos int
+	err error
+}
+
+func (r *reader) fail(reason string) {
+	if r.err == nil {
+		r.err = fmt.Errorf("%v: %s", ErrInvalidModule, reason)
+	}
+	r.pos = len(r.buf)
+}
+
+func (r *reader) byte() byte {
+	if r.pos >= len(r.buf) {
+		r.fail("unexpected end")
+		return 0
+	}
+	b := r.buf[r.pos]
+	r.pos++
+	return b
+}
+
+func (r *reader) bytes(n int) []byte {
+	if n < 0 || n > len(r.buf)-r.pos {
+		r.fail("unexpected end")
+		return nil
+	}
+	b := r.buf[r.pos : r.pos+n]
+	r.pos += n
+	return b
+}
+
+func (r *reader) name() string {
+	return string(r.bytes(int(r.u32())))
+}
+
+func (r *reader) u32() uint32 {
+	var (
+		result uint64
+		shift  uint
+	)
+	for i := 0; i < 5; i++ {
+		b := r.byte()
+		result |= uint64(b&0x7f) << shift
+		if b&0x80 == 0 {
+			if result > math.MaxUint32 {
+				r.fail("integer too large")
+			}
+			return uint32(result)
+		}
+		shift += 7
+	}
+	r.fail("integer representation too long")
+	return 0
+}
+
+func (r *reader) s32() int32 {
+	v := r.signed(5)
+	if v < math.MinInt32 || v > math.MaxInt32 {
+		r.fail("integer too large")
+	}
+	return int32(v)
+}
+
+func (r *reader) s64() int64 {
+	return r.signed(10)
+}
+
+func (r *reader) signed(max int) int64 {
+	var (
+		result int64
+		shift  uint
+	)
+	for i := 0; i < max; i++ {
+		b := r.byte()
+		if shift < 64 {
+			result |= int64(b&0x7f) << shift
+		}
+		shift += 7
+		if b&0x80 == 0 {
+			if shift < 64 && b&0x40 != 0 {
+				result |= -1 << shift
+			}
+			return result
+		}
+	}
+	r.fail("integer representation too long")
+	return 0
+}
+
+func (r *reader) valueType() ValueType {
+	switch t := ValueType(r.byte()); t {
+	case I32, I64:
+		return t
+	default:
+		r.fail(fmt.Sprintf("unsupported value type 0x%x", byte(t)))
+		return 0
+	}
+}
+
+func (r *reader) limits() Limits {
+	var limits Limits
+	switch r.byte() {
+	case 0:
+		limits.Min = r.u32()
+	case 1:
+		limits.Min, limits.Max, limits.HasMax = r.u32(), r.u32(), true
+		if limits.Max < limits.Min {
+			r.fail("limits maximum below minimum")
+		}
+	default:
+		r.fail("invalid limits")
+	}
+	return limits
+}
+
+// constExpr decodes a constant initialiser expression of the given type.
+// Imported globals are not supported, so only constants are accepted.
+func (r *reader) constExpr(t ValueType) uint64 {
+	var value uint64
+	switch op := r.byte(); {
+	case op == opI32Const && t == I32:
+		value = uint64(uint32(r.s32()))
+	case op == opI64Const && t == I64:
+		value = uint64(r.s64())
+	default:
+		r.fail("unsupported initialiser expression")
+	}
+	if r.byte() != opEnd {
+		r.fail("unterminated initialiser expression")
+	}
+	return value
+}
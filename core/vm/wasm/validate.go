@@ -0,0 +1,287 @@
+// Copyright 2019 The go-gclchaineum Authors
+// This file is part of the go-gclchaineum library.
+//
+// The go-gclchaineum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-gclchaineum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-gclchaineum library. If not, see <http://www.gnu.org/licenses/>.
+
+package wasm
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// unknown is the type of an operand popped from the polymorphic stack of
+// unreachable code, matching any expected type.
+const unknown ValueType = 0
+
+// frame is a structured control instruction being type checked.
+type frame struct {
+	op          byte
+	results     []ValueType // Values left on the stack by the block
+	height      int         // Height of the operand stack when the block was entered
+	unreachable bool        // Whether the rest of the block is unreachable
+}
+
+// labels returns the types of the values carried by branches to the frame.
+func (f *frame) labels() []ValueType {
+	if f.op == opLoop {
+		return nil
+	}
+	return f.results
+}
+
+// checker type checks the body of a function, following the validation
+// algorithm of the WebAssembly specification.
+type checker struct {
+	vals   []ValueType
+	frames []frame
+	err    error
+}
+
+func (c *checker) fail(format string, args ...interface{}) {
+	if c.err == nil {
+		c.err = fmt.Errorf(format, args...)
+	}
+}
+
+func (c *checker) push(types ...ValueType) {
+	c.vals = append(c.vals, types...)
+}
+
+func (c *checker) pop() ValueType {
+	f := &c.frames[len(c.frames)-1]
+	if len(c.vals) == f.height {
+		if !f.unreachable {
+			c.fail("operand stack underflow")
+		}
+		return unknown
+	}
+	t := c.vals[len(c.vals)-1]
+	c.vals = c.vals[:len(c.vals)-1]
+	return t
+}
+
+func (c *checker) popExpect(want ValueType) ValueType {
+	have := c.pop()
+	if have == unknown {
+		return want
+	}
+	if want != unknown && have != want {
+		c.fail("type mismatch: have %v, want %v", have, want)
+	}
+	return have
+}
+
+func (c *checker) popAll(types []ValueType) {
+	for i := len(types) - 1; i >= 0; i-- {
+		c.popExpect(types[i])
+	}
+}
+
+func (c *checker) enter(op byte, results []ValueType) {
+	c.frames = append(c.frames, frame{op: op, results: results, height: len(c.vals)})
+}
+
+func (c *checker) leave() frame {
+	f := c.frames[len(c.frames)-1]
+	c.popAll(f.results)
+	if len(c.vals) != f.height {
+		c.fail("block leaves %d extra values on the stack", len(c.vals)-f.height)
+	}
+	c.frames = c.frames[:len(c.frames)-1]
+	return f
+}
+
+func (c *checker) markUnreachable() {
+	f := &c.frames[len(c.frames)-1]
+	c.vals, f.unreachable = c.vals[:f.height], true
+}
+
+// label returns the frame targeted by a branch of the given depth.
+func (c *checker) label(depth uint64) *frame {
+	if depth >= uint64(len(c.frames)) {
+		c.fail("invalid branch depth %d", depth)
+		return nil
+	}
+	return &c.frames[len(c.frames)-1-int(depth)]
+}
+
+// validateFunction checks that every instruction of the function finds its
+// operands on the stack, that branches target an enclosing block with values
+// of the right types and that every block leaves exactly its results behind.
+func (m *Module) validateFunction(fn *Function) error {
+	var (
+		typ    = m.Types[fn.Type]
+		locals = append(append([]ValueType{}, typ.Params...), fn.Locals...)
+		c      = new(checker)
+	)
+	c.enter(opBlock, typ.Results)
+	for _, in := range fn.code {
+		if len(c.frames) == 0 {
+			return fmt.Errorf("instructions after function end")
+		}
+		switch in.op {
+		case opUnreachable:
+			c.markUnreachable()
+		case opNop:
+		case opBlock, opLoop:
+			c.enter(in.op, blockResults(in.typ))
+		case opIf:
+			c.popExpect(I32)
+			c.enter(opIf, blockResults(in.typ))
+		case opElse:
+			f := c.leave()
+			c.enter(opElse, f.results)
+		case opEnd:
+			f := c.leave()
+			if f.op == opIf && len(f.results) > 0 {
+				c.fail("if without else can't have results")
+			}
+			c.push(f.results...)
+		case opBr:
+			if f := c.label(in.a); f != nil {
+				c.popAll(f.labels())
+			}
+			c.markUnreachable()
+		case opBrIf:
+			c.popExpect(I32)
+			if f := c.label(in.a); f != nil {
+				c.popAll(f.labels())
+				c.push(f.labels()...)
+			}
+		case opBrTable:
+			c.popExpect(I32)
+			def := c.label(uint64(in.table[len(in.table)-1]))
+			if def == nil {
+				break
+			}
+			for _, depth := range in.table {
+				if f := c.label(uint64(depth)); f != nil && !bytes.Equal(valueBytes(f.labels()), valueBytes(def.labels())) {
+					c.fail("branch table targets of different types")
+				}
+			}
+			c.popAll(def.labels())
+			c.markUnreachable()
+		case opReturn:
+			c.popAll(c.frames[0].results)
+			c.markUnreachable()
+		case opCall:
+			sig := m.FuncType(uint32(in.a))
+			c.popAll(sig.Params)
+			c.push(sig.Results...)
+		case opCallIndirect:
+			c.popExpect(I32)
+			sig := m.Types[in.a]
+			c.popAll(sig.Params)
+			c.push(sig.Results...)
+		case opDrop:
+			c.pop()
+		case opSelect:
+			c.popExpect(I32)
+			t := c.pop()
+			c.push(c.popExpect(t))
+		case opLocalGet:
+			c.push(locals[in.a])
+		case opLocalSet:
+			c.popExpect(locals[in.a])
+		case opLocalTee:
+			c.push(c.popExpect(locals[in.a]))
+		case opGlobalGet:
+			c.push(m.Globals[in.a].Type)
+		case opGlobalSet:
+			c.popExpect(m.Globals[in.a].Type)
+		case opMemorySize:
+			c.push(I32)
+		case opMemoryGrow:
+			c.popExpect(I32)
+			c.push(I32)
+		case opI32Const:
+			c.push(I32)
+		case opI64Const:
+			c.push(I64)
+		default:
+			switch {
+			case in.op >= opI32Load && in.op <= opI64Load32U:
+				c.popExpect(I32)
+				c.push(loadType(in.op))
+			case in.op >= opI32Store && in.op <= opI64Store32:
+				c.popExpect(storeType(in.op))
+				c.popExpect(I32)
+			default:
+				params, result := numericType(in.op)
+				c.popAll(params)
+				c.push(result)
+			}
+		}
+		if c.err != nil {
+			return c.err
+		}
+	}
+	if len(c.frames) != 0 {
+		return fmt.Errorf("missing function end")
+	}
+	return nil
+}
+
+// blockResults returns the results of a block with the given result type.
+func blockResults(t ValueType) []ValueType {
+	if t == unknown {
+		return nil
+	}
+	return []ValueType{t}
+}
+
+// loadType returns the type of the value pushed by a load instruction.
+func loadType(op byte) ValueType {
+	switch op {
+	case opI32Load, opI32Load8S, opI32Load8U, opI32Load16S, opI32Load16U:
+		return I32
+	}
+	return I64
+}
+
+// storeType returns the type of the value popped by a store instruction.
+func storeType(op byte) ValueType {
+	switch op {
+	case opI32Store, opI32Store8, opI32Store16:
+		return I32
+	}
+	return I64
+}
+
+// numericType returns the operand and result types of an integer instruction.
+func numericType(op byte) ([]ValueType, ValueType) {
+	switch {
+	case op == opI32Eqz:
+		return []ValueType{I32}, I32
+	case op >= opI32Eq && op <= opI32GeU:
+		return []ValueType{I32, I32}, I32
+	case op == opI64Eqz:
+		return []ValueType{I64}, I32
+	case op >= opI64Eq && op <= opI64GeU:
+		return []ValueType{I64, I64}, I32
+	case op >= opI32Clz && op <= opI32Popcnt:
+		return []ValueType{I32}, I32
+	case op >= opI32Add && op <= opI32Rotr:
+		return []ValueType{I32, I32}, I32
+	case op >= opI64Clz && op <= opI64Popcnt:
+		return []ValueType{I64}, I64
+	case op >= opI64Add && op <= opI64Rotr:
+		return []ValueType{I64, I64}, I64
+	case op == opI32WrapI64:
+		return []ValueType{I64}, I32
+	default: // opI64ExtendI32S, opI64ExtendI32U
+		return []ValueType{I32}, I64
+	}
+}
@@ -0,0 +1,647 @@
+// Copyright 2019 The go-gclchaineum Authors
+// This file is part of the go-gclchaineum library.
+//
+// The go-gclchaineum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-gclchaineum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-gclchaineum library. If not, see <http://www.gnu.org/licenses/>.
+
+package wasm
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"math/bits"
+)
+
+const (
+	maxStackHeight      = 1 << 20 // Maximum number of values on the operand stack
+	defaultMaxCallDepth = 1024    // Maximum depth of nested function calls if not configured
+)
+
+var (
+	ErrUnreachable        = errors.New("wasm: unreachable executed")
+	ErrMemoryAccess       = errors.New("wasm: out of bounds memory access")
+	ErrDivideByZero       = errors.New("wasm: integer divide by zero")
+	ErrIntegerOverflow    = errors.New("wasm: integer overflow")
+	ErrCallStackExhausted = errors.New("wasm: call stack exhausted")
+	ErrStackOverflow      = errors.New("wasm: operand stack overflow")
+	ErrStackUnderflow     = errors.New("wasm: operand stack underflow")
+	ErrInvalidBranch      = errors.New("wasm: invalid branch depth")
+	ErrIndirectCall       = errors.New("wasm: invalid indirect call")
+	ErrOutOfGas           = errors.New("wasm: out of gas")
+)
+
+// HostFunc is a function provided by the host. It receives the arguments of
+// the call and returns its result, which is ignored for functions without
+// one. Returning an error aborts the execution with that error.
+type HostFunc func(args []uint64) (uint64, error)
+
+// Resolver resolves the functions imported by a module.
+type Resolver func(module, name string, typ FuncType) (HostFunc, error)
+
+// Config are the execution options of an instance.
+type Config struct {
+	Resolver     Resolver          // Resolves the imported functions
+	UseGas       func(uint64) bool // Charges gas, returning false if there is not enough (nil = unmetered)
+	MaxCallDepth int               // Maximum depth of nested function calls (0 = 1024)
+	MaxPages     uint32            // Maximum number of linear memory pages
+	PageGas      uint64            // Gas charged per linear memory page allocated by memory.grow
+}
+
+// trap wraps the error aborting an execution, unwinding the interpreter.
+type trap struct{ err error }
+
+// label is the branch target of a structured control instruction.
+type label struct {
+	arity  int  // Number of values carried by branches to the label
+	height int  // Height of the operand stack when the label was entered
+	cont   int  // Instruction to continue at after a branch
+	loop   bool // Whether branches re-enter the block
+}
+
+// Instance is an instantiated module with its memory, globals and table.
+type Instance struct {
+	module   *Module
+	cfg      Config
+	host     []HostFunc
+	memory   []byte
+	maxPages uint32
+	globals  []uint64
+	table    []int64 // Function indices, -1 for uninitialised elements
+
+	stack []uint64
+	floor int // Height of the operand stack below which the current block can't pop
+	depth int
+}
+
+// Instantiate resolves the imports of the module and initialises its state,
+// running the start function if there is one.
+func Instantiate(m *Module, cfg Config) (vm *Instance, err error) {
+	if cfg.MaxCallDepth == 0 {
+		cfg.MaxCallDepth = defaultMaxCallDepth
+	}
+	vm = &Instance{module: m, cfg: cfg}
+	for _, imp := range m.Imports {
+		if cfg.Resolver == nil {
+			return nil, fmt.Errorf("wasm: unresolved import %s.%s", imp.Module, imp.Name)
+		}
+		fn, err := cfg.Resolver(imp.Module, imp.Name, m.Types[imp.Type])
+		if err != nil {
+			return nil, err
+		}
+		vm.host = append(vm.host, fn)
+	}
+	if m.Memory != nil {
+		vm.maxPages = cfg.MaxPages
+		if m.Memory.HasMax && m.Memory.Max < vm.maxPages {
+			vm.maxPages = m.Memory.Max
+		}
+		if m.Memory.Min > vm.maxPages {
+			return nil, fmt.Errorf("wasm: initial memory of %d pages exceeds the limit", m.Memory.Min)
+		}
+		vm.memory = make([]byte, int(m.Memory.Min)*PageSize)
+	}
+	for _, global := range m.Globals {
+		vm.globals = append(vm.globals, global.Init)
+	}
+	if m.Table != nil {
+		vm.table = make([]int64, m.Table.Min)
+		for i := range vm.table {
+			vm.table[i] = -1
+		}
+	}
+	for _, seg := range m.Elements {
+		if uint64(seg.Offset)+uint64(len(seg.Funcs)) > uint64(len(vm.table)) {
+			return nil, errors.New("wasm: element segment exceeds the table")
+		}
+		for i, idx := range seg.Funcs {
+			vm.table[int(seg.Offset)+i] = int64(idx)
+		}
+	}
+	for _, seg := range m.Data {
+		if uint64(seg.Offset)+uint64(len(seg.Data)) > uint64(len(vm.memory)) {
+			return nil, errors.New("wasm: data segment exceeds the memory")
+		}
+		copy(vm.memory[seg.Offset:], seg.Data)
+	}
+	if m.Start != nil {
+		defer vm.recover(&err)
+		vm.call(*m.Start)
+	}
+	return vm, nil
+}
+
+// Memory returns the linear memory of the instance. The slice is replaced
+// whenever the memory grows.
+func (vm *Instance) Memory() []byte {
+	return vm.memory
+}
+
+// Invoke calls the exported function with the given arguments and returns its
+// results. Errors returned by host functions are passed through unchanged.
+func (vm *Instance) Invoke(name string, args ...uint64) (results []uint64, err error) {
+	export, ok := vm.module.Exports[name]
+	if !ok || export.Kind != ExternalFunction {
+		return nil, fmt.Errorf("wasm: function %s not exported", name)
+	}
+	typ := vm.module.FuncType(export.Index)
+	if len(args) != len(typ.Params) {
+		return nil, fmt.Errorf("wasm: function %s expects %d arguments, have %d", name, len(typ.Params), len(args))
+	}
+	defer vm.recover(&err)
+
+	vm.stack, vm.floor = append(vm.stack[:0], args...), 0
+	vm.call(export.Index)
+	return append([]uint64(nil), vm.stack...), nil
+}
+
+// recover converts the traps unwinding the interpreter into errors. Any other
+// panic is a bug, validation rejects the code that could cause one, and is
+// propagated.
+func (vm *Instance) recover(err *error) {
+	if r := recover(); r != nil {
+		t, ok := r.(trap)
+		if !ok {
+			panic(r)
+		}
+		*err = t.err
+		vm.stack, vm.floor, vm.depth = vm.stack[:0], 0, 0
+	}
+}
+
+func (vm *Instance) useGas(amount uint64) {
+	if vm.cfg.UseGas != nil && !vm.cfg.UseGas(amount) {
+		panic(trap{ErrOutOfGas})
+	}
+}
+
+func (vm *Instance) push(v uint64) {
+	if len(vm.stack) >= maxStackHeight {
+		panic(trap{ErrStackOverflow})
+	}
+	vm.stack = append(vm.stack, v)
+}
+
+func (vm *Instance) pop() uint64 {
+	n := len(vm.stack) - 1
+	if n < vm.floor {
+		panic(trap{ErrStackUnderflow})
+	}
+	v := vm.stack[n]
+	vm.stack = vm.stack[:n]
+	return v
+}
+
+// call calls the function with the given index, taking its arguments from the
+// operand stack and leaving its results on it.
+func (vm *Instance) call(idx uint32) {
+	if vm.depth >= vm.cfg.MaxCallDepth {
+		panic(trap{ErrCallStackExhausted})
+	}
+	var (
+		typ    = vm.module.FuncType(idx)
+		params = len(typ.Params)
+	)
+	if len(vm.stack)-params < vm.floor {
+		panic(trap{ErrStackUnderflow})
+	}
+	args := vm.stack[len(vm.stack)-params:]
+	vm.stack = vm.stack[:len(vm.stack)-params]
+
+	if int(idx) < len(vm.host) {
+		result, err := vm.host[idx](append([]uint64(nil), args...))
+		if err != nil {
+			panic(trap{err})
+		}
+		if len(typ.Results) > 0 {
+			vm.push(result)
+		}
+		return
+	}
+	fn := &vm.module.Functions[int(idx)-len(vm.host)]
+	vm.useGas(uint64(len(fn.Locals)))
+
+	locals := make([]uint64, params+len(fn.Locals))
+	copy(locals, args)
+
+	floor := vm.floor
+	vm.depth++
+	vm.execute(fn.code, locals, len(typ.Results))
+	vm.depth--
+	vm.floor = floor
+}
+
+// branch unwinds the operand and label stacks to the label at the given depth,
+// returning the instruction to continue at.
+func (vm *Instance) branch(labels *[]label, depth uint64) int {
+	if depth >= uint64(len(*labels)) {
+		panic(trap{ErrInvalidBranch})
+	}
+	var (
+		idx   = len(*labels) - 1 - int(depth)
+		l     = (*labels)[idx]
+		arity = l.arity
+	)
+	if l.loop {
+		arity = 0
+	}
+	if len(vm.stack)-arity < l.height {
+		panic(trap{ErrStackUnderflow})
+	}
+	copy(vm.stack[l.height:], vm.stack[len(vm.stack)-arity:])
+	vm.stack = vm.stack[:l.height+arity]
+
+	if l.loop {
+		*labels = (*labels)[:idx+1]
+	} else {
+		*labels = (*labels)[:idx]
+	}
+	if len(*labels) > 0 {
+		vm.floor = (*labels)[len(*labels)-1].height
+	}
+	return l.cont
+}
+
+// execute runs the body of a function, leaving its results on the stack.
+func (vm *Instance) execute(code []instr, locals []uint64, arity int) {
+	base := len(vm.stack)
+	labels := []label{{arity: arity, height: base, cont: len(code)}}
+	vm.floor = base
+
+	for pc := 0; pc < len(code); pc++ {
+		in := &code[pc]
+		vm.useGas(1)
+
+		switch in.op {
+		case opUnreachable:
+			panic(trap{ErrUnreachable})
+		case opNop:
+		case opBlock, opLoop:
+			l := label{arity: int(in.arity), height: len(vm.stack), cont: int(in.a) + 1}
+			if in.op == opLoop {
+				l.cont, l.loop = pc+1, true
+			}
+			labels = append(labels, l)
+			vm.floor = l.height
+			continue
+		case opIf:
+			cond := uint32(vm.pop())
+			switch {
+			case cond != 0:
+				labels = append(labels, label{arity: int(in.arity), height: len(vm.stack), cont: int(in.a) + 1})
+				vm.floor = len(vm.stack)
+			case in.b != 0:
+				labels = append(labels, label{arity: int(in.arity), height: len(vm.stack), cont: int(in.a) + 1})
+				vm.floor = len(vm.stack)
+				pc = int(in.b)
+			default:
+				pc = int(in.a)
+			}
+		case opElse:
+			// Reached the end of the taken branch, skip the other one
+			labels = labels[:len(labels)-1]
+			vm.floor = labels[len(labels)-1].height
+			pc = int(in.a)
+		case opEnd:
+			labels = labels[:len(labels)-1]
+			if len(labels) > 0 {
+				vm.floor = labels[len(labels)-1].height
+			}
+		case opBr:
+			pc = vm.branch(&labels, in.a) - 1
+		case opBrIf:
+			if uint32(vm.pop()) != 0 {
+				pc = vm.branch(&labels, in.a) - 1
+			}
+		case opBrTable:
+			i := uint64(uint32(vm.pop()))
+			if i >= uint64(len(in.table)-1) {
+				i = uint64(len(in.table) - 1)
+			}
+			pc = vm.branch(&labels, uint64(in.table[i])) - 1
+		case opReturn:
+			pc = vm.branch(&labels, uint64(len(labels)-1)) - 1
+		case opCall:
+			vm.call(uint32(in.a))
+		case opCallIndirect:
+			i := uint64(uint32(vm.pop()))
+			if i >= uint64(len(vm.table)) || vm.table[i] < 0 {
+				panic(trap{ErrIndirectCall})
+			}
+			if !vm.module.FuncType(uint32(vm.table[i])).Equal(vm.module.Types[in.a]) {
+				panic(trap{ErrIndirectCall})
+			}
+			vm.call(uint32(vm.table[i]))
+
+		case opDrop:
+			vm.pop()
+		case opSelect:
+			cond, b, a := uint32(vm.pop()), vm.pop(), vm.pop()
+			if cond != 0 {
+				vm.push(a)
+			} else {
+				vm.push(b)
+			}
+
+		case opLocalGet:
+			vm.push(locals[in.a])
+		case opLocalSet:
+			locals[in.a] = vm.pop()
+		case opLocalTee:
+			v := vm.pop()
+			locals[in.a] = v
+			vm.push(v)
+		case opGlobalGet:
+			vm.push(vm.globals[in.a])
+		case opGlobalSet:
+			vm.globals[in.a] = vm.pop()
+
+		case opMemorySize:
+			vm.push(uint64(len(vm.memory) / PageSize))
+		case opMemoryGrow:
+			var (
+				delta = uint64(uint32(vm.pop()))
+				pages = uint64(len(vm.memory) / PageSize)
+			)
+			if pages+delta > uint64(vm.maxPages) {
+				vm.push(uint64(math.MaxUint32))
+				break
+			}
+			vm.useGas(delta * vm.cfg.PageGas)
+			vm.memory = append(vm.memory, make([]byte, int(delta)*PageSize)...)
+			vm.push(pages)
+
+		case opI32Const, opI64Const:
+			vm.push(in.a)
+
+		default:
+			if in.op >= opI32Load && in.op <= opI64Store32 {
+				vm.memoryOp(in)
+			} else {
+				vm.numericOp(in.op)
+			}
+		}
+	}
+	// Move the results of the function to the base of its frame
+	if len(vm.stack)-arity < base {
+		panic(trap{ErrStackUnderflow})
+	}
+	copy(vm.stack[base:], vm.stack[len(vm.stack)-arity:])
+	vm.stack = vm.stack[:base+arity]
+}
+
+// access returns the memory range accessed by a load or store.
+func (vm *Instance) access(base uint64, offset uint64, size uint64) []byte {
+	addr := uint64(uint32(base)) + offset
+	if addr+size > uint64(len(vm.memory)) {
+		panic(trap{ErrMemoryAccess})
+	}
+	return vm.memory[addr : addr+size]
+}
+
+func (vm *Instance) memoryOp(in *instr) {
+	le := binary.LittleEndian
+	switch in.op {
+	case opI32Load:
+		vm.push(uint64(le.Uint32(vm.access(vm.pop(), in.a, 4))))
+	case opI64Load:
+		vm.push(le.Uint64(vm.access(vm.pop(), in.a, 8)))
+	case opI32Load8S:
+		vm.push(uint64(uint32(int32(int8(vm.access(vm.pop(), in.a, 1)[0])))))
+	case opI32Load8U, opI64Load8U:
+		vm.push(uint64(vm.access(vm.pop(), in.a, 1)[0]))
+	case opI32Load16S:
+		vm.push(uint64(uint32(int32(int16(le.Uint16(vm.access(vm.pop(), in.a, 2)))))))
+	case opI32Load16U, opI64Load16U:
+		vm.push(uint64(le.Uint16(vm.access(vm.pop(), in.a, 2))))
+	case opI64Load8S:
+		vm.push(uint64(int64(int8(vm.access(vm.pop(), in.a, 1)[0]))))
+	case opI64Load16S:
+		vm.push(uint64(int64(int16(le.Uint16(vm.access(vm.pop(), in.a, 2))))))
+	case opI64Load32S:
+		vm.push(uint64(int64(int32(le.Uint32(vm.access(vm.pop(), in.a, 4))))))
+	case opI64Load32U:
+		vm.push(uint64(le.Uint32(vm.access(vm.pop(), in.a, 4))))
+	case opI32Store, opI64Store32:
+		v := vm.pop()
+		le.PutUint32(vm.access(vm.pop(), in.a, 4), uint32(v))
+	case opI64Store:
+		v := vm.pop()
+		le.PutUint64(vm.access(vm.pop(), in.a, 8), v)
+	case opI32Store8, opI64Store8:
+		v := vm.pop()
+		vm.access(vm.pop(), in.a, 1)[0] = byte(v)
+	case opI32Store16, opI64Store16:
+		v := vm.pop()
+		le.PutUint16(vm.access(vm.pop(), in.a, 2), uint16(v))
+	}
+}
+
+func b2u(b bool) uint64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func (vm *Instance) numericOp(op byte) {
+	switch op {
+	case opI32Eqz:
+		vm.push(b2u(uint32(vm.pop()) == 0))
+	case opI64Eqz:
+		vm.push(b2u(vm.pop() == 0))
+	case opI32Clz:
+		vm.push(uint64(bits.LeadingZeros32(uint32(vm.pop()))))
+	case opI32Ctz:
+		vm.push(uint64(bits.TrailingZeros32(uint32(vm.pop()))))
+	case opI32Popcnt:
+		vm.push(uint64(bits.OnesCount32(uint32(vm.pop()))))
+	case opI64Clz:
+		vm.push(uint64(bits.LeadingZeros64(vm.pop())))
+	case opI64Ctz:
+		vm.push(uint64(bits.TrailingZeros64(vm.pop())))
+	case opI64Popcnt:
+		vm.push(uint64(bits.OnesCount64(vm.pop())))
+	case opI32WrapI64:
+		vm.push(uint64(uint32(vm.pop())))
+	case opI64ExtendI32S:
+		vm.push(uint64(int64(int32(vm.pop()))))
+	case opI64ExtendI32U:
+		vm.push(uint64(uint32(vm.pop())))
+	default:
+		b := vm.pop()
+		a := vm.pop()
+		if op <= opI64GeU || (op >= opI64Clz && op <= opI64Rotr) {
+			if op >= opI32Eq && op <= opI32GeU {
+				vm.push(compare32(op, uint32(a), uint32(b)))
+			} else if op >= opI64Eq && op <= opI64GeU {
+				vm.push(compare64(op, a, b))
+			} else {
+				vm.push(binary64(op, a, b))
+			}
+		} else {
+			vm.push(uint64(binary32(op, uint32(a), uint32(b))))
+		}
+	}
+}
+
+func compare32(op byte, a, b uint32) uint64 {
+	switch op {
+	case opI32Eq:
+		return b2u(a == b)
+	case opI32Ne:
+		return b2u(a != b)
+	case opI32LtS:
+		return b2u(int32(a) < int32(b))
+	case opI32LtU:
+		return b2u(a < b)
+	case opI32GtS:
+		return b2u(int32(a) > int32(b))
+	case opI32GtU:
+		return b2u(a > b)
+	case opI32LeS:
+		return b2u(int32(a) <= int32(b))
+	case opI32LeU:
+		return b2u(a <= b)
+	case opI32GeS:
+		return b2u(int32(a) >= int32(b))
+	default:
+		return b2u(a >= b)
+	}
+}
+
+func compare64(op byte, a, b uint64) uint64 {
+	switch op {
+	case opI64Eq:
+		return b2u(a == b)
+	case opI64Ne:
+		return b2u(a != b)
+	case opI64LtS:
+		return b2u(int64(a) < int64(b))
+	case opI64LtU:
+		return b2u(a < b)
+	case opI64GtS:
+		return b2u(int64(a) > int64(b))
+	case opI64GtU:
+		return b2u(a > b)
+	case opI64LeS:
+		return b2u(int64(a) <= int64(b))
+	case opI64LeU:
+		return b2u(a <= b)
+	case opI64GeS:
+		return b2u(int64(a) >= int64(b))
+	default:
+		return b2u(a >= b)
+	}
+}
+
+func binary32(op byte, a, b uint32) uint32 {
+	switch op {
+	case opI32Add:
+		return a + b
+	case opI32Sub:
+		return a - b
+	case opI32Mul:
+		return a * b
+	case opI32DivS:
+		if b == 0 {
+			panic(trap{ErrDivideByZero})
+		}
+		if int32(a) == math.MinInt32 && int32(b) == -1 {
+			panic(trap{ErrIntegerOverflow})
+		}
+		return uint32(int32(a) / int32(b))
+	case opI32DivU:
+		if b == 0 {
+			panic(trap{ErrDivideByZero})
+		}
+		return a / b
+	case opI32RemS:
+		if b == 0 {
+			panic(trap{ErrDivideByZero})
+		}
+		return uint32(int32(a) % int32(b))
+	case opI32RemU:
+		if b == 0 {
+			panic(trap{ErrDivideByZero})
+		}
+		return a % b
+	case opI32And:
+		return a & b
+	case opI32Or:
+		return a | b
+	case opI32Xor:
+		return a ^ b
+	case opI32Shl:
+		return a << (b & 31)
+	case opI32ShrS:
+		return uint32(int32(a) >> (b & 31))
+	case opI32ShrU:
+		return a >> (b & 31)
+	case opI32Rotl:
+		return bits.RotateLeft32(a, int(b&31))
+	default:
+		return bits.RotateLeft32(a, -int(b&31))
+	}
+}
+
+func binary64(op byte, a, b uint64) uint64 {
+	switch op {
+	case opI64Add:
+		return a + b
+	case opI64Sub:
+		return a - b
+	case opI64Mul:
+		return a * b
+	case opI64DivS:
+		if b == 0 {
+			panic(trap{ErrDivideByZero})
+		}
+		if int64(a) == math.MinInt64 && int64(b) == -1 {
+			panic(trap{ErrIntegerOverflow})
+		}
+		return uint64(int64(a) / int64(b))
+	case opI64DivU:
+		if b == 0 {
+			panic(trap{ErrDivideByZero})
+		}
+		return a / b
+	case opI64RemS:
+		if b == 0 {
+			panic(trap{ErrDivideByZero})
+		}
+		return uint64(int64(a) % int64(b))
+	case opI64RemU:
+		if b == 0 {
+			panic(trap{ErrDivideByZero})
+		}
+		return a % b
+	case opI64And:
+		return a & b
+	case opI64Or:
+		return a | b
+	case opI64Xor:
+		return a ^ b
+	case opI64Shl:
+		return a << (b & 63)
+	case opI64ShrS:
+		return uint64(int64(a) >> (b & 63))
+	case opI64ShrU:
+		return a >> (b & 63)
+	case opI64Rotl:
+		return bits.RotateLeft64(a, int(b&63))
+	default:
+		return bits.RotateLeft64(a, -int(b&63))
+	}
+}
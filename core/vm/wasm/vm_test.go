@@ -0,0 +1,260 @@
+// Copyright 2019 The go-gclchaineum Authors
+// This file is part of the go-gclchaineum library.
+//
+// The go-gclchaineum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-gclchaineum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-gclchaineum library. If not, see <http://www.gnu.org/licenses/>.
+
+package wasm
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func leb(v uint64) []byte {
+	var out []byte
+	for {
+		b := byte(v & 0x7f)
+		if v >>= 7; v != 0 {
+			out = append(out, b|0x80)
+			continue
+		}
+		return append(out, b)
+	}
+}
+
+func vec(items ...[]byte) []byte {
+	out := leb(uint64(len(items)))
+	for _, item := range items {
+		out = append(out, item...)
+	}
+	return out
+}
+
+func section(id byte, items ...[]byte) []byte {
+	content := vec(items...)
+	return append(append([]byte{id}, leb(uint64(len(content)))...), content...)
+}
+
+func str(s string) []byte {
+	return append(leb(uint64(len(s))), s...)
+}
+
+func functype(params, results []byte) []byte {
+	out := append([]byte{0x60}, leb(uint64(len(params)))...)
+	out = append(out, params...)
+	out = append(out, leb(uint64(len(results)))...)
+	return append(out, results...)
+}
+
+func body(locals []byte, code ...byte) []byte {
+	content := append(locals, code...)
+	return append(leb(uint64(len(content))), content...)
+}
+
+func export(name string, kind byte, idx uint64) []byte {
+	return append(append(str(name), kind), leb(idx)...)
+}
+
+func module(sections ...[]byte) []byte {
+	out := append([]byte{}, Magic...)
+	for _, s := range sections {
+		out = append(out, s...)
+	}
+	return out
+}
+
+// testModule exercises control flow, calls, memory, tables and globals.
+var testModule = module(
+	section(1,
+		functype([]byte{0x7e}, []byte{0x7e}),       // 0: (i64) -> i64
+		functype([]byte{0x7f, 0x7f}, []byte{0x7f}), // 1: (i32, i32) -> i32
+		functype(nil, []byte{0x7f}),                // 2: () -> i32
+	),
+	section(2, append(append(str("env"), str("triple")...), 0x00, 0x01)), // env.triple: type 1
+	section(3, []byte{0}, []byte{1}, []byte{1}, []byte{2}, []byte{2}, []byte{2}, []byte{2}, []byte{2}, []byte{2}, []byte{2}, []byte{2}),
+	section(4, []byte{0x70, 0x00, 0x01}),
+	section(5, []byte{0x00, 0x01}),
+	section(6, []byte{0x7f, 0x01, 0x41, 0x25, 0x0b}),
+	section(7,
+		export("fac", 0, 1), export("sum", 0, 2), export("switch", 0, 3),
+		export("mem", 0, 4), export("divzero", 0, 5), export("indirect", 0, 6),
+		export("badindirect", 0, 7), export("global", 0, 8), export("unreachable", 0, 9),
+		export("recurse", 0, 10), export("host", 0, 11), export("memory", 2, 0),
+	),
+	section(9, append([]byte{0x00, 0x41, 0x00, 0x0b}, vec([]byte{0x03})...)),
+	section(10,
+		// fac: if n == 0 { 1 } else { n * fac(n-1) }
+		body(vec(), 0x20, 0x00, 0x50, 0x04, 0x7e, 0x42, 0x01, 0x05, 0x20, 0x00, 0x20, 0x00, 0x42, 0x01, 0x7d, 0x10, 0x01, 0x7e, 0x0b, 0x0b),
+		// sum: sum of [a, b) using a loop
+		body(vec([]byte{0x01, 0x7f}), 0x02, 0x40, 0x03, 0x40, 0x20, 0x00, 0x20, 0x01, 0x4f, 0x0d, 0x01, 0x20, 0x02, 0x20, 0x00, 0x6a, 0x21, 0x02,
+			0x20, 0x00, 0x41, 0x01, 0x6a, 0x21, 0x00, 0x0c, 0x00, 0x0b, 0x0b, 0x20, 0x02, 0x0b),
+		// switch: br_table on the first parameter, 10, 20 or 30
+		body(vec(), 0x02, 0x40, 0x02, 0x40, 0x02, 0x40, 0x20, 0x00, 0x0e, 0x02, 0x00, 0x01, 0x02, 0x0b, 0x41, 0x0a, 0x0f, 0x0b, 0x41, 0x14, 0x0f, 0x0b, 0x41, 0x1e, 0x0b),
+		// mem: load8_s(store(-2)) + grow(1)*100 + size*1000
+		body(vec(), 0x41, 0x08, 0x42, 0x7e, 0x37, 0x03, 0x00, 0x41, 0x08, 0x2c, 0x00, 0x00, 0x41, 0x01, 0x40, 0x00, 0x41, 0xe4, 0x00, 0x6c, 0x6a,
+			0x3f, 0x00, 0x41, 0xe8, 0x07, 0x6c, 0x6a, 0x0b),
+		// divzero
+		body(vec(), 0x41, 0x01, 0x41, 0x00, 0x6d, 0x0b),
+		// indirect: table[0](1, 0), calling switch
+		body(vec(), 0x41, 0x01, 0x41, 0x00, 0x41, 0x00, 0x11, 0x01, 0x00, 0x0b),
+		// badindirect: table[0]() with the wrong signature
+		body(vec(), 0x41, 0x00, 0x11, 0x02, 0x00, 0x0b),
+		// global: global += 5
+		body(vec(), 0x23, 0x00, 0x41, 0x05, 0x6a, 0x24, 0x00, 0x23, 0x00, 0x0b),
+		// unreachable
+		body(vec(), 0x00, 0x0b),
+		// recurse: infinite recursion
+		body(vec(), 0x10, 0x0a, 0x0b),
+		// host: triple(7, 0)
+		body(vec(), 0x41, 0x07, 0x41, 0x00, 0x10, 0x00, 0x0b),
+	),
+)
+
+func instantiate(t *testing.T, cfg Config) *Instance {
+	m, err := Decode(testModule)
+	if err != nil {
+		t.Fatalf("failed to decode module: %v", err)
+	}
+	cfg.Resolver = func(module, name string, typ FuncType) (HostFunc, error) {
+		if module != "env" || name != "triple" {
+			return nil, errors.New("unknown import")
+		}
+		return func(args []uint64) (uint64, error) { return uint64(uint32(args[0] * 3)), nil }, nil
+	}
+	cfg.MaxPages = 4
+	vm, err := Instantiate(m, cfg)
+	if err != nil {
+		t.Fatalf("failed to instantiate module: %v", err)
+	}
+	return vm
+}
+
+func TestExecute(t *testing.T) {
+	vm := instantiate(t, Config{MaxCallDepth: 64})
+
+	tests := []struct {
+		name string
+		args []uint64
+		want []uint64
+		err  error
+	}{
+		{"fac", []uint64{20}, []uint64{2432902008176640000}, nil},
+		{"sum", []uint64{3, 10}, []uint64{42}, nil},
+		{"switch", []uint64{0, 0}, []uint64{10}, nil},
+		{"switch", []uint64{1, 0}, []uint64{20}, nil},
+		{"switch", []uint64{7, 0}, []uint64{30}, nil},
+		{"mem", nil, []uint64{2098}, nil},
+		{"divzero", nil, nil, ErrDivideByZero},
+		{"indirect", nil, []uint64{20}, nil},
+		{"badindirect", nil, nil, ErrIndirectCall},
+		{"global", nil, []uint64{42}, nil},
+		{"global", nil, []uint64{47}, nil},
+		{"unreachable", nil, nil, ErrUnreachable},
+		{"recurse", nil, nil, ErrCallStackExhausted},
+		{"host", nil, []uint64{21}, nil},
+	}
+	for i, tt := range tests {
+		have, err := vm.Invoke(tt.name, tt.args...)
+		if err != tt.err {
+			t.Errorf("test %d (%s): error mismatch: have %v, want %v", i, tt.name, err, tt.err)
+			continue
+		}
+		if err == nil && !reflect.DeepEqual(have, tt.want) {
+			t.Errorf("test %d (%s): result mismatch: have %v, want %v", i, tt.name, have, tt.want)
+		}
+	}
+	// The memory grew by one page in the mem test, the next growth must fail
+	// once the limit is reached
+	if pages := len(vm.Memory()) / PageSize; pages != 2 {
+		t.Errorf("memory size mismatch: have %d pages, want 2", pages)
+	}
+}
+
+func TestExecuteGas(t *testing.T) {
+	var used uint64
+	vm := instantiate(t, Config{UseGas: func(gas uint64) bool {
+		if used+gas > 100 {
+			return false
+		}
+		used += gas
+		return true
+	}})
+	if _, err := vm.Invoke("sum", 0, 3); err != nil {
+		t.Fatalf("failed to run with sufficient gas: %v", err)
+	}
+	if used == 0 {
+		t.Errorf("no gas used")
+	}
+	if _, err := vm.Invoke("sum", 0, 100); err != ErrOutOfGas {
+		t.Errorf("error mismatch: have %v, want %v", err, ErrOutOfGas)
+	}
+}
+
+func TestDecodeInvalid(t *testing.T) {
+	code := section(10, body(vec(), 0x43, 0x00, 0x00, 0x00, 0x00, 0x1a, 0x0b))
+	tests := map[string][]byte{
+		"magic":   {0x00, 0x61, 0x73, 0x6d, 0x02, 0x00, 0x00, 0x00},
+		"order":   module(section(3), section(1)),
+		"float":   module(section(1, functype(nil, nil)), section(3, []byte{0}), code),
+		"noend":   module(section(1, functype(nil, nil)), section(3, []byte{0}), section(10, body(vec(), 0x01))),
+		"nocode":  module(section(1, functype(nil, nil)), section(3, []byte{0})),
+		"badcall": module(section(1, functype(nil, nil)), section(3, []byte{0}), section(10, body(vec(), 0x10, 0x05, 0x0b))),
+		"import":  module(section(2, append(append(str("env"), str("mem")...), 0x02, 0x00, 0x01))),
+		"trailer": append(module(section(1, functype(nil, nil))), 0x01, 0x05),
+		"table":   module(section(4, append([]byte{0x70, 0x00}, leb(maxTableSize+1)...))),
+		"start":   module(section(1, functype([]byte{0x7f}, nil)), section(3, []byte{0}), []byte{0x08, 0x01, 0x00}, section(10, body(vec(), 0x0b))),
+
+		// Function bodies that don't type check
+		"underflow": module(section(1, functype(nil, nil)), section(3, []byte{0}), section(10, body(vec(), 0x1a, 0x0b))),
+		"mismatch":  module(section(1, functype(nil, nil)), section(3, []byte{0}), section(10, body(vec(), 0x42, 0x00, 0x45, 0x1a, 0x0b))),
+		"extra":     module(section(1, functype(nil, nil)), section(3, []byte{0}), section(10, body(vec(), 0x41, 0x01, 0x0b))),
+		"noresult":  module(section(1, functype(nil, []byte{0x7f})), section(3, []byte{0}), section(10, body(vec(), 0x0b))),
+		"depth":     module(section(1, functype(nil, nil)), section(3, []byte{0}), section(10, body(vec(), 0x0c, 0x01, 0x0b))),
+		"ifresult":  module(section(1, functype(nil, []byte{0x7f})), section(3, []byte{0}), section(10, body(vec(), 0x41, 0x01, 0x04, 0x7f, 0x41, 0x02, 0x0b, 0x0b))),
+		"brtable":   module(section(1, functype(nil, nil)), section(3, []byte{0}), section(10, body(vec(), 0x02, 0x7f, 0x03, 0x40, 0x41, 0x00, 0x0e, 0x01, 0x01, 0x00, 0x0b, 0x0b, 0x1a, 0x0b))),
+		"blocktype": module(section(1, functype(nil, nil)), section(3, []byte{0}), section(10, body(vec(), 0x02, 0x7e, 0x41, 0x00, 0x0b, 0x1a, 0x0b))),
+	}
+	for name, code := range tests {
+		if _, err := Decode(code); err == nil {
+			t.Errorf("%s: expected decoding error", name)
+		}
+	}
+	// Code following an unconditional branch is type checked against a
+	// polymorphic stack
+	valid := module(section(1, functype(nil, []byte{0x7f})), section(3, []byte{0}), section(10, body(vec(), 0x00, 0x6a, 0x0b)))
+	if _, err := Decode(valid); err != nil {
+		t.Errorf("failed to decode unreachable code: %v", err)
+	}
+}
+
+// Tests that panics other than traps are not swallowed by the interpreter.
+func TestPanicPropagation(t *testing.T) {
+	m, err := Decode(testModule)
+	if err != nil {
+		t.Fatalf("failed to decode module: %v", err)
+	}
+	vm, err := Instantiate(m, Config{Resolver: func(module, name string, typ FuncType) (HostFunc, error) {
+		return func(args []uint64) (uint64, error) { return args[len(args)], nil }, nil
+	}, MaxPages: 1})
+	if err != nil {
+		t.Fatalf("failed to instantiate module: %v", err)
+	}
+	defer func() {
+		if recover() == nil {
+			t.Errorf("host panic was swallowed")
+		}
+	}()
+	vm.Invoke("host")
+}
@@ -0,0 +1,51 @@
+// Copyright 2019 The go-gclchaineum Authors
+// This file is part of the go-gclchaineum library.
+//
+// The go-gclchaineum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-gclchaineum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-gclchaineum library. If not, see <http://www.gnu.org/licenses/>.
+
+package sm3
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+// Tests the SM3 implementation against the examples of GB/T 32905-2016, both
+// in one shot and when the data is fed in arbitrary chunks.
+func TestSum(t *testing.T) {
+	tests := []struct {
+		input, want string
+	}{
+		{"abc", "66c7f0f462eeedd9d1f2d46bdc10e4e24167c4875cf2f7a2297da02b8f4ba8e0"},
+		{strings.Repeat("abcd", 16), "debe9ff92275b8a138604889c18e5a4d6fdb70e5387e5765293dcba39c0c5732"},
+	}
+	for i, tt := range tests {
+		sum := Sum([]byte(tt.input))
+		if have := hex.EncodeToString(sum[:]); have != tt.want {
+			t.Errorf("test %d: checksum mismatch: have %s, want %s", i, have, tt.want)
+		}
+		h := New()
+		for j := 0; j < len(tt.input); j += 7 {
+			end := j + 7
+			if end > len(tt.input) {
+				end = len(tt.input)
+			}
+			h.Write([]byte(tt.input[j:end]))
+		}
+		if have := hex.EncodeToString(h.Sum(nil)); have != tt.want {
+			t.Errorf("test %d: streamed checksum mismatch: have %s, want %s", i, have, tt.want)
+		}
+	}
+}
@@ -33,6 +33,7 @@ import (
 	"github.com/gclchaineum/go-gclchaineum/core"
 	"github.com/gclchaineum/go-gclchaineum/core/rawdb"
 	"github.com/gclchaineum/go-gclchaineum/core/state"
+	"github.com/gclchaineum/go-gclchaineum/core/stateless"
 	"github.com/gclchaineum/go-gclchaineum/core/types"
 	"github.com/gclchaineum/go-gclchaineum/internal/gclapi"
 	"github.com/gclchaineum/go-gclchaineum/params"
@@ -248,6 +249,20 @@ func (api *PrivateAdminAPI) ImportChain(file string) (bool, error) {
 	return true, nil
 }
 
+// TxPolicy returns the configuration of the transaction pool admission policies.
+func (api *PrivateAdminAPI) TxPolicy() core.TxPolicyConfig {
+	return api.gcl.TxPool().TxPolicy()
+}
+
+// SetTxPolicy replaces the admission policies of the transaction pool without
+// restarting the node. Transactions already in the pool are not affected.
+func (api *PrivateAdminAPI) SetTxPolicy(config core.TxPolicyConfig) (bool, error) {
+	if err := api.gcl.TxPool().SetTxPolicy(config); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
 // PublicDebugAPI is the collection of Gclchain full node APIs exposed
 // over the public debugging endpoint.
 type PublicDebugAPI struct {
@@ -285,6 +300,46 @@ func (api *PublicDebugAPI) DumpBlock(blockNr rpc.BlockNumber) (state.Dump, error
 	return stateDb.RawDump(), nil
 }
 
+// AccountRangeMaxResults is the maximum number of accounts that will be returned
+// per call to debug_accountRange.
+const AccountRangeMaxResults = 256
+
+// AccountRange retrieves a page of the accounts in the state at a given block,
+// starting at the given hashed account key. The returned next key can be used
+// to continue with the subsequent page.
+func (api *PublicDebugAPI) AccountRange(blockNr rpc.BlockNumber, start hexutil.Bytes, maxResults int, nocode, nostorage bool) (state.IteratorDump, error) {
+	var stateDb *state.StateDB
+	if blockNr == rpc.PendingBlockNumber {
+		// If we're dumping the pending state, we need to request
+		// both the pending block as well as the pending state from
+		// the miner and operate on those
+		_, stateDb = api.gcl.miner.Pending()
+	} else {
+		var block *types.Block
+		if blockNr == rpc.LatestBlockNumber {
+			block = api.gcl.blockchain.CurrentBlock()
+		} else {
+			block = api.gcl.blockchain.GetBlockByNumber(uint64(blockNr))
+		}
+		if block == nil {
+			return state.IteratorDump{}, fmt.Errorf("block #%d not found", blockNr)
+		}
+		var err error
+		if stateDb, err = api.gcl.BlockChain().StateAt(block.Root()); err != nil {
+			return state.IteratorDump{}, err
+		}
+	}
+	if maxResults <= 0 || maxResults > AccountRangeMaxResults {
+		maxResults = AccountRangeMaxResults
+	}
+	return stateDb.IteratorDump(&state.DumpConfig{
+		SkipCode:    nocode,
+		SkipStorage: nostorage,
+		Start:       start,
+		Max:         maxResults,
+	})
+}
+
 // PrivateDebugAPI is the collection of Gclchain full node APIs exposed over
 // the private debugging endpoint.
 type PrivateDebugAPI struct {
@@ -336,6 +391,28 @@ func (api *PrivateDebugAPI) GetBadBlocks(ctx context.Context) ([]*BadBlockArgs,
 	return results, nil
 }
 
+// GetBlockWitness re-executes the block with the given hash on top of its parent
+// state and returns every trie node, contract code and ancestor header accessed,
+// which is enough to verify the block without access to the full state.
+func (api *PrivateDebugAPI) GetBlockWitness(ctx context.Context, hash common.Hash) (*stateless.Witness, error) {
+	block := api.gcl.blockchain.GetBlockByHash(hash)
+	if block == nil {
+		return nil, fmt.Errorf("block %#x not found", hash)
+	}
+	if block.NumberU64() == 0 {
+		return nil, errors.New("genesis is not executable")
+	}
+	parent := api.gcl.blockchain.GetBlock(block.ParentHash(), block.NumberU64()-1)
+	if parent == nil {
+		return nil, fmt.Errorf("parent %#x not found", block.ParentHash())
+	}
+	statedb, err := api.computeStateDB(parent, defaultTraceReexec)
+	if err != nil {
+		return nil, err
+	}
+	return stateless.Record(api.gcl.blockchain, statedb.Database(), block)
+}
+
 // StorageRangeResult is the result of a debug_storageRangeAt API call.
 type StorageRangeResult struct {
 	Storage storageMap   `json:"storage"`
@@ -385,6 +462,32 @@ func storageRangeAt(st state.Trie, start []byte, maxResult int) (StorageRangeRes
 	return result, nil
 }
 
+// StateDiff returns the changes a block made to the state: the previous and new
+// balance, nonce and code hash of every touched account, along with each of its
+// modified storage slots.
+func (api *PrivateDebugAPI) StateDiff(ctx context.Context, number rpc.BlockNumber) (state.DumpDiff, error) {
+	var block *types.Block
+	switch number {
+	case rpc.PendingBlockNumber:
+		return state.DumpDiff{}, errors.New("pending block state diff not supported")
+	case rpc.LatestBlockNumber:
+		block = api.gcl.blockchain.CurrentBlock()
+	default:
+		block = api.gcl.blockchain.GetBlockByNumber(uint64(number))
+	}
+	if block == nil {
+		return state.DumpDiff{}, fmt.Errorf("block #%d not found", number)
+	}
+	if block.NumberU64() == 0 {
+		return state.DumpDiff{}, errors.New("genesis block has no parent state")
+	}
+	parent := api.gcl.blockchain.GetHeader(block.ParentHash(), block.NumberU64()-1)
+	if parent == nil {
+		return state.DumpDiff{}, fmt.Errorf("parent %#x not found", block.ParentHash())
+	}
+	return state.DiffStates(api.gcl.blockchain.StateCache(), parent.Root, block.Root())
+}
+
 // GetModifiedAccountsByNumber returns all accounts that have changed between the
 // two blocks specified. A change is defined as a difference in nonce, balance,
 // code hash, or storage hash.
@@ -185,6 +185,10 @@ func (b *EthAPIBackend) TxPoolContent() (map[common.Address]types.Transactions,
 	return b.gcl.TxPool().Content()
 }
 
+func (b *EthAPIBackend) TxPoolContentFrom(addr common.Address) (types.Transactions, []core.TxQueuedInfo) {
+	return b.gcl.TxPool().ContentFrom(addr)
+}
+
 func (b *EthAPIBackend) SubscribeNewTxsEvent(ch chan<- core.NewTxsEvent) event.Subscription {
 	return b.gcl.TxPool().SubscribeNewTxsEvent(ch)
 }
@@ -20,6 +20,7 @@ import (
 	"bufio"
 	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io/ioutil"
@@ -29,6 +30,7 @@ import (
 	"time"
 
 	"github.com/gclchaineum/go-gclchaineum/common"
+	"github.com/gclchaineum/go-gclchaineum/common/compiler"
 	"github.com/gclchaineum/go-gclchaineum/common/hexutil"
 	"github.com/gclchaineum/go-gclchaineum/core"
 	"github.com/gclchaineum/go-gclchaineum/core/rawdb"
@@ -60,6 +62,14 @@ type TraceConfig struct {
 	Tracer  *string
 	Timeout *string
 	Reexec  *uint64
+	Sources *TraceSources
+}
+
+// TraceSources holds the compiler output and Solidity sources used to annotate
+// the struct logs with their source positions.
+type TraceSources struct {
+	CombinedJSON json.RawMessage   `json:"combinedJson"` // Output of solc --combined-json srcmap-runtime,bin-runtime
+	Files        map[string]string `json:"files"`        // Source contents keyed by their name in the source list
 }
 
 // StdTraceConfig holds extra parameters to standard-json trace functions.
@@ -710,45 +720,114 @@ func (api *PrivateDebugAPI) TraceTransaction(ctx context.Context, hash common.Ha
 	return api.traceTx(ctx, msg, vmctx, statedb, config)
 }
 
+// TraceCall returns the structured logs created during the execution of EVM if
+// the given call was executed on top of the provided block, without sending a
+// transaction. The call is assembled the same way as for gcl_call.
+func (api *PrivateDebugAPI) TraceCall(ctx context.Context, args gclapi.CallArgs, blockNrOrHash rpc.BlockNumberOrHash, config *TraceConfig) (interface{}, error) {
+	// Fetch the block and state that we want to run the call on top of
+	var (
+		block   *types.Block
+		statedb *state.StateDB
+		err     error
+	)
+	if hash, ok := blockNrOrHash.Hash(); ok {
+		block = api.gcl.blockchain.GetBlockByHash(hash)
+	} else if number, ok := blockNrOrHash.Number(); ok {
+		switch number {
+		case rpc.PendingBlockNumber:
+			block, statedb = api.gcl.miner.Pending()
+		case rpc.LatestBlockNumber:
+			block = api.gcl.blockchain.CurrentBlock()
+		default:
+			block = api.gcl.blockchain.GetBlockByNumber(uint64(number))
+		}
+	}
+	if block == nil {
+		return nil, fmt.Errorf("block %v not found", blockNrOrHash)
+	}
+	if statedb == nil {
+		reexec := defaultTraceReexec
+		if config != nil && config.Reexec != nil {
+			reexec = *config.Reexec
+		}
+		if statedb, err = api.computeStateDB(block, reexec); err != nil {
+			return nil, err
+		}
+	}
+	// Bound the gas of the call by the block gas limit like that of transactions,
+	// instead of the practically unlimited default
+	if gas := uint64(args.Gas); gas == 0 || gas > block.GasLimit() {
+		args.Gas = hexutil.Uint64(block.GasLimit())
+	}
+	// Assemble the call message and its EVM context, then trace it
+	msg := args.ToMessage(api.gcl.AccountManager())
+	vmctx := core.NewEVMContext(msg, block.Header(), api.gcl.blockchain, nil)
+
+	return api.traceTx(ctx, msg, vmctx, statedb, config)
+}
+
 // traceTx configures a new tracer according to the provided configuration, and
 // executes the given message in the provided environment. The return value will
 // be tracer dependent.
 func (api *PrivateDebugAPI) traceTx(ctx context.Context, message core.Message, vmctx vm.Context, statedb *state.StateDB, config *TraceConfig) (interface{}, error) {
-	// Assemble the structured logger or the JavaScript tracer
+	// Assemble the structured logger or the JavaScript/native tracer
 	var (
 		tracer vm.Tracer
 		err    error
 	)
+	// Define a meaningful timeout of a single transaction trace
+	timeout := defaultTraceTimeout
+	if config != nil && config.Timeout != nil {
+		if timeout, err = time.ParseDuration(*config.Timeout); err != nil {
+			return nil, err
+		}
+	}
+	deadlineCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
 	switch {
+	case config != nil && config.Tracer != nil && config.Sources != nil:
+		return nil, errors.New("source annotations are not supported by custom tracers")
+
 	case config != nil && config.Tracer != nil:
-		// Define a meaningful timeout of a single transaction trace
-		timeout := defaultTraceTimeout
-		if config.Timeout != nil {
-			if timeout, err = time.ParseDuration(*config.Timeout); err != nil {
-				return nil, err
-			}
-		}
-		// Constuct the JavaScript tracer to execute with
-		if tracer, err = tracers.New(*config.Tracer); err != nil {
+		// Constuct the native or JavaScript tracer to execute with
+		var txTracer tracers.TxTracer
+		if txTracer, err = tracers.NewTracer(*config.Tracer); err != nil {
 			return nil, err
 		}
+		tracer = txTracer
 		// Handle timeouts and RPC cancellations
-		deadlineCtx, cancel := context.WithTimeout(ctx, timeout)
 		go func() {
 			<-deadlineCtx.Done()
-			tracer.(*tracers.Tracer).Stop(errors.New("execution timeout"))
+			txTracer.Stop(errors.New("execution timeout"))
 		}()
-		defer cancel()
 
 	case config == nil:
 		tracer = vm.NewStructLogger(nil)
 
+	case config.Sources != nil:
+		sources, err := compiler.ParseSourceMaps(config.Sources.CombinedJSON, config.Sources.Files)
+		if err != nil {
+			return nil, fmt.Errorf("invalid source maps: %v", err)
+		}
+		var logConfig vm.LogConfig
+		if config.LogConfig != nil {
+			logConfig = *config.LogConfig
+		}
+		logConfig.Sources = sources
+		tracer = vm.NewStructLogger(&logConfig)
+
 	default:
 		tracer = vm.NewStructLogger(config.LogConfig)
 	}
 	// Run the transaction with tracing enabled.
 	vmenv := vm.NewEVM(vmctx, statedb, api.config, vm.Config{Debug: true, Tracer: tracer})
 
+	// Abort the execution on timeouts and RPC cancellations, whatever the tracer
+	go func() {
+		<-deadlineCtx.Done()
+		vmenv.Cancel()
+	}()
 	ret, gas, failed, err := core.ApplyMessage(vmenv, message, new(core.GasPool).AddGas(message.Gas()))
 	if err != nil {
 		return nil, fmt.Errorf("tracing failed: %v", err)
@@ -756,6 +835,10 @@ func (api *PrivateDebugAPI) traceTx(ctx context.Context, message core.Message, v
 	// Depending on the tracer type, format and return the output
 	switch tracer := tracer.(type) {
 	case *vm.StructLogger:
+		// If the timer caused an abort, the trace is incomplete
+		if vmenv.Cancelled() {
+			return nil, fmt.Errorf("execution aborted (timeout = %v)", timeout)
+		}
 		return &gclapi.ExecutionResult{
 			Gas:         gas,
 			Failed:      failed,
@@ -763,8 +846,14 @@ func (api *PrivateDebugAPI) traceTx(ctx context.Context, message core.Message, v
 			StructLogs:  gclapi.FormatLogs(tracer.StructLogs()),
 		}, nil
 
-	case *tracers.Tracer:
-		return tracer.GetResult()
+	case tracers.TxTracer:
+		// Always finalize the tracer to release its resources, but don't let an
+		// aborted execution pass for a complete trace
+		result, err := tracer.GetResult()
+		if err == nil && vmenv.Cancelled() {
+			return nil, fmt.Errorf("execution aborted (timeout = %v)", timeout)
+		}
+		return result, err
 
 	default:
 		panic(fmt.Sprintf("bad tracer type %T", tracer))
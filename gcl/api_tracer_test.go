@@ -0,0 +1,233 @@
+// Copyright 2019 The go-gclchaineum Authors
+// This file is part of the go-gclchaineum library.
+//
+// The go-gclchaineum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-gclchaineum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-gclchaineum library. If not, see <http://www.gnu.org/licenses/>.
+
+package gcl
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gclchaineum/go-gclchaineum/accounts"
+	"github.com/gclchaineum/go-gclchaineum/common"
+	"github.com/gclchaineum/go-gclchaineum/common/hexutil"
+	"github.com/gclchaineum/go-gclchaineum/consensus/gclash"
+	"github.com/gclchaineum/go-gclchaineum/core"
+	"github.com/gclchaineum/go-gclchaineum/core/types"
+	"github.com/gclchaineum/go-gclchaineum/core/vm"
+	"github.com/gclchaineum/go-gclchaineum/event"
+	"github.com/gclchaineum/go-gclchaineum/gcldb"
+	"github.com/gclchaineum/go-gclchaineum/internal/gclapi"
+	"github.com/gclchaineum/go-gclchaineum/miner"
+	"github.com/gclchaineum/go-gclchaineum/params"
+	"github.com/gclchaineum/go-gclchaineum/rpc"
+)
+
+// newTestTraceBackend creates a minimal Gclchain service for tracing, with a
+// chain of the given length in which every block sends 1 wei to the contract,
+// and a miner maintaining the pending block.
+func newTestTraceBackend(t *testing.T, blocks int, contract common.Address, code []byte) *Gclchain {
+	var (
+		engine = gclash.NewFaker()
+		db     = gcldb.NewMemDatabase()
+		gspec  = &core.Genesis{
+			Config: params.TestChainConfig,
+			Alloc: core.GenesisAlloc{
+				testBank: {Balance: big.NewInt(1000000000)},
+				contract: {Balance: new(big.Int), Code: code},
+			},
+		}
+		genesis = gspec.MustCommit(db)
+		signer  = types.HomesteadSigner{}
+	)
+	chain, _ := core.GenerateChain(gspec.Config, genesis, engine, db, blocks, func(i int, block *core.BlockGen) {
+		tx, _ := types.SignTx(types.NewTransaction(block.TxNonce(testBank), contract, big.NewInt(1), 100000, big.NewInt(1), nil), signer, testBankKey)
+		block.AddTx(tx)
+	})
+	blockchain, _ := core.NewBlockChain(db, nil, gspec.Config, engine, vm.Config{}, nil)
+	if _, err := blockchain.InsertChain(chain); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	txconfig := core.DefaultTxPoolConfig
+	txconfig.Journal = ""
+
+	gcl := &Gclchain{
+		chainConfig:    gspec.Config,
+		blockchain:     blockchain,
+		txPool:         core.NewTxPool(txconfig, gspec.Config, blockchain),
+		chainDb:        db,
+		eventMux:       new(event.TypeMux),
+		engine:         engine,
+		accountManager: accounts.NewManager(),
+	}
+	gcl.miner = miner.New(gcl, gspec.Config, gcl.eventMux, engine, time.Second, params.GenesisGasLimit, params.GenesisGasLimit, func(*types.Block) bool { return false })
+	return gcl
+}
+
+// Tests that calls can be traced on top of blocks selected by number, by hash
+// and on top of the pending block.
+func TestTraceCall(t *testing.T) {
+	// The contract returns its own balance, which grows by 1 wei with every block
+	var (
+		contract = common.HexToAddress("0xc0de")
+		code     = []byte{
+			byte(vm.ADDRESS), byte(vm.BALANCE), byte(vm.PUSH1), 0x00, byte(vm.MSTORE),
+			byte(vm.PUSH1), 0x20, byte(vm.PUSH1), 0x00, byte(vm.RETURN),
+		}
+		ops = []string{"ADDRESS", "BALANCE", "PUSH1", "MSTORE", "PUSH1", "PUSH1", "RETURN"}
+	)
+	gcl := newTestTraceBackend(t, 4, contract, code)
+	defer gcl.blockchain.Stop()
+	defer gcl.txPool.Stop()
+	defer gcl.miner.Close()
+
+	// Add a transaction to the pool to distinguish the pending state from the head
+	tx, _ := types.SignTx(types.NewTransaction(gcl.txPool.State().GetNonce(testBank), contract, big.NewInt(1), 100000, big.NewInt(1), nil), types.HomesteadSigner{}, testBankKey)
+	if err := gcl.txPool.AddLocal(tx); err != nil {
+		t.Fatalf("failed to add pending transaction: %v", err)
+	}
+	for start := time.Now(); ; time.Sleep(10 * time.Millisecond) {
+		if block := gcl.miner.PendingBlock(); block != nil && len(block.Transactions()) == 1 {
+			break
+		}
+		if time.Since(start) > 5*time.Second {
+			t.Fatalf("pending block not assembled")
+		}
+	}
+	api := NewPrivateDebugAPI(gcl.chainConfig, gcl)
+
+	var (
+		pending = rpc.PendingBlockNumber
+		latest  = rpc.LatestBlockNumber
+		second  = rpc.BlockNumber(2)
+		hash    = gcl.blockchain.GetBlockByNumber(1).Hash()
+	)
+	tests := []struct {
+		block   rpc.BlockNumberOrHash
+		balance int64
+	}{
+		{rpc.BlockNumberOrHash{BlockNumber: &second}, 2},
+		{rpc.BlockNumberOrHash{BlockHash: &hash}, 1},
+		{rpc.BlockNumberOrHash{BlockNumber: &latest}, 4},
+		{rpc.BlockNumberOrHash{BlockNumber: &pending}, 5},
+	}
+	for i, tt := range tests {
+		args := gclapi.CallArgs{
+			From:     testBank,
+			To:       &contract,
+			Gas:      hexutil.Uint64(100000),
+			GasPrice: hexutil.Big(*big.NewInt(1)),
+		}
+		res, err := api.TraceCall(context.Background(), args, tt.block, nil)
+		if err != nil {
+			t.Errorf("test %d (%v): failed to trace call: %v", i, tt.block, err)
+			continue
+		}
+		result, ok := res.(*gclapi.ExecutionResult)
+		if !ok {
+			t.Fatalf("test %d: result type mismatch: have %T", i, res)
+		}
+		if result.Failed {
+			t.Errorf("test %d: call failed", i)
+		}
+		if want := fmt.Sprintf("%064x", tt.balance); result.ReturnValue != want {
+			t.Errorf("test %d (%v): return value mismatch: have %s, want %s", i, tt.block, result.ReturnValue, want)
+		}
+		var have []string
+		for _, log := range result.StructLogs {
+			have = append(have, log.Op)
+		}
+		if !reflect.DeepEqual(have, ops) {
+			t.Errorf("test %d: struct log mismatch: have %v, want %v", i, have, ops)
+		}
+	}
+	// Unknown blocks must be reported
+	unknown := common.Hash{0x01}
+	if _, err := api.TraceCall(context.Background(), gclapi.CallArgs{From: testBank, To: &contract}, rpc.BlockNumberOrHash{BlockHash: &unknown}, nil); err == nil {
+		t.Errorf("expected error for unknown block")
+	}
+	// Custom tracers cannot annotate source positions
+	tracer := "callTracer"
+	config := &TraceConfig{Tracer: &tracer, Sources: &TraceSources{}}
+	if _, err := api.TraceCall(context.Background(), gclapi.CallArgs{From: testBank, To: &contract}, rpc.BlockNumberOrHash{BlockNumber: &latest}, config); err == nil || !strings.Contains(err.Error(), "source annotations") {
+		t.Errorf("tracer with sources error mismatch: have %v", err)
+	}
+}
+
+// Tests that traced calls are bounded by the block gas limit and aborted on
+// timeout, whatever the tracer.
+func TestTraceCallBounds(t *testing.T) {
+	// The contract returns the gas available to it
+	contract := common.HexToAddress("0xc0de")
+	gcl := newTestTraceBackend(t, 1, contract, []byte{
+		byte(vm.GAS), byte(vm.PUSH1), 0x00, byte(vm.MSTORE),
+		byte(vm.PUSH1), 0x20, byte(vm.PUSH1), 0x00, byte(vm.RETURN),
+	})
+	defer gcl.blockchain.Stop()
+	defer gcl.txPool.Stop()
+	defer gcl.miner.Close()
+
+	var (
+		api    = NewPrivateDebugAPI(gcl.chainConfig, gcl)
+		latest = rpc.BlockNumberOrHash{BlockNumber: new(rpc.BlockNumber)}
+		limit  = gcl.blockchain.CurrentBlock().GasLimit()
+	)
+	*latest.BlockNumber = rpc.LatestBlockNumber
+
+	for _, gas := range []hexutil.Uint64{0, hexutil.Uint64(limit + 1), 1 << 62} {
+		res, err := api.TraceCall(context.Background(), gclapi.CallArgs{From: testBank, To: &contract, Gas: gas, GasPrice: hexutil.Big(*big.NewInt(1))}, latest, nil)
+		if err != nil {
+			t.Fatalf("gas %d: failed to trace call: %v", gas, err)
+		}
+		ret, _ := new(big.Int).SetString(res.(*gclapi.ExecutionResult).ReturnValue, 16)
+		if ret == nil || ret.Uint64() >= limit {
+			t.Errorf("gas %d: available gas mismatch: have %v, want below %d", gas, ret, limit)
+		}
+	}
+	// Infinitely looping calls must be aborted by the struct logger too
+	loop := common.HexToAddress("0x1009")
+	gcl = newTestTraceBackend(t, 1, loop, []byte{byte(vm.JUMPDEST), byte(vm.PUSH1), 0x00, byte(vm.JUMP)})
+	defer gcl.blockchain.Stop()
+	defer gcl.txPool.Stop()
+	defer gcl.miner.Close()
+
+	api = NewPrivateDebugAPI(gcl.chainConfig, gcl)
+	for _, tracer := range []*string{nil, new(string)} {
+		timeout := "10ms"
+		config := &TraceConfig{Timeout: &timeout}
+		if tracer != nil {
+			*tracer = "callTracer"
+			config.Tracer = tracer
+		}
+		done := make(chan error, 1)
+		go func() {
+			_, err := api.TraceCall(context.Background(), gclapi.CallArgs{From: testBank, To: &loop, GasPrice: hexutil.Big(*big.NewInt(1))}, latest, config)
+			done <- err
+		}()
+		select {
+		case err := <-done:
+			if err == nil {
+				t.Errorf("tracer %v: expected error for aborted call", config.Tracer)
+			}
+		case <-time.After(10 * time.Second):
+			t.Fatalf("tracer %v: looping call not aborted", config.Tracer)
+		}
+	}
+}
@@ -34,6 +34,7 @@ import (
 	"github.com/gclchaineum/go-gclchaineum/core"
 	"github.com/gclchaineum/go-gclchaineum/core/bloombits"
 	"github.com/gclchaineum/go-gclchaineum/core/rawdb"
+	"github.com/gclchaineum/go-gclchaineum/core/state/pruner"
 	"github.com/gclchaineum/go-gclchaineum/core/types"
 	"github.com/gclchaineum/go-gclchaineum/core/vm"
 	"github.com/gclchaineum/go-gclchaineum/gcl/downloader"
@@ -118,6 +119,24 @@ func New(ctx *node.ServiceContext, config *Config) (*Gclchain, error) {
 	if err != nil {
 		return nil, err
 	}
+	// Attach the ancient store if one was requested for a persistent database
+	if config.DatabaseFreezer != "" {
+		if freezer := ctx.ResolvePath(config.DatabaseFreezer); freezer != "" {
+			frdb, err := rawdb.NewDatabaseWithFreezer(chainDb, freezer, "gcl/db/chaindata/")
+			if err != nil {
+				chainDb.Close()
+				return nil, err
+			}
+			chainDb = frdb
+		}
+	}
+	// Complete any interrupted offline state pruning before touching the state
+	if datadir := ctx.ResolvePath(""); datadir != "" {
+		if err := pruner.RecoverPruning(datadir, chainDb); err != nil {
+			chainDb.Close()
+			return nil, err
+		}
+	}
 	chainConfig, genesisHash, genesisErr := core.SetupGenesisBlockWithOverride(chainDb, config.Genesis, config.ConstantinopleOverride)
 	if _, ok := genesisErr.(*params.ConfigCompatError); genesisErr != nil && !ok {
 		return nil, genesisErr
@@ -155,8 +174,9 @@ func New(ctx *node.ServiceContext, config *Config) (*Gclchain, error) {
 			EnablePreimageRecording: config.EnablePreimageRecording,
 			EWASMInterpreter:        config.EWASMInterpreter,
 			EVMInterpreter:          config.EVMInterpreter,
+			ParallelExecution:       config.ParallelExecution,
 		}
-		cacheConfig = &core.CacheConfig{Disabled: config.NoPruning, TrieCleanLimit: config.TrieCleanCache, TrieDirtyLimit: config.TrieDirtyCache, TrieTimeLimit: config.TrieTimeout}
+		cacheConfig = &core.CacheConfig{Disabled: config.NoPruning, TrieCleanLimit: config.TrieCleanCache, TrieDirtyLimit: config.TrieDirtyCache, TrieTimeLimit: config.TrieTimeout, SnapshotLimit: config.SnapshotCache}
 	)
 	gcl.blockchain, err = core.NewBlockChain(chainDb, cacheConfig, gcl.chainConfig, gcl.engine, vmConfig, gcl.shouldPreserve)
 	if err != nil {
@@ -173,6 +193,9 @@ func New(ctx *node.ServiceContext, config *Config) (*Gclchain, error) {
 	if config.TxPool.Journal != "" {
 		config.TxPool.Journal = ctx.ResolvePath(config.TxPool.Journal)
 	}
+	if config.TxPool.Snapshot != "" {
+		config.TxPool.Snapshot = ctx.ResolvePath(config.TxPool.Snapshot)
+	}
 	gcl.txPool = core.NewTxPool(config.TxPool, gcl.chainConfig, gcl.blockchain)
 
 	if gcl.protocolManager, err = NewProtocolManager(gcl.chainConfig, config.SyncMode, config.NetworkId, gcl.eventMux, gcl.txPool, gcl.engine, gcl.blockchain, chainDb, config.Whitelist); err != nil {
@@ -98,9 +98,11 @@ type Config struct {
 	SkipBcVersionCheck bool `toml:"-"`
 	DatabaseHandles    int  `toml:"-"`
 	DatabaseCache      int
+	DatabaseFreezer    string
 	TrieCleanCache     int
 	TrieDirtyCache     int
 	TrieTimeout        time.Duration
+	SnapshotCache      int
 
 	// Mining-related options
 	Gclchainbase      common.Address `toml:",omitempty"`
@@ -133,6 +135,9 @@ type Config struct {
 	// Type of the EVM interpreter ("" for default)
 	EVMInterpreter string
 
+	// Enables speculative parallel transaction execution during block import
+	ParallelExecution bool
+
 	// Constantinople block override (TODO: remove after the fork)
 	ConstantinopleOverride *big.Int
 }
@@ -0,0 +1,98 @@
+// Copyright 2019 The go-gclchaineum Authors
+// This file is part of the go-gclchaineum library.
+//
+// The go-gclchaineum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-gclchaineum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-gclchaineum library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracers
+
+import (
+	"encoding/json"
+	"math"
+	"math/big"
+	"sync/atomic"
+
+	"github.com/gclchaineum/go-gclchaineum/core/vm"
+)
+
+// TxTracer is a vm.Tracer which accumulates a JSON result over the execution of
+// a single transaction and which can be interrupted. It is implemented by both
+// the JavaScript and the native Go tracers.
+type TxTracer interface {
+	vm.Tracer
+
+	// GetResult returns the JSON result of the trace, or any accumulated error.
+	GetResult() (json.RawMessage, error)
+
+	// Stop terminates execution of the tracer at the first opportune moment.
+	Stop(err error)
+}
+
+// natives contains all the built in native Go tracers by name. Those with a
+// JavaScript counterpart produce the same output, just a lot faster.
+var natives = map[string]func() TxTracer{
+	"callTracer":     func() TxTracer { return newCallTracer() },
+	"prestateTracer": func() TxTracer { return newPrestateTracer() },
+	"4byteTracer":    func() TxTracer { return newFourByteTracer() },
+	"gasProfiler":    func() TxTracer { return newGasProfiler() },
+}
+
+// NewTracer instantiates a tracer for a transaction. If code names one of the
+// native tracers, that is used, otherwise code is evaluated as a JavaScript
+// tracer (built in by name or a custom snippet).
+func NewTracer(code string) (TxTracer, error) {
+	if constructor, ok := natives[code]; ok {
+		return constructor(), nil
+	}
+	tracer, err := New(code)
+	if err != nil {
+		return nil, err
+	}
+	return tracer, nil
+}
+
+// interrupter is embedded into native tracers to implement the Stop method and
+// to surface the interruption reason in place of the trace result.
+type interrupter struct {
+	interrupt uint32 // Atomic flag to signal execution interruption
+	reason    error  // Textual reason for the interruption
+}
+
+// Stop terminates execution of the tracer at the first opportune moment.
+func (i *interrupter) Stop(err error) {
+	i.reason = err
+	atomic.StoreUint32(&i.interrupt, 1)
+}
+
+// interrupted returns whether the tracer was stopped.
+func (i *interrupter) interrupted() bool {
+	return atomic.LoadUint32(&i.interrupt) > 0
+}
+
+// saturate returns the value as an uint64, capping it at the maximum if it does
+// not fit.
+func saturate(v *big.Int) uint64 {
+	if !v.IsUint64() {
+		return math.MaxUint64
+	}
+	return v.Uint64()
+}
+
+// memorySlice returns a copy of the requested range of memory. Out of bound
+// requests result in an empty slice, same as for the JavaScript tracers.
+func memorySlice(memory *vm.Memory, offset, size uint64) []byte {
+	if size == 0 || offset+size < offset || offset+size > uint64(memory.Len()) {
+		return nil
+	}
+	return memory.Get(int64(offset), int64(size))
+}
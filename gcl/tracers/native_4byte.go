@@ -0,0 +1,108 @@
+// Copyright 2019 The go-gclchaineum Authors
+// This file is part of the go-gclchaineum library.
+//
+// The go-gclchaineum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-gclchaineum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-gclchaineum library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracers
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/gclchaineum/go-gclchaineum/common"
+	"github.com/gclchaineum/go-gclchaineum/common/hexutil"
+	"github.com/gclchaineum/go-gclchaineum/core/vm"
+)
+
+// fourByteTracer is the native implementation of the 4byteTracer, collecting
+// the 4 byte method identifiers of all calls along with the size of the supplied
+// data, so a reversed signature can be matched against the size of the data.
+type fourByteTracer struct {
+	interrupter
+
+	ids map[string]int // Aggregated 4 byte identifiers found
+
+	err error // Error, if one has occurred
+}
+
+// newFourByteTracer creates a native 4byte tracer.
+func newFourByteTracer() *fourByteTracer {
+	return &fourByteTracer{ids: make(map[string]int)}
+}
+
+// store saves the given identifier and data size.
+func (t *fourByteTracer) store(id []byte, size uint64) {
+	t.ids[fmt.Sprintf("%s-%d", hexutil.Encode(id), size)]++
+}
+
+// CaptureStart implements the Tracer interface to initialize the tracing operation.
+func (t *fourByteTracer) CaptureStart(from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) error {
+	// Save the outer calldata also
+	if len(input) >= 4 {
+		t.store(input[:4], uint64(len(input)-4))
+	}
+	return nil
+}
+
+// CaptureState implements the Tracer interface to trace a single step of VM execution.
+func (t *fourByteTracer) CaptureState(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64, memory *vm.Memory, stack *vm.Stack, contract *vm.Contract, depth int, err error) error {
+	if t.err != nil {
+		return nil
+	}
+	if t.interrupted() {
+		t.err = t.reason
+		return nil
+	}
+	// Skip any opcodes that are not internal calls, locating the input otherwise
+	var in int
+	switch op {
+	case vm.CALL, vm.CALLCODE:
+		in = 3 // gas, addr, val, memin, meminsz, memout, memoutsz
+	case vm.DELEGATECALL, vm.STATICCALL:
+		in = 2 // gas, addr, memin, meminsz, memout, memoutsz
+	default:
+		return nil
+	}
+	// Skip any pre-compile invocations, those are just fancy opcodes
+	stk := &stackWrapper{stack}
+	if vm.IsPrecompile(env, common.BigToAddress(stk.peek(1))) {
+		return nil
+	}
+	// Gather internal call details
+	if size := saturate(stk.peek(in + 1)); size >= 4 {
+		t.store(memorySlice(memory, saturate(stk.peek(in)), 4), size-4)
+	}
+	return nil
+}
+
+// CaptureFault implements the Tracer interface to trace an execution fault
+// while running an opcode.
+func (t *fourByteTracer) CaptureFault(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64, memory *vm.Memory, stack *vm.Stack, contract *vm.Contract, depth int, err error) error {
+	return nil
+}
+
+// CaptureEnd is called after the call finishes to finalize the tracing.
+func (t *fourByteTracer) CaptureEnd(output []byte, gasUsed uint64, d time.Duration, err error) error {
+	return nil
+}
+
+// GetResult returns the 4 byte identifiers found, or any accumulated error.
+func (t *fourByteTracer) GetResult() (json.RawMessage, error) {
+	if t.err != nil {
+		return nil, t.err
+	}
+	return json.Marshal(t.ids)
+}
@@ -0,0 +1,270 @@
+// Copyright 2019 The go-gclchaineum Authors
+// This file is part of the go-gclchaineum library.
+//
+// The go-gclchaineum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-gclchaineum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-gclchaineum library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracers
+
+import (
+	"encoding/json"
+	"math/big"
+	"time"
+
+	"github.com/gclchaineum/go-gclchaineum/common"
+	"github.com/gclchaineum/go-gclchaineum/common/hexutil"
+	"github.com/gclchaineum/go-gclchaineum/core/vm"
+)
+
+// callFrame is a single call of the call tracer. The exported fields are in the
+// order of the JavaScript tracer's output, the unexported ones are scratch values
+// needed until the call returns.
+type callFrame struct {
+	Type    string       `json:"type"`
+	From    string       `json:"from,omitempty"`
+	To      string       `json:"to,omitempty"`
+	Value   string       `json:"value,omitempty"`
+	Gas     string       `json:"gas,omitempty"`
+	GasUsed string       `json:"gasUsed,omitempty"`
+	Input   string       `json:"input,omitempty"`
+	Output  string       `json:"output,omitempty"`
+	Error   string       `json:"error,omitempty"`
+	Time    string       `json:"time,omitempty"`
+	Calls   []*callFrame `json:"calls,omitempty"`
+
+	gas     *uint64 // Gas allowance inside the call, if known
+	gasIn   uint64  // Gas available before the call opcode
+	gasCost uint64  // Gas cost of the call opcode
+	outOff  uint64  // Memory offset to retrieve the output from
+	outLen  uint64  // Memory length to retrieve the output from
+}
+
+// finish drops the scratch values of the frame, formatting the gas allowance.
+func (f *callFrame) finish() {
+	if f.gas != nil {
+		f.Gas = hexutil.EncodeUint64(*f.gas)
+	}
+	f.gas, f.gasIn, f.gasCost, f.outOff, f.outLen = nil, 0, 0, 0, 0
+}
+
+// callTracer is the native implementation of the callTracer, extracting and
+// reporting all the internal calls made by a transaction.
+type callTracer struct {
+	interrupter
+
+	callstack []*callFrame // Current recursive call stack of the EVM execution
+	descended bool         // Whether we've just descended into an inner call
+
+	ctx callFrame // Outer transaction gathered from the capture start and end
+	err error     // Error, if one has occurred
+}
+
+// newCallTracer creates a native call tracer.
+func newCallTracer() *callTracer {
+	return &callTracer{callstack: []*callFrame{{}}}
+}
+
+// top returns the innermost call currently executing.
+func (t *callTracer) top() *callFrame {
+	return t.callstack[len(t.callstack)-1]
+}
+
+// CaptureStart implements the Tracer interface to initialize the tracing operation.
+func (t *callTracer) CaptureStart(from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) error {
+	t.ctx.Type = "CALL"
+	if create {
+		t.ctx.Type = "CREATE"
+	}
+	if value == nil {
+		value = new(big.Int)
+	}
+	t.ctx.From = hexutil.Encode(from[:])
+	t.ctx.To = hexutil.Encode(to[:])
+	t.ctx.Input = hexutil.Encode(input)
+	t.ctx.Gas = hexutil.EncodeUint64(gas)
+	t.ctx.Value = hexutil.EncodeBig(value)
+	return nil
+}
+
+// CaptureState implements the Tracer interface to trace a single step of VM execution.
+func (t *callTracer) CaptureState(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64, memory *vm.Memory, stack *vm.Stack, contract *vm.Contract, depth int, err error) error {
+	if t.err != nil {
+		return nil
+	}
+	if t.interrupted() {
+		t.err = t.reason
+		return nil
+	}
+	// Capture any errors immediately
+	if err != nil {
+		t.fault(err)
+		return nil
+	}
+	stk := &stackWrapper{stack}
+	switch op {
+	case vm.CREATE, vm.CREATE2:
+		// If a new contract is being created, add to the call stack
+		t.callstack = append(t.callstack, &callFrame{
+			Type:    op.String(),
+			From:    hexutil.Encode(contract.Address().Bytes()),
+			Input:   hexutil.Encode(memorySlice(memory, saturate(stk.peek(1)), saturate(stk.peek(2)))),
+			Value:   hexutil.EncodeBig(stk.peek(0)),
+			gasIn:   gas,
+			gasCost: cost,
+		})
+		t.descended = true
+		return nil
+
+	case vm.SELFDESTRUCT:
+		// If a contract is being self destructed, gather that as a subcall too
+		parent := t.top()
+		parent.Calls = append(parent.Calls, &callFrame{Type: op.String()})
+		return nil
+
+	case vm.CALL, vm.CALLCODE, vm.DELEGATECALL, vm.STATICCALL:
+		// Skip any pre-compile invocations, those are just fancy opcodes
+		to := common.BigToAddress(stk.peek(1))
+		if vm.IsPrecompile(env, to) {
+			return nil
+		}
+		off := 1
+		if op == vm.DELEGATECALL || op == vm.STATICCALL {
+			off = 0
+		}
+		call := &callFrame{
+			Type:    op.String(),
+			From:    hexutil.Encode(contract.Address().Bytes()),
+			To:      hexutil.Encode(to[:]),
+			Input:   hexutil.Encode(memorySlice(memory, saturate(stk.peek(2+off)), saturate(stk.peek(3+off)))),
+			gasIn:   gas,
+			gasCost: cost,
+			outOff:  saturate(stk.peek(4 + off)),
+			outLen:  saturate(stk.peek(5 + off)),
+		}
+		if op != vm.DELEGATECALL && op != vm.STATICCALL {
+			call.Value = hexutil.EncodeBig(stk.peek(2))
+		}
+		t.callstack = append(t.callstack, call)
+		t.descended = true
+		return nil
+	}
+	// If we've just descended into an inner call, retrieve it's true allowance. We
+	// need to extract if from within the call as there may be funky gas dynamics
+	// with regard to requested and actually given gas (2300 stipend, 63/64 rule).
+	if t.descended {
+		if depth >= len(t.callstack) {
+			allowance := gas
+			t.top().gas = &allowance
+		}
+		t.descended = false
+	}
+	// If an existing call is returning, pop off the call stack
+	if op == vm.REVERT {
+		t.top().Error = "execution reverted"
+		return nil
+	}
+	if depth == len(t.callstack)-1 {
+		// Pop off the last call and get the execution results
+		call := t.top()
+		t.callstack = t.callstack[:len(t.callstack)-1]
+
+		if call.Type == "CREATE" || call.Type == "CREATE2" {
+			// If the call was a CREATE, retrieve the contract address and output code
+			call.GasUsed = hexutil.EncodeUint64(call.gasIn - call.gasCost - gas)
+
+			if ret := stk.peek(0); ret.Sign() != 0 {
+				addr := common.BigToAddress(ret)
+				call.To = hexutil.Encode(addr[:])
+				call.Output = hexutil.Encode(env.StateDB.GetCode(addr))
+			} else if call.Error == "" {
+				call.Error = "internal failure"
+			}
+		} else if call.gas != nil {
+			// If the call was a contract call, retrieve the gas usage and output
+			call.GasUsed = hexutil.EncodeUint64(call.gasIn - call.gasCost + *call.gas - gas)
+
+			if ret := stk.peek(0); ret.Sign() != 0 {
+				call.Output = hexutil.Encode(memorySlice(memory, call.outOff, call.outLen))
+			} else if call.Error == "" {
+				call.Error = "internal failure"
+			}
+		}
+		call.finish()
+
+		// Inject the call into the previous one
+		parent := t.top()
+		parent.Calls = append(parent.Calls, call)
+	}
+	return nil
+}
+
+// CaptureFault implements the Tracer interface to trace an execution fault
+// while running an opcode.
+func (t *callTracer) CaptureFault(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64, memory *vm.Memory, stack *vm.Stack, contract *vm.Contract, depth int, err error) error {
+	if t.err == nil {
+		t.fault(err)
+	}
+	return nil
+}
+
+// fault handles the failure of an opcode, flattening the failed call into its
+// parent.
+func (t *callTracer) fault(err error) {
+	// If the topmost call already reverted, don't handle the additional fault again
+	if t.top().Error != "" {
+		return
+	}
+	// Pop off the just failed call, consuming all available gas
+	call := t.top()
+	t.callstack = t.callstack[:len(t.callstack)-1]
+
+	call.Error = err.Error()
+	call.finish()
+	call.GasUsed = call.Gas
+
+	// Flatten the failed call into its parent, or leave it in the stack if the
+	// last call failed too
+	if len(t.callstack) == 0 {
+		t.callstack = append(t.callstack, call)
+		return
+	}
+	parent := t.top()
+	parent.Calls = append(parent.Calls, call)
+}
+
+// CaptureEnd is called after the call finishes to finalize the tracing.
+func (t *callTracer) CaptureEnd(output []byte, gasUsed uint64, d time.Duration, err error) error {
+	t.ctx.Output = hexutil.Encode(output)
+	t.ctx.GasUsed = hexutil.EncodeUint64(gasUsed)
+	t.ctx.Time = d.String()
+	if err != nil {
+		t.ctx.Error = err.Error()
+	}
+	return nil
+}
+
+// GetResult returns the call tree of the transaction, or any accumulated error.
+func (t *callTracer) GetResult() (json.RawMessage, error) {
+	if t.err != nil {
+		return nil, t.err
+	}
+	result := t.ctx
+	result.Calls = t.callstack[0].Calls
+	if t.callstack[0].Error != "" {
+		result.Error = t.callstack[0].Error
+	}
+	if result.Error != "" {
+		result.Output = ""
+	}
+	return json.Marshal(&result)
+}
@@ -0,0 +1,65 @@
+// Copyright 2019 The go-gclchaineum Authors
+// This file is part of the go-gclchaineum library.
+//
+// The go-gclchaineum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-gclchaineum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-gclchaineum library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracers
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/gclchaineum/go-gclchaineum/core/vm"
+)
+
+// gasProfiler exposes the EVM gas profiler as a native tracer, reporting both the
+// per call frame gas breakdown and its folded stack rendering for flamegraphs.
+type gasProfiler struct {
+	interrupter
+	*vm.GasProfiler
+
+	err error // Error, if one has occurred
+}
+
+// newGasProfiler creates a native gas profiling tracer.
+func newGasProfiler() *gasProfiler {
+	return &gasProfiler{GasProfiler: vm.NewGasProfiler()}
+}
+
+// CaptureState implements the Tracer interface to trace a single step of VM execution.
+func (t *gasProfiler) CaptureState(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64, memory *vm.Memory, stack *vm.Stack, contract *vm.Contract, depth int, err error) error {
+	if t.err != nil {
+		return nil
+	}
+	if t.interrupted() {
+		t.err = t.reason
+		return nil
+	}
+	return t.GasProfiler.CaptureState(env, pc, op, gas, cost, memory, stack, contract, depth, err)
+}
+
+// GetResult returns the gas profile of the transaction, or any accumulated error.
+func (t *gasProfiler) GetResult() (json.RawMessage, error) {
+	if t.err != nil {
+		return nil, t.err
+	}
+	folded := new(bytes.Buffer)
+	if err := t.WriteFolded(folded); err != nil {
+		return nil, err
+	}
+	return json.Marshal(map[string]interface{}{
+		"profile": t.Profile(),
+		"folded":  folded.String(),
+	})
+}
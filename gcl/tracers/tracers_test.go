@@ -273,3 +273,135 @@ func TestCallTracer(t *testing.T) {
 		})
 	}
 }
+
+// Iterates over all the input-output datasets in the tracer test harness and
+// checks that the native tracers produce the same output as the JavaScript ones.
+func TestNativeTracers(t *testing.T) {
+	files, err := ioutil.ReadDir("testdata")
+	if err != nil {
+		t.Fatalf("failed to retrieve tracer test suite: %v", err)
+	}
+	for _, file := range files {
+		if !strings.HasPrefix(file.Name(), "call_tracer_") {
+			continue
+		}
+		file := file // capture range variable
+		t.Run(camel(strings.TrimSuffix(strings.TrimPrefix(file.Name(), "call_tracer_"), ".json")), func(t *testing.T) {
+			t.Parallel()
+
+			blob, err := ioutil.ReadFile(filepath.Join("testdata", file.Name()))
+			if err != nil {
+				t.Fatalf("failed to read testcase: %v", err)
+			}
+			test := new(callTracerTest)
+			if err := json.Unmarshal(blob, test); err != nil {
+				t.Fatalf("failed to parse testcase: %v", err)
+			}
+			for name, native := range natives {
+				if _, ok := tracer(name); !ok {
+					continue // native only tracer, nothing to compare against
+				}
+				js, err := New(name)
+				if err != nil {
+					t.Fatalf("failed to create JavaScript %s: %v", name, err)
+				}
+				want := runTracerTest(t, test, js)
+				have := runTracerTest(t, test, native())
+				if !reflect.DeepEqual(have, want) {
+					t.Errorf("%s mismatch: \nhave %+v\nwant %+v", name, have, want)
+				}
+			}
+		})
+	}
+}
+
+// runTracerTest executes the transaction of a tracer test case with the given
+// tracer and returns the decoded trace result, stripped of the execution time.
+func runTracerTest(t *testing.T, test *callTracerTest, tracer TxTracer) interface{} {
+	tx := new(types.Transaction)
+	if err := rlp.DecodeBytes(common.FromHex(test.Input), tx); err != nil {
+		t.Fatalf("failed to parse testcase input: %v", err)
+	}
+	signer := types.MakeSigner(test.Genesis.Config, new(big.Int).SetUint64(uint64(test.Context.Number)))
+	origin, _ := signer.Sender(tx)
+
+	context := vm.Context{
+		CanTransfer: core.CanTransfer,
+		Transfer:    core.Transfer,
+		Origin:      origin,
+		Coinbase:    test.Context.Miner,
+		BlockNumber: new(big.Int).SetUint64(uint64(test.Context.Number)),
+		Time:        new(big.Int).SetUint64(uint64(test.Context.Time)),
+		Difficulty:  (*big.Int)(test.Context.Difficulty),
+		GasLimit:    uint64(test.Context.GasLimit),
+		GasPrice:    tx.GasPrice(),
+	}
+	statedb := tests.MakePreState(gcldb.NewMemDatabase(), test.Genesis.Alloc)
+	evm := vm.NewEVM(context, statedb, test.Genesis.Config, vm.Config{Debug: true, Tracer: tracer})
+
+	msg, err := tx.AsMessage(signer)
+	if err != nil {
+		t.Fatalf("failed to prepare transaction for tracing: %v", err)
+	}
+	st := core.NewStateTransition(evm, msg, new(core.GasPool).AddGas(tx.Gas()))
+	if _, _, _, err = st.TransitionDb(); err != nil {
+		t.Fatalf("failed to execute transaction: %v", err)
+	}
+	res, err := tracer.GetResult()
+	if err != nil {
+		t.Fatalf("failed to retrieve trace result: %v", err)
+	}
+	var ret interface{}
+	if err := json.Unmarshal(res, &ret); err != nil {
+		t.Fatalf("failed to unmarshal trace result: %v", err)
+	}
+	if obj, ok := ret.(map[string]interface{}); ok {
+		delete(obj, "time")
+	}
+	return ret
+}
+
+// Tests that the native and JavaScript tracers agree on which addresses are
+// pre-compiled contracts, including the ones only active from Istanbul on.
+func TestNativeTracersPrecompile(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	signer := types.NewEIP155Signer(params.TestChainConfig.ChainID)
+	tx, err := types.SignTx(types.NewTransaction(0, common.HexToAddress("0x1000"), new(big.Int), 100000, big.NewInt(1), nil), signer, key)
+	if err != nil {
+		t.Fatalf("failed to sign transaction: %v", err)
+	}
+	input, _ := rlp.EncodeToBytes(tx)
+
+	test := &callTracerTest{
+		Genesis: &core.Genesis{
+			Config: params.TestChainConfig,
+			Alloc: core.GenesisAlloc{
+				crypto.PubkeyToAddress(key.PublicKey): {Balance: big.NewInt(1000000)},
+				// Calls blake2f (0x09) with 32 bytes of input and stops
+				common.HexToAddress("0x1000"): {Balance: new(big.Int), Code: hexutil.MustDecode("0x6000600060206000600060096161a8f100")},
+			},
+		},
+		Context: &callContext{Number: 1, Difficulty: (*math.HexOrDecimal256)(big.NewInt(1)), GasLimit: 6000000},
+		Input:   hexutil.Encode(input),
+	}
+	for name, native := range natives {
+		if _, ok := tracer(name); !ok {
+			continue
+		}
+		js, err := New(name)
+		if err != nil {
+			t.Fatalf("failed to create JavaScript %s: %v", name, err)
+		}
+		want := runTracerTest(t, test, js)
+		have := runTracerTest(t, test, native())
+		if !reflect.DeepEqual(have, want) {
+			t.Errorf("%s mismatch: \nhave %+v\nwant %+v", name, have, want)
+		}
+		switch res := have.(type) {
+		case map[string]interface{}:
+			if _, ok := res["calls"]; ok || (name == "4byteTracer" && len(res) > 0) {
+				t.Errorf("%s traced the pre-compile call: %+v", name, res)
+			}
+		}
+	}
+}
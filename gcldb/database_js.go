@@ -66,3 +66,28 @@ func (db *LDBDatabase) Meter(prefix string) {
 func (db *LDBDatabase) NewBatch() Batch {
 	return nil
 }
+
+func (db *LDBDatabase) NewIterator() Iterator {
+	return &errIterator{}
+}
+
+func (db *LDBDatabase) NewIteratorWithStart(start []byte) Iterator {
+	return &errIterator{}
+}
+
+func (db *LDBDatabase) NewIteratorWithPrefix(prefix []byte) Iterator {
+	return &errIterator{}
+}
+
+func (db *LDBDatabase) NewIteratorWithRange(start []byte, limit []byte) Iterator {
+	return &errIterator{}
+}
+
+// errIterator is an empty iterator that reports the database as unsupported.
+type errIterator struct{}
+
+func (it *errIterator) Next() bool    { return false }
+func (it *errIterator) Error() error  { return errNotSupported }
+func (it *errIterator) Key() []byte   { return nil }
+func (it *errIterator) Value() []byte { return nil }
+func (it *errIterator) Release()      {}
@@ -212,3 +212,63 @@ func testParallelPutGet(db gcldb.Database, t *testing.T) {
 	}
 	pending.Wait()
 }
+
+func TestLDB_Iterator(t *testing.T) {
+	db, remove := newTestLDB()
+	defer remove()
+	testIterator(db, t)
+}
+
+func TestMemoryDB_Iterator(t *testing.T) {
+	testIterator(gcldb.NewMemDatabase(), t)
+}
+
+func TestTable_Iterator(t *testing.T) {
+	db := gcldb.NewMemDatabase()
+
+	// Add some data outside of the table to ensure it's not iterated over
+	db.Put([]byte("a"), []byte("outside"))
+	db.Put([]byte("u"), []byte("outside"))
+
+	testIterator(gcldb.NewTable(db, "t-"), t)
+}
+
+func testIterator(db gcldb.Database, t *testing.T) {
+	t.Parallel()
+
+	keys := []string{"1", "2", "3", "4", "6", "10", "11", "12", "20", "21", "22"}
+	for _, k := range keys {
+		if err := db.Put([]byte(k), []byte("val-"+k)); err != nil {
+			t.Fatalf("put failed: %v", err)
+		}
+	}
+	tests := []struct {
+		it   gcldb.Iterator
+		want []string
+	}{
+		{db.NewIterator(), []string{"1", "10", "11", "12", "2", "20", "21", "22", "3", "4", "6"}},
+		{db.NewIteratorWithStart([]byte("2")), []string{"2", "20", "21", "22", "3", "4", "6"}},
+		{db.NewIteratorWithStart([]byte("5")), []string{"6"}},
+		{db.NewIteratorWithPrefix([]byte("1")), []string{"1", "10", "11", "12"}},
+		{db.NewIteratorWithPrefix([]byte("5")), nil},
+		{db.NewIteratorWithRange([]byte("11"), []byte("21")), []string{"11", "12", "2", "20"}},
+		{db.NewIteratorWithRange([]byte("3"), nil), []string{"3", "4", "6"}},
+	}
+	for i, tt := range tests {
+		var have []string
+		for tt.it.Next() {
+			if want := "val-" + string(tt.it.Key()); string(tt.it.Value()) != want {
+				t.Errorf("test %d: value mismatch for key %q: have %q, want %q", i, tt.it.Key(), tt.it.Value(), want)
+			}
+			have = append(have, string(tt.it.Key()))
+		}
+		if err := tt.it.Error(); err != nil {
+			t.Errorf("test %d: iteration failed: %v", i, err)
+		}
+		tt.it.Release()
+
+		if fmt.Sprint(have) != fmt.Sprint(tt.want) {
+			t.Errorf("test %d: key mismatch: have %v, want %v", i, have, tt.want)
+		}
+	}
+}
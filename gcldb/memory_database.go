@@ -17,7 +17,9 @@
 package gcldb
 
 import (
+	"bytes"
 	"errors"
+	"sort"
 	"sync"
 
 	"github.com/gclchaineum/go-gclchaineum/common"
@@ -96,6 +98,110 @@ func (db *MemDatabase) NewBatch() Batch {
 
 func (db *MemDatabase) Len() int { return len(db.db) }
 
+// NewIterator returns an iterator over the entire database content.
+func (db *MemDatabase) NewIterator() Iterator {
+	return db.NewIteratorWithRange(nil, nil)
+}
+
+// NewIteratorWithStart returns an iterator over a subset of database content
+// starting at a particular initial key (or after, if it does not exist).
+func (db *MemDatabase) NewIteratorWithStart(start []byte) Iterator {
+	return db.NewIteratorWithRange(start, nil)
+}
+
+// NewIteratorWithPrefix returns an iterator over a subset of database content
+// with a particular key prefix.
+func (db *MemDatabase) NewIteratorWithPrefix(prefix []byte) Iterator {
+	return db.NewIteratorWithRange(prefix, prefixLimit(prefix))
+}
+
+// NewIteratorWithRange returns an iterator over the database content with keys
+// in the range [start, limit). The iterator operates on a snapshot of the keys
+// taken at creation time.
+func (db *MemDatabase) NewIteratorWithRange(start []byte, limit []byte) Iterator {
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+
+	var (
+		keys   []string
+		values [][]byte
+	)
+	for key := range db.db {
+		if bytes.Compare([]byte(key), start) < 0 {
+			continue
+		}
+		if limit != nil && bytes.Compare([]byte(key), limit) >= 0 {
+			continue
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		values = append(values, db.db[key])
+	}
+	return &memIterator{
+		keys:   keys,
+		values: values,
+		index:  -1,
+	}
+}
+
+// prefixLimit returns the smallest key that is larger than all keys with the
+// given prefix, or nil if no such key exists.
+func prefixLimit(prefix []byte) []byte {
+	limit := common.CopyBytes(prefix)
+	for i := len(limit) - 1; i >= 0; i-- {
+		if limit[i] < 0xff {
+			limit[i]++
+			return limit[:i+1]
+		}
+	}
+	return nil
+}
+
+// memIterator is an iterator over a sorted snapshot of a memory database.
+type memIterator struct {
+	keys   []string
+	values [][]byte
+	index  int
+}
+
+// Next moves the iterator to the next key/value pair. It returns whether the
+// iterator is exhausted.
+func (it *memIterator) Next() bool {
+	if it.index >= len(it.keys) {
+		return false
+	}
+	it.index++
+	return it.index < len(it.keys)
+}
+
+// Error returns any accumulated error. A memory iterator never fails.
+func (it *memIterator) Error() error {
+	return nil
+}
+
+// Key returns the key of the current key/value pair, or nil if done.
+func (it *memIterator) Key() []byte {
+	if it.index < 0 || it.index >= len(it.keys) {
+		return nil
+	}
+	return []byte(it.keys[it.index])
+}
+
+// Value returns the value of the current key/value pair, or nil if done.
+func (it *memIterator) Value() []byte {
+	if it.index < 0 || it.index >= len(it.keys) {
+		return nil
+	}
+	return it.values[it.index]
+}
+
+// Release releases the snapshot held by the iterator.
+func (it *memIterator) Release() {
+	it.keys, it.values, it.index = nil, nil, -1
+}
+
 type kv struct {
 	k, v []byte
 	del  bool
@@ -49,3 +49,61 @@ func (dt *table) Delete(key []byte) error {
 func (dt *table) Close() {
 	// Do nothing; don't close the underlying DB.
 }
+
+func (dt *table) NewIterator() Iterator {
+	return dt.NewIteratorWithPrefix(nil)
+}
+
+func (dt *table) NewIteratorWithStart(start []byte) Iterator {
+	return dt.NewIteratorWithRange(start, nil)
+}
+
+func (dt *table) NewIteratorWithPrefix(prefix []byte) Iterator {
+	return &tableIterator{
+		it:     dt.db.NewIteratorWithPrefix(append([]byte(dt.prefix), prefix...)),
+		prefix: dt.prefix,
+	}
+}
+
+func (dt *table) NewIteratorWithRange(start []byte, limit []byte) Iterator {
+	// An unbounded range must still stop at the end of the table's keyspace
+	end := prefixLimit([]byte(dt.prefix))
+	if limit != nil {
+		end = append([]byte(dt.prefix), limit...)
+	}
+	return &tableIterator{
+		it:     dt.db.NewIteratorWithRange(append([]byte(dt.prefix), start...), end),
+		prefix: dt.prefix,
+	}
+}
+
+// tableIterator is a wrapper around a database iterator that strips the table
+// prefix from the iterated keys.
+type tableIterator struct {
+	it     Iterator
+	prefix string
+}
+
+func (it *tableIterator) Next() bool {
+	return it.it.Next()
+}
+
+func (it *tableIterator) Error() error {
+	return it.it.Error()
+}
+
+func (it *tableIterator) Key() []byte {
+	key := it.it.Key()
+	if key == nil {
+		return nil
+	}
+	return key[len(it.prefix):]
+}
+
+func (it *tableIterator) Value() []byte {
+	return it.it.Value()
+}
+
+func (it *tableIterator) Release() {
+	it.it.Release()
+}
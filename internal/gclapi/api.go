@@ -35,6 +35,7 @@ import (
 	"github.com/gclchaineum/go-gclchaineum/consensus/gclash"
 	"github.com/gclchaineum/go-gclchaineum/core"
 	"github.com/gclchaineum/go-gclchaineum/core/rawdb"
+	"github.com/gclchaineum/go-gclchaineum/core/state"
 	"github.com/gclchaineum/go-gclchaineum/core/types"
 	"github.com/gclchaineum/go-gclchaineum/core/vm"
 	"github.com/gclchaineum/go-gclchaineum/crypto"
@@ -134,12 +135,73 @@ func (s *PublicTxPoolAPI) Content() map[string]map[string]map[string]*RPCTransac
 	return content
 }
 
-// Status returns the number of pending and queued transaction in the pool.
-func (s *PublicTxPoolAPI) Status() map[string]hexutil.Uint {
-	pending, queue := s.b.Stats()
-	return map[string]hexutil.Uint{
-		"pending": hexutil.Uint(pending),
-		"queued":  hexutil.Uint(queue),
+// RPCQueuedReason describes why a queued transaction is not executable.
+type RPCQueuedReason struct {
+	Reason       string          `json:"reason"`
+	MissingNonce *hexutil.Uint64 `json:"missingNonce,omitempty"`
+}
+
+// newRPCQueuedReason converts the pool diagnostics of a queued transaction into
+// their RPC representation.
+func newRPCQueuedReason(info core.TxQueuedInfo) *RPCQueuedReason {
+	reason := &RPCQueuedReason{Reason: info.Reason.String()}
+	if info.Reason == core.TxQueuedNonceGap {
+		nonce := hexutil.Uint64(info.MissingNonce)
+		reason.MissingNonce = &nonce
+	}
+	return reason
+}
+
+// RPCQueuedTransaction is a queued transaction along with the reason it is not
+// executable.
+type RPCQueuedTransaction struct {
+	*RPCTransaction
+	*RPCQueuedReason
+}
+
+// ContentFrom returns the transactions contained within the transaction pool
+// sent by the given account, along with the reason queued ones are not executable.
+func (s *PublicTxPoolAPI) ContentFrom(addr common.Address) map[string]interface{} {
+	pending, queue := s.b.TxPoolContentFrom(addr)
+
+	pendingDump := make(map[string]*RPCTransaction)
+	for _, tx := range pending {
+		pendingDump[fmt.Sprintf("%d", tx.Nonce())] = newRPCPendingTransaction(tx)
+	}
+	queuedDump := make(map[string]*RPCQueuedTransaction)
+	for _, info := range queue {
+		queuedDump[fmt.Sprintf("%d", info.Tx.Nonce())] = &RPCQueuedTransaction{
+			RPCTransaction:  newRPCPendingTransaction(info.Tx),
+			RPCQueuedReason: newRPCQueuedReason(info),
+		}
+	}
+	return map[string]interface{}{
+		"pending": pendingDump,
+		"queued":  queuedDump,
+	}
+}
+
+// Status returns the number of pending and queued transaction in the pool. If an
+// account is specified, only its transactions are counted and the reasons its
+// queued ones are not executable are returned too, keyed by nonce.
+func (s *PublicTxPoolAPI) Status(addr *common.Address) map[string]interface{} {
+	if addr == nil {
+		pending, queue := s.b.Stats()
+		return map[string]interface{}{
+			"pending": hexutil.Uint(pending),
+			"queued":  hexutil.Uint(queue),
+		}
+	}
+	pending, queue := s.b.TxPoolContentFrom(*addr)
+
+	reasons := make(map[string]*RPCQueuedReason)
+	for _, info := range queue {
+		reasons[fmt.Sprintf("%d", info.Tx.Nonce())] = newRPCQueuedReason(info)
+	}
+	return map[string]interface{}{
+		"pending": hexutil.Uint(len(pending)),
+		"queued":  hexutil.Uint(len(queue)),
+		"reasons": reasons,
 	}
 }
 
@@ -696,17 +758,14 @@ type CallArgs struct {
 	Data     hexutil.Bytes   `json:"data"`
 }
 
-func (s *PublicBlockChainAPI) doCall(ctx context.Context, args CallArgs, blockNr rpc.BlockNumber, timeout time.Duration) ([]byte, uint64, bool, error) {
-	defer func(start time.Time) { log.Debug("Executing EVM call finished", "runtime", time.Since(start)) }(time.Now())
-
-	state, header, err := s.b.StateAndHeaderByNumber(ctx, blockNr)
-	if state == nil || err != nil {
-		return nil, 0, false, err
-	}
+// ToMessage converts the call arguments to the message type used by the core
+// EVM, defaulting the sender to the first local account and the gas and gas
+// price to unmetered values if they weren't specified.
+func (args *CallArgs) ToMessage(am *accounts.Manager) types.Message {
 	// Set sender address or use a default if none specified
 	addr := args.From
 	if addr == (common.Address{}) {
-		if wallets := s.b.AccountManager().Wallets(); len(wallets) > 0 {
+		if wallets := am.Wallets(); len(wallets) > 0 {
 			if accounts := wallets[0].Accounts(); len(accounts) > 0 {
 				addr = accounts[0].Address
 			}
@@ -720,9 +779,74 @@ func (s *PublicBlockChainAPI) doCall(ctx context.Context, args CallArgs, blockNr
 	if gasPrice.Sign() == 0 {
 		gasPrice = new(big.Int).SetUint64(defaultGasPrice)
 	}
+	return types.NewMessage(addr, args.To, 0, args.Value.ToInt(), gas, gasPrice, args.Data, false)
+}
+
+// account indicates the overriding fields of account during the execution of
+// a message call.
+//
+// Note, state and stateDiff can't be specified at the same time. If state is
+// set, message execution will only use the data in the given state. Otherwise
+// if stateDiff is set, all diff will be applied first and then execute the call
+// message.
+type account struct {
+	Nonce     *hexutil.Uint64              `json:"nonce"`
+	Code      *hexutil.Bytes               `json:"code"`
+	Balance   **hexutil.Big                `json:"balance"`
+	State     *map[common.Hash]common.Hash `json:"state"`
+	StateDiff *map[common.Hash]common.Hash `json:"stateDiff"`
+}
+
+// StateOverride is the collection of overridden accounts, keyed by address.
+type StateOverride map[common.Address]account
+
+// Apply overrides the fields of the specified accounts in the given state.
+func (diff *StateOverride) Apply(statedb *state.StateDB) error {
+	if diff == nil {
+		return nil
+	}
+	for addr, account := range *diff {
+		// Override account nonce.
+		if account.Nonce != nil {
+			statedb.SetNonce(addr, uint64(*account.Nonce))
+		}
+		// Override account(contract) code.
+		if account.Code != nil {
+			statedb.SetCode(addr, *account.Code)
+		}
+		// Override account balance.
+		if account.Balance != nil {
+			statedb.SetBalance(addr, (*big.Int)(*account.Balance))
+		}
+		if account.State != nil && account.StateDiff != nil {
+			return fmt.Errorf("account %s has both 'state' and 'stateDiff'", addr.Hex())
+		}
+		// Replace entire state if caller requires.
+		if account.State != nil {
+			statedb.SetStorage(addr, *account.State)
+		}
+		// Apply state diff into specified accounts.
+		if account.StateDiff != nil {
+			for key, value := range *account.StateDiff {
+				statedb.SetState(addr, key, value)
+			}
+		}
+	}
+	return nil
+}
+
+func (s *PublicBlockChainAPI) doCall(ctx context.Context, args CallArgs, blockNr rpc.BlockNumber, overrides *StateOverride, timeout time.Duration) ([]byte, uint64, bool, error) {
+	defer func(start time.Time) { log.Debug("Executing EVM call finished", "runtime", time.Since(start)) }(time.Now())
 
+	state, header, err := s.b.StateAndHeaderByNumber(ctx, blockNr)
+	if state == nil || err != nil {
+		return nil, 0, false, err
+	}
+	if err := overrides.Apply(state); err != nil {
+		return nil, 0, false, err
+	}
 	// Create new call message
-	msg := types.NewMessage(addr, args.To, 0, args.Value.ToInt(), gas, gasPrice, args.Data, false)
+	msg := args.ToMessage(s.b.AccountManager())
 
 	// Setup context so it may be cancelled the call has completed
 	// or, in case of unmetered gas, setup a context with a timeout.
@@ -760,14 +884,18 @@ func (s *PublicBlockChainAPI) doCall(ctx context.Context, args CallArgs, blockNr
 
 // Call executes the given transaction on the state for the given block number.
 // It doesn't make and changes in the state/blockchain and is useful to execute and retrieve values.
-func (s *PublicBlockChainAPI) Call(ctx context.Context, args CallArgs, blockNr rpc.BlockNumber) (hexutil.Bytes, error) {
-	result, _, _, err := s.doCall(ctx, args, blockNr, 5*time.Second)
+//
+// Additionally, the caller can specify a set of accounts whose nonce, balance,
+// code or storage are overridden for the duration of the call.
+func (s *PublicBlockChainAPI) Call(ctx context.Context, args CallArgs, blockNr rpc.BlockNumber, overrides *StateOverride) (hexutil.Bytes, error) {
+	result, _, _, err := s.doCall(ctx, args, blockNr, overrides, 5*time.Second)
 	return (hexutil.Bytes)(result), err
 }
 
 // EstimateGas returns an estimate of the amount of gas needed to execute the
-// given transaction against the current pending block.
-func (s *PublicBlockChainAPI) EstimateGas(ctx context.Context, args CallArgs) (hexutil.Uint64, error) {
+// given transaction against the current pending block, with the optional
+// account overrides applied on top of it.
+func (s *PublicBlockChainAPI) EstimateGas(ctx context.Context, args CallArgs, overrides *StateOverride) (hexutil.Uint64, error) {
 	// Binary search the gas requirement, as it may be higher than the amount used
 	var (
 		lo  uint64 = params.TxGas - 1
@@ -790,7 +918,7 @@ func (s *PublicBlockChainAPI) EstimateGas(ctx context.Context, args CallArgs) (h
 	executable := func(gas uint64) bool {
 		args.Gas = hexutil.Uint64(gas)
 
-		_, _, failed, err := s.doCall(ctx, args, rpc.PendingBlockNumber, 0)
+		_, _, failed, err := s.doCall(ctx, args, rpc.PendingBlockNumber, overrides, 0)
 		if err != nil || failed {
 			return false
 		}
@@ -814,6 +942,84 @@ func (s *PublicBlockChainAPI) EstimateGas(ctx context.Context, args CallArgs) (h
 	return hexutil.Uint64(hi), nil
 }
 
+// BundleCallResult is the outcome of a single call of a simulated bundle.
+type BundleCallResult struct {
+	GasUsed    hexutil.Uint64 `json:"gasUsed"`
+	Failed     bool           `json:"failed"`
+	ReturnData hexutil.Bytes  `json:"returnData"` // Revert data if the call failed
+	Logs       []*types.Log   `json:"logs"`
+}
+
+// CallBundle executes an ordered list of calls on top of the state of the given
+// block number, each call seeing the state changes of the ones before it. The
+// results of the individual calls are returned in the same order.
+//
+// Note, this function doesn't make any changes in the state/blockchain and is
+// useful to simulate multi-step flows before submitting the transactions.
+func (s *PublicBlockChainAPI) CallBundle(ctx context.Context, bundle []CallArgs, blockNr rpc.BlockNumber) ([]*BundleCallResult, error) {
+	defer func(start time.Time) { log.Debug("Executing EVM call bundle finished", "runtime", time.Since(start)) }(time.Now())
+
+	if len(bundle) == 0 {
+		return nil, errors.New("empty call bundle")
+	}
+	state, header, err := s.b.StateAndHeaderByNumber(ctx, blockNr)
+	if state == nil || err != nil {
+		return nil, err
+	}
+	// Setup a context with a timeout shared by all the calls of the bundle and
+	// make sure it is cancelled when the bundle has completed.
+	timeout := 5 * time.Second
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	// Logs are collected without transaction hash, so track how many of them
+	// were emitted by the calls so far to split them up per call.
+	var (
+		results = make([]*BundleCallResult, 0, len(bundle))
+		gp      = new(core.GasPool).AddGas(math.MaxUint64)
+		logs    int
+	)
+	for i, args := range bundle {
+		msg := args.ToMessage(s.b.AccountManager())
+		state.Prepare(common.Hash{}, common.Hash{}, i)
+
+		evm, vmError, err := s.b.GetEVM(ctx, msg, state, header)
+		if err != nil {
+			return nil, err
+		}
+		// Wait for the context to be done and cancel the evm. Even if the
+		// EVM has finished, cancelling may be done (repeatedly)
+		go func() {
+			<-ctx.Done()
+			evm.Cancel()
+		}()
+		res, gas, failed, err := core.ApplyMessage(evm, msg, gp)
+		if err := vmError(); err != nil {
+			return nil, err
+		}
+		// If the timer caused an abort, the call result is meaningless
+		if evm.Cancelled() {
+			return nil, fmt.Errorf("call %d: execution aborted (timeout = %v)", i, timeout)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("call %d: %v", i, err)
+		}
+		// Intermediate state roots are not needed, but deleted accounts must be
+		// cleared the same way as between the transactions of a block.
+		state.Finalise(s.b.ChainConfig().IsEIP158(header.Number))
+
+		emitted := state.GetLogs(common.Hash{})
+		results = append(results, &BundleCallResult{
+			GasUsed:    hexutil.Uint64(gas),
+			Failed:     failed,
+			ReturnData: res,
+			Logs:       append([]*types.Log{}, emitted[logs:]...),
+		})
+		logs = len(emitted)
+	}
+	return results, nil
+}
+
 // ExecutionResult groups all structured logs emitted by the EVM
 // while replaying a transaction in debug mode as well as transaction
 // execution status, the amount of gas used and the return value
@@ -836,6 +1042,7 @@ type StructLogRes struct {
 	Stack   *[]string          `json:"stack,omitempty"`
 	Memory  *[]string          `json:"memory,omitempty"`
 	Storage *map[string]string `json:"storage,omitempty"`
+	Source  *vm.SourcePosition `json:"source,omitempty"`
 }
 
 // formatLogs formats EVM returned structured logs for json output
@@ -849,6 +1056,7 @@ func FormatLogs(logs []vm.StructLog) []StructLogRes {
 			GasCost: trace.GasCost,
 			Depth:   trace.Depth,
 			Error:   trace.Err,
+			Source:  trace.Source,
 		}
 		if trace.Stack != nil {
 			stack := make([]string, len(trace.Stack))
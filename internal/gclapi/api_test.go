@@ -0,0 +1,137 @@
+// Copyright 2019 The go-gclchaineum Authors
+// This file is part of the go-gclchaineum library.
+//
+// The go-gclchaineum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-gclchaineum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-gclchaineum library. If not, see <http://www.gnu.org/licenses/>.
+
+package gclapi
+
+import (
+	"bytes"
+	"context"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/gclchaineum/go-gclchaineum/accounts"
+	"github.com/gclchaineum/go-gclchaineum/common"
+	"github.com/gclchaineum/go-gclchaineum/common/math"
+	"github.com/gclchaineum/go-gclchaineum/consensus/gclash"
+	"github.com/gclchaineum/go-gclchaineum/core"
+	"github.com/gclchaineum/go-gclchaineum/core/state"
+	"github.com/gclchaineum/go-gclchaineum/core/types"
+	"github.com/gclchaineum/go-gclchaineum/core/vm"
+	"github.com/gclchaineum/go-gclchaineum/gcldb"
+	"github.com/gclchaineum/go-gclchaineum/params"
+	"github.com/gclchaineum/go-gclchaineum/rpc"
+)
+
+// testBackend is a Backend serving the calls of the API from a local chain.
+// Methods not needed by the tests are left unimplemented.
+type testBackend struct {
+	Backend
+	chain *core.BlockChain
+}
+
+func newTestBackend(t *testing.T, alloc core.GenesisAlloc) *testBackend {
+	var (
+		db    = gcldb.NewMemDatabase()
+		gspec = &core.Genesis{Config: params.TestChainConfig, Alloc: alloc}
+	)
+	gspec.MustCommit(db)
+	chain, err := core.NewBlockChain(db, nil, gspec.Config, gclash.NewFaker(), vm.Config{}, nil)
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	return &testBackend{chain: chain}
+}
+
+func (b *testBackend) ChainConfig() *params.ChainConfig  { return b.chain.Config() }
+func (b *testBackend) AccountManager() *accounts.Manager { return accounts.NewManager() }
+func (b *testBackend) StateAndHeaderByNumber(ctx context.Context, blockNr rpc.BlockNumber) (*state.StateDB, *types.Header, error) {
+	state, err := b.chain.State()
+	return state, b.chain.CurrentHeader(), err
+}
+
+func (b *testBackend) GetEVM(ctx context.Context, msg core.Message, state *state.StateDB, header *types.Header) (*vm.EVM, func() error, error) {
+	state.SetBalance(msg.From(), math.MaxBig256)
+	context := core.NewEVMContext(msg, header, b.chain, nil)
+	return vm.NewEVM(context, state, b.chain.Config(), vm.Config{}), func() error { return nil }, nil
+}
+
+// Tests that the calls of a bundle see the state changes of the previous ones,
+// and that logs and revert data are reported per call.
+func TestCallBundle(t *testing.T) {
+	var (
+		counter  = common.HexToAddress("0xc0")
+		reverter = common.HexToAddress("0xc1")
+		looper   = common.HexToAddress("0xc2")
+	)
+	backend := newTestBackend(t, core.GenesisAlloc{
+		// Increments slot 0, logs and returns the new value
+		counter: {Balance: new(big.Int), Code: []byte{
+			byte(vm.PUSH1), 0x00, byte(vm.SLOAD), byte(vm.PUSH1), 0x01, byte(vm.ADD), byte(vm.DUP1), byte(vm.PUSH1), 0x00, byte(vm.SSTORE),
+			byte(vm.PUSH1), 0x00, byte(vm.MSTORE), byte(vm.PUSH1), 0x20, byte(vm.PUSH1), 0x00, byte(vm.LOG0),
+			byte(vm.PUSH1), 0x20, byte(vm.PUSH1), 0x00, byte(vm.RETURN),
+		}},
+		// Reverts with "oops"
+		reverter: {Balance: new(big.Int), Code: []byte{
+			byte(vm.PUSH4), 'o', 'o', 'p', 's', byte(vm.PUSH1), 0x00, byte(vm.MSTORE),
+			byte(vm.PUSH1), 0x04, byte(vm.PUSH1), 0x1c, byte(vm.REVERT),
+		}},
+		// Loops forever
+		looper: {Balance: new(big.Int), Code: []byte{byte(vm.JUMPDEST), byte(vm.PUSH1), 0x00, byte(vm.JUMP)}},
+	})
+	defer backend.chain.Stop()
+
+	api := NewPublicBlockChainAPI(backend)
+	results, err := api.CallBundle(context.Background(), []CallArgs{{To: &counter}, {To: &counter}, {To: &reverter}, {To: &counter}}, rpc.LatestBlockNumber)
+	if err != nil {
+		t.Fatalf("failed to execute bundle: %v", err)
+	}
+	want := []struct {
+		failed bool
+		ret    []byte
+		logs   int
+	}{
+		{false, common.LeftPadBytes([]byte{1}, 32), 1},
+		{false, common.LeftPadBytes([]byte{2}, 32), 1},
+		{true, []byte("oops"), 0},
+		{false, common.LeftPadBytes([]byte{3}, 32), 1},
+	}
+	if len(results) != len(want) {
+		t.Fatalf("result count mismatch: have %d, want %d", len(results), len(want))
+	}
+	for i, res := range results {
+		if res.Failed != want[i].failed || !bytes.Equal(res.ReturnData, want[i].ret) {
+			t.Errorf("call %d: result mismatch: have failed %v, %x; want failed %v, %x", i, res.Failed, []byte(res.ReturnData), want[i].failed, want[i].ret)
+		}
+		if len(res.Logs) != want[i].logs {
+			t.Errorf("call %d: log count mismatch: have %d, want %d", i, len(res.Logs), want[i].logs)
+			continue
+		}
+		if want[i].logs > 0 && (res.Logs[0].Address != counter || !bytes.Equal(res.Logs[0].Data, want[i].ret)) {
+			t.Errorf("call %d: log mismatch: have %v", i, res.Logs[0])
+		}
+	}
+	// The bundle must not modify the chain state
+	if state, _ := backend.chain.State(); state.GetState(counter, common.Hash{}) != (common.Hash{}) {
+		t.Errorf("bundle modified the chain state")
+	}
+	// Aborted calls must be reported as errors instead of empty successes
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := api.CallBundle(ctx, []CallArgs{{To: &looper}, {To: &counter}}, rpc.LatestBlockNumber); err == nil || !strings.Contains(err.Error(), "execution aborted") {
+		t.Errorf("aborted bundle error mismatch: have %v", err)
+	}
+}
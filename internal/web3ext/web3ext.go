@@ -199,6 +199,11 @@ web3._extend({
 			name: 'stopWS',
 			call: 'admin_stopWS'
 		}),
+		new web3._extend.Mgclod({
+			name: 'setTxPolicy',
+			call: 'admin_setTxPolicy',
+			params: 1
+		}),
 	],
 	properties: [
 		new web3._extend.Property({
@@ -213,6 +218,10 @@ web3._extend({
 			name: 'datadir',
 			getter: 'admin_datadir'
 		}),
+		new web3._extend.Property({
+			name: 'txPolicy',
+			getter: 'admin_txPolicy'
+		}),
 	]
 });
 `
@@ -246,6 +255,12 @@ web3._extend({
 			call: 'debug_dumpBlock',
 			params: 1
 		}),
+		new web3._extend.Mgclod({
+			name: 'accountRange',
+			call: 'debug_accountRange',
+			params: 5,
+			inputFormatter: [web3._extend.formatters.inputBlockNumberFormatter, null, null, null, null]
+		}),
 		new web3._extend.Mgclod({
 			name: 'chaindbProperty',
 			call: 'debug_chaindbProperty',
@@ -415,6 +430,12 @@ web3._extend({
 			params: 2,
 			inputFormatter: [null, null]
 		}),
+		new web3._extend.Mgclod({
+			name: 'traceCall',
+			call: 'debug_traceCall',
+			params: 3,
+			inputFormatter: [null, null, null]
+		}),
 		new web3._extend.Mgclod({
 			name: 'preimage',
 			call: 'debug_preimage',
@@ -426,11 +447,22 @@ web3._extend({
 			call: 'debug_getBadBlocks',
 			params: 0,
 		}),
+		new web3._extend.Mgclod({
+			name: 'getBlockWitness',
+			call: 'debug_getBlockWitness',
+			params: 1,
+		}),
 		new web3._extend.Mgclod({
 			name: 'storageRangeAt',
 			call: 'debug_storageRangeAt',
 			params: 5,
 		}),
+		new web3._extend.Mgclod({
+			name: 'stateDiff',
+			call: 'debug_stateDiff',
+			params: 1,
+			inputFormatter: [web3._extend.formatters.inputBlockNumberFormatter]
+		}),
 		new web3._extend.Mgclod({
 			name: 'getModifiedAccountsByNumber',
 			call: 'debug_getModifiedAccountsByNumber',
@@ -500,6 +532,12 @@ web3._extend({
 			params: 3,
 			inputFormatter: [web3._extend.formatters.inputAddressFormatter, null, web3._extend.formatters.inputBlockNumberFormatter]
 		}),
+		new web3._extend.Mgclod({
+			name: 'callBundle',
+			call: 'gcl_callBundle',
+			params: 2,
+			inputFormatter: [null, web3._extend.formatters.inputBlockNumberFormatter]
+		}),
 	],
 	properties: [
 		new web3._extend.Property({
@@ -688,7 +726,23 @@ web3._extend({
 const TxPool_JS = `
 web3._extend({
 	property: 'txpool',
-	mgclods: [],
+	mgclods: [
+		new web3._extend.Mgclod({
+			name: 'contentFrom',
+			call: 'txpool_contentFrom',
+			params: 1
+		}),
+		new web3._extend.Mgclod({
+			name: 'statusFrom',
+			call: 'txpool_status',
+			params: 1,
+			outputFormatter: function(status) {
+				status.pending = web3._extend.utils.toDecimal(status.pending);
+				status.queued = web3._extend.utils.toDecimal(status.queued);
+				return status;
+			}
+		}),
+	],
 	properties:
 	[
 		new web3._extend.Property({
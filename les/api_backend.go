@@ -139,6 +139,11 @@ func (b *LesApiBackend) TxPoolContent() (map[common.Address]types.Transactions,
 	return b.gcl.txPool.Content()
 }
 
+func (b *LesApiBackend) TxPoolContentFrom(addr common.Address) (types.Transactions, []core.TxQueuedInfo) {
+	pending, _ := b.gcl.txPool.Content()
+	return pending[addr], nil
+}
+
 func (b *LesApiBackend) SubscribeNewTxsEvent(ch chan<- core.NewTxsEvent) event.Subscription {
 	return b.gcl.txPool.SubscribeNewTxsEvent(ch)
 }
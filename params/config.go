@@ -141,16 +141,16 @@ var (
 	//
 	// This configuration is intentionally not using keyed fields to force anyone
 	// adding flags to the config to also have to set these fields.
-	AllEthashProtocolChanges = &ChainConfig{big.NewInt(1337), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, new(EthashConfig), nil}
+	AllEthashProtocolChanges = &ChainConfig{big.NewInt(1337), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, nil, nil, new(EthashConfig), nil}
 
 	// AllCliqueProtocolChanges contains every protocol change (EIPs) introduced
 	// and accepted by the Gclchain core developers into the Clique consensus.
 	//
 	// This configuration is intentionally not using keyed fields to force anyone
 	// adding flags to the config to also have to set these fields.
-	AllCliqueProtocolChanges = &ChainConfig{big.NewInt(1337), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, nil, &CliqueConfig{Period: 0, Epoch: 30000}}
+	AllCliqueProtocolChanges = &ChainConfig{big.NewInt(1337), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, nil, nil, nil, &CliqueConfig{Period: 0, Epoch: 30000}}
 
-	TestChainConfig = &ChainConfig{big.NewInt(1), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, new(EthashConfig), nil}
+	TestChainConfig = &ChainConfig{big.NewInt(1), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, nil, nil, new(EthashConfig), nil}
 	TestRules       = TestChainConfig.Rules(new(big.Int))
 )
 
@@ -189,8 +189,15 @@ type ChainConfig struct {
 	ByzantiumBlock      *big.Int `json:"byzantiumBlock,omitempty"`      // Byzantium switch block (nil = no fork, 0 = already on byzantium)
 	ConstantinopleBlock *big.Int `json:"constantinopleBlock,omitempty"` // Constantinople switch block (nil = no fork, 0 = already activated)
 	PetersburgBlock     *big.Int `json:"petersburgBlock,omitempty"`     // Petersburg switch block (nil = same as Constantinople)
+	IstanbulBlock       *big.Int `json:"istanbulBlock,omitempty"`       // Istanbul switch block (nil = no fork, 0 = already on istanbul)
 	EWASMBlock          *big.Int `json:"ewasmBlock,omitempty"`          // EWASM switch block (nil = no fork, 0 = already activated)
 
+	// Optional native contracts enabled on top of the fork defined ones
+	Precompiles []*PrecompileConfig `json:"precompiles,omitempty"`
+
+	// Optional opcodes disabled for everyone but the allowlisted accounts
+	OpcodeRestrictions []*OpcodeRestriction `json:"opcodeRestrictions,omitempty"`
+
 	// Various consensus engines
 	Ethash *EthashConfig `json:"gclash,omitempty"`
 	Clique *CliqueConfig `json:"clique,omitempty"`
@@ -215,6 +222,36 @@ func (c *CliqueConfig) String() string {
 	return "clique"
 }
 
+// PrecompileConfig enables an optional native contract, not part of any fork,
+// at a custom address. The gas charged for a call is BaseGas plus WordGas for
+// every 32 byte word of input.
+type PrecompileConfig struct {
+	Name    string         `json:"name"`            // Name of the contract in the registry of optional precompiles
+	Address common.Address `json:"address"`         // Address to make the contract available at
+	Block   *big.Int       `json:"block,omitempty"` // Activation block (nil = disabled, 0 = active from genesis)
+	BaseGas uint64         `json:"baseGas"`         // Flat gas cost of a call
+	WordGas uint64         `json:"wordGas"`         // Gas cost of every 32 byte word of input
+}
+
+// IsActive returns whether the precompile is enabled at the given block.
+func (p *PrecompileConfig) IsActive(num *big.Int) bool {
+	return isForked(p.Block, num)
+}
+
+// OpcodeRestriction disables an opcode from a given block on. Transactions sent
+// by an allowlisted account and contracts at an allowlisted address may still
+// execute it.
+type OpcodeRestriction struct {
+	Opcode    string           `json:"opcode"`              // Name of the restricted opcode, e.g. SELFDESTRUCT
+	Block     *big.Int         `json:"block,omitempty"`     // Activation block (nil = disabled, 0 = active from genesis)
+	Allowlist []common.Address `json:"allowlist,omitempty"` // Transaction origins and contracts exempt from the restriction
+}
+
+// IsActive returns whether the restriction is enforced at the given block.
+func (r *OpcodeRestriction) IsActive(num *big.Int) bool {
+	return isForked(r.Block, num)
+}
+
 // String implements the fmt.Stringer interface.
 func (c *ChainConfig) String() string {
 	var engine interface{}
@@ -226,7 +263,7 @@ func (c *ChainConfig) String() string {
 	default:
 		engine = "unknown"
 	}
-	return fmt.Sprintf("{ChainID: %v Homestead: %v DAO: %v DAOSupport: %v EIP150: %v EIP155: %v EIP158: %v Byzantium: %v Constantinople: %v  ConstantinopleFix: %v Engine: %v}",
+	return fmt.Sprintf("{ChainID: %v Homestead: %v DAO: %v DAOSupport: %v EIP150: %v EIP155: %v EIP158: %v Byzantium: %v Constantinople: %v  ConstantinopleFix: %v Istanbul: %v Engine: %v}",
 		c.ChainID,
 		c.HomesteadBlock,
 		c.DAOForkBlock,
@@ -237,6 +274,7 @@ func (c *ChainConfig) String() string {
 		c.ByzantiumBlock,
 		c.ConstantinopleBlock,
 		c.PetersburgBlock,
+		c.IstanbulBlock,
 		engine,
 	)
 }
@@ -283,6 +321,11 @@ func (c *ChainConfig) IsPetersburg(num *big.Int) bool {
 	return isForked(c.PetersburgBlock, num) || c.PetersburgBlock == nil && isForked(c.ConstantinopleBlock, num)
 }
 
+// IsIstanbul returns whgclchain num is either equal to the Istanbul fork block or greater.
+func (c *ChainConfig) IsIstanbul(num *big.Int) bool {
+	return isForked(c.IstanbulBlock, num)
+}
+
 // IsEWASM returns whgclchain num represents a block number after the EWASM fork
 func (c *ChainConfig) IsEWASM(num *big.Int) bool {
 	return isForked(c.EWASMBlock, num)
@@ -296,6 +339,8 @@ func (c *ChainConfig) GasTable(num *big.Int) GasTable {
 		return GasTableHomestead
 	}
 	switch {
+	case c.IsIstanbul(num):
+		return GasTableIstanbul
 	case c.IsConstantinople(num):
 		return GasTableConstantinople
 	case c.IsEIP158(num):
@@ -356,12 +401,130 @@ func (c *ChainConfig) checkCompatible(newcfg *ChainConfig, head *big.Int) *Confi
 	if isForkIncompatible(c.PetersburgBlock, newcfg.PetersburgBlock, head) {
 		return newCompatError("ConstantinopleFix fork block", c.PetersburgBlock, newcfg.PetersburgBlock)
 	}
+	if isForkIncompatible(c.IstanbulBlock, newcfg.IstanbulBlock, head) {
+		return newCompatError("Istanbul fork block", c.IstanbulBlock, newcfg.IstanbulBlock)
+	}
 	if isForkIncompatible(c.EWASMBlock, newcfg.EWASMBlock, head) {
 		return newCompatError("ewasm fork block", c.EWASMBlock, newcfg.EWASMBlock)
 	}
+	if err := checkPrecompilesCompatible(c.Precompiles, newcfg.Precompiles, head); err != nil {
+		return err
+	}
+	return checkOpcodeRestrictionsCompatible(c.OpcodeRestrictions, newcfg.OpcodeRestrictions, head)
+}
+
+// checkPrecompilesCompatible checks that no optional precompile already active
+// at head is moved, removed or redefined, and that none is retroactively added.
+func checkPrecompilesCompatible(stored, newcfg []*PrecompileConfig, head *big.Int) *ConfigCompatError {
+	index := func(list []*PrecompileConfig) map[common.Address]*PrecompileConfig {
+		set := make(map[common.Address]*PrecompileConfig)
+		for _, p := range list {
+			set[p.Address] = p
+		}
+		return set
+	}
+	var (
+		oldset   = index(stored)
+		newset   = index(newcfg)
+		disabled = new(PrecompileConfig)
+	)
+	check := func(addr common.Address) *ConfigCompatError {
+		oldp, newp := oldset[addr], newset[addr]
+		if oldp == nil {
+			oldp = disabled
+		}
+		if newp == nil {
+			newp = disabled
+		}
+		what := fmt.Sprintf("precompile %x activation block", addr)
+		if isForkIncompatible(oldp.Block, newp.Block, head) {
+			return newCompatError(what, oldp.Block, newp.Block)
+		}
+		if oldp.IsActive(head) && (oldp.Name != newp.Name || oldp.BaseGas != newp.BaseGas || oldp.WordGas != newp.WordGas) {
+			return newCompatError(fmt.Sprintf("precompile %x definition", addr), oldp.Block, newp.Block)
+		}
+		return nil
+	}
+	for _, p := range stored {
+		if err := check(p.Address); err != nil {
+			return err
+		}
+	}
+	for _, p := range newcfg {
+		if err := check(p.Address); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
+// checkOpcodeRestrictionsCompatible checks that no opcode restriction already
+// enforced at head is lifted or has its allowlist changed, and that none is
+// retroactively added.
+func checkOpcodeRestrictionsCompatible(stored, newcfg []*OpcodeRestriction, head *big.Int) *ConfigCompatError {
+	index := func(list []*OpcodeRestriction) map[string]*OpcodeRestriction {
+		set := make(map[string]*OpcodeRestriction)
+		for _, r := range list {
+			set[r.Opcode] = r
+		}
+		return set
+	}
+	var (
+		oldset   = index(stored)
+		newset   = index(newcfg)
+		disabled = new(OpcodeRestriction)
+	)
+	check := func(opcode string) *ConfigCompatError {
+		oldr, newr := oldset[opcode], newset[opcode]
+		if oldr == nil {
+			oldr = disabled
+		}
+		if newr == nil {
+			newr = disabled
+		}
+		if isForkIncompatible(oldr.Block, newr.Block, head) {
+			return newCompatError(fmt.Sprintf("%s restriction block", opcode), oldr.Block, newr.Block)
+		}
+		if oldr.IsActive(head) && !addressSetEqual(oldr.Allowlist, newr.Allowlist) {
+			return newCompatError(fmt.Sprintf("%s restriction allowlist", opcode), oldr.Block, newr.Block)
+		}
+		return nil
+	}
+	for _, r := range stored {
+		if err := check(r.Opcode); err != nil {
+			return err
+		}
+	}
+	for _, r := range newcfg {
+		if err := check(r.Opcode); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addressSetEqual reports whether two address lists contain the same addresses,
+// disregarding their order and duplicates.
+func addressSetEqual(a, b []common.Address) bool {
+	index := func(list []common.Address) map[common.Address]bool {
+		set := make(map[common.Address]bool, len(list))
+		for _, addr := range list {
+			set[addr] = true
+		}
+		return set
+	}
+	aset, bset := index(a), index(b)
+	if len(aset) != len(bset) {
+		return false
+	}
+	for addr := range aset {
+		if !bset[addr] {
+			return false
+		}
+	}
+	return true
+}
+
 // isForkIncompatible returns true if a fork scheduled at s1 cannot be rescheduled to
 // block s2 because head is already past the fork.
 func isForkIncompatible(s1, s2, head *big.Int) bool {
@@ -423,9 +586,9 @@ func (err *ConfigCompatError) Error() string {
 // Rules is a one time interface meaning that it shouldn't be used in between transition
 // phases.
 type Rules struct {
-	ChainID                                     *big.Int
-	IsHomestead, IsEIP150, IsEIP155, IsEIP158   bool
-	IsByzantium, IsConstantinople, IsPetersburg bool
+	ChainID                                                 *big.Int
+	IsHomestead, IsEIP150, IsEIP155, IsEIP158               bool
+	IsByzantium, IsConstantinople, IsPetersburg, IsIstanbul bool
 }
 
 // Rules ensures c's ChainID is not nil.
@@ -443,5 +606,6 @@ func (c *ChainConfig) Rules(num *big.Int) Rules {
 		IsByzantium:      c.IsByzantium(num),
 		IsConstantinople: c.IsConstantinople(num),
 		IsPetersburg:     c.IsPetersburg(num),
+		IsIstanbul:       c.IsIstanbul(num),
 	}
 }
@@ -20,6 +20,8 @@ import (
 	"math/big"
 	"reflect"
 	"testing"
+
+	"github.com/gclchaineum/go-gclchaineum/common"
 )
 
 func TestCheckCompatible(t *testing.T) {
@@ -70,6 +72,73 @@ func TestCheckCompatible(t *testing.T) {
 				RewindTo:     9,
 			},
 		},
+		{
+			stored: &ChainConfig{PetersburgBlock: big.NewInt(10), IstanbulBlock: big.NewInt(20)},
+			new:    &ChainConfig{PetersburgBlock: big.NewInt(10), IstanbulBlock: big.NewInt(30)},
+			head:   25,
+			wantErr: &ConfigCompatError{
+				What:         "Istanbul fork block",
+				StoredConfig: big.NewInt(20),
+				NewConfig:    big.NewInt(30),
+				RewindTo:     19,
+			},
+		},
+		{
+			stored: &ChainConfig{Precompiles: []*PrecompileConfig{{Name: "sm3", Address: common.HexToAddress("0100"), Block: big.NewInt(10)}}},
+			new:    &ChainConfig{Precompiles: []*PrecompileConfig{{Name: "sm3", Address: common.HexToAddress("0100"), Block: big.NewInt(20)}}},
+			head:   15,
+			wantErr: &ConfigCompatError{
+				What:         "precompile 0000000000000000000000000000000000000100 activation block",
+				StoredConfig: big.NewInt(10),
+				NewConfig:    big.NewInt(20),
+				RewindTo:     9,
+			},
+		},
+		{
+			stored: &ChainConfig{Precompiles: []*PrecompileConfig{{Name: "sm3", Address: common.HexToAddress("0100"), Block: big.NewInt(10)}}},
+			new:    &ChainConfig{Precompiles: []*PrecompileConfig{{Name: "sm3", Address: common.HexToAddress("0100"), Block: big.NewInt(10), BaseGas: 1}}},
+			head:   15,
+			wantErr: &ConfigCompatError{
+				What:         "precompile 0000000000000000000000000000000000000100 definition",
+				StoredConfig: big.NewInt(10),
+				NewConfig:    big.NewInt(10),
+				RewindTo:     9,
+			},
+		},
+		{
+			stored:  &ChainConfig{},
+			new:     &ChainConfig{Precompiles: []*PrecompileConfig{{Name: "sm3", Address: common.HexToAddress("0100"), Block: big.NewInt(20)}}},
+			head:    15,
+			wantErr: nil,
+		},
+		{
+			stored: &ChainConfig{},
+			new:    &ChainConfig{OpcodeRestrictions: []*OpcodeRestriction{{Opcode: "CREATE2", Block: big.NewInt(10)}}},
+			head:   15,
+			wantErr: &ConfigCompatError{
+				What:         "CREATE2 restriction block",
+				StoredConfig: nil,
+				NewConfig:    big.NewInt(10),
+				RewindTo:     9,
+			},
+		},
+		{
+			stored: &ChainConfig{OpcodeRestrictions: []*OpcodeRestriction{{Opcode: "CREATE2", Block: big.NewInt(10)}}},
+			new:    &ChainConfig{OpcodeRestrictions: []*OpcodeRestriction{{Opcode: "CREATE2", Block: big.NewInt(10), Allowlist: []common.Address{{1}}}}},
+			head:   15,
+			wantErr: &ConfigCompatError{
+				What:         "CREATE2 restriction allowlist",
+				StoredConfig: big.NewInt(10),
+				NewConfig:    big.NewInt(10),
+				RewindTo:     9,
+			},
+		},
+		{
+			stored:  &ChainConfig{OpcodeRestrictions: []*OpcodeRestriction{{Opcode: "CREATE2", Block: big.NewInt(10), Allowlist: []common.Address{{1}, {2}}}}},
+			new:     &ChainConfig{OpcodeRestrictions: []*OpcodeRestriction{{Opcode: "CREATE2", Block: big.NewInt(10), Allowlist: []common.Address{{2}, {1}}}}},
+			head:    15,
+			wantErr: nil,
+		},
 	}
 
 	for _, test := range tests {
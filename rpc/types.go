@@ -17,6 +17,7 @@
 package rpc
 
 import (
+	"encoding/json"
 	"fmt"
 	"math"
 	"reflect"
@@ -24,6 +25,7 @@ import (
 	"sync"
 
 	mapset "github.com/deckarep/golang-set"
+	"github.com/gclchaineum/go-gclchaineum/common"
 	"github.com/gclchaineum/go-gclchaineum/common/hexutil"
 )
 
@@ -163,3 +165,74 @@ func (bn *BlockNumber) UnmarshalJSON(data []byte) error {
 func (bn BlockNumber) Int64() int64 {
 	return (int64)(bn)
 }
+
+// BlockNumberOrHash identifies a block either by its number (or one of the
+// special tags) or by its hash.
+type BlockNumberOrHash struct {
+	BlockNumber *BlockNumber `json:"blockNumber,omitempty"`
+	BlockHash   *common.Hash `json:"blockHash,omitempty"`
+}
+
+// UnmarshalJSON parses the given JSON fragment into a BlockNumberOrHash. It
+// supports:
+// - an object with exactly one of the "blockNumber" or "blockHash" fields
+// - a 32 byte hex encoded block hash
+// - anything accepted by BlockNumber
+func (bnh *BlockNumberOrHash) UnmarshalJSON(data []byte) error {
+	input := strings.TrimSpace(string(data))
+	if len(input) > 0 && input[0] == '{' {
+		var obj struct {
+			BlockNumber *BlockNumber `json:"blockNumber"`
+			BlockHash   *common.Hash `json:"blockHash"`
+		}
+		if err := json.Unmarshal(data, &obj); err != nil {
+			return err
+		}
+		if (obj.BlockNumber == nil) == (obj.BlockHash == nil) {
+			return fmt.Errorf("exactly one of blockNumber or blockHash must be specified")
+		}
+		bnh.BlockNumber, bnh.BlockHash = obj.BlockNumber, obj.BlockHash
+		return nil
+	}
+	if len(input) == 2+2*common.HashLength+2 {
+		var hash common.Hash
+		if err := json.Unmarshal(data, &hash); err != nil {
+			return err
+		}
+		bnh.BlockNumber, bnh.BlockHash = nil, &hash
+		return nil
+	}
+	var number BlockNumber
+	if err := number.UnmarshalJSON(data); err != nil {
+		return err
+	}
+	bnh.BlockNumber, bnh.BlockHash = &number, nil
+	return nil
+}
+
+// Number returns the block number, if the block was identified by number.
+func (bnh BlockNumberOrHash) Number() (BlockNumber, bool) {
+	if bnh.BlockNumber != nil {
+		return *bnh.BlockNumber, true
+	}
+	return BlockNumber(0), false
+}
+
+// Hash returns the block hash, if the block was identified by hash.
+func (bnh BlockNumberOrHash) Hash() (common.Hash, bool) {
+	if bnh.BlockHash != nil {
+		return *bnh.BlockHash, true
+	}
+	return common.Hash{}, false
+}
+
+// String implements fmt.Stringer.
+func (bnh BlockNumberOrHash) String() string {
+	if bnh.BlockHash != nil {
+		return bnh.BlockHash.Hex()
+	}
+	if bnh.BlockNumber != nil {
+		return fmt.Sprintf("#%d", *bnh.BlockNumber)
+	}
+	return "nil"
+}
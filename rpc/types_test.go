@@ -20,6 +20,7 @@ import (
 	"encoding/json"
 	"testing"
 
+	"github.com/gclchaineum/go-gclchaineum/common"
 	"github.com/gclchaineum/go-gclchaineum/common/math"
 )
 
@@ -64,3 +65,46 @@ func TestBlockNumberJSONUnmarshal(t *testing.T) {
 		}
 	}
 }
+
+func TestBlockNumberOrHashJSONUnmarshal(t *testing.T) {
+	hash := common.HexToHash("0xc6ef2fc5426d6ad6fd9e2a26abeab0aa2411b7ab17f30a99d3cb96aed1d1055b")
+	tests := []struct {
+		input    string
+		mustFail bool
+		number   *BlockNumber
+		hash     *common.Hash
+	}{
+		0: {`"0x12"`, false, blockNumberPtr(18), nil},
+		1: {`"latest"`, false, blockNumberPtr(LatestBlockNumber), nil},
+		2: {`"` + hash.Hex() + `"`, false, nil, &hash},
+		3: {`{"blockNumber":"pending"}`, false, blockNumberPtr(PendingBlockNumber), nil},
+		4: {`{"blockHash":"` + hash.Hex() + `"}`, false, nil, &hash},
+		5: {`{"blockNumber":"0x1","blockHash":"` + hash.Hex() + `"}`, true, nil, nil},
+		6: {`{}`, true, nil, nil},
+		7: {`"0xzz"`, true, nil, nil},
+	}
+	for i, test := range tests {
+		var bnh BlockNumberOrHash
+		err := json.Unmarshal([]byte(test.input), &bnh)
+		if test.mustFail {
+			if err == nil {
+				t.Errorf("Test %d should fail", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Test %d should pass but got err: %v", i, err)
+			continue
+		}
+		if number, ok := bnh.Number(); ok != (test.number != nil) || (ok && number != *test.number) {
+			t.Errorf("Test %d got unexpected number, want %v, got %v", i, test.number, bnh.BlockNumber)
+		}
+		if hash, ok := bnh.Hash(); ok != (test.hash != nil) || (ok && hash != *test.hash) {
+			t.Errorf("Test %d got unexpected hash, want %v, got %v", i, test.hash, bnh.BlockHash)
+		}
+	}
+}
+
+func blockNumberPtr(n BlockNumber) *BlockNumber {
+	return &n
+}
@@ -94,23 +94,38 @@ type btHeaderMarshaling struct {
 	Timestamp  *math.HexOrDecimal256
 }
 
+// Run executes the test against the fork ruleset of its network.
 func (t *BlockTest) Run() error {
-	config, ok := Forks[t.json.Network]
-	if !ok {
-		return UnsupportedForkError{t.json.Network}
-	}
+	_, err := t.RunWith(nil, vm.Config{})
+	return err
+}
+
+// Network returns the name of the fork ruleset the test is defined for.
+func (t *BlockTest) Network() string {
+	return t.json.Network
+}
 
+// RunWith executes the test with the given EVM configuration, using the chain
+// config instead of the test's own fork ruleset if not nil. The final state of
+// the chain is returned if the blocks could be imported, even if the test failed.
+func (t *BlockTest) RunWith(config *params.ChainConfig, vmconfig vm.Config) (*state.StateDB, error) {
+	if config == nil {
+		var ok bool
+		if config, ok = Forks[t.json.Network]; !ok {
+			return nil, UnsupportedForkError{t.json.Network}
+		}
+	}
 	// import pre accounts & construct test genesis block & state root
 	db := gcldb.NewMemDatabase()
 	gblock, err := t.genesis(config).Commit(db)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	if gblock.Hash() != t.json.Genesis.Hash {
-		return fmt.Errorf("genesis block hash doesn't match test: computed=%x, test=%x", gblock.Hash().Bytes()[:6], t.json.Genesis.Hash[:6])
+		return nil, fmt.Errorf("genesis block hash doesn't match test: computed=%x, test=%x", gblock.Hash().Bytes()[:6], t.json.Genesis.Hash[:6])
 	}
 	if gblock.Root() != t.json.Genesis.StateRoot {
-		return fmt.Errorf("genesis block state root does not match test: computed=%x, test=%x", gblock.Root().Bytes()[:6], t.json.Genesis.StateRoot[:6])
+		return nil, fmt.Errorf("genesis block state root does not match test: computed=%x, test=%x", gblock.Root().Bytes()[:6], t.json.Genesis.StateRoot[:6])
 	}
 	var engine consensus.Engine
 	if t.json.SealEngine == "NoProof" {
@@ -118,28 +133,28 @@ func (t *BlockTest) Run() error {
 	} else {
 		engine = gclash.NewShared()
 	}
-	chain, err := core.NewBlockChain(db, &core.CacheConfig{TrieCleanLimit: 0}, config, engine, vm.Config{}, nil)
+	chain, err := core.NewBlockChain(db, &core.CacheConfig{TrieCleanLimit: 0}, config, engine, vmconfig, nil)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer chain.Stop()
 
 	validBlocks, err := t.insertBlocks(chain)
 	if err != nil {
-		return err
-	}
-	cmlast := chain.CurrentBlock().Hash()
-	if common.Hash(t.json.BestBlock) != cmlast {
-		return fmt.Errorf("last block hash validation mismatch: want: %x, have: %x", t.json.BestBlock, cmlast)
+		return nil, err
 	}
 	newDB, err := chain.State()
 	if err != nil {
-		return err
+		return nil, err
+	}
+	cmlast := chain.CurrentBlock().Hash()
+	if common.Hash(t.json.BestBlock) != cmlast {
+		return newDB, fmt.Errorf("last block hash validation mismatch: want: %x, have: %x", t.json.BestBlock, cmlast)
 	}
 	if err = t.validatePostState(newDB); err != nil {
-		return fmt.Errorf("post state validation failed: %v", err)
+		return newDB, fmt.Errorf("post state validation failed: %v", err)
 	}
-	return t.validateImportedHeaders(chain, validBlocks)
+	return newDB, t.validateImportedHeaders(chain, validBlocks)
 }
 
 func (t *BlockTest) genesis(config *params.ChainConfig) *core.Genesis {
@@ -0,0 +1,66 @@
+// Copyright 2019 The go-gclchaineum Authors
+// This file is part of the go-gclchaineum library.
+//
+// The go-gclchaineum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-gclchaineum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-gclchaineum library. If not, see <http://www.gnu.org/licenses/>.
+
+package trie
+
+import (
+	"sync"
+
+	"github.com/gclchaineum/go-gclchaineum/common"
+)
+
+// Recorder collects the encoded trie nodes loaded from the database while the
+// tries it is attached to are being accessed. The recorded nodes are enough to
+// replay the same accesses (reads as well as modifications) against a database
+// holding nothing else, which is what stateless block verification relies on.
+//
+// A Recorder may be shared between multiple tries and is safe for concurrent use.
+type Recorder struct {
+	nodes map[common.Hash][]byte
+	lock  sync.Mutex
+}
+
+// NewRecorder creates an empty trie node recorder.
+func NewRecorder() *Recorder {
+	return &Recorder{
+		nodes: make(map[common.Hash][]byte),
+	}
+}
+
+// record stores the encoded form of a node resolved from the database.
+func (r *Recorder) record(hash common.Hash, db *Database) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if _, ok := r.nodes[hash]; ok {
+		return
+	}
+	if blob, err := db.Node(hash); err == nil && len(blob) > 0 {
+		r.nodes[hash] = common.CopyBytes(blob)
+	}
+}
+
+// Nodes returns all the recorded trie nodes, keyed by their hash.
+func (r *Recorder) Nodes() map[common.Hash][]byte {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	nodes := make(map[common.Hash][]byte, len(r.nodes))
+	for hash, blob := range r.nodes {
+		nodes[hash] = blob
+	}
+	return nodes
+}
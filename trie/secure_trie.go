@@ -52,10 +52,16 @@ type SecureTrie struct {
 // A new cache generation is created by each call to Commit.
 // cachelimit sets the number of past cache generations to keep.
 func NewSecure(root common.Hash, db *Database, cachelimit uint16) (*SecureTrie, error) {
+	return NewSecureWithRecorder(root, db, cachelimit, nil)
+}
+
+// NewSecureWithRecorder creates a secure trie like NewSecure, recording every
+// node loaded from the database into recorder.
+func NewSecureWithRecorder(root common.Hash, db *Database, cachelimit uint16, recorder *Recorder) (*SecureTrie, error) {
 	if db == nil {
 		panic("trie.NewSecure called without a database")
 	}
-	trie, err := New(root, db)
+	trie, err := NewWithRecorder(root, db, recorder)
 	if err != nil {
 		return nil, err
 	}
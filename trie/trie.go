@@ -73,6 +73,9 @@ type Trie struct {
 	// new nodes are tagged with the current generation and unloaded
 	// when their generation is older than than cachegen-cachelimit.
 	cachegen, cachelimit uint16
+
+	// recorder, if set, collects every node resolved from the database.
+	recorder *Recorder
 }
 
 // SetCacheLimit sets the number of 'cache generations' to keep.
@@ -93,11 +96,18 @@ func (t *Trie) newFlag() nodeFlag {
 // New will panic if db is nil and returns a MissingNodeError if root does
 // not exist in the database. Accessing the trie loads nodes from db on demand.
 func New(root common.Hash, db *Database) (*Trie, error) {
+	return NewWithRecorder(root, db, nil)
+}
+
+// NewWithRecorder creates a trie with an existing root node from db, recording
+// every node loaded from the database (including the root) into recorder.
+func NewWithRecorder(root common.Hash, db *Database, recorder *Recorder) (*Trie, error) {
 	if db == nil {
 		panic("trie.New called without a database")
 	}
 	trie := &Trie{
-		db: db,
+		db:       db,
+		recorder: recorder,
 	}
 	if root != (common.Hash{}) && root != emptyRoot {
 		rootnode, err := trie.resolveHash(root[:], nil)
@@ -432,6 +442,9 @@ func (t *Trie) resolveHash(n hashNode, prefix []byte) (node, error) {
 
 	hash := common.BytesToHash(n)
 	if node := t.db.node(hash, t.cachegen); node != nil {
+		if t.recorder != nil {
+			t.recorder.record(hash, t.db)
+		}
 		return node, nil
 	}
 	return nil, &MissingNodeError{NodeHash: hash, Path: prefix}